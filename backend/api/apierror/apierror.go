@@ -0,0 +1,198 @@
+// Package apierror is the JSON error model of the API. Every error response
+// is an Error with a machine-readable code; Write classifies Docker, SQL and
+// service errors so that internal messages are logged rather than returned.
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Codes clients can branch on
+const (
+	CodeInvalidArgument  = "invalid_argument"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "payload_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal"
+	CodeNotImplemented   = "not_implemented"
+	CodeUnavailable      = "unavailable"
+	CodeTimeout          = "timeout"
+)
+
+// RequestIDHeader carries the ID of a request, which error bodies repeat
+const RequestIDHeader = "X-Request-Id"
+
+const internalMessage = "Internal server error"
+
+// Error is the body of every error response
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details is optional structured context, such as per-item failures
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+
+	cause error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// New creates an error with a status, code and client-facing message
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func InvalidArgument(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidArgument, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+// WithDetails returns a copy of e carrying details
+func (e *Error) WithDetails(details interface{}) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// From classifies err. Errors it does not recognise are internal: their
+// message is replaced and the original is only logged.
+func From(err error) *Error {
+	if e := classify(err); e != nil {
+		return e
+	}
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: internalMessage, cause: err}
+}
+
+// Wrap is From with message in place of the generic text for internal
+// errors, e.g. "Failed to list tasks"
+func Wrap(err error, message string) *Error {
+	e := From(err)
+	if e.Message == internalMessage {
+		c := *e
+		c.Message = message
+		return &c
+	}
+	return e
+}
+
+// Invalid is From for errors the caller's input caused, such as a service's
+// validation errors: unrecognised errors keep their message and become
+// invalid_argument. Database errors are still recognised and hidden.
+func Invalid(err error) *Error {
+	if e := classify(err); e != nil {
+		return e
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidArgument, Message: err.Error(), cause: err}
+}
+
+func classify(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: "Not found", cause: err}
+	case errors.As(err, &maxBytesErr):
+		return &Error{Status: http.StatusRequestEntityTooLarge, Code: CodeTooLarge, Message: "Request body is too large", cause: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Status: http.StatusGatewayTimeout, Code: CodeTimeout, Message: "Operation timed out", cause: err}
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return fromSQLite(sqliteErr, err)
+	}
+
+	// Docker's messages are written for users, as the docker CLI shows them
+	status, code := 0, ""
+	switch {
+	case errdefs.IsNotFound(err):
+		status, code = http.StatusNotFound, CodeNotFound
+	case errdefs.IsConflict(err):
+		status, code = http.StatusConflict, CodeConflict
+	case errdefs.IsInvalidParameter(err):
+		status, code = http.StatusBadRequest, CodeInvalidArgument
+	case errdefs.IsUnauthorized(err):
+		status, code = http.StatusUnauthorized, CodeUnauthorized
+	case errdefs.IsForbidden(err):
+		status, code = http.StatusForbidden, CodeForbidden
+	case errdefs.IsNotImplemented(err):
+		status, code = http.StatusNotImplemented, CodeNotImplemented
+	case errdefs.IsUnavailable(err):
+		status, code = http.StatusServiceUnavailable, CodeUnavailable
+	case errdefs.IsDeadline(err):
+		status, code = http.StatusGatewayTimeout, CodeTimeout
+	case errdefs.IsSystem(err), errdefs.IsUnknown(err), errdefs.IsDataLoss(err):
+		status, code = http.StatusInternalServerError, CodeInternal
+	default:
+		return nil
+	}
+	message := strings.Replace(err.Error(), "Error response from daemon: ", "", 1)
+	return &Error{Status: status, Code: code, Message: message, cause: err}
+}
+
+func fromSQLite(sqliteErr sqlite3.Error, err error) *Error {
+	switch {
+	case sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique, sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey:
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: "Already exists", cause: err}
+	case sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey:
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: "Still referenced by other records", cause: err}
+	case sqliteErr.Code == sqlite3.ErrConstraint:
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidArgument, Message: "Invalid value", cause: err}
+	case sqliteErr.Code == sqlite3.ErrBusy, sqliteErr.Code == sqlite3.ErrLocked:
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: "Database is busy, try again", cause: err}
+	}
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: internalMessage, cause: err}
+}
+
+// Write responds with err as an Error, classified by From
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e := *From(err)
+	e.RequestID = w.Header().Get(RequestIDHeader)
+	if e.Status >= 500 && e.cause != nil {
+		log.Printf("%s %s [%s]: %v", r.Method, r.URL.Path, e.RequestID, e.cause)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
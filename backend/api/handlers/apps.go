@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
+	"sunspear/api/apierror"
 	"sunspear/services"
 
 	"github.com/docker/docker/api/types/container"
@@ -17,12 +21,14 @@ import (
 type AppHandler struct {
 	marketplaceService *services.MarketplaceService
 	dockerService      *services.DockerService
+	proxyService       *services.ProxyService
 }
 
-func NewAppHandler(marketplaceService *services.MarketplaceService, dockerService *services.DockerService) *AppHandler {
+func NewAppHandler(marketplaceService *services.MarketplaceService, dockerService *services.DockerService, proxyService *services.ProxyService) *AppHandler {
 	return &AppHandler{
 		marketplaceService: marketplaceService,
 		dockerService:      dockerService,
+		proxyService:       proxyService,
 	}
 }
 
@@ -37,7 +43,7 @@ func (h *AppHandler) GetApp(w http.ResponseWriter, r *http.Request) {
 
 	app := h.marketplaceService.GetApp(appID)
 	if app == nil {
-		http.Error(w, "App not found", http.StatusNotFound)
+		apierror.Write(w, r, apierror.NotFound("App not found"))
 		return
 	}
 
@@ -50,25 +56,48 @@ func (h *AppHandler) InstallApp(w http.ResponseWriter, r *http.Request) {
 
 	app := h.marketplaceService.GetApp(appID)
 	if app == nil {
-		http.Error(w, "App not found", http.StatusNotFound)
+		apierror.Write(w, r, apierror.NotFound("App not found"))
 		return
 	}
 
-	// Parse request body matching frontend payload format
-	var installReq struct {
-		Name    string            `json:"name"`
-		Env     []struct {
-			Name  string `json:"name"`
-			Value string `json:"value"`
-		} `json:"env"`
-		Ports   map[string]string `json:"ports"`
-		Volumes map[string]string `json:"volumes"`
-	}
+	var installReq InstallAppRequest
 	if err := json.NewDecoder(r.Body).Decode(&installReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
 		return
 	}
 
+	labels := map[string]string{services.AppLabel: appID}
+	if p := installReq.Proxy; p != nil && (p.Host != "" || p.Path != "") {
+		if p.Host != "" {
+			if err := services.ValidateProxyHost(p.Host); err != nil {
+				apierror.Write(w, r, apierror.Invalid(err))
+				return
+			}
+			labels[services.ProxyHostLabel] = p.Host
+		}
+		if p.Path != "" {
+			path, err := services.NormalizeProxyPath(p.Path)
+			if err != nil {
+				apierror.Write(w, r, apierror.Invalid(err))
+				return
+			}
+			labels[services.ProxyPathLabel] = path
+		}
+		port := p.Port
+		if port == 0 && len(app.Ports) == 1 {
+			for _, containerPort := range app.Ports {
+				port = containerPort
+			}
+		}
+		if port < 0 || port > 65535 {
+			apierror.Write(w, r, apierror.InvalidArgument("Invalid proxy port"))
+			return
+		}
+		if port > 0 {
+			labels[services.ProxyPortLabel] = strconv.Itoa(port)
+		}
+	}
+
 	// Validate required env vars
 	envMap := make(map[string]string)
 	for _, e := range installReq.Env {
@@ -76,7 +105,7 @@ func (h *AppHandler) InstallApp(w http.ResponseWriter, r *http.Request) {
 	}
 	for _, required := range app.EnvVars.Required {
 		if val, ok := envMap[required.Name]; !ok || val == "" {
-			http.Error(w, fmt.Sprintf("Missing required environment variable: %s", required.Name), http.StatusBadRequest)
+			apierror.Write(w, r, apierror.InvalidArgument(fmt.Sprintf("Missing required environment variable: %s", required.Name)))
 			return
 		}
 	}
@@ -87,7 +116,7 @@ func (h *AppHandler) InstallApp(w http.ResponseWriter, r *http.Request) {
 	// Pull the image
 	pullReader, err := h.dockerService.PullImage(r.Context(), imageName)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to pull image: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to pull image"))
 		return
 	}
 	io.Copy(io.Discard, pullReader)
@@ -139,6 +168,7 @@ func (h *AppHandler) InstallApp(w http.ResponseWriter, r *http.Request) {
 		Image:        imageName,
 		Env:          env,
 		ExposedPorts: exposedPorts,
+		Labels:       labels,
 	}
 
 	restartPolicy := container.RestartPolicy{}
@@ -151,14 +181,14 @@ func (h *AppHandler) InstallApp(w http.ResponseWriter, r *http.Request) {
 
 	createResp, err := h.dockerService.CreateContainer(r.Context(), containerConfig, hostConfig, containerName)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create container: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to create container"))
 		return
 	}
 
 	// Start container
 	if err := h.dockerService.StartContainer(r.Context(), createResp.ID); err != nil {
 		h.dockerService.RemoveContainer(r.Context(), createResp.ID, true)
-		http.Error(w, fmt.Sprintf("Failed to start container: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to start container"))
 		return
 	}
 
@@ -167,64 +197,132 @@ func (h *AppHandler) InstallApp(w http.ResponseWriter, r *http.Request) {
 	configMap["containerName"] = containerName
 	installedApp, err := h.marketplaceService.InstallApp(appID, []string{createResp.ID}, configMap)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Container started but failed to track in database: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(err, "Container started but failed to track in database"))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"id":          installedApp.ID,
-		"appId":       installedApp.AppID,
-		"appName":     installedApp.AppName,
-		"containerId": createResp.ID,
-		"status":      "running",
+	respondJSON(w, http.StatusOK, InstallAppResponse{
+		ID:          installedApp.ID,
+		AppID:       installedApp.AppID,
+		AppName:     installedApp.AppName,
+		ContainerID: createResp.ID,
+		Status:      "running",
 	})
 }
 
+var installedAppSortKeys = map[string]func(a, b services.InstalledApp) bool{
+	"name":      func(a, b services.InstalledApp) bool { return a.AppName < b.AppName },
+	"installed": func(a, b services.InstalledApp) bool { return a.InstalledAt < b.InstalledAt },
+	"status":    func(a, b services.InstalledApp) bool { return a.Status < b.Status },
+}
+
+// ListInstalledApps filters by name (a case-insensitive substring of the
+// app's name or ID) and status
 func (h *AppHandler) ListInstalledApps(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, "name", "installed", "status")
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
 	apps, err := h.marketplaceService.GetInstalledApps()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get installed apps: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to get installed apps"))
 		return
 	}
-	respondJSON(w, http.StatusOK, apps)
+
+	name := strings.ToLower(r.URL.Query().Get("name"))
+	status := r.URL.Query().Get("status")
+	matching := make([]services.InstalledApp, 0, len(apps))
+	for _, app := range apps {
+		nameMatches := strings.Contains(strings.ToLower(app.AppName), name) || strings.Contains(strings.ToLower(app.AppID), name)
+		if nameMatches && (status == "" || app.Status == status) {
+			matching = append(matching, app)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, paginate(w, matching, opts, installedAppSortKeys))
 }
 
 func (h *AppHandler) GetInstalledApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid app ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid app ID"))
 		return
 	}
 
 	app, err := h.marketplaceService.GetInstalledApp(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("App not found"))
+		return
+	}
 	if err != nil {
-		http.Error(w, "App not found", http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	respondJSON(w, http.StatusOK, app)
 }
 
+// GetInstalledAppRoutes returns the proxy routes of an installed app's containers
+func (h *AppHandler) GetInstalledAppRoutes(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid app ID"))
+		return
+	}
+
+	app, err := h.marketplaceService.GetInstalledApp(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("App not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	var containerIDs []string
+	json.Unmarshal([]byte(app.ContainerIDs), &containerIDs)
+	owned := make(map[string]bool, len(containerIDs))
+	for _, containerID := range containerIDs {
+		owned[containerID] = true
+	}
+
+	routes := []services.ProxyRoute{}
+	for _, route := range h.proxyService.Status().Routes {
+		if owned[route.ContainerID] {
+			routes = append(routes, route)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, routes)
+}
+
 func (h *AppHandler) UninstallApp(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid app ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid app ID"))
 		return
 	}
 
 	// Get installed app info
 	installedApp, err := h.marketplaceService.GetInstalledApp(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("App not found"))
+		return
+	}
 	if err != nil {
-		http.Error(w, "App not found", http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	// Parse container IDs
 	var containerIDs []string
 	if err := json.Unmarshal([]byte(installedApp.ContainerIDs), &containerIDs); err != nil {
-		http.Error(w, "Failed to parse container IDs", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "Failed to parse container IDs"))
 		return
 	}
 
@@ -241,12 +339,15 @@ func (h *AppHandler) UninstallApp(w http.ResponseWriter, r *http.Request) {
 
 	// Delete from database
 	if err := h.marketplaceService.UninstallApp(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to uninstall app: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to uninstall app"))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"status": "App uninstalled successfully",
+	// Drop the app's proxy routes without waiting for the next pass
+	h.proxyService.Notify()
+
+	respondJSON(w, http.StatusOK, StatusResponse{
+		Status: "App uninstalled successfully",
 	})
 }
 
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sunspear/api/apierror"
+	"sunspear/services"
+	"time"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListAudit returns audit entries filtered by user, action and time range
+func (h *AuditHandler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
+	entries, err := h.auditService.Query(filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// ExportAudit downloads audit entries as CSV or JSON
+func (h *AuditHandler) ExportAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		filter.Limit = 10000
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		apierror.Write(w, r, apierror.InvalidArgument("format must be csv or json"))
+		return
+	}
+
+	entries, err := h.auditService.Query(filter)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("sunspear-audit-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "timestamp", "user_id", "username", "action", "resource", "params", "result", "status", "error", "client_ip"})
+	for _, e := range entries {
+		cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.Timestamp,
+			strconv.Itoa(e.UserID),
+			e.Username,
+			e.Action,
+			e.Resource,
+			e.Params,
+			e.Result,
+			strconv.Itoa(e.Status),
+			e.Error,
+			e.ClientIP,
+		})
+	}
+	cw.Flush()
+}
+
+func parseAuditFilter(r *http.Request) (services.AuditFilter, error) {
+	q := r.URL.Query()
+	filter := services.AuditFilter{
+		Action: q.Get("action"),
+	}
+
+	// "user" accepts either a numeric user ID or a username
+	if user := q.Get("user"); user != "" {
+		if id, err := strconv.Atoi(user); err == nil {
+			filter.UserID = id
+		} else {
+			filter.Username = user
+		}
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(q.Get("from")); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseEndTimeParam(q.Get("to")); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+
+	if limit := q.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return filter, fmt.Errorf("invalid limit")
+		}
+	}
+	if offset := q.Get("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil || filter.Offset < 0 {
+			return filter, fmt.Errorf("invalid offset")
+		}
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam accepts RFC 3339 timestamps, plain dates or Unix seconds
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("expected RFC 3339 time, YYYY-MM-DD or Unix seconds")
+}
+
+// parseEndTimeParam is parseTimeParam for inclusive upper bounds: a plain
+// date covers that whole day, ending just before the next midnight
+func parseEndTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return parseTimeParam(value)
+}
@@ -4,9 +4,14 @@ import (
 	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"sunspear/api/apierror"
 	"sunspear/api/middleware"
 	"sunspear/config"
+	"sunspear/services"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,22 +19,32 @@ import (
 )
 
 type AuthHandler struct {
-	cfg *config.Config
-	db  *sql.DB
+	cfg          *config.Config
+	db           *sql.DB
+	auditService *services.AuditService
 }
 
-func NewAuthHandler(cfg *config.Config, db *sql.DB) *AuthHandler {
-	return &AuthHandler{cfg: cfg, db: db}
+func NewAuthHandler(cfg *config.Config, db *sql.DB, auditService *services.AuditService) *AuthHandler {
+	return &AuthHandler{cfg: cfg, db: db, auditService: auditService}
 }
 
+// Login verifies credentials and issues a JWT. With "mode": "cookie" the token
+// is set as an HttpOnly session cookie alongside a CSRF cookie instead of being
+// returned in the body; the default bearer mode is kept for API clients.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
+	var req LoginRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
+	// Reject locked usernames before touching the password
+	if lockedUntil, locked := h.lockedUntil(req.Username); locked {
+		h.recordLogin(r, 0, req.Username, http.StatusTooManyRequests, "account locked")
+		retryAfter := int(time.Until(lockedUntil).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many failed login attempts. Please try again later."))
 		return
 	}
 
@@ -38,33 +53,113 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var passwordHash string
 	err := h.db.QueryRow("SELECT id, password_hash FROM users WHERE username = ?", req.Username).Scan(&userID, &passwordHash)
 	if err == sql.ErrNoRows {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		// Unknown usernames are only rate limited per IP; counting them
+		// would let anyone grow login_failures without bound
+		h.recordLogin(r, 0, req.Username, http.StatusUnauthorized, "unknown user")
+		apierror.Write(w, r, apierror.Unauthorized("Invalid credentials"))
 		return
 	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)); err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		h.recordFailedLogin(req.Username)
+		h.recordLogin(r, userID, req.Username, http.StatusUnauthorized, "invalid password")
+		apierror.Write(w, r, apierror.Unauthorized("Invalid credentials"))
 		return
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	h.clearFailedLogins(req.Username)
+
+	expiresAt := time.Now().Add(h.cfg.Live().SessionLifetime)
+	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
-	})
+		"exp":     expiresAt.Unix(),
+	}
 
-	tokenString, err := token.SignedString([]byte(h.cfg.JWTSecret))
+	if req.Mode == "cookie" {
+		csrfToken, err := middleware.NewCSRFToken()
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		// Bind the CSRF token to the session so a planted CSRF cookie is useless
+		claims["csrf"] = csrfToken
+
+		tokenString, err := h.signToken(claims)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		h.setSessionCookies(w, tokenString, csrfToken, expiresAt)
+		h.recordLogin(r, userID, req.Username, http.StatusOK, "")
+
+		respondJSON(w, http.StatusOK, LoginResponse{
+			Mode:      "cookie",
+			CSRFToken: csrfToken,
+		})
+		return
+	}
+
+	// Generate JWT token
+	tokenString, err := h.signToken(claims)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"token": tokenString,
+	h.recordLogin(r, userID, req.Username, http.StatusOK, "")
+
+	respondJSON(w, http.StatusOK, LoginResponse{
+		Token: tokenString,
+	})
+}
+
+// Logout clears the session cookies. Bearer tokens are stateless, so API
+// clients simply discard their token.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	h.setSessionCookies(w, "", "", time.Unix(0, 0))
+
+	respondJSON(w, http.StatusOK, StatusResponse{
+		Status: "Logged out",
+	})
+}
+
+func (h *AuthHandler) signToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(h.cfg.JWTSecret))
+}
+
+// setSessionCookies writes (or, with an expiry in the past, clears) the
+// session and CSRF cookies.
+func (h *AuthHandler) setSessionCookies(w http.ResponseWriter, token, csrfToken string, expiresAt time.Time) {
+	maxAge := int(time.Until(expiresAt).Seconds())
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookieName,
+		Value:    token,
+		Path:     "/api",
+		Expires:  expiresAt,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.cfg.SessionCookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.CSRFCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		Expires:  expiresAt,
+		MaxAge:   maxAge,
+		HttpOnly: false,
+		Secure:   h.cfg.SessionCookieSecure,
+		SameSite: http.SameSiteStrictMode,
 	})
 }
 
@@ -72,85 +167,92 @@ func (h *AuthHandler) Setup(w http.ResponseWriter, r *http.Request) {
 	// Check if setup is already completed
 	var count int
 	if err := h.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	if count > 0 {
-		http.Error(w, "Setup already completed", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Conflict("Setup already completed"))
 		return
 	}
 
 	if h.cfg.SetupBootstrapToken == "" {
-		http.Error(w, "Setup is disabled until SETUP_BOOTSTRAP_TOKEN is configured", http.StatusServiceUnavailable)
+		apierror.Write(w, r, apierror.New(http.StatusServiceUnavailable, apierror.CodeUnavailable, "Setup is disabled until SETUP_BOOTSTRAP_TOKEN is configured"))
 		return
 	}
 
 	setupToken := r.Header.Get("X-Setup-Token")
 	if setupToken == "" {
-		http.Error(w, "Missing setup bootstrap token", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("Missing setup bootstrap token"))
 		return
 	}
 
 	if subtle.ConstantTimeCompare([]byte(setupToken), []byte(h.cfg.SetupBootstrapToken)) != 1 {
-		http.Error(w, "Invalid setup bootstrap token", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("Invalid setup bootstrap token"))
 		return
 	}
 
-	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
+	var req SetupRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if err := validateUsername(req.Username); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 	if err := validatePassword(req.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	// Hash password
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	// Create user
-	_, err = h.db.Exec("INSERT INTO users (username, password_hash) VALUES (?, ?)", req.Username, string(passwordHash))
+	result, err := h.db.Exec("INSERT INTO users (username, password_hash) VALUES (?, ?)", req.Username, string(passwordHash))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, map[string]string{
-		"status": "Setup completed successfully",
+	newID, _ := result.LastInsertId()
+	h.auditService.Record(services.AuditEntry{
+		UserID:   int(newID),
+		Username: req.Username,
+		Action:   "auth.setup",
+		Resource: "users/" + strconv.FormatInt(newID, 10),
+		Status:   http.StatusCreated,
+		ClientIP: middleware.ClientIP(r),
+	})
+
+	respondJSON(w, http.StatusCreated, StatusResponse{
+		Status: "Setup completed successfully",
 	})
 }
 
 func (h *AuthHandler) SetupStatus(w http.ResponseWriter, r *http.Request) {
 	var count int
 	if err := h.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]bool{
-		"needs_setup": count == 0,
+	respondJSON(w, http.StatusOK, SetupStatusResponse{
+		NeedsSetup: count == 0,
 	})
 }
 
 func (h *AuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
 	// If we reach here, the auth middleware has already validated the token
-	respondJSON(w, http.StatusOK, map[string]bool{
-		"valid": true,
+	respondJSON(w, http.StatusOK, VerifyResponse{
+		Valid: true,
 	})
 }
 
@@ -159,13 +261,120 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 
 	var username string
 	err := h.db.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("User not found"))
+		return
+	}
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"id":       userID,
-		"username": username,
+	respondJSON(w, http.StatusOK, User{
+		ID:       int64(userID),
+		Username: username,
+	})
+}
+
+// recordLogin writes login attempts to the audit log. Login is a public route,
+// so it is not covered by the audit middleware.
+func (h *AuthHandler) recordLogin(r *http.Request, userID int, username string, status int, reason string) {
+	h.auditService.Record(services.AuditEntry{
+		UserID:   userID,
+		Username: username,
+		Action:   "auth.login",
+		Resource: "users/" + username,
+		Status:   status,
+		Error:    reason,
+		ClientIP: middleware.ClientIP(r),
 	})
 }
+
+const lockoutTimeLayout = "2006-01-02 15:04:05"
+
+// lockedUntil reports whether a username is currently locked out after
+// repeated failed logins.
+func (h *AuthHandler) lockedUntil(username string) (time.Time, bool) {
+	if h.cfg.Live().LoginLockoutThreshold == 0 {
+		return time.Time{}, false
+	}
+
+	var lockedUntil sql.NullString
+	err := h.db.QueryRow("SELECT locked_until FROM login_failures WHERE username = ?", username).Scan(&lockedUntil)
+	if err != nil || !lockedUntil.Valid {
+		return time.Time{}, false
+	}
+
+	until, err := time.Parse(lockoutTimeLayout, lockedUntil.String)
+	if err != nil || time.Now().UTC().After(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// recordFailedLogin counts a failed attempt against a username and locks it
+// once the threshold is reached. Failures older than the lockout duration are
+// forgotten. Unknown usernames are tracked too so lockout does not reveal
+// which accounts exist.
+// recordFailedLogin counts a wrong password for an existing user, locking
+// the username once the threshold is reached
+func (h *AuthHandler) recordFailedLogin(username string) {
+	policy := h.cfg.Live()
+	if policy.LoginLockoutThreshold == 0 || username == "" {
+		return
+	}
+
+	now := time.Now().UTC()
+	h.pruneLoginFailures(now, policy.LoginLockoutDuration)
+	failures := 0
+	var lastFailure sql.NullString
+	err := h.db.QueryRow("SELECT failures, last_failure FROM login_failures WHERE username = ?", username).Scan(&failures, &lastFailure)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to read login failures for %s: %v", username, err)
+		return
+	}
+	if lastFailure.Valid {
+		if last, err := time.Parse(lockoutTimeLayout, lastFailure.String); err != nil || now.Sub(last) > policy.LoginLockoutDuration {
+			failures = 0
+		}
+	}
+	failures++
+
+	var lockedUntil interface{}
+	if failures >= policy.LoginLockoutThreshold {
+		lockedUntil = now.Add(policy.LoginLockoutDuration).Format(lockoutTimeLayout)
+		failures = 0
+		log.Printf("Locking username %q after repeated failed logins", username)
+	}
+
+	_, err = h.db.Exec(`
+		INSERT INTO login_failures (username, failures, last_failure, locked_until)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(username) DO UPDATE SET
+		failures = excluded.failures,
+		last_failure = excluded.last_failure,
+		locked_until = COALESCE(excluded.locked_until, login_failures.locked_until)
+	`, username, failures, now.Format(lockoutTimeLayout), lockedUntil)
+	if err != nil {
+		log.Printf("Failed to record login failure for %s: %v", username, err)
+	}
+}
+
+// pruneLoginFailures drops rows whose failures have expired and whose lock
+// has passed, and rows of usernames that no longer exist
+func (h *AuthHandler) pruneLoginFailures(now time.Time, window time.Duration) {
+	_, err := h.db.Exec(`
+		DELETE FROM login_failures
+		WHERE username NOT IN (SELECT username FROM users)
+		OR (last_failure < ? AND (locked_until IS NULL OR locked_until < ?))
+	`, now.Add(-window).Format(lockoutTimeLayout), now.Format(lockoutTimeLayout))
+	if err != nil {
+		log.Printf("Failed to prune login failures: %v", err)
+	}
+}
+
+func (h *AuthHandler) clearFailedLogins(username string) {
+	if _, err := h.db.Exec("DELETE FROM login_failures WHERE username = ?", username); err != nil {
+		log.Printf("Failed to clear login failures for %s: %v", username, err)
+	}
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sunspear/api/apierror"
+	"sunspear/services"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type BackupHandler struct {
+	backupService *services.BackupService
+}
+
+func NewBackupHandler(backupService *services.BackupService) *BackupHandler {
+	return &BackupHandler{backupService: backupService}
+}
+
+func redactTarget(t *services.BackupTargetRecord) *services.BackupTargetRecord {
+	t.Config = t.Config.Redacted()
+	return t
+}
+
+func (h *BackupHandler) ListTargets(w http.ResponseWriter, r *http.Request) {
+	targets, err := h.backupService.ListTargets()
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list backup targets"))
+		return
+	}
+
+	for i := range targets {
+		redactTarget(&targets[i])
+	}
+	respondJSON(w, http.StatusOK, targets)
+}
+
+func (h *BackupHandler) GetTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	target, err := h.backupService.GetTarget(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Backup target not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, redactTarget(target))
+}
+
+func (h *BackupHandler) CreateTarget(w http.ResponseWriter, r *http.Request) {
+	var req BackupTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	target, err := h.backupService.CreateTarget(req.Name, req.Config)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to create backup target"))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, redactTarget(target))
+}
+
+func (h *BackupHandler) UpdateTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req BackupTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	target, err := h.backupService.UpdateTarget(id, req.Name, req.Config)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Backup target not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to update backup target"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, redactTarget(target))
+}
+
+func (h *BackupHandler) DeleteTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.backupService.DeleteTarget(id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		apierror.Write(w, r, apierror.NotFound("Backup target not found"))
+		return
+	case errors.Is(err, services.ErrBackupTargetInUse):
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+		return
+	case err != nil:
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to delete backup target"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MessageResponse{Message: "Backup target deleted"})
+}
+
+// TestTarget checks connectivity and write access to a target
+func (h *BackupHandler) TestTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	err := h.backupService.TestTarget(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Backup target not found"))
+		return
+	}
+	if err != nil {
+		respondJSON(w, http.StatusOK, ConnectionTestResponse{OK: false, Error: errorMessage(r, err, "Connection test failed")})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, ConnectionTestResponse{OK: true})
+}
+
+func (h *BackupHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.backupService.ListPolicies()
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list backup policies"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, policies)
+}
+
+func (h *BackupHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	policy, err := h.backupService.GetPolicy(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Backup policy not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, policy)
+}
+
+func (h *BackupHandler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	policy := services.BackupPolicy{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	created, err := h.backupService.CreatePolicy(r.Context(), policy)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to create backup policy"))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, created)
+}
+
+func (h *BackupHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var policy services.BackupPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	updated, err := h.backupService.UpdatePolicy(r.Context(), id, policy)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Backup policy not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to update backup policy"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, updated)
+}
+
+func (h *BackupHandler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.backupService.DeletePolicy(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Backup policy not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to delete backup policy"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MessageResponse{Message: "Backup policy deleted"})
+}
+
+// RunPolicy starts a policy run immediately. The run happens in the
+// background; poll the runs endpoint for its outcome.
+func (h *BackupHandler) RunPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	runID, err := h.backupService.StartRun(id, "manual")
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		apierror.Write(w, r, apierror.NotFound("Backup policy not found"))
+		return
+	case errors.Is(err, services.ErrBackupRunning):
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+		return
+	case err != nil:
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to start backup"))
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, RunStartedResponse{RunID: runID})
+}
+
+func (h *BackupHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	runs, err := h.backupService.ListRuns(id, limit)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list backup runs"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, runs)
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid ID"))
+		return 0, false
+	}
+	return id, true
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"sunspear/api/apierror"
 	"sunspear/services"
 
 	"github.com/gorilla/mux"
@@ -11,47 +15,66 @@ import (
 
 type ComposeHandler struct {
 	composeService *services.ComposeService
+	logService     *services.ContainerLogService
 }
 
-func NewComposeHandler(composeService *services.ComposeService) *ComposeHandler {
-	return &ComposeHandler{composeService: composeService}
+func NewComposeHandler(composeService *services.ComposeService, logService *services.ContainerLogService) *ComposeHandler {
+	return &ComposeHandler{composeService: composeService, logService: logService}
 }
 
+var projectSortKeys = map[string]func(a, b services.ComposeProject) bool{
+	"name":    func(a, b services.ComposeProject) bool { return a.Name < b.Name },
+	"created": func(a, b services.ComposeProject) bool { return a.CreatedAt < b.CreatedAt },
+	"status":  func(a, b services.ComposeProject) bool { return a.Status < b.Status },
+}
+
+// ListProjects filters by name (a case-insensitive substring) and status
 func (h *ComposeHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, "name", "created", "status")
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
 	projects, err := h.composeService.ListProjects()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, projects)
+	name := strings.ToLower(r.URL.Query().Get("name"))
+	status := r.URL.Query().Get("status")
+	matching := make([]services.ComposeProject, 0, len(projects))
+	for _, project := range projects {
+		if strings.Contains(strings.ToLower(project.Name), name) && (status == "" || project.Status == status) {
+			matching = append(matching, project)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, paginate(w, matching, opts, projectSortKeys))
 }
 
 func (h *ComposeHandler) DeployProject(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		YAML        string `json:"yaml"`
-	}
+	var req DeployProjectRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("name is required"))
 		return
 	}
 
 	if req.YAML == "" {
-		http.Error(w, "yaml is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("yaml is required"))
 		return
 	}
 
 	project, err := h.composeService.Deploy(r.Context(), req.Name, req.Description, req.YAML)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -59,23 +82,21 @@ func (h *ComposeHandler) DeployProject(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ComposeHandler) ValidateYAML(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		YAML string `json:"yaml"`
-	}
+	var req ValidateComposeRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if req.YAML == "" {
-		http.Error(w, "yaml is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("yaml is required"))
 		return
 	}
 
 	composeFile, err := h.composeService.ParseYAML(req.YAML)
 	if err != nil {
-		http.Error(w, "Invalid YAML: "+err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid YAML: "+err.Error()))
 		return
 	}
 
@@ -84,19 +105,17 @@ func (h *ComposeHandler) ValidateYAML(w http.ResponseWriter, r *http.Request) {
 		serviceNames = append(serviceNames, name)
 	}
 
-	response := map[string]interface{}{
-		"valid":    true,
-		"services": serviceNames,
-		"version":  composeFile.Version,
-	}
-
-	respondJSON(w, http.StatusOK, response)
+	respondJSON(w, http.StatusOK, ValidateComposeResponse{
+		Valid:    true,
+		Services: serviceNames,
+		Version:  composeFile.Version,
+	})
 }
 
 func (h *ComposeHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
 	templates, err := h.composeService.ListTemplates()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -109,7 +128,7 @@ func (h *ComposeHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
 
 	template, err := h.composeService.GetTemplate(name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Write(w, r, apierror.NotFound("Template not found"))
 		return
 	}
 
@@ -120,79 +139,114 @@ func (h *ComposeHandler) GetProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid project ID"))
 		return
 	}
 
 	project, err := h.composeService.GetProject(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Project not found"))
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	respondJSON(w, http.StatusOK, project)
 }
 
+// GetProjectLogs returns the logs of all project services merged by
+// timestamp, each line prefixed with its service
+func (h *ComposeHandler) GetProjectLogs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid project ID"))
+		return
+	}
+
+	project, err := h.composeService.GetProject(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Project not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	opts, err := parseLogOptions(r, "100")
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
+	writeLogs(w, r, true, func(emit func(services.LogLine) error) error {
+		return h.logService.ReadProject(r.Context(), project.Name, opts, emit)
+	})
+}
+
 func (h *ComposeHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid project ID"))
 		return
 	}
 
 	if err := h.composeService.DeleteProject(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "deleted"})
 }
 
 func (h *ComposeHandler) StartProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid project ID"))
 		return
 	}
 
 	if err := h.composeService.StartProject(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "started"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "started"})
 }
 
 func (h *ComposeHandler) StopProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid project ID"))
 		return
 	}
 
 	if err := h.composeService.StopProject(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "stopped"})
 }
 
 func (h *ComposeHandler) RestartProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid project ID"))
 		return
 	}
 
 	if err := h.composeService.RestartProject(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "restarted"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "restarted"})
 }
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sunspear/api/apierror"
+	"sunspear/services"
+	"time"
+)
+
+// maxLogFilterLength bounds user-supplied filter expressions
+const maxLogFilterLength = 1024
+
+// parseLogOptions reads tail, since, until, stream (stdout, stderr or both)
+// and filter (a regular expression matched against each message)
+func parseLogOptions(r *http.Request, defaultTail string) (services.LogOptions, error) {
+	q := r.URL.Query()
+	opts := services.LogOptions{
+		Tail:  q.Get("tail"),
+		Since: q.Get("since"),
+		Until: q.Get("until"),
+	}
+	if opts.Tail == "" {
+		opts.Tail = defaultTail
+	}
+
+	switch q.Get("stream") {
+	case "stdout":
+		opts.Stdout = true
+	case "stderr":
+		opts.Stderr = true
+	case "", "both":
+	default:
+		return opts, fmt.Errorf("stream must be stdout, stderr or both")
+	}
+
+	if filter := q.Get("filter"); filter != "" {
+		if len(filter) > maxLogFilterLength {
+			return opts, fmt.Errorf("filter is too long")
+		}
+		re, err := regexp.Compile(filter)
+		if err != nil {
+			return opts, fmt.Errorf("invalid filter: %w", err)
+		}
+		opts.Filter = re
+	}
+
+	return opts, opts.Validate()
+}
+
+// formatLogLine renders a line as text: timestamp, optional service prefix
+// and message
+func formatLogLine(line services.LogLine, withService bool) string {
+	text := line.Time.UTC().Format(time.RFC3339Nano) + " "
+	if withService {
+		text += line.Service + " | "
+	}
+	return text + line.Message + "\n"
+}
+
+// writeLogs runs read and writes its lines as plain text or, with
+// format=json, as a JSON array
+func writeLogs(w http.ResponseWriter, r *http.Request, withService bool, read func(emit func(services.LogLine) error) error) {
+	if r.URL.Query().Get("format") == "json" {
+		lines := []services.LogLine{}
+		err := read(func(line services.LogLine) error {
+			lines = append(lines, line)
+			return nil
+		})
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, lines)
+		return
+	}
+
+	started := false
+	err := read(func(line services.LogLine) error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		_, err := w.Write([]byte(formatLogLine(line, withService)))
+		return err
+	})
+	if err != nil && !started {
+		apierror.Write(w, r, err)
+		return
+	}
+	if !started {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+}
+
+// logLineMessage is the WebSocket form of a log line. Data keeps the text
+// form for clients that only append it.
+func logLineMessage(line services.LogLine, withService bool) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":      "log",
+		"data":      formatLogLine(line, withService),
+		"stream":    line.Stream,
+		"time":      line.Time,
+		"message":   line.Message,
+		"container": line.Container,
+		"service":   line.Service,
+	})
+	return data
+}
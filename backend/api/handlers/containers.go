@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
+	"sunspear/api/apierror"
 	"sunspear/services"
+	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/go-connections/nat"
 	"github.com/gorilla/mux"
@@ -17,22 +21,44 @@ import (
 
 type ContainerHandler struct {
 	dockerService *services.DockerService
+	cloneService  *services.ContainerCloneService
+	logService    *services.ContainerLogService
 }
 
-func NewContainerHandler(dockerService *services.DockerService) *ContainerHandler {
-	return &ContainerHandler{dockerService: dockerService}
+func NewContainerHandler(dockerService *services.DockerService, cloneService *services.ContainerCloneService, logService *services.ContainerLogService) *ContainerHandler {
+	return &ContainerHandler{dockerService: dockerService, cloneService: cloneService, logService: logService}
 }
 
+var containerSortKeys = map[string]func(a, b types.Container) bool{
+	"name":    func(a, b types.Container) bool { return firstOrEmpty(a.Names) < firstOrEmpty(b.Names) },
+	"created": func(a, b types.Container) bool { return a.Created < b.Created },
+	"state":   func(a, b types.Container) bool { return a.State < b.State },
+	"image":   func(a, b types.Container) bool { return a.Image < b.Image },
+}
+
+// ListContainers filters by label, name, status and compose project. A
+// status filter implies all, since stopped containers are hidden otherwise.
 func (h *ContainerHandler) ListContainers(w http.ResponseWriter, r *http.Request) {
-	all := r.URL.Query().Get("all") == "true"
+	q := r.URL.Query()
+	all := q.Get("all") == "true" || q.Get("status") != ""
+
+	opts, err := parseListOptions(r, "name", "created", "state", "image")
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+	args := dockerFilters(r, map[string]string{"label": "label", "name": "name", "status": "status"})
+	if project := q.Get("project"); project != "" {
+		args.Add("label", services.ProjectLabel+"="+project)
+	}
 
-	containers, err := h.dockerService.ListContainers(r.Context(), all)
+	containers, err := h.dockerService.ListContainersMatching(r.Context(), all, args)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, containers)
+	respondJSON(w, http.StatusOK, paginate(w, containers, opts, containerSortKeys))
 }
 
 func (h *ContainerHandler) GetContainer(w http.ResponseWriter, r *http.Request) {
@@ -41,7 +67,7 @@ func (h *ContainerHandler) GetContainer(w http.ResponseWriter, r *http.Request)
 
 	container, err := h.dockerService.GetContainer(r.Context(), containerID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -53,11 +79,11 @@ func (h *ContainerHandler) StartContainer(w http.ResponseWriter, r *http.Request
 	containerID := vars["id"]
 
 	if err := h.dockerService.StartContainer(r.Context(), containerID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "started"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "started"})
 }
 
 func (h *ContainerHandler) StopContainer(w http.ResponseWriter, r *http.Request) {
@@ -72,11 +98,11 @@ func (h *ContainerHandler) StopContainer(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := h.dockerService.StopContainer(r.Context(), containerID, timeout); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "stopped"})
 }
 
 func (h *ContainerHandler) RestartContainer(w http.ResponseWriter, r *http.Request) {
@@ -91,11 +117,11 @@ func (h *ContainerHandler) RestartContainer(w http.ResponseWriter, r *http.Reque
 	}
 
 	if err := h.dockerService.RestartContainer(r.Context(), containerID, timeout); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "restarted"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "restarted"})
 }
 
 func (h *ContainerHandler) RemoveContainer(w http.ResponseWriter, r *http.Request) {
@@ -105,31 +131,27 @@ func (h *ContainerHandler) RemoveContainer(w http.ResponseWriter, r *http.Reques
 	force := r.URL.Query().Get("force") == "true"
 
 	if err := h.dockerService.RemoveContainer(r.Context(), containerID, force); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "removed"})
 }
 
+// GetLogs returns demultiplexed logs (tail, since, until, stream, filter;
+// format=json for structured lines)
 func (h *ContainerHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	containerID := vars["id"]
-
-	tail := r.URL.Query().Get("tail")
-	if tail == "" {
-		tail = "100"
-	}
+	containerID := mux.Vars(r)["id"]
 
-	logs, err := h.dockerService.GetContainerLogs(r.Context(), containerID, tail)
+	opts, err := parseLogOptions(r, "100")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
-	defer logs.Close()
 
-	w.Header().Set("Content-Type", "text/plain")
-	io.Copy(w, logs)
+	writeLogs(w, r, false, func(emit func(services.LogLine) error) error {
+		return h.logService.Read(r.Context(), containerID, opts, emit)
+	})
 }
 
 func (h *ContainerHandler) GetStats(w http.ResponseWriter, r *http.Request) {
@@ -138,7 +160,7 @@ func (h *ContainerHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 
 	stats, err := h.dockerService.GetContainerStats(r.Context(), containerID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 	defer stats.Body.Close()
@@ -148,17 +170,10 @@ func (h *ContainerHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ContainerHandler) CreateContainer(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Image         string            `json:"image"`
-		Name          string            `json:"name"`
-		Ports         map[string]string `json:"ports"`
-		Volumes       map[string]string `json:"volumes"`
-		Env           []string          `json:"env"`
-		RestartPolicy string            `json:"restartPolicy"`
-	}
+	var req CreateContainerRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
@@ -208,7 +223,7 @@ func (h *ContainerHandler) CreateContainer(w http.ResponseWriter, r *http.Reques
 
 	response, err := h.dockerService.CreateContainer(r.Context(), config, hostConfig, req.Name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -219,32 +234,102 @@ func (h *ContainerHandler) RenameContainer(w http.ResponseWriter, r *http.Reques
 	vars := mux.Vars(r)
 	containerID := vars["id"]
 
-	var req struct {
-		Name string `json:"name"`
-	}
+	var req RenameContainerRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("name is required"))
 		return
 	}
 
 	if err := h.dockerService.RenameContainer(r.Context(), containerID, req.Name); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, RenameContainerResponse{Status: "renamed", Name: req.Name})
+}
+
+// commitInstructions are the Dockerfile instructions Docker accepts as commit changes
+var commitInstructions = map[string]bool{
+	"CMD": true, "ENTRYPOINT": true, "ENV": true, "EXPOSE": true, "LABEL": true,
+	"ONBUILD": true, "USER": true, "VOLUME": true, "WORKDIR": true,
+}
+
+// CommitContainer snapshots a container into a new image
+func (h *ContainerHandler) CommitContainer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	var req CommitContainerRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
+	for _, change := range req.Changes {
+		instruction, _, _ := strings.Cut(strings.TrimSpace(change), " ")
+		if !commitInstructions[strings.ToUpper(instruction)] {
+			apierror.Write(w, r, apierror.InvalidArgument(fmt.Sprintf("unsupported change instruction %q", instruction)))
+			return
+		}
+	}
+
+	// Pause during the commit unless explicitly disabled, as docker does
+	pause := req.Pause == nil || *req.Pause
+
+	response, err := h.dockerService.CommitContainer(r.Context(), containerID, types.ContainerCommitOptions{
+		Reference: req.Reference,
+		Author:    req.Author,
+		Comment:   req.Message,
+		Changes:   req.Changes,
+		Pause:     pause,
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, CommitContainerResponse{ID: response.ID, Reference: req.Reference})
+}
+
+// CloneContainer creates a copy of a container under a new name
+func (h *ContainerHandler) CloneContainer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	var opts services.CloneOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
+	if opts.Name == "" {
+		apierror.Write(w, r, apierror.InvalidArgument("name is required"))
+		return
+	}
+
+	// Copying large volumes can take a while
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	result, err := h.cloneService.Clone(r.Context(), containerID, opts)
+	if err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "renamed", "name": req.Name})
+	respondJSON(w, http.StatusCreated, result)
 }
 
 func (h *ContainerHandler) BulkStopContainers(w http.ResponseWriter, r *http.Request) {
 	containers, err := h.dockerService.ListContainers(r.Context(), false)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -262,22 +347,13 @@ func (h *ContainerHandler) BulkStopContainers(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	response := map[string]interface{}{
-		"stopped": stoppedCount,
-		"total":   len(containers),
-	}
-
-	if len(errors) > 0 {
-		response["errors"] = errors
-	}
-
-	respondJSON(w, http.StatusOK, response)
+	respondJSON(w, http.StatusOK, BulkStopResponse{Stopped: stoppedCount, Total: len(containers), Errors: errors})
 }
 
 func (h *ContainerHandler) BulkRestartContainers(w http.ResponseWriter, r *http.Request) {
 	containers, err := h.dockerService.ListContainers(r.Context(), false)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -295,16 +371,7 @@ func (h *ContainerHandler) BulkRestartContainers(w http.ResponseWriter, r *http.
 		}
 	}
 
-	response := map[string]interface{}{
-		"restarted": restartedCount,
-		"total":     len(containers),
-	}
-
-	if len(errors) > 0 {
-		response["errors"] = errors
-	}
-
-	respondJSON(w, http.StatusOK, response)
+	respondJSON(w, http.StatusOK, BulkRestartResponse{Restarted: restartedCount, Total: len(containers), Errors: errors})
 }
 
 func setRestartPolicyName(policy *container.RestartPolicy, name string) {
@@ -324,3 +391,14 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
+
+// errorMessage is the message apierror.Write would send for err, for
+// responses that report a failure in their body. The text of unclassified
+// errors is logged instead.
+func errorMessage(r *http.Request, err error, fallback string) string {
+	e := apierror.Wrap(err, fallback)
+	if e.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	return e.Message
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sunspear/api/apierror"
+	"sunspear/services"
+)
+
+type EventHandler struct {
+	eventService *services.EventService
+}
+
+func NewEventHandler(eventService *services.EventService) *EventHandler {
+	return &EventHandler{eventService: eventService}
+}
+
+// ListEvents returns recorded Docker events filtered by type, action,
+// resource and time range; with after, events following that cursor
+func (h *EventHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	query, err := parseEventQuery(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
+	events, err := h.eventService.List(query)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list events"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, events)
+}
+
+func parseEventQuery(r *http.Request) (services.EventQuery, error) {
+	q := r.URL.Query()
+	query := services.EventQuery{
+		Type:     q.Get("type"),
+		Action:   q.Get("action"),
+		Resource: q.Get("resource"),
+	}
+
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			return query, fmt.Errorf("invalid limit")
+		}
+	}
+	if v := q.Get("after"); v != "" {
+		if query.After, err = strconv.ParseInt(v, 10, 64); err != nil || query.After < 0 {
+			return query, fmt.Errorf("invalid after cursor")
+		}
+	}
+	if query.From, err = parseTimeParam(q.Get("from")); err != nil {
+		return query, fmt.Errorf("invalid from: %v", err)
+	}
+	if query.To, err = parseEndTimeParam(q.Get("to")); err != nil {
+		return query, fmt.Errorf("invalid to: %v", err)
+	}
+	return query, nil
+}
@@ -1,49 +1,95 @@
 package handlers
 
 import (
-	"archive/tar"
-	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sunspear/api/apierror"
 	"sunspear/services"
+	"time"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
 	"github.com/gorilla/mux"
 )
 
 type ImageHandler struct {
-	dockerService *services.DockerService
+	dockerService      *services.DockerService
+	imageUpdateService *services.ImageUpdateService
+	buildService       *services.ImageBuildService
+	analysisService    *services.ImageAnalysisService
 }
 
-func NewImageHandler(dockerService *services.DockerService) *ImageHandler {
-	return &ImageHandler{dockerService: dockerService}
+func NewImageHandler(dockerService *services.DockerService, imageUpdateService *services.ImageUpdateService, buildService *services.ImageBuildService, analysisService *services.ImageAnalysisService) *ImageHandler {
+	return &ImageHandler{
+		dockerService:      dockerService,
+		imageUpdateService: imageUpdateService,
+		buildService:       buildService,
+		analysisService:    analysisService,
+	}
+}
+
+var imageSortKeys = map[string]func(a, b types.ImageSummary) bool{
+	"name":    func(a, b types.ImageSummary) bool { return firstOrEmpty(a.RepoTags) < firstOrEmpty(b.RepoTags) },
+	"created": func(a, b types.ImageSummary) bool { return a.Created < b.Created },
+	"size":    func(a, b types.ImageSummary) bool { return a.Size < b.Size },
 }
 
+// ListImages filters by label, name (a reference pattern such as nginx:*),
+// dangling and inUse
 func (h *ImageHandler) ListImages(w http.ResponseWriter, r *http.Request) {
-	images, err := h.dockerService.ListImages(r.Context())
+	opts, err := parseListOptions(r, "name", "created", "size")
+	if err == nil {
+		_, err = boolParam(r, "dangling")
+	}
+	var inUse *bool
+	if err == nil {
+		inUse, err = boolParam(r, "inUse")
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+	args := dockerFilters(r, map[string]string{"label": "label", "name": "reference", "dangling": "dangling"})
+
+	images, err := h.dockerService.ListImagesMatching(r.Context(), args, inUse != nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, images)
+	if inUse != nil {
+		matching := make([]types.ImageSummary, 0, len(images))
+		for _, image := range images {
+			if (image.Containers > 0) == *inUse {
+				matching = append(matching, image)
+			}
+		}
+		images = matching
+	}
+
+	respondJSON(w, http.StatusOK, paginate(w, images, opts, imageSortKeys))
 }
 
 func (h *ImageHandler) PullImage(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Image string `json:"image"`
-	}
+	var req PullImageRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	reader, err := h.dockerService.PullImage(r.Context(), req.Image)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 	defer reader.Close()
@@ -60,7 +106,7 @@ func (h *ImageHandler) RemoveImage(w http.ResponseWriter, r *http.Request) {
 
 	response, err := h.dockerService.RemoveImage(r.Context(), imageID, force)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -70,13 +116,13 @@ func (h *ImageHandler) RemoveImage(w http.ResponseWriter, r *http.Request) {
 func (h *ImageHandler) SearchImages(w http.ResponseWriter, r *http.Request) {
 	term := r.URL.Query().Get("term")
 	if term == "" {
-		http.Error(w, "search term required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("search term required"))
 		return
 	}
 
 	results, err := h.dockerService.SearchImages(r.Context(), term)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -87,18 +133,15 @@ func (h *ImageHandler) TagImage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	imageID := vars["id"]
 
-	var req struct {
-		Repo string `json:"repo"`
-		Tag  string `json:"tag"`
-	}
+	var req TagImageRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if req.Repo == "" {
-		http.Error(w, "repo is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("repo is required"))
 		return
 	}
 
@@ -109,11 +152,11 @@ func (h *ImageHandler) TagImage(w http.ResponseWriter, r *http.Request) {
 	newRef := fmt.Sprintf("%s:%s", req.Repo, req.Tag)
 
 	if err := h.dockerService.TagImage(r.Context(), imageID, newRef); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "tagged", "tag": newRef})
+	respondJSON(w, http.StatusOK, TagImageResponse{Status: "tagged", Tag: newRef})
 }
 
 func (h *ImageHandler) InspectImage(w http.ResponseWriter, r *http.Request) {
@@ -122,7 +165,7 @@ func (h *ImageHandler) InspectImage(w http.ResponseWriter, r *http.Request) {
 
 	inspect, err := h.dockerService.InspectImage(r.Context(), imageID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -135,7 +178,7 @@ func (h *ImageHandler) GetImageHistory(w http.ResponseWriter, r *http.Request) {
 
 	history, err := h.dockerService.GetImageHistory(r.Context(), imageID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -145,66 +188,343 @@ func (h *ImageHandler) GetImageHistory(w http.ResponseWriter, r *http.Request) {
 func (h *ImageHandler) PruneImages(w http.ResponseWriter, r *http.Request) {
 	report, err := h.dockerService.PruneImages(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"deleted":        report.ImagesDeleted,
-		"spaceReclaimed": report.SpaceReclaimed,
+	respondJSON(w, http.StatusOK, PruneImagesResponse{
+		Deleted:        report.ImagesDeleted,
+		SpaceReclaimed: report.SpaceReclaimed,
 	})
 }
 
+// BuildImage builds an image from a multipart form. Option fields must
+// precede the optional "context" file (tar, tar.gz or zip), which is
+// streamed to the daemon rather than buffered. Progress is streamed back as
+// newline-delimited JSON events.
 func (h *ImageHandler) BuildImage(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
-	dockerfile := r.FormValue("dockerfile")
-	if dockerfile == "" {
-		http.Error(w, "dockerfile content is required", http.StatusBadRequest)
+	var opts services.BuildOptions
+	var buildContext io.Reader
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Invalid(err))
+			return
+		}
+		if part.FormName() == "context" {
+			defer part.Close()
+			buildContext = part
+			break
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxBuildFieldSize+1))
+		part.Close()
+		if err != nil {
+			apierror.Write(w, r, apierror.Invalid(err))
+			return
+		}
+		if len(value) > maxBuildFieldSize {
+			apierror.Write(w, r, apierror.InvalidArgument(fmt.Sprintf("field %s is too large", part.FormName())))
+			return
+		}
+		if err := setBuildOption(&opts, part.FormName(), string(value)); err != nil {
+			apierror.Write(w, r, apierror.Invalid(err))
+			return
+		}
+	}
+
+	if err := opts.Validate(buildContext != nil); err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
-	tagsStr := r.FormValue("tags")
-	if tagsStr == "" {
-		http.Error(w, "at least one tag is required", http.StatusBadRequest)
+	// Uploads and builds outlast the server's timeouts
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	emit := func(event services.BuildEvent) {
+		encoder.Encode(event)
+		rc.Flush()
+	}
+
+	if _, err := h.buildService.Build(r.Context(), opts, buildContext, emit); err != nil {
+		log.Printf("Image build failed: %v", err)
+	}
+}
+
+const maxBuildFieldSize = 1 << 20
+
+func setBuildOption(opts *services.BuildOptions, field, value string) error {
+	switch field {
+	case "tags":
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				opts.Tags = append(opts.Tags, tag)
+			}
+		}
+	case "dockerfile":
+		opts.Dockerfile = value
+	case "dockerfilePath":
+		opts.DockerfilePath = value
+	case "buildArgs":
+		if err := json.Unmarshal([]byte(value), &opts.BuildArgs); err != nil {
+			return fmt.Errorf("buildArgs must be a JSON object of strings")
+		}
+	case "labels":
+		if err := json.Unmarshal([]byte(value), &opts.Labels); err != nil {
+			return fmt.Errorf("labels must be a JSON object of strings")
+		}
+	case "target":
+		opts.Target = value
+	case "platform":
+		opts.Platform = value
+	case "noCache":
+		opts.NoCache = value == "true"
+	case "gitUrl":
+		opts.GitURL = strings.TrimSpace(value)
+	case "gitRef":
+		opts.GitRef = strings.TrimSpace(value)
+	case "gitSubdir":
+		opts.GitSubdir = strings.Trim(strings.TrimSpace(value), "/")
+	}
+	return nil
+}
+
+func (h *ImageHandler) ListBuilds(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	builds, err := h.buildService.ListBuilds(limit)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list builds"))
 		return
 	}
 
-	tags := strings.Split(tagsStr, ",")
-	for i, tag := range tags {
-		tags[i] = strings.TrimSpace(tag)
+	respondJSON(w, http.StatusOK, builds)
+}
+
+// GetBuild returns a build with its full log
+func (h *ImageHandler) GetBuild(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid build ID"))
+		return
 	}
 
-	buf := new(bytes.Buffer)
-	tw := tar.NewWriter(buf)
-	dockerfileBytes := []byte(dockerfile)
-	if err := tw.WriteHeader(&tar.Header{
-		Name: "Dockerfile",
-		Size: int64(len(dockerfileBytes)),
-		Mode: 0644,
-	}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	build, err := h.buildService.Get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Build not found"))
 		return
 	}
-	if _, err := tw.Write(dockerfileBytes); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
-	if err := tw.Close(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	respondJSON(w, http.StatusOK, build)
+}
+
+func (h *ImageHandler) DeleteBuild(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid build ID"))
 		return
 	}
 
-	buildResponse, err := h.dockerService.BuildImage(r.Context(), buf, tags)
+	err = h.buildService.Delete(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Build not found or still running"))
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to delete build"))
 		return
 	}
-	defer buildResponse.Body.Close()
 
-	w.Header().Set("Content-Type", "application/json")
-	io.Copy(w, buildResponse.Body)
+	respondJSON(w, http.StatusOK, MessageResponse{Message: "Build deleted"})
+}
+
+// ListImageUpdates returns the latest registry check for each image used by
+// a running container, with the containers and installed apps using it
+func (h *ImageHandler) ListImageUpdates(w http.ResponseWriter, r *http.Request) {
+	updates, err := h.imageUpdateService.List(r.Context())
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, updates)
+}
+
+// CheckImageUpdates starts a registry check immediately
+func (h *ImageHandler) CheckImageUpdates(w http.ResponseWriter, r *http.Request) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+		if err := h.imageUpdateService.Check(ctx); err != nil {
+			log.Printf("Image update check failed: %v", err)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, StatusResponse{Status: "checking"})
+}
+
+// ExportImage streams a `docker save` tarball of the image in the path plus
+// any listed in repeated "image" query parameters. gzip=true compresses it.
+func (h *ImageHandler) ExportImage(w http.ResponseWriter, r *http.Request) {
+	images := append([]string{mux.Vars(r)["id"]}, r.URL.Query()["image"]...)
+	compress := r.URL.Query().Get("gzip") == "true"
+
+	// Check every image up front so a typo fails with 404 instead of a
+	// broken download
+	for _, image := range images {
+		if _, err := h.dockerService.InspectImage(r.Context(), image); err != nil {
+			apierror.Write(w, r, apierror.NotFound(fmt.Sprintf("Image %s not found", image)))
+			return
+		}
+	}
+
+	reader, err := h.dockerService.SaveImages(r.Context(), images)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	defer reader.Close()
+
+	filename := exportFilename(images[0]) + ".tar"
+	contentType := "application/x-tar"
+	if compress {
+		filename += ".gz"
+		contentType = "application/gzip"
+	}
+
+	// Multi-GB images take longer than the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	out := &lazyHeaderWriter{w: w, onFirstWrite: func() {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}}
+
+	if compress {
+		gz := gzip.NewWriter(out)
+		_, err = io.Copy(gz, reader)
+		if err == nil {
+			err = gz.Close()
+		}
+	} else {
+		_, err = io.Copy(out, reader)
+	}
+	if err != nil {
+		if !out.written {
+			apierror.Write(w, r, err)
+			return
+		}
+		log.Printf("Image export of %s failed mid-stream: %v", strings.Join(images, ", "), err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportFilename turns an image reference into a safe file name
+func exportFilename(image string) string {
+	name := strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(image)
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return name
+}
+
+// ImportImage loads a `docker save` tarball (optionally compressed), sent as
+// the raw request body or the "archive" field of a multipart form. The
+// upload is streamed to the daemon, not buffered.
+func (h *ImageHandler) ImportImage(w http.ResponseWriter, r *http.Request) {
+	// Large uploads outlast the server's read timeout
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	var archive io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		part, err := multipartFile(r, "archive")
+		if err != nil {
+			apierror.Write(w, r, apierror.Invalid(err))
+			return
+		}
+		defer part.Close()
+		archive = part
+	}
+
+	result, err := h.dockerService.LoadImages(r.Context(), archive)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to import images"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// AnalyzeImage reports per-layer sizes, the largest files and space wasted
+// by files that later layers overwrite or delete. Results are cached per
+// image ID; refresh=true recomputes them.
+func (h *ImageHandler) AnalyzeImage(w http.ResponseWriter, r *http.Request) {
+	imageID := mux.Vars(r)["id"]
+
+	// Exporting and scanning a large image outlasts the write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	analysis, err := h.analysisService.Analyze(r.Context(), imageID, r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		imageAnalysisError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, analysis)
+}
+
+// GetLayerTree returns the file tree of one layer (path= to start below the
+// root, depth= levels, default 3, 0 for all)
+func (h *ImageHandler) GetLayerTree(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	layer, err := strconv.Atoi(vars["layer"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid layer index"))
+		return
+	}
+	depth := 3
+	if d := r.URL.Query().Get("depth"); d != "" {
+		if depth, err = strconv.Atoi(d); err != nil || depth < 0 {
+			apierror.Write(w, r, apierror.InvalidArgument("Invalid depth"))
+			return
+		}
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	tree, err := h.analysisService.LayerTree(r.Context(), vars["id"], layer, r.URL.Query().Get("path"), depth)
+	if err != nil {
+		imageAnalysisError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tree)
+}
+
+func imageAnalysisError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errdefs.IsNotFound(err):
+		apierror.Write(w, r, apierror.NotFound("Image not found"))
+	case errors.Is(err, services.ErrAnalysisRunning):
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+	default:
+		apierror.Write(w, r, err)
+	}
 }
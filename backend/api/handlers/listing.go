@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/filters"
+)
+
+// TotalCountHeader reports how many items matched a list request before
+// limit and offset were applied
+const TotalCountHeader = "X-Total-Count"
+
+// listOptions are the sort and paging parameters shared by list endpoints
+type listOptions struct {
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+}
+
+// parseListOptions reads sort, order (asc or desc), limit and offset.
+// sortKeys are the sort keys the endpoint accepts.
+func parseListOptions(r *http.Request, sortKeys ...string) (listOptions, error) {
+	q := r.URL.Query()
+	opts := listOptions{Sort: q.Get("sort")}
+
+	if opts.Sort != "" {
+		valid := false
+		for _, key := range sortKeys {
+			valid = valid || key == opts.Sort
+		}
+		if !valid {
+			return opts, fmt.Errorf("sort must be one of %s", strings.Join(sortKeys, ", "))
+		}
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, fmt.Errorf("order must be asc or desc")
+	}
+
+	var err error
+	if limit := q.Get("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit < 0 {
+			return opts, fmt.Errorf("invalid limit")
+		}
+	}
+	if offset := q.Get("offset"); offset != "" {
+		if opts.Offset, err = strconv.Atoi(offset); err != nil || opts.Offset < 0 {
+			return opts, fmt.Errorf("invalid offset")
+		}
+	}
+	return opts, nil
+}
+
+// dockerFilters maps query parameters to Docker filter names, e.g.
+// {"name": "reference"}. Parameters may repeat; "label" takes key or
+// key=value.
+func dockerFilters(r *http.Request, params map[string]string) filters.Args {
+	args := filters.NewArgs()
+	q := r.URL.Query()
+	for param, filter := range params {
+		for _, value := range q[param] {
+			if value != "" {
+				args.Add(filter, value)
+			}
+		}
+	}
+	return args
+}
+
+// boolParam reads an optional true/false query parameter
+func boolParam(r *http.Request, name string) (*bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &b, nil
+}
+
+// paginate sorts items by the requested key, keeping their order when none
+// was given, sets the total count header and returns the requested page
+func paginate[T any](w http.ResponseWriter, items []T, opts listOptions, less map[string]func(a, b T) bool) []T {
+	if items == nil {
+		items = []T{}
+	}
+	if fn := less[opts.Sort]; fn != nil {
+		sort.SliceStable(items, func(i, j int) bool {
+			if opts.Desc {
+				return fn(items[j], items[i])
+			}
+			return fn(items[i], items[j])
+		})
+	}
+
+	w.Header().Set(TotalCountHeader, strconv.Itoa(len(items)))
+	if opts.Offset >= len(items) {
+		return items[:0]
+	}
+	items = items[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(items) {
+		items = items[:opts.Limit]
+	}
+	return items
+}
+
+// firstOrEmpty returns the first of a container's names or an image's tags
+func firstOrEmpty(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
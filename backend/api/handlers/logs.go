@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sunspear/api/apierror"
+	"sunspear/services"
+
+	"github.com/gorilla/mux"
+)
+
+type LogHandler struct {
+	logCollector *services.LogCollectorService
+}
+
+func NewLogHandler(logCollector *services.LogCollectorService) *LogHandler {
+	return &LogHandler{logCollector: logCollector}
+}
+
+// SearchLogs searches captured log lines (q, container, project, stream,
+// from, to, limit), newest first
+func (h *LogHandler) SearchLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := services.LogSearchQuery{
+		Query:     q.Get("q"),
+		Container: q.Get("container"),
+		Project:   q.Get("project"),
+		Stream:    q.Get("stream"),
+	}
+	query.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+	var err error
+	if query.From, err = parseTimeParam(q.Get("from")); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument(fmt.Sprintf("invalid from: %v", err)))
+		return
+	}
+	if query.To, err = parseEndTimeParam(q.Get("to")); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument(fmt.Sprintf("invalid to: %v", err)))
+		return
+	}
+
+	entries, err := h.logCollector.Search(query)
+	if errors.Is(err, services.ErrInvalidLogQuery) {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to search logs"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
+
+func (h *LogHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.logCollector.Status())
+}
+
+func (h *LogHandler) ListTargets(w http.ResponseWriter, r *http.Request) {
+	targets, err := h.logCollector.ListTargets()
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list log capture targets"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, targets)
+}
+
+func (h *LogHandler) AddTarget(w http.ResponseWriter, r *http.Request) {
+	var target services.LogCaptureTarget
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	saved, err := h.logCollector.AddTarget(target)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to add log capture target"))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, saved)
+}
+
+func (h *LogHandler) DeleteTarget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid ID"))
+		return
+	}
+
+	err = h.logCollector.DeleteTarget(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Log capture target not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to delete log capture target"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MessageResponse{Message: "Log capture target deleted"})
+}
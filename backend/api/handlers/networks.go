@@ -3,8 +3,10 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"sunspear/api/apierror"
 	"sunspear/services"
 
+	"github.com/docker/docker/api/types"
 	"github.com/gorilla/mux"
 )
 
@@ -16,30 +18,40 @@ func NewNetworkHandler(dockerService *services.DockerService) *NetworkHandler {
 	return &NetworkHandler{dockerService: dockerService}
 }
 
+var networkSortKeys = map[string]func(a, b types.NetworkResource) bool{
+	"name":    func(a, b types.NetworkResource) bool { return a.Name < b.Name },
+	"created": func(a, b types.NetworkResource) bool { return a.Created.Before(b.Created) },
+	"driver":  func(a, b types.NetworkResource) bool { return a.Driver < b.Driver },
+}
+
+// ListNetworks filters by name, label and driver
 func (h *NetworkHandler) ListNetworks(w http.ResponseWriter, r *http.Request) {
-	networks, err := h.dockerService.ListNetworks(r.Context())
+	opts, err := parseListOptions(r, "name", "created", "driver")
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+	args := dockerFilters(r, map[string]string{"name": "name", "label": "label", "driver": "driver"})
+
+	networks, err := h.dockerService.ListNetworksMatching(r.Context(), args)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, networks)
+	respondJSON(w, http.StatusOK, paginate(w, networks, opts, networkSortKeys))
 }
 
 func (h *NetworkHandler) CreateNetwork(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name     string `json:"name"`
-		Driver   string `json:"driver"`
-		Internal bool   `json:"internal"`
-	}
+	var req CreateNetworkRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("name is required"))
 		return
 	}
 
@@ -49,7 +61,7 @@ func (h *NetworkHandler) CreateNetwork(w http.ResponseWriter, r *http.Request) {
 
 	network, err := h.dockerService.CreateNetwork(r.Context(), req.Name, req.Driver, req.Internal)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -62,7 +74,7 @@ func (h *NetworkHandler) InspectNetwork(w http.ResponseWriter, r *http.Request)
 
 	network, err := h.dockerService.InspectNetwork(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -74,75 +86,67 @@ func (h *NetworkHandler) RemoveNetwork(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	if err := h.dockerService.RemoveNetwork(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "removed"})
 }
 
 func (h *NetworkHandler) ConnectContainer(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	networkID := vars["id"]
 
-	var req struct {
-		ContainerID string `json:"containerId"`
-	}
+	var req NetworkContainerRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if req.ContainerID == "" {
-		http.Error(w, "containerId is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("containerId is required"))
 		return
 	}
 
 	if err := h.dockerService.ConnectNetwork(r.Context(), networkID, req.ContainerID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "connected"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "connected"})
 }
 
 func (h *NetworkHandler) DisconnectContainer(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	networkID := vars["id"]
 
-	var req struct {
-		ContainerID string `json:"containerId"`
-	}
+	var req NetworkContainerRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if req.ContainerID == "" {
-		http.Error(w, "containerId is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("containerId is required"))
 		return
 	}
 
 	if err := h.dockerService.DisconnectNetwork(r.Context(), networkID, req.ContainerID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "disconnected"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "disconnected"})
 }
 
 func (h *NetworkHandler) PruneNetworks(w http.ResponseWriter, r *http.Request) {
 	report, err := h.dockerService.PruneNetworks(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"deleted": report.NetworksDeleted,
-	}
-
-	respondJSON(w, http.StatusOK, response)
+	respondJSON(w, http.StatusOK, PruneNetworksResponse{Deleted: report.NetworksDeleted})
 }
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"sunspear/api/apierror"
+	"sunspear/services"
+)
+
+type ProxyHandler struct {
+	proxyService *services.ProxyService
+}
+
+func NewProxyHandler(proxyService *services.ProxyService) *ProxyHandler {
+	return &ProxyHandler{proxyService: proxyService}
+}
+
+// ListRoutes returns the reverse-proxy routes requested by containers and
+// their state in Caddy, optionally filtered by app, project or container
+func (h *ProxyHandler) ListRoutes(w http.ResponseWriter, r *http.Request) {
+	status := h.proxyService.Status()
+	q := r.URL.Query()
+	app, project, container := q.Get("app"), q.Get("project"), q.Get("container")
+
+	routes := []services.ProxyRoute{}
+	for _, route := range status.Routes {
+		if (app != "" && route.App != app) || (project != "" && route.Project != project) ||
+			(container != "" && route.Container != container && route.ContainerID != container) {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	status.Routes = routes
+
+	respondJSON(w, http.StatusOK, status)
+}
+
+// SyncRoutes pushes routes to Caddy now instead of waiting for the next pass
+func (h *ProxyHandler) SyncRoutes(w http.ResponseWriter, r *http.Request) {
+	status := h.proxyService.Status()
+	if !status.Enabled {
+		apierror.Write(w, r, apierror.Conflict("Automatic proxy routes are disabled (set CADDY_ADMIN_URL)"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, h.proxyService.Sync())
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sunspear/api/apierror"
+	"sunspear/services"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type RegistryHandler struct {
+	credentialService *services.RegistryCredentialService
+}
+
+func NewRegistryHandler(credentialService *services.RegistryCredentialService) *RegistryHandler {
+	return &RegistryHandler{credentialService: credentialService}
+}
+
+func (h *RegistryHandler) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	creds, err := h.credentialService.List()
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list registry credentials"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, creds)
+}
+
+func (h *RegistryHandler) CreateCredential(w http.ResponseWriter, r *http.Request) {
+	var req services.RegistryCredential
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	cred, err := h.credentialService.Create(req)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to save registry credential"))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, cred)
+}
+
+func (h *RegistryHandler) UpdateCredential(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid ID"))
+		return
+	}
+
+	var req services.RegistryCredential
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	cred, err := h.credentialService.Update(id, req)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Registry credential not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to save registry credential"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, cred)
+}
+
+func (h *RegistryHandler) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid ID"))
+		return
+	}
+
+	err = h.credentialService.Delete(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Registry credential not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to delete registry credential"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MessageResponse{Message: "Registry credential deleted"})
+}
+
+// TestCredential logs in to the registry with the stored credential
+func (h *RegistryHandler) TestCredential(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	status, err := h.credentialService.Test(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Registry credential not found"))
+		return
+	}
+	if err != nil {
+		respondJSON(w, http.StatusOK, ConnectionTestResponse{OK: false, Error: errorMessage(r, err, "Login failed")})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, ConnectionTestResponse{OK: true, Status: status})
+}
@@ -3,8 +3,10 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"sunspear/api/apierror"
 	"sunspear/api/middleware"
 	"sunspear/config"
 
@@ -25,23 +27,23 @@ func NewSettingsHandler(cfg *config.Config, db *sql.DB) *SettingsHandler {
 func (h *SettingsHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query("SELECT key, value FROM settings")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 	defer rows.Close()
 
-	settings := make(map[string]string)
+	settings := make(Settings)
 	for rows.Next() {
 		var key, value string
 		if err := rows.Scan(&key, &value); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
 			return
 		}
 		settings[key] = value
 	}
 
 	if err := rows.Err(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -50,12 +52,19 @@ func (h *SettingsHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
 
 // UpdateSettings upserts multiple settings at once
 func (h *SettingsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
-	var settings map[string]string
+	var settings Settings
 	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
+	for key, value := range settings {
+		if err := validateSetting(key, value); err != nil {
+			apierror.Write(w, r, apierror.Invalid(err))
+			return
+		}
+	}
+
 	// Upsert each key-value pair
 	for key, value := range settings {
 		_, err := h.db.Exec(
@@ -67,13 +76,13 @@ func (h *SettingsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request)
 			key, value,
 		)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
 			return
 		}
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"status": "Settings updated",
+	respondJSON(w, http.StatusOK, StatusResponse{
+		Status: "Settings updated",
 	})
 }
 
@@ -81,28 +90,23 @@ func (h *SettingsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request)
 func (h *SettingsHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query("SELECT id, username, created_at FROM users")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 	defer rows.Close()
 
-	users := []map[string]interface{}{}
+	users := []User{}
 	for rows.Next() {
-		var id int
-		var username, createdAt string
-		if err := rows.Scan(&id, &username, &createdAt); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.CreatedAt); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
-		users = append(users, map[string]interface{}{
-			"id":         id,
-			"username":   username,
-			"created_at": createdAt,
-		})
+		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -111,29 +115,26 @@ func (h *SettingsHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 // CreateUser creates a new user with hashed password
 func (h *SettingsHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
+	var req CreateUserRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if err := validateUsername(req.Username); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 	if err := validatePassword(req.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	// Hash password
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -144,20 +145,19 @@ func (h *SettingsHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		string(passwordHash),
 	)
 	if err != nil {
-		// Check for duplicate username (SQLite UNIQUE constraint violation)
-		if err.Error() == "UNIQUE constraint failed: users.username" {
-			http.Error(w, "Username already exists", http.StatusConflict)
+		if apierror.From(err).Code == apierror.CodeConflict {
+			apierror.Write(w, r, apierror.Conflict("Username already exists"))
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	newID, _ := result.LastInsertId()
 
-	respondJSON(w, http.StatusCreated, map[string]interface{}{
-		"id":       newID,
-		"username": req.Username,
+	respondJSON(w, http.StatusCreated, User{
+		ID:       newID,
+		Username: req.Username,
 	})
 }
 
@@ -167,37 +167,37 @@ func (h *SettingsHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	userIDStr := vars["id"]
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid user ID"))
 		return
 	}
 
 	// Check if this is the last user
 	var count int
 	if err := h.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	if count <= 1 {
-		http.Error(w, "Cannot delete the last user", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Cannot delete the last user"))
 		return
 	}
 
 	// Delete the user
 	result, err := h.db.Exec("DELETE FROM users WHERE id = ?", userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "User not found", http.StatusNotFound)
+		apierror.Write(w, r, apierror.NotFound("User not found"))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"status": "User deleted",
+	respondJSON(w, http.StatusOK, StatusResponse{
+		Status: "User deleted",
 	})
 }
 
@@ -208,66 +208,67 @@ func (h *SettingsHandler) ChangePassword(w http.ResponseWriter, r *http.Request)
 	targetIDStr := vars["id"]
 	targetID, err := strconv.Atoi(targetIDStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid user ID"))
 		return
 	}
 
 	// Get requesting user's ID from context
 	requestingID := r.Context().Value(middleware.UserIDKey).(int)
 	if requestingID != targetID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		apierror.Write(w, r, apierror.Forbidden("Forbidden"))
 		return
 	}
 
-	var req struct {
-		CurrentPassword string `json:"current_password"`
-		NewPassword     string `json:"new_password"`
-	}
+	var req ChangePasswordRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	// Always verify current password (own or other user's password change)
 	var passwordHash string
 	err = h.db.QueryRow("SELECT password_hash FROM users WHERE id = ?", requestingID).Scan(&passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("User not found"))
+		return
+	}
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.CurrentPassword)); err != nil {
-		http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("Current password is incorrect"))
 		return
 	}
 
 	if err := validatePassword(req.NewPassword); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	// Hash new password
 	newPasswordHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	// Update password
 	result, err := h.db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", string(newPasswordHash), targetID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "User not found", http.StatusNotFound)
+		apierror.Write(w, r, apierror.NotFound("User not found"))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"status": "Password changed",
+	respondJSON(w, http.StatusOK, StatusResponse{
+		Status: "Password changed",
 	})
 }
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"sunspear/api/apierror"
 	"sunspear/services"
 )
 
@@ -25,7 +26,7 @@ func (h *SystemHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
 func (h *SystemHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
 	info, err := h.dockerService.GetSystemInfo(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -35,7 +36,7 @@ func (h *SystemHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
 func (h *SystemHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
 	version, err := h.dockerService.GetVersion(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
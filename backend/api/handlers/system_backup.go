@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sunspear/api/apierror"
+	"sunspear/services"
+	"time"
+)
+
+type SystemBackupHandler struct {
+	systemBackupService *services.SystemBackupService
+}
+
+func NewSystemBackupHandler(systemBackupService *services.SystemBackupService) *SystemBackupHandler {
+	return &SystemBackupHandler{systemBackupService: systemBackupService}
+}
+
+// Backup downloads a consistent archive of Sunspear's own state
+func (h *SystemBackupHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	archive, err := h.systemBackupService.Backup(r.Context())
+	if err != nil {
+		systemBackupError(w, r, err)
+		return
+	}
+	defer archive.Close()
+
+	f, err := os.Open(archive.Path)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	// Large databases can take longer than the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archive.Name))
+	http.ServeContent(w, r, archive.Name, archive.Manifest.CreatedAt, f)
+}
+
+// Restore replaces Sunspear's state with an uploaded archive from Backup,
+// sent as the raw body or the "archive" field of a multipart form. With
+// ?redeploy=true every compose project and installed app is recreated. The
+// backend then restarts, as running services still hold the old state.
+func (h *SystemBackupHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	// Uploads of large archives outlast the server's read timeout
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	var archive io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		part, err := multipartFile(r, "archive")
+		if err != nil {
+			apierror.Write(w, r, apierror.Invalid(err))
+			return
+		}
+		defer part.Close()
+		archive = part
+	}
+
+	opts := services.RestoreOptions{
+		Redeploy:          r.URL.Query().Get("redeploy") == "true",
+		AcceptKeyMismatch: r.URL.Query().Get("acceptKeyMismatch") == "true",
+	}
+	result, err := h.systemBackupService.Restore(r.Context(), archive, opts)
+	if err != nil {
+		systemBackupError(w, r, err)
+		return
+	}
+
+	// Shutdown waits for this response to be written
+	result.Restarting = h.systemBackupService.Restart()
+	respondJSON(w, http.StatusOK, result)
+}
+
+func systemBackupError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrSystemBackupInProgress), errors.Is(err, services.ErrSecretsKeyMismatch):
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+	case errors.Is(err, services.ErrInvalidSystemBackup):
+		apierror.Write(w, r, apierror.Invalid(err))
+	default:
+		apierror.Write(w, r, err)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sunspear/api/apierror"
+	"sunspear/services"
+)
+
+type TaskHandler struct {
+	taskService *services.TaskService
+}
+
+func NewTaskHandler(taskService *services.TaskService) *TaskHandler {
+	return &TaskHandler{taskService: taskService}
+}
+
+func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := h.taskService.ListTasks()
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list tasks"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tasks)
+}
+
+func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := h.taskService.GetTask(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Task not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, task)
+}
+
+func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
+	task := services.ScheduledTask{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	created, err := h.taskService.CreateTask(task)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to create task"))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, created)
+}
+
+func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var task services.ScheduledTask
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	updated, err := h.taskService.UpdateTask(id, task)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Task not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to update task"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, updated)
+}
+
+func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.taskService.DeleteTask(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Task not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to delete task"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MessageResponse{Message: "Task deleted"})
+}
+
+// RunTask starts a task immediately, subject to its concurrency policy. The
+// run happens in the background; poll the runs endpoint for its outcome.
+func (h *TaskHandler) RunTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	runID, err := h.taskService.StartRun(id, "manual")
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		apierror.Write(w, r, apierror.NotFound("Task not found"))
+		return
+	case errors.Is(err, services.ErrTaskRunning):
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+		return
+	case err != nil:
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to start task"))
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, RunStartedResponse{RunID: runID})
+}
+
+func (h *TaskHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	runs, err := h.taskService.ListRuns(id, limit)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list task runs"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, runs)
+}
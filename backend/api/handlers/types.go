@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"sunspear/services"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// Request and response bodies of the API. Endpoints that pass a service or
+// Docker type straight through use that type instead.
+
+// StatusResponse acknowledges an action
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+// MessageResponse acknowledges a deletion
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// RunStartedResponse identifies a run started in the background
+type RunStartedResponse struct {
+	RunID int `json:"runId"`
+}
+
+// ConnectionTestResponse reports whether a remote service accepted the
+// stored credentials
+type ConnectionTestResponse struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+// LoginRequest authenticates a user. Mode "cookie" starts a browser session
+// instead of returning a bearer token.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Mode     string `json:"mode,omitempty"`
+}
+
+// LoginResponse carries a bearer token, or the CSRF token of a cookie session
+type LoginResponse struct {
+	Token     string `json:"token,omitempty"`
+	Mode      string `json:"mode,omitempty"`
+	CSRFToken string `json:"csrfToken,omitempty"`
+}
+
+// SetupRequest creates the first user
+type SetupRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type SetupStatusResponse struct {
+	NeedsSetup bool `json:"needs_setup"`
+}
+
+type VerifyResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// User is an account without its password hash
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// Settings maps setting keys to values
+type Settings map[string]string
+
+// CreateContainerRequest creates a container. Ports map container ports
+// (e.g. "80" or "53/udp") to host ports and Volumes map container paths to
+// host paths.
+type CreateContainerRequest struct {
+	Image         string            `json:"image"`
+	Name          string            `json:"name"`
+	Ports         map[string]string `json:"ports"`
+	Volumes       map[string]string `json:"volumes"`
+	Env           []string          `json:"env"`
+	RestartPolicy string            `json:"restartPolicy"`
+}
+
+type RenameContainerRequest struct {
+	Name string `json:"name"`
+}
+
+type RenameContainerResponse struct {
+	Status string `json:"status"`
+	Name   string `json:"name"`
+}
+
+// CommitContainerRequest snapshots a container. Changes are Dockerfile
+// instructions; Pause defaults to true.
+type CommitContainerRequest struct {
+	Reference string   `json:"reference"`
+	Author    string   `json:"author"`
+	Message   string   `json:"message"`
+	Changes   []string `json:"changes"`
+	Pause     *bool    `json:"pause"`
+}
+
+type CommitContainerResponse struct {
+	ID        string `json:"id"`
+	Reference string `json:"reference"`
+}
+
+type BulkStopResponse struct {
+	Stopped int      `json:"stopped"`
+	Total   int      `json:"total"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type BulkRestartResponse struct {
+	Restarted int      `json:"restarted"`
+	Total     int      `json:"total"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+type PullImageRequest struct {
+	Image string `json:"image"`
+}
+
+// TagImageRequest adds a tag; Tag defaults to "latest"
+type TagImageRequest struct {
+	Repo string `json:"repo"`
+	Tag  string `json:"tag"`
+}
+
+type TagImageResponse struct {
+	Status string `json:"status"`
+	Tag    string `json:"tag"`
+}
+
+type PruneImagesResponse struct {
+	Deleted        []image.DeleteResponse `json:"deleted"`
+	SpaceReclaimed uint64                 `json:"spaceReclaimed"`
+}
+
+// CreateNetworkRequest creates a network; Driver defaults to "bridge"
+type CreateNetworkRequest struct {
+	Name     string `json:"name"`
+	Driver   string `json:"driver"`
+	Internal bool   `json:"internal"`
+}
+
+// NetworkContainerRequest names the container to connect or disconnect
+type NetworkContainerRequest struct {
+	ContainerID string `json:"containerId"`
+}
+
+type PruneNetworksResponse struct {
+	Deleted []string `json:"deleted"`
+}
+
+// CreateVolumeRequest creates a volume; Driver defaults to "local"
+type CreateVolumeRequest struct {
+	Name   string            `json:"name"`
+	Driver string            `json:"driver"`
+	Labels map[string]string `json:"labels"`
+}
+
+type PruneVolumesResponse struct {
+	Deleted        []string `json:"deleted"`
+	SpaceReclaimed uint64   `json:"spaceReclaimed"`
+}
+
+type CreateVolumeDirectoryRequest struct {
+	Path string `json:"path"`
+}
+
+type DeployProjectRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	YAML        string `json:"yaml"`
+}
+
+type ValidateComposeRequest struct {
+	YAML string `json:"yaml"`
+}
+
+type ValidateComposeResponse struct {
+	Valid    bool     `json:"valid"`
+	Services []string `json:"services"`
+	Version  string   `json:"version"`
+}
+
+// InstallAppRequest installs a marketplace app. Ports map the catalog's port
+// labels to host ports and Volumes map container paths to host paths.
+type InstallAppRequest struct {
+	Name    string             `json:"name"`
+	Env     []InstallAppEnvVar `json:"env"`
+	Ports   map[string]string  `json:"ports"`
+	Volumes map[string]string  `json:"volumes"`
+	// Proxy publishes the app through Caddy at a hostname and/or path
+	Proxy *InstallAppProxy `json:"proxy"`
+}
+
+type InstallAppEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// InstallAppProxy defaults Port to the app's only port
+type InstallAppProxy struct {
+	Host string `json:"host"`
+	Path string `json:"path"`
+	Port int    `json:"port"`
+}
+
+type InstallAppResponse struct {
+	ID          int    `json:"id"`
+	AppID       string `json:"appId"`
+	AppName     string `json:"appName"`
+	ContainerID string `json:"containerId"`
+	Status      string `json:"status"`
+}
+
+// BackupTargetRequest creates or updates a backup target
+type BackupTargetRequest struct {
+	Name   string                      `json:"name"`
+	Config services.BackupTargetConfig `json:"config"`
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sunspear/api/apierror"
+	"sunspear/services"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type UpdateHandler struct {
+	autoUpdateService *services.AutoUpdateService
+}
+
+func NewUpdateHandler(autoUpdateService *services.AutoUpdateService) *UpdateHandler {
+	return &UpdateHandler{autoUpdateService: autoUpdateService}
+}
+
+func (h *UpdateHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.autoUpdateService.ListPolicies()
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to list update policies"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, policies)
+}
+
+// SetPolicy creates or replaces the policy for a container or project
+func (h *UpdateHandler) SetPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy services.UpdatePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	saved, err := h.autoUpdateService.SetPolicy(policy)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to save update policy"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, saved)
+}
+
+func (h *UpdateHandler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid policy ID"))
+		return
+	}
+
+	err = h.autoUpdateService.DeletePolicy(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Update policy not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to delete update policy"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MessageResponse{Message: "Update policy deleted"})
+}
+
+// ListContainerPolicies returns the effective policy of every running container
+func (h *UpdateHandler) ListContainerPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.autoUpdateService.Effective(r.Context())
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, policies)
+}
+
+func (h *UpdateHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	entries, err := h.autoUpdateService.ListHistory(limit)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// UpdateContainer pulls and applies an image update immediately, ignoring
+// the container's policy and window
+func (h *UpdateHandler) UpdateContainer(w http.ResponseWriter, r *http.Request) {
+	containerID := mux.Vars(r)["id"]
+
+	// Pulling and waiting for the health check outlasts the write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	entry, err := h.autoUpdateService.UpdateContainer(r.Context(), containerID, "manual")
+	if errors.Is(err, services.ErrUpdateInProgress) {
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+		return
+	}
+	if entry == nil && err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if err != nil {
+		respondJSON(w, http.StatusBadGateway, entry)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entry)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// multipartFile streams the named file field of a multipart request without
+// buffering the upload in memory or on disk.
+func multipartFile(r *http.Request, field string) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing %q file field", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// lazyHeaderWriter defers committing response headers until the first byte
+// of a streamed body, so handlers can still send an error status if the
+// stream fails before producing output.
+type lazyHeaderWriter struct {
+	w            http.ResponseWriter
+	onFirstWrite func()
+	written      bool
+}
+
+func (l *lazyHeaderWriter) Write(p []byte) (int, error) {
+	if !l.written {
+		l.written = true
+		if l.onFirstWrite != nil {
+			l.onFirstWrite()
+		}
+	}
+	return l.w.Write(p)
+}
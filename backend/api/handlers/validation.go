@@ -1,6 +1,11 @@
 package handlers
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sunspear/services"
+)
 
 const minPasswordLength = 8
 
@@ -22,3 +27,42 @@ func validateUsername(username string) error {
 	}
 	return nil
 }
+
+// validateSetting checks values of settings that services interpret.
+func validateSetting(key, value string) error {
+	switch key {
+	case "audit_retention_days", "event_retention_days":
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return fmt.Errorf("%s must be a non-negative number of days", key)
+		}
+	case "image_update_check_hours":
+		hours, err := strconv.Atoi(value)
+		if err != nil || hours < 0 {
+			return fmt.Errorf("%s must be a non-negative number of hours", key)
+		}
+	case "update_policy_default":
+		if err := services.ValidateUpdatePolicy(value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	case "update_health_timeout_seconds":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return fmt.Errorf("%s must be a positive number of seconds", key)
+		}
+	case "log_retention_days", "log_retention_mb":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s must be a non-negative number (0 disables the limit)", key)
+		}
+	case "notification_webhook_url":
+		if value == "" {
+			return nil
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s must be an http or https URL", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sunspear/api/apierror"
+	"sunspear/services"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ListVolumeFiles lists a directory inside a volume (?path=, default "/")
+func (h *VolumeHandler) ListVolumeFiles(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	entries, err := h.volumeFileService.List(r.Context(), name, r.URL.Query().Get("path"))
+	if err != nil {
+		volumeFileError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// DownloadVolumeFile streams a regular file from a volume (?path=)
+func (h *VolumeHandler) DownloadVolumeFile(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	reader, entry, err := h.volumeFileService.Open(r.Context(), name, r.URL.Query().Get("path"))
+	if err != nil {
+		volumeFileError(w, r, err)
+		return
+	}
+	defer reader.Close()
+
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": entry.Name}))
+	w.Header().Set("Last-Modified", entry.ModTime.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Printf("Volume file download %s:%s failed: %v", name, entry.Path, err)
+	}
+}
+
+// UploadVolumeFile creates or replaces a file in a volume (?path=). The
+// content is the raw request body or the "file" field of a multipart form.
+func (h *VolumeHandler) UploadVolumeFile(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	var content io.Reader = r.Body
+	size := r.ContentLength
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		part, err := multipartFile(r, "file")
+		if err != nil {
+			apierror.Write(w, r, apierror.Invalid(err))
+			return
+		}
+		defer part.Close()
+		content = part
+		size = -1
+	}
+
+	entry, err := h.volumeFileService.WriteFile(r.Context(), name, r.URL.Query().Get("path"), content, size)
+	if err != nil {
+		volumeFileError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entry)
+}
+
+// CreateVolumeDirectory creates a directory (and missing parents) in a volume
+func (h *VolumeHandler) CreateVolumeDirectory(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req CreateVolumeDirectoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid request body"))
+		return
+	}
+
+	entry, err := h.volumeFileService.Mkdir(r.Context(), name, req.Path)
+	if err != nil {
+		volumeFileError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, entry)
+}
+
+// DeleteVolumePath removes a file or directory from a volume (?path=).
+// Non-empty directories require recursive=true.
+func (h *VolumeHandler) DeleteVolumePath(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	query := r.URL.Query()
+
+	if err := h.volumeFileService.Delete(r.Context(), name, query.Get("path"), query.Get("recursive") == "true"); err != nil {
+		volumeFileError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MessageResponse{Message: "Path deleted"})
+}
+
+func volumeFileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrVolumeNotFound):
+		apierror.Write(w, r, apierror.NotFound("Volume not found"))
+	case errors.Is(err, services.ErrVolumePathNotFound):
+		apierror.Write(w, r, apierror.NotFound("Path not found"))
+	case errors.Is(err, services.ErrVolumeDirNotEmpty):
+		apierror.Write(w, r, apierror.Conflict("Directory is not empty (use recursive=true)"))
+	case errors.Is(err, services.ErrVolumePathInvalid):
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid path"))
+	case errors.Is(err, services.ErrVolumePathIsDir):
+		apierror.Write(w, r, apierror.InvalidArgument("Path is a directory"))
+	case errors.Is(err, services.ErrVolumePathNotDir):
+		apierror.Write(w, r, apierror.InvalidArgument("Path is not a directory"))
+	case errors.Is(err, services.ErrVolumePathNotRegular):
+		apierror.Write(w, r, apierror.InvalidArgument("Path is not a regular file"))
+	default:
+		apierror.Write(w, r, err)
+	}
+}
@@ -2,44 +2,84 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sunspear/api/apierror"
 	"sunspear/services"
+	"time"
 
+	"github.com/docker/docker/api/types/volume"
 	"github.com/gorilla/mux"
 )
 
 type VolumeHandler struct {
-	dockerService *services.DockerService
+	dockerService       *services.DockerService
+	volumeBackupService *services.VolumeBackupService
+	volumeFileService   *services.VolumeFileService
 }
 
-func NewVolumeHandler(dockerService *services.DockerService) *VolumeHandler {
-	return &VolumeHandler{dockerService: dockerService}
+func NewVolumeHandler(dockerService *services.DockerService, volumeBackupService *services.VolumeBackupService, volumeFileService *services.VolumeFileService) *VolumeHandler {
+	return &VolumeHandler{
+		dockerService:       dockerService,
+		volumeBackupService: volumeBackupService,
+		volumeFileService:   volumeFileService,
+	}
+}
+
+var volumeSortKeys = map[string]func(a, b *volume.Volume) bool{
+	"name":    func(a, b *volume.Volume) bool { return a.Name < b.Name },
+	"created": func(a, b *volume.Volume) bool { return a.CreatedAt < b.CreatedAt },
+	"driver":  func(a, b *volume.Volume) bool { return a.Driver < b.Driver },
 }
 
+// ListVolumes filters by name, label, driver, dangling and inUse, the
+// latter being the opposite of dangling. Both may be given only if they agree.
 func (h *VolumeHandler) ListVolumes(w http.ResponseWriter, r *http.Request) {
-	volumes, err := h.dockerService.ListVolumes(r.Context())
+	opts, err := parseListOptions(r, "name", "created", "driver")
+	var dangling, inUse *bool
+	if err == nil {
+		dangling, err = boolParam(r, "dangling")
+	}
+	if err == nil {
+		inUse, err = boolParam(r, "inUse")
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+	if dangling != nil && inUse != nil && *dangling == *inUse {
+		apierror.Write(w, r, apierror.InvalidArgument("dangling and inUse contradict each other"))
+		return
+	}
+	args := dockerFilters(r, map[string]string{"name": "name", "label": "label", "driver": "driver", "dangling": "dangling"})
+	if inUse != nil && dangling == nil {
+		args.Add("dangling", strconv.FormatBool(!*inUse))
+	}
+
+	volumes, err := h.dockerService.ListVolumesMatching(r.Context(), args)
+	if err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, volumes)
+	respondJSON(w, http.StatusOK, paginate(w, volumes, opts, volumeSortKeys))
 }
 
 func (h *VolumeHandler) CreateVolume(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name   string            `json:"name"`
-		Driver string            `json:"driver"`
-		Labels map[string]string `json:"labels"`
-	}
+	var req CreateVolumeRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid(err))
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidArgument("name is required"))
 		return
 	}
 
@@ -49,7 +89,7 @@ func (h *VolumeHandler) CreateVolume(w http.ResponseWriter, r *http.Request) {
 
 	volume, err := h.dockerService.CreateVolume(r.Context(), req.Name, req.Driver, req.Labels)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -62,7 +102,7 @@ func (h *VolumeHandler) InspectVolume(w http.ResponseWriter, r *http.Request) {
 
 	volume, err := h.dockerService.InspectVolume(r.Context(), name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -76,24 +116,120 @@ func (h *VolumeHandler) RemoveVolume(w http.ResponseWriter, r *http.Request) {
 	force := r.URL.Query().Get("force") == "true"
 
 	if err := h.dockerService.RemoveVolume(r.Context(), name, force); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "removed"})
 }
 
 func (h *VolumeHandler) PruneVolumes(w http.ResponseWriter, r *http.Request) {
 	report, err := h.dockerService.PruneVolumes(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"deleted":        report.VolumesDeleted,
-		"spaceReclaimed": report.SpaceReclaimed,
+	respondJSON(w, http.StatusOK, PruneVolumesResponse{
+		Deleted:        report.VolumesDeleted,
+		SpaceReclaimed: report.SpaceReclaimed,
+	})
+}
+
+// BackupVolume streams a tar (or tar.gz) archive of the volume contents.
+// The SHA-256 of the archive is sent as the X-Checksum-Sha256 trailer and
+// recorded in the volume's archive history.
+func (h *VolumeHandler) BackupVolume(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	opts := services.VolumeArchiveOptions{
+		StopContainers: r.URL.Query().Get("stop") == "true",
+		Compress:       r.URL.Query().Get("compress") != "none",
+	}
+
+	filename := fmt.Sprintf("%s-%s.tar", name, time.Now().UTC().Format("20060102-150405"))
+	contentType := "application/x-tar"
+	if opts.Compress {
+		filename += ".gz"
+		contentType = "application/gzip"
+	}
+
+	// Large volumes can take longer than the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Trailer", "X-Checksum-Sha256")
+	out := &lazyHeaderWriter{w: w, onFirstWrite: func() {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}}
+
+	record, err := h.volumeBackupService.Backup(r.Context(), name, out, opts)
+	if err != nil {
+		if !out.written {
+			w.Header().Del("Trailer")
+			apierror.Write(w, r, err)
+			return
+		}
+		// Headers are already sent; abort so the client sees a failed
+		// transfer instead of a truncated archive.
+		log.Printf("Volume backup of %s failed mid-stream: %v", name, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	w.Header().Set("X-Checksum-Sha256", record.SHA256)
+}
+
+// RestoreVolume extracts an uploaded tar or tar.gz archive into the volume.
+// The archive may be sent as the raw request body or as the "archive" field
+// of a multipart form; either way it is streamed, not buffered in memory.
+func (h *VolumeHandler) RestoreVolume(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	opts := services.VolumeArchiveOptions{
+		StopContainers: r.URL.Query().Get("stop") == "true",
+		Replace:        r.URL.Query().Get("replace") == "true",
+		ExpectedSHA256: r.URL.Query().Get("sha256"),
+	}
+
+	// Uploads of large archives outlast the server's read timeout
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	var archive io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		part, err := multipartFile(r, "archive")
+		if err != nil {
+			apierror.Write(w, r, apierror.Invalid(err))
+			return
+		}
+		defer part.Close()
+		archive = part
+	}
+
+	record, err := h.volumeBackupService.Restore(r.Context(), name, archive, opts)
+	if errors.Is(err, services.ErrChecksumMismatch) {
+		apierror.Write(w, r, apierror.InvalidArgument(err.Error()))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(err, "Failed to restore volume"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, record)
+}
+
+// ListVolumeArchives returns the backup and restore history of a volume
+func (h *VolumeHandler) ListVolumeArchives(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	records, err := h.volumeBackupService.ListArchives(name)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
 	}
 
-	respondJSON(w, http.StatusOK, response)
+	respondJSON(w, http.StatusOK, records)
 }
@@ -2,30 +2,38 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sunspear/api/apierror"
 	"sunspear/services"
-	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
-	"github.com/docker/docker/pkg/stdcopy"
 )
 
 type WSHandler struct {
 	dockerService  *services.DockerService
 	monitorService *services.MonitoringService
+	logService     *services.ContainerLogService
+	composeService *services.ComposeService
+	eventService   *services.EventService
 	upgrader       websocket.Upgrader
 }
 
-func NewWSHandler(dockerService *services.DockerService, monitorService *services.MonitoringService, allowedOrigins []string) *WSHandler {
+func NewWSHandler(dockerService *services.DockerService, monitorService *services.MonitoringService, logService *services.ContainerLogService, composeService *services.ComposeService, eventService *services.EventService, allowedOrigins []string) *WSHandler {
 	return &WSHandler{
 		dockerService:  dockerService,
 		monitorService: monitorService,
+		logService:     logService,
+		composeService: composeService,
+		eventService:   eventService,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -50,8 +58,16 @@ func NewWSHandler(dockerService *services.DockerService, monitorService *service
 	}
 }
 
-// StreamEvents streams Docker container events over WebSocket
+// StreamEvents streams Docker events over WebSocket. With after, recorded
+// events following that cursor are replayed before live ones; type, action
+// and resource filter both.
 func (h *WSHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	query, err := parseEventQuery(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
@@ -72,43 +88,123 @@ func (h *WSHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	events, errs := h.dockerService.GetEvents(ctx)
+	// Subscribe before replaying so nothing recorded in between is missed
+	live, unsubscribe := h.eventService.Subscribe()
+	defer unsubscribe()
+
+	last := query.After
+	if last > 0 {
+		query.Limit = 1000
+		for {
+			query.After = last
+			missed, err := h.eventService.List(query)
+			if err != nil {
+				errMsg, _ := json.Marshal(map[string]string{"type": "error", "message": errorMessage(r, err, "Failed to read events")})
+				conn.WriteMessage(websocket.TextMessage, errMsg)
+				return
+			}
+			for _, event := range missed {
+				if err := conn.WriteMessage(websocket.TextMessage, eventMessage(event)); err != nil {
+					return
+				}
+				last = event.ID
+			}
+			if len(missed) < query.Limit {
+				break
+			}
+		}
+	}
 
 	for {
 		select {
-		case event := <-events:
-			msg := map[string]interface{}{
-				"type": "container",
-				"data": map[string]interface{}{
-					"action":       event.Action,
-					"containerId":  event.Actor.ID,
-					"containerName": event.Actor.Attributes["name"],
-					"attributes":   event.Actor.Attributes,
-					"time":         event.Time,
-				},
-			}
-			data, _ := json.Marshal(msg)
-			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		case event, ok := <-live:
+			if !ok {
+				// Fell behind or shutting down; the client resumes from its cursor
 				return
 			}
-		case err := <-errs:
-			if err != nil {
-				log.Printf("Docker events error: %v", err)
-				errMsg, _ := json.Marshal(map[string]string{"type": "error", "message": err.Error()})
-				conn.WriteMessage(websocket.TextMessage, errMsg)
+			if event.ID <= last || !query.Matches(event) {
+				continue
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, eventMessage(event)); err != nil {
+				return
 			}
-			return
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-// StreamLogs streams container logs in real-time over WebSocket
+// eventMessage wraps an event for the events socket. Container events also
+// carry containerId and containerName.
+func eventMessage(event services.DockerEvent) []byte {
+	payload := map[string]interface{}{
+		"id":           event.ID,
+		"action":       event.Action,
+		"resourceId":   event.ResourceID,
+		"resourceName": event.ResourceName,
+		"attributes":   event.Attributes,
+		"time":         event.Time,
+	}
+	if event.Type == "container" {
+		payload["containerId"] = event.ResourceID
+		payload["containerName"] = event.ResourceName
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": event.Type,
+		"id":   event.ID,
+		"data": payload,
+	})
+	return data
+}
+
+// StreamLogs streams container logs in real-time over WebSocket. Accepts
+// the same tail, since, until, stream and filter parameters as GetLogs.
 func (h *WSHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	containerID := vars["id"]
+	containerID := mux.Vars(r)["id"]
+
+	opts, err := parseLogOptions(r, "50")
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+	opts.Follow = true
 
+	h.streamLogLines(w, r, false, func(ctx context.Context, emit func(services.LogLine) error) error {
+		return h.logService.Read(ctx, containerID, opts, emit)
+	})
+}
+
+// StreamProjectLogs streams the logs of every service in a compose project,
+// interleaved by timestamp and prefixed with the service name
+func (h *WSHandler) StreamProjectLogs(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("Invalid project ID"))
+		return
+	}
+	project, err := h.composeService.GetProject(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("Project not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	opts, err := parseLogOptions(r, "50")
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid(err))
+		return
+	}
+	opts.Follow = true
+
+	h.streamLogLines(w, r, true, func(ctx context.Context, emit func(services.LogLine) error) error {
+		return h.logService.ReadProject(ctx, project.Name, opts, emit)
+	})
+}
+
+func (h *WSHandler) streamLogLines(w http.ResponseWriter, r *http.Request, withService bool, read func(ctx context.Context, emit func(services.LogLine) error) error) {
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
@@ -129,62 +225,13 @@ func (h *WSHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// Get streaming logs (follow=true)
-	reader, err := h.dockerService.StreamContainerLogs(ctx, containerID)
-	if err != nil {
-		errMsg, _ := json.Marshal(map[string]string{"type": "error", "message": err.Error()})
+	err = read(ctx, func(line services.LogLine) error {
+		return conn.WriteMessage(websocket.TextMessage, logLineMessage(line, withService))
+	})
+	if err != nil && ctx.Err() == nil {
+		errMsg, _ := json.Marshal(map[string]string{"type": "error", "message": errorMessage(r, err, "Failed to read logs")})
 		conn.WriteMessage(websocket.TextMessage, errMsg)
-		return
-	}
-	defer reader.Close()
-
-	containerInfo, err := h.dockerService.GetContainer(ctx, containerID)
-	if err != nil {
-		errMsg, _ := json.Marshal(map[string]string{"type": "error", "message": err.Error()})
-		conn.WriteMessage(websocket.TextMessage, errMsg)
-		return
-	}
-
-	go func() {
-		<-ctx.Done()
-		reader.Close()
-	}()
-
-	sendLogChunk := func(content []byte) error {
-		if len(content) == 0 {
-			return nil
-		}
-		msg := map[string]interface{}{
-			"type": "log",
-			"data": string(content),
-		}
-		data, _ := json.Marshal(msg)
-		return conn.WriteMessage(websocket.TextMessage, data)
 	}
-
-	if containerInfo.Config != nil && containerInfo.Config.Tty {
-		buf := make([]byte, 4096)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				n, err := reader.Read(buf)
-				if n > 0 {
-					if writeErr := sendLogChunk(buf[:n]); writeErr != nil {
-						return
-					}
-				}
-				if err != nil {
-					return
-				}
-			}
-		}
-	}
-
-	var mu sync.Mutex
-	writer := &wsLogWriter{conn: conn, mu: &mu}
-	_, _ = stdcopy.StdCopy(writer, writer, reader)
 }
 
 // StreamMetrics pushes system metrics over WebSocket every 3 seconds
@@ -240,25 +287,3 @@ func (h *WSHandler) StreamMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
-
-type wsLogWriter struct {
-	conn *websocket.Conn
-	mu   *sync.Mutex
-}
-
-func (w *wsLogWriter) Write(p []byte) (int, error) {
-	if len(p) == 0 {
-		return 0, nil
-	}
-	msg := map[string]interface{}{
-		"type": "log",
-		"data": string(p),
-	}
-	data, _ := json.Marshal(msg)
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	if err := w.conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		return 0, err
-	}
-	return len(p), nil
-}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sunspear/services"
+
+	"github.com/gorilla/mux"
+)
+
+// maxAuditBodySize caps how much of a request body is captured for the audit log.
+const maxAuditBodySize = 64 << 10
+
+// maxAuditErrorSize caps how much of an error response is captured.
+const maxAuditErrorSize = 512
+
+// AuditMiddleware records every mutating request (POST, PUT, PATCH, DELETE)
+// handled by the wrapped router. It must run after AuthMiddleware so the
+// user ID is available in the request context.
+func AuditMiddleware(auditService *services.AuditService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isMutatingMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var params string
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") && r.Body != nil {
+				captured, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBodySize))
+				if err == nil {
+					r.Body = struct {
+						io.Reader
+						io.Closer
+					}{io.MultiReader(bytes.NewReader(captured), r.Body), r.Body}
+					params = services.RedactParams(captured)
+				}
+			} else if r.URL.RawQuery != "" {
+				params = services.RedactQuery(r.URL.RawQuery)
+			}
+
+			recorder := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			userID, _ := r.Context().Value(UserIDKey).(int)
+			auditService.Record(services.AuditEntry{
+				UserID:   userID,
+				Action:   AuditAction(r),
+				Resource: strings.TrimPrefix(r.URL.Path, "/api/"),
+				Params:   params,
+				Status:   recorder.status,
+				Error:    strings.TrimSpace(recorder.errBody.String()),
+				ClientIP: ClientIP(r),
+			})
+		})
+	}
+}
+
+// AuditAction derives a stable action name from the matched route template,
+// e.g. "POST /api/containers/{id}/stop" becomes "containers.stop",
+// "POST /api/compose/projects" becomes "compose.projects.create" and
+// "DELETE /api/volumes/{name}" becomes "volumes.delete".
+func AuditAction(r *http.Request) string {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			path = tmpl
+		}
+	}
+
+	var parts []string
+	endsWithVar := false
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/api/"), "/") {
+		if segment == "" {
+			continue
+		}
+		endsWithVar = strings.HasPrefix(segment, "{")
+		if !endsWithVar {
+			parts = append(parts, segment)
+		}
+	}
+	if len(parts) == 0 {
+		return strings.ToLower(r.Method)
+	}
+
+	// Routes ending in a verb ("stop", "prune") already name the action;
+	// collections and items are named after the HTTP method instead.
+	last := parts[len(parts)-1]
+	switch r.Method {
+	case http.MethodPost:
+		if !endsWithVar && strings.HasSuffix(last, "s") {
+			parts = append(parts, "create")
+		}
+	case http.MethodPut, http.MethodPatch:
+		if endsWithVar || len(parts) == 1 {
+			parts = append(parts, "update")
+		}
+	case http.MethodDelete:
+		if endsWithVar {
+			parts = append(parts, "delete")
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// auditResponseWriter captures the status code and the start of any error
+// body while passing the response through unchanged.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	errBody     bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status >= 400 && w.errBody.Len() < maxAuditErrorSize {
+		remaining := maxAuditErrorSize - w.errBody.Len()
+		if len(p) < remaining {
+			remaining = len(p)
+		}
+		w.errBody.Write(p[:remaining])
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// extend deadlines for long uploads and downloads.
+func (w *auditResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush keeps streaming responses (image pulls, builds) working through the wrapper
+func (w *auditResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
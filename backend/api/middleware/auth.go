@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"sunspear/api/apierror"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -16,6 +17,7 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var tokenString string
+			fromCookie := false
 
 			// Get token from Authorization header
 			authHeader := r.Header.Get("Authorization")
@@ -23,16 +25,16 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				// Extract token (Bearer <token>)
 				parts := strings.Fields(authHeader)
 				if len(parts) != 2 || parts[0] != "Bearer" {
-					http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
+					apierror.Write(w, r, apierror.Unauthorized("Invalid authorization header"))
 					return
 				}
 				tokenString = parts[1]
-			} else if strings.HasPrefix(r.URL.Path, "/api/ws/") && r.URL.Query().Get("token") != "" {
-				// WebSocket handshake fallback only.
-				t := r.URL.Query().Get("token")
-				tokenString = t
+			} else if cookie, err := r.Cookie(SessionCookieName); err == nil && cookie.Value != "" {
+				// Cookie session mode (browser clients)
+				tokenString = cookie.Value
+				fromCookie = true
 			} else {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
 				return
 			}
 
@@ -45,12 +47,22 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 			})
 
 			if err != nil || !token.Valid {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				apierror.Write(w, r, apierror.Unauthorized("Invalid token"))
 				return
 			}
 
 			// Extract claims
 			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				// Cookies are sent automatically by browsers, so cookie-authenticated
+				// writes must prove they came from our frontend.
+				if fromCookie {
+					sessionCSRF, _ := claims["csrf"].(string)
+					if !validCSRF(r, sessionCSRF) {
+						apierror.Write(w, r, apierror.Forbidden("Invalid CSRF token"))
+						return
+					}
+				}
+
 				if userID, ok := claims["user_id"].(float64); ok {
 					ctx := context.WithValue(r.Context(), UserIDKey, int(userID))
 					next.ServeHTTP(w, r.WithContext(ctx))
@@ -58,7 +70,7 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				}
 			}
 
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Unauthorized("Invalid token claims"))
 		})
 	}
 }
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+)
+
+const (
+	// SessionCookieName holds the JWT in cookie session mode. It is HttpOnly,
+	// so scripts never see the token.
+	SessionCookieName = "sunspear_session"
+	// CSRFCookieName holds the CSRF token. It is readable by the frontend,
+	// which echoes it back in CSRFHeaderName on mutating requests.
+	CSRFCookieName = "sunspear_csrf"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// NewCSRFToken returns a random token for double-submit CSRF protection.
+func NewCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// validCSRF checks a cookie-authenticated request. Safe methods pass; mutating
+// methods must echo the CSRF cookie in the CSRF header, and both must match
+// the token bound into the session JWT.
+func validCSRF(r *http.Request, sessionCSRF string) bool {
+	if !isMutatingMethod(r.Method) {
+		return true
+	}
+	if sessionCSRF == "" {
+		return false
+	}
+
+	header := r.Header.Get(CSRFHeaderName)
+	cookie, err := r.Cookie(CSRFCookieName)
+	if header == "" || err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(header), []byte(sessionCSRF)) == 1
+}
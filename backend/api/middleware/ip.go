@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sunspear/api/apierror"
+	"sync"
+)
+
+var (
+	trustedProxiesMu sync.RWMutex
+	trustedProxies   []*net.IPNet
+)
+
+// SetTrustedProxies configures which peers may supply the client address via
+// X-Forwarded-For or X-Real-IP. With no trusted proxies, only RemoteAddr is used.
+func SetTrustedProxies(nets []*net.IPNet) {
+	trustedProxiesMu.Lock()
+	defer trustedProxiesMu.Unlock()
+	trustedProxies = nets
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	trustedProxiesMu.RLock()
+	defer trustedProxiesMu.RUnlock()
+	return containsIP(trustedProxies, ip)
+}
+
+// ClientIP returns the address of the client that sent the request. Forwarding
+// headers are only honored when the direct peer is a trusted proxy, and
+// X-Forwarded-For is walked right to left so a client cannot spoof its address
+// by prepending entries.
+func ClientIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && host != "" {
+		peer = host
+	}
+
+	peerIP := net.ParseIP(peer)
+	if peerIP == nil || !isTrustedProxy(peerIP) {
+		return peer
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			if !isTrustedProxy(hop) || i == 0 {
+				return hop.String()
+			}
+		}
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP.String()
+	}
+
+	return peer
+}
+
+var (
+	ipListsMu  sync.RWMutex
+	apiAllow   []*net.IPNet
+	apiDeny    []*net.IPNet
+	adminAllow []*net.IPNet
+	adminDeny  []*net.IPNet
+)
+
+// SetIPLists configures the allow and deny lists checked by APIIPFilter and
+// AdminIPFilter. Deny entries take precedence; an empty allow list allows all.
+func SetIPLists(allow, deny, adminAllowList, adminDenyList []*net.IPNet) {
+	ipListsMu.Lock()
+	defer ipListsMu.Unlock()
+	apiAllow, apiDeny = allow, deny
+	adminAllow, adminDeny = adminAllowList, adminDenyList
+}
+
+func ipAllowed(r *http.Request, admin bool) bool {
+	ipListsMu.RLock()
+	allow, deny := apiAllow, apiDeny
+	if admin {
+		allow, deny = adminAllow, adminDeny
+	}
+	ipListsMu.RUnlock()
+
+	if len(allow) == 0 && len(deny) == 0 {
+		return true
+	}
+	ip := net.ParseIP(ClientIP(r))
+	return ip != nil && !containsIP(deny, ip) && (len(allow) == 0 || containsIP(allow, ip))
+}
+
+// AdminIPFilter rejects requests not permitted by the admin IP lists
+func AdminIPFilter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ipAllowed(r, true) {
+			apierror.Write(w, r, apierror.Forbidden("Forbidden"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// APIIPFilter applies the API IP lists to /api routes only, leaving /health
+// reachable for container health checks.
+func APIIPFilter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") && !ipAllowed(r, false) {
+			apierror.Write(w, r, apierror.Forbidden("Forbidden"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
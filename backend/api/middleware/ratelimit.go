@@ -1,8 +1,8 @@
 package middleware
 
 import (
-	"net"
 	"net/http"
+	"sunspear/api/apierror"
 	"sync"
 	"time"
 )
@@ -23,7 +23,11 @@ func newRateLimiter(limit int, window time.Duration) *rateLimiter {
 	// Cleanup old entries periodically
 	go func() {
 		for {
+			rl.mu.Lock()
+			window := rl.window
+			rl.mu.Unlock()
 			time.Sleep(window)
+
 			rl.mu.Lock()
 			now := time.Now()
 			for ip, times := range rl.attempts {
@@ -69,25 +73,29 @@ func (rl *rateLimiter) allow(ip string) bool {
 	return true
 }
 
-var authLimiter = newRateLimiter(5, time.Minute)
+// setLimit changes the limit and window; recorded attempts are kept
+func (rl *rateLimiter) setLimit(limit int, window time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit = limit
+	rl.window = window
+}
 
-func getClientIP(r *http.Request) string {
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err == nil && host != "" {
-		return host
-	}
+var authLimiter = newRateLimiter(5, time.Minute)
 
-	return r.RemoteAddr
+// SetAuthRateLimit configures how many auth requests one IP may make per window
+func SetAuthRateLimit(limit int, window time.Duration) {
+	authLimiter.setLimit(limit, window)
 }
 
 // RateLimitMiddleware limits requests per IP within a time window.
-// 5 attempts per minute for auth endpoints.
+// Defaults to 5 attempts per minute for auth endpoints.
 func RateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
+		ip := ClientIP(r)
 
 		if !authLimiter.allow(ip) {
-			http.Error(w, "Too many requests. Please try again later.", http.StatusTooManyRequests)
+			apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests. Please try again later."))
 			return
 		}
 
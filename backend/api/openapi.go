@@ -0,0 +1,314 @@
+package api
+
+import (
+	"sunspear/api/handlers"
+	"sunspear/services"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// Operation documents one route for the OpenAPI document and the generated
+// Go client. Request and Response hold a zero value of the JSON body type, a
+// Stream for other media types, or nil for no body.
+type Operation struct {
+	ID       string
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Params   []Param
+	Request  interface{}
+	Response interface{}
+	// Status is the success status, 200 if unset
+	Status int
+	// Public routes need no token; Admin routes also pass the admin IP lists
+	Public    bool
+	Admin     bool
+	WebSocket bool
+}
+
+// Param is a query or header parameter
+type Param struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// Stream is a non-JSON body of the given media type, such as an archive or
+// a progress stream
+type Stream string
+
+func query(name, typ, description string) Param {
+	return Param{Name: name, In: "query", Type: typ, Description: description}
+}
+
+// listParams adds the sort and paging parameters of list endpoints, whose
+// X-Total-Count header holds the number of matches before paging
+func listParams(sortKeys string, params ...Param) []Param {
+	return append(params,
+		query("sort", "string", "Sort by "+sortKeys),
+		query("order", "string", "asc (default) or desc"),
+		query("limit", "integer", "Maximum number of items, 0 for all"),
+		query("offset", "integer", "Items to skip"),
+	)
+}
+
+var (
+	labelParam = query("label", "string", "Label key or key=value (repeatable)")
+	logParams  = []Param{
+		query("tail", "string", `Lines from the end, or "all"`),
+		query("since", "string", "Only lines after this time (RFC 3339, date or Unix seconds)"),
+		query("until", "string", "Only lines before this time"),
+		query("stream", "string", "stdout, stderr or both"),
+		query("filter", "string", "Regular expression matched against each message"),
+	}
+	eventParams = []Param{
+		query("type", "string", "Event type, e.g. container"),
+		query("action", "string", "Event action, e.g. die"),
+		query("resource", "string", "Resource ID or name"),
+		query("from", "string", "Earliest event time"),
+		query("to", "string", "Latest event time; a plain date includes that whole day"),
+		query("limit", "integer", "Maximum number of events"),
+		query("after", "integer", "Only events after this cursor"),
+	}
+	auditParams = []Param{
+		query("action", "string", "Action, e.g. POST /api/containers/{id}/start"),
+		query("user", "string", "User ID or username"),
+		query("from", "string", "Earliest entry time"),
+		query("to", "string", "Latest entry time; a plain date includes that whole day"),
+		query("limit", "integer", "Maximum number of entries"),
+		query("offset", "integer", "Entries to skip"),
+	}
+	limitParam  = query("limit", "integer", "Maximum number of records")
+	forceParam  = query("force", "boolean", "Remove even if in use")
+	formatParam = query("format", "string", "json for a JSON array of lines instead of text")
+	pathParam   = query("path", "string", "Path inside the volume")
+)
+
+// Operations lists every route registered by NewRouter
+var Operations = []Operation{
+	{ID: "Health", Method: "GET", Path: "/health", Tag: "system", Summary: "Liveness check", Response: Stream("text/plain"), Public: true},
+	{ID: "OpenAPI", Method: "GET", Path: "/api/openapi.json", Tag: "system", Summary: "This document", Response: Stream("application/json")},
+
+	{ID: "Login", Method: "POST", Path: "/api/auth/login", Tag: "auth", Summary: "Log in and get a token or session cookie", Request: handlers.LoginRequest{}, Response: handlers.LoginResponse{}, Public: true},
+	{ID: "Setup", Method: "POST", Path: "/api/auth/setup", Tag: "auth", Summary: "Create the first user",
+		Params:  []Param{{Name: "X-Setup-Token", In: "header", Type: "string", Description: "SETUP_BOOTSTRAP_TOKEN", Required: true}},
+		Request: handlers.SetupRequest{}, Response: handlers.StatusResponse{}, Status: 201, Public: true},
+	{ID: "SetupStatus", Method: "GET", Path: "/api/auth/setup/status", Tag: "auth", Summary: "Whether the first user still has to be created", Response: handlers.SetupStatusResponse{}, Public: true},
+	{ID: "Verify", Method: "GET", Path: "/api/auth/verify", Tag: "auth", Summary: "Check the token", Response: handlers.VerifyResponse{}},
+	{ID: "Me", Method: "GET", Path: "/api/auth/me", Tag: "auth", Summary: "Current user", Response: handlers.User{}},
+	{ID: "Logout", Method: "POST", Path: "/api/auth/logout", Tag: "auth", Summary: "Clear the session cookies", Response: handlers.StatusResponse{}},
+
+	{ID: "ListContainers", Method: "GET", Path: "/api/containers", Tag: "containers", Summary: "List containers",
+		Params: listParams("name, created, state or image",
+			query("all", "boolean", "Include stopped containers"),
+			labelParam,
+			query("name", "string", "Name substring"),
+			query("status", "string", "created, running, paused, restarting, exited or dead; implies all"),
+			query("project", "string", "Compose project name"),
+		), Response: []types.Container{}},
+	{ID: "CreateContainer", Method: "POST", Path: "/api/containers", Tag: "containers", Summary: "Create a container", Request: handlers.CreateContainerRequest{}, Response: container.CreateResponse{}, Status: 201},
+	{ID: "BulkStopContainers", Method: "POST", Path: "/api/containers/bulk/stop", Tag: "containers", Summary: "Stop every running container", Response: handlers.BulkStopResponse{}},
+	{ID: "BulkRestartContainers", Method: "POST", Path: "/api/containers/bulk/restart", Tag: "containers", Summary: "Restart every running container", Response: handlers.BulkRestartResponse{}},
+	{ID: "GetContainer", Method: "GET", Path: "/api/containers/{id}", Tag: "containers", Summary: "Inspect a container", Response: types.ContainerJSON{}},
+	{ID: "StartContainer", Method: "POST", Path: "/api/containers/{id}/start", Tag: "containers", Summary: "Start a container", Response: handlers.StatusResponse{}},
+	{ID: "StopContainer", Method: "POST", Path: "/api/containers/{id}/stop", Tag: "containers", Summary: "Stop a container",
+		Params: []Param{query("timeout", "integer", "Seconds to wait before killing")}, Response: handlers.StatusResponse{}},
+	{ID: "RestartContainer", Method: "POST", Path: "/api/containers/{id}/restart", Tag: "containers", Summary: "Restart a container",
+		Params: []Param{query("timeout", "integer", "Seconds to wait before killing")}, Response: handlers.StatusResponse{}},
+	{ID: "RenameContainer", Method: "POST", Path: "/api/containers/{id}/rename", Tag: "containers", Summary: "Rename a container", Request: handlers.RenameContainerRequest{}, Response: handlers.RenameContainerResponse{}},
+	{ID: "CommitContainer", Method: "POST", Path: "/api/containers/{id}/commit", Tag: "containers", Summary: "Snapshot a container into an image", Request: handlers.CommitContainerRequest{}, Response: handlers.CommitContainerResponse{}, Status: 201},
+	{ID: "CloneContainer", Method: "POST", Path: "/api/containers/{id}/clone", Tag: "containers", Summary: "Copy a container under a new name", Request: services.CloneOptions{}, Response: services.CloneResult{}, Status: 201},
+	{ID: "UpdateContainer", Method: "POST", Path: "/api/containers/{id}/update", Tag: "updates", Summary: "Pull the container's image and recreate it if it changed", Response: services.UpdateHistoryEntry{}},
+	{ID: "RemoveContainer", Method: "DELETE", Path: "/api/containers/{id}/remove", Tag: "containers", Summary: "Remove a container", Params: []Param{forceParam}, Response: handlers.StatusResponse{}},
+	{ID: "GetContainerLogs", Method: "GET", Path: "/api/containers/{id}/logs", Tag: "containers", Summary: "Container logs as text",
+		Params: append(logParams[:len(logParams):len(logParams)], formatParam), Response: Stream("text/plain")},
+	{ID: "GetContainerStats", Method: "GET", Path: "/api/containers/{id}/stats", Tag: "containers", Summary: "One resource usage sample", Response: types.StatsJSON{}},
+
+	{ID: "ListImages", Method: "GET", Path: "/api/images", Tag: "images", Summary: "List images",
+		Params: listParams("name, created or size",
+			labelParam,
+			query("name", "string", "Reference, e.g. nginx or nginx:*"),
+			query("dangling", "boolean", "Only untagged images, or only tagged ones"),
+			query("inUse", "boolean", "Only images used by a container, or only unused ones"),
+		), Response: []image.Summary{}},
+	{ID: "PullImage", Method: "POST", Path: "/api/images/pull", Tag: "images", Summary: "Pull an image, streaming Docker progress messages", Request: handlers.PullImageRequest{}, Response: Stream("application/json")},
+	{ID: "BuildImage", Method: "POST", Path: "/api/images/build", Tag: "images", Summary: "Build an image from form fields and an optional context archive", Request: Stream("multipart/form-data"), Response: Stream("application/x-ndjson")},
+	{ID: "ListBuilds", Method: "GET", Path: "/api/images/builds", Tag: "images", Summary: "List image builds", Params: []Param{limitParam}, Response: []services.ImageBuildRecord{}},
+	{ID: "GetBuild", Method: "GET", Path: "/api/images/builds/{id}", Tag: "images", Summary: "Get a build with its log", Response: services.ImageBuildRecord{}},
+	{ID: "DeleteBuild", Method: "DELETE", Path: "/api/images/builds/{id}", Tag: "images", Summary: "Delete a finished build", Response: handlers.MessageResponse{}},
+	{ID: "PruneImages", Method: "POST", Path: "/api/images/prune", Tag: "images", Summary: "Remove dangling images", Response: handlers.PruneImagesResponse{}},
+	{ID: "ImportImage", Method: "POST", Path: "/api/images/import", Tag: "images", Summary: "Load a docker save archive", Request: Stream("application/x-tar"), Response: services.ImageLoadResult{}},
+	{ID: "SearchImages", Method: "GET", Path: "/api/images/search", Tag: "images", Summary: "Search Docker Hub",
+		Params: []Param{{Name: "term", In: "query", Type: "string", Description: "Search term", Required: true}}, Response: []registry.SearchResult{}},
+	{ID: "ListImageUpdates", Method: "GET", Path: "/api/images/updates", Tag: "images", Summary: "Latest registry check for each image in use", Response: []services.ImageUpdate{}},
+	{ID: "CheckImageUpdates", Method: "POST", Path: "/api/images/updates/check", Tag: "images", Summary: "Start a registry check", Response: handlers.StatusResponse{}, Status: 202},
+	{ID: "InspectImage", Method: "GET", Path: "/api/images/{id}", Tag: "images", Summary: "Inspect an image", Response: types.ImageInspect{}},
+	{ID: "TagImage", Method: "POST", Path: "/api/images/{id}/tag", Tag: "images", Summary: "Tag an image", Request: handlers.TagImageRequest{}, Response: handlers.TagImageResponse{}},
+	{ID: "GetImageHistory", Method: "GET", Path: "/api/images/{id}/history", Tag: "images", Summary: "Image layer history", Response: []image.HistoryResponseItem{}},
+	{ID: "ExportImage", Method: "GET", Path: "/api/images/{id}/export", Tag: "images", Summary: "Download a docker save archive",
+		Params: []Param{query("image", "string", "Further images to include (repeatable)"), query("gzip", "boolean", "Compress the archive")}, Response: Stream("application/x-tar")},
+	{ID: "AnalyzeImage", Method: "GET", Path: "/api/images/{id}/analysis", Tag: "images", Summary: "Layer sizes, largest files and wasted space",
+		Params: []Param{query("refresh", "boolean", "Recompute instead of using the cached result")}, Response: services.ImageAnalysis{}},
+	{ID: "GetLayerTree", Method: "GET", Path: "/api/images/{id}/analysis/layers/{layer}", Tag: "images", Summary: "File tree of one layer",
+		Params: []Param{query("path", "string", "Directory to start below"), query("depth", "integer", "Levels to return, 0 for all")}, Response: services.LayerTreeNode{}},
+	{ID: "RemoveImage", Method: "DELETE", Path: "/api/images/{id}/remove", Tag: "images", Summary: "Remove an image", Params: []Param{forceParam}, Response: []image.DeleteResponse{}},
+
+	{ID: "ListRegistryCredentials", Method: "GET", Path: "/api/registries", Tag: "registries", Summary: "List registry credentials", Response: []services.RegistryCredential{}, Admin: true},
+	{ID: "CreateRegistryCredential", Method: "POST", Path: "/api/registries", Tag: "registries", Summary: "Add registry credentials", Request: services.RegistryCredential{}, Response: services.RegistryCredential{}, Status: 201, Admin: true},
+	{ID: "UpdateRegistryCredential", Method: "PUT", Path: "/api/registries/{id}", Tag: "registries", Summary: "Update registry credentials", Request: services.RegistryCredential{}, Response: services.RegistryCredential{}, Admin: true},
+	{ID: "DeleteRegistryCredential", Method: "DELETE", Path: "/api/registries/{id}", Tag: "registries", Summary: "Delete registry credentials", Response: handlers.MessageResponse{}, Admin: true},
+	{ID: "TestRegistryCredential", Method: "POST", Path: "/api/registries/{id}/test", Tag: "registries", Summary: "Log in to the registry with the credentials", Response: handlers.ConnectionTestResponse{}, Admin: true},
+
+	{ID: "ListUpdatePolicies", Method: "GET", Path: "/api/updates/policies", Tag: "updates", Summary: "List automatic update policies", Response: []services.UpdatePolicy{}},
+	{ID: "SetUpdatePolicy", Method: "PUT", Path: "/api/updates/policies", Tag: "updates", Summary: "Create or replace an update policy", Request: services.UpdatePolicy{}, Response: services.UpdatePolicy{}},
+	{ID: "DeleteUpdatePolicy", Method: "DELETE", Path: "/api/updates/policies/{id}", Tag: "updates", Summary: "Delete an update policy", Response: handlers.MessageResponse{}},
+	{ID: "ListContainerUpdatePolicies", Method: "GET", Path: "/api/updates/containers", Tag: "updates", Summary: "Effective update policy of each container", Response: []services.EffectiveUpdatePolicy{}},
+	{ID: "ListUpdateHistory", Method: "GET", Path: "/api/updates/history", Tag: "updates", Summary: "Recent container updates", Params: []Param{limitParam}, Response: []services.UpdateHistoryEntry{}},
+
+	{ID: "SearchLogs", Method: "GET", Path: "/api/logs/search", Tag: "logs", Summary: "Search captured log lines, newest first",
+		Params: []Param{
+			query("q", "string", "Full-text query"),
+			query("container", "string", "Container name or ID"),
+			query("project", "string", "Compose project"),
+			query("stream", "string", "stdout or stderr"),
+			query("from", "string", "Earliest line time"),
+			query("to", "string", "Latest line time; a plain date includes that whole day"),
+			limitParam,
+		}, Response: []services.LogEntry{}},
+	{ID: "GetLogCaptureStatus", Method: "GET", Path: "/api/logs/status", Tag: "logs", Summary: "Log capture status", Response: services.LogCollectorStatus{}},
+	{ID: "ListLogCaptureTargets", Method: "GET", Path: "/api/logs/targets", Tag: "logs", Summary: "List log capture targets", Response: []services.LogCaptureTarget{}},
+	{ID: "AddLogCaptureTarget", Method: "POST", Path: "/api/logs/targets", Tag: "logs", Summary: "Capture the logs of a container or project", Request: services.LogCaptureTarget{}, Response: services.LogCaptureTarget{}, Status: 201},
+	{ID: "DeleteLogCaptureTarget", Method: "DELETE", Path: "/api/logs/targets/{id}", Tag: "logs", Summary: "Stop capturing a target", Response: handlers.MessageResponse{}},
+
+	{ID: "ListEvents", Method: "GET", Path: "/api/events", Tag: "events", Summary: "Recorded Docker events", Params: eventParams, Response: []services.DockerEvent{}},
+
+	{ID: "ListProxyRoutes", Method: "GET", Path: "/api/proxy/routes", Tag: "proxy", Summary: "Reverse-proxy routes and their state in Caddy",
+		Params: []Param{query("app", "string", "Marketplace app ID"), query("project", "string", "Compose project"), query("container", "string", "Container name or ID")}, Response: services.ProxyStatus{}},
+	{ID: "SyncProxyRoutes", Method: "POST", Path: "/api/proxy/sync", Tag: "proxy", Summary: "Push routes to Caddy now", Response: services.ProxyStatus{}},
+
+	{ID: "ListTasks", Method: "GET", Path: "/api/tasks", Tag: "tasks", Summary: "List scheduled tasks", Response: []services.ScheduledTask{}},
+	{ID: "CreateTask", Method: "POST", Path: "/api/tasks", Tag: "tasks", Summary: "Create a scheduled task", Request: services.ScheduledTask{}, Response: services.ScheduledTask{}, Status: 201},
+	{ID: "GetTask", Method: "GET", Path: "/api/tasks/{id}", Tag: "tasks", Summary: "Get a scheduled task", Response: services.ScheduledTask{}},
+	{ID: "UpdateTask", Method: "PUT", Path: "/api/tasks/{id}", Tag: "tasks", Summary: "Update a scheduled task", Request: services.ScheduledTask{}, Response: services.ScheduledTask{}},
+	{ID: "DeleteTask", Method: "DELETE", Path: "/api/tasks/{id}", Tag: "tasks", Summary: "Delete a scheduled task", Response: handlers.MessageResponse{}},
+	{ID: "RunTask", Method: "POST", Path: "/api/tasks/{id}/run", Tag: "tasks", Summary: "Run a task now", Response: handlers.RunStartedResponse{}, Status: 202},
+	{ID: "ListTaskRuns", Method: "GET", Path: "/api/tasks/{id}/runs", Tag: "tasks", Summary: "Recent runs of a task", Params: []Param{limitParam}, Response: []services.TaskRun{}},
+
+	{ID: "GetSystemMetrics", Method: "GET", Path: "/api/system/metrics", Tag: "system", Summary: "Host and container resource usage", Response: services.SystemMetrics{}},
+	{ID: "GetSystemInfo", Method: "GET", Path: "/api/system/info", Tag: "system", Summary: "Docker daemon information", Response: types.Info{}},
+	{ID: "GetSystemVersion", Method: "GET", Path: "/api/system/version", Tag: "system", Summary: "Docker daemon version", Response: types.Version{}},
+	{ID: "BackupSystem", Method: "GET", Path: "/api/system/backup", Tag: "system", Summary: "Download an archive of Sunspear's own state", Response: Stream("application/gzip"), Admin: true},
+	{ID: "RestoreSystem", Method: "POST", Path: "/api/system/restore", Tag: "system", Summary: "Replace Sunspear's state with a backup archive",
+		Params: []Param{
+			query("redeploy", "boolean", "Recreate every compose project and installed app"),
+			query("acceptKeyMismatch", "boolean", "Restore a backup made with a different SECRETS_KEY; stored credentials must then be re-entered"),
+		}, Request: Stream("application/gzip"), Response: services.RestoreResult{}, Admin: true},
+
+	{ID: "ListApps", Method: "GET", Path: "/api/apps", Tag: "apps", Summary: "Marketplace catalog", Response: []services.App{}},
+	{ID: "ListInstalledApps", Method: "GET", Path: "/api/apps/installed", Tag: "apps", Summary: "List installed apps",
+		Params: listParams("name, installed or status",
+			query("name", "string", "Substring of the app name or ID"),
+			query("status", "string", "Status"),
+		), Response: []services.InstalledApp{}},
+	{ID: "GetInstalledApp", Method: "GET", Path: "/api/apps/installed/{id}", Tag: "apps", Summary: "Get an installed app", Response: services.InstalledApp{}},
+	{ID: "GetInstalledAppRoutes", Method: "GET", Path: "/api/apps/installed/{id}/routes", Tag: "apps", Summary: "Proxy routes of an installed app", Response: []services.ProxyRoute{}},
+	{ID: "UninstallApp", Method: "POST", Path: "/api/apps/installed/{id}/uninstall", Tag: "apps", Summary: "Remove an installed app and its containers", Response: handlers.StatusResponse{}},
+	{ID: "GetApp", Method: "GET", Path: "/api/apps/{id}", Tag: "apps", Summary: "Get a catalog app", Response: services.App{}},
+	{ID: "InstallApp", Method: "POST", Path: "/api/apps/{id}/install", Tag: "apps", Summary: "Install a catalog app", Request: handlers.InstallAppRequest{}, Response: handlers.InstallAppResponse{}},
+
+	{ID: "StreamEvents", Method: "GET", Path: "/api/ws/events", Tag: "events", Summary: "Docker events over WebSocket", Params: eventParams, WebSocket: true},
+	{ID: "StreamContainerLogs", Method: "GET", Path: "/api/ws/logs/{id}", Tag: "containers", Summary: "Container logs over WebSocket", Params: logParams, WebSocket: true},
+	{ID: "StreamProjectLogs", Method: "GET", Path: "/api/ws/compose/{id}/logs", Tag: "compose", Summary: "Project logs over WebSocket", Params: logParams, WebSocket: true},
+	{ID: "StreamMetrics", Method: "GET", Path: "/api/ws/metrics", Tag: "system", Summary: "System metrics over WebSocket", WebSocket: true},
+
+	{ID: "ListVolumes", Method: "GET", Path: "/api/volumes", Tag: "volumes", Summary: "List volumes",
+		Params: listParams("name, created or driver",
+			labelParam,
+			query("name", "string", "Name substring"),
+			query("driver", "string", "Volume driver"),
+			query("dangling", "boolean", "Only volumes no container uses, or only used ones"),
+			query("inUse", "boolean", "The opposite of dangling; rejected if both are given and disagree"),
+		), Response: []*volume.Volume{}},
+	{ID: "CreateVolume", Method: "POST", Path: "/api/volumes", Tag: "volumes", Summary: "Create a volume", Request: handlers.CreateVolumeRequest{}, Response: volume.Volume{}, Status: 201},
+	{ID: "PruneVolumes", Method: "POST", Path: "/api/volumes/prune", Tag: "volumes", Summary: "Remove unused volumes", Response: handlers.PruneVolumesResponse{}},
+	{ID: "InspectVolume", Method: "GET", Path: "/api/volumes/{name}", Tag: "volumes", Summary: "Inspect a volume", Response: volume.Volume{}},
+	{ID: "RemoveVolume", Method: "DELETE", Path: "/api/volumes/{name}", Tag: "volumes", Summary: "Remove a volume", Params: []Param{forceParam}, Response: handlers.StatusResponse{}},
+	{ID: "BackupVolume", Method: "POST", Path: "/api/volumes/{name}/backup", Tag: "volumes", Summary: "Download an archive of the volume; the SHA-256 follows in the X-Checksum-Sha256 trailer",
+		Params: []Param{query("stop", "boolean", "Stop containers using the volume meanwhile"), query("compress", "string", "none for a plain tar")}, Response: Stream("application/gzip")},
+	{ID: "RestoreVolume", Method: "POST", Path: "/api/volumes/{name}/restore", Tag: "volumes", Summary: "Extract an archive into the volume",
+		Params: []Param{
+			query("stop", "boolean", "Stop containers using the volume meanwhile"),
+			query("replace", "boolean", "Empty the volume first"),
+			query("sha256", "string", "Expected archive checksum"),
+		}, Request: Stream("application/gzip"), Response: services.VolumeArchiveRecord{}},
+	{ID: "ListVolumeArchives", Method: "GET", Path: "/api/volumes/{name}/archives", Tag: "volumes", Summary: "Backup and restore history of a volume", Response: []services.VolumeArchiveRecord{}},
+	{ID: "ListVolumeFiles", Method: "GET", Path: "/api/volumes/{name}/files", Tag: "volumes", Summary: "List a directory", Params: []Param{pathParam}, Response: []services.VolumeFileEntry{}},
+	{ID: "DeleteVolumePath", Method: "DELETE", Path: "/api/volumes/{name}/files", Tag: "volumes", Summary: "Delete a file or directory",
+		Params: []Param{pathParam, query("recursive", "boolean", "Delete non-empty directories")}, Response: handlers.MessageResponse{}},
+	{ID: "DownloadVolumeFile", Method: "GET", Path: "/api/volumes/{name}/files/content", Tag: "volumes", Summary: "Download a file", Params: []Param{pathParam}, Response: Stream("application/octet-stream")},
+	{ID: "UploadVolumeFile", Method: "PUT", Path: "/api/volumes/{name}/files/content", Tag: "volumes", Summary: "Create or replace a file",
+		Params: []Param{pathParam}, Request: Stream("application/octet-stream"), Response: services.VolumeFileEntry{}},
+	{ID: "CreateVolumeDirectory", Method: "POST", Path: "/api/volumes/{name}/files/mkdir", Tag: "volumes", Summary: "Create a directory", Request: handlers.CreateVolumeDirectoryRequest{}, Response: services.VolumeFileEntry{}, Status: 201},
+
+	{ID: "ListBackupTargets", Method: "GET", Path: "/api/backups/targets", Tag: "backups", Summary: "List backup targets", Response: []services.BackupTargetRecord{}, Admin: true},
+	{ID: "CreateBackupTarget", Method: "POST", Path: "/api/backups/targets", Tag: "backups", Summary: "Create a backup target", Request: handlers.BackupTargetRequest{}, Response: services.BackupTargetRecord{}, Status: 201, Admin: true},
+	{ID: "GetBackupTarget", Method: "GET", Path: "/api/backups/targets/{id}", Tag: "backups", Summary: "Get a backup target", Response: services.BackupTargetRecord{}, Admin: true},
+	{ID: "UpdateBackupTarget", Method: "PUT", Path: "/api/backups/targets/{id}", Tag: "backups", Summary: "Update a backup target", Request: handlers.BackupTargetRequest{}, Response: services.BackupTargetRecord{}, Admin: true},
+	{ID: "DeleteBackupTarget", Method: "DELETE", Path: "/api/backups/targets/{id}", Tag: "backups", Summary: "Delete an unused backup target", Response: handlers.MessageResponse{}, Admin: true},
+	{ID: "TestBackupTarget", Method: "POST", Path: "/api/backups/targets/{id}/test", Tag: "backups", Summary: "Check that the target is writable", Response: handlers.ConnectionTestResponse{}, Admin: true},
+	{ID: "ListBackupPolicies", Method: "GET", Path: "/api/backups/policies", Tag: "backups", Summary: "List backup policies", Response: []services.BackupPolicy{}},
+	{ID: "CreateBackupPolicy", Method: "POST", Path: "/api/backups/policies", Tag: "backups", Summary: "Create a backup policy", Request: services.BackupPolicy{}, Response: services.BackupPolicy{}, Status: 201},
+	{ID: "GetBackupPolicy", Method: "GET", Path: "/api/backups/policies/{id}", Tag: "backups", Summary: "Get a backup policy", Response: services.BackupPolicy{}},
+	{ID: "UpdateBackupPolicy", Method: "PUT", Path: "/api/backups/policies/{id}", Tag: "backups", Summary: "Update a backup policy", Request: services.BackupPolicy{}, Response: services.BackupPolicy{}},
+	{ID: "DeleteBackupPolicy", Method: "DELETE", Path: "/api/backups/policies/{id}", Tag: "backups", Summary: "Delete a backup policy", Response: handlers.MessageResponse{}},
+	{ID: "RunBackupPolicy", Method: "POST", Path: "/api/backups/policies/{id}/run", Tag: "backups", Summary: "Run a backup policy now", Response: handlers.RunStartedResponse{}, Status: 202},
+	{ID: "ListBackupRuns", Method: "GET", Path: "/api/backups/policies/{id}/runs", Tag: "backups", Summary: "Recent runs of a policy", Params: []Param{limitParam}, Response: []services.BackupRun{}},
+
+	{ID: "ListNetworks", Method: "GET", Path: "/api/networks", Tag: "networks", Summary: "List networks",
+		Params: listParams("name, created or driver",
+			labelParam,
+			query("name", "string", "Name substring"),
+			query("driver", "string", "Network driver, e.g. bridge"),
+		), Response: []types.NetworkResource{}},
+	{ID: "CreateNetwork", Method: "POST", Path: "/api/networks", Tag: "networks", Summary: "Create a network", Request: handlers.CreateNetworkRequest{}, Response: types.NetworkCreateResponse{}, Status: 201},
+	{ID: "PruneNetworks", Method: "POST", Path: "/api/networks/prune", Tag: "networks", Summary: "Remove unused networks", Response: handlers.PruneNetworksResponse{}},
+	{ID: "InspectNetwork", Method: "GET", Path: "/api/networks/{id}", Tag: "networks", Summary: "Inspect a network", Response: types.NetworkResource{}},
+	{ID: "RemoveNetwork", Method: "DELETE", Path: "/api/networks/{id}", Tag: "networks", Summary: "Remove a network", Response: handlers.StatusResponse{}},
+	{ID: "ConnectNetwork", Method: "POST", Path: "/api/networks/{id}/connect", Tag: "networks", Summary: "Connect a container", Request: handlers.NetworkContainerRequest{}, Response: handlers.StatusResponse{}},
+	{ID: "DisconnectNetwork", Method: "POST", Path: "/api/networks/{id}/disconnect", Tag: "networks", Summary: "Disconnect a container", Request: handlers.NetworkContainerRequest{}, Response: handlers.StatusResponse{}},
+
+	{ID: "ListProjects", Method: "GET", Path: "/api/compose/projects", Tag: "compose", Summary: "List compose projects",
+		Params: listParams("name, created or status",
+			query("name", "string", "Name substring"),
+			query("status", "string", "Status"),
+		), Response: []services.ComposeProject{}},
+	{ID: "DeployProject", Method: "POST", Path: "/api/compose/projects", Tag: "compose", Summary: "Deploy a compose file as a project", Request: handlers.DeployProjectRequest{}, Response: services.ComposeProject{}, Status: 201},
+	{ID: "ValidateCompose", Method: "POST", Path: "/api/compose/validate", Tag: "compose", Summary: "Parse a compose file", Request: handlers.ValidateComposeRequest{}, Response: handlers.ValidateComposeResponse{}},
+	{ID: "ListStackTemplates", Method: "GET", Path: "/api/compose/templates", Tag: "compose", Summary: "List stack templates", Response: []services.StackTemplate{}},
+	{ID: "GetStackTemplate", Method: "GET", Path: "/api/compose/templates/{name}", Tag: "compose", Summary: "Get a stack template", Response: services.StackTemplate{}},
+	{ID: "GetProject", Method: "GET", Path: "/api/compose/projects/{id}", Tag: "compose", Summary: "Get a compose project", Response: services.ComposeProject{}},
+	{ID: "DeleteProject", Method: "DELETE", Path: "/api/compose/projects/{id}", Tag: "compose", Summary: "Remove a project and its resources", Response: handlers.StatusResponse{}},
+	{ID: "GetProjectLogs", Method: "GET", Path: "/api/compose/projects/{id}/logs", Tag: "compose", Summary: "Logs of every service, prefixed with the service name",
+		Params: append(logParams[:len(logParams):len(logParams)], formatParam), Response: Stream("text/plain")},
+	{ID: "StartProject", Method: "POST", Path: "/api/compose/projects/{id}/start", Tag: "compose", Summary: "Start a project", Response: handlers.StatusResponse{}},
+	{ID: "StopProject", Method: "POST", Path: "/api/compose/projects/{id}/stop", Tag: "compose", Summary: "Stop a project", Response: handlers.StatusResponse{}},
+	{ID: "RestartProject", Method: "POST", Path: "/api/compose/projects/{id}/restart", Tag: "compose", Summary: "Restart a project", Response: handlers.StatusResponse{}},
+
+	{ID: "GetSettings", Method: "GET", Path: "/api/settings", Tag: "settings", Summary: "All settings", Response: handlers.Settings{}, Admin: true},
+	{ID: "UpdateSettings", Method: "PUT", Path: "/api/settings", Tag: "settings", Summary: "Set several settings", Request: handlers.Settings{}, Response: handlers.StatusResponse{}, Admin: true},
+	{ID: "ListUsers", Method: "GET", Path: "/api/users", Tag: "users", Summary: "List users", Response: []handlers.User{}, Admin: true},
+	{ID: "CreateUser", Method: "POST", Path: "/api/users", Tag: "users", Summary: "Create a user", Request: handlers.CreateUserRequest{}, Response: handlers.User{}, Status: 201, Admin: true},
+	{ID: "DeleteUser", Method: "DELETE", Path: "/api/users/{id}", Tag: "users", Summary: "Delete a user other than the last", Response: handlers.StatusResponse{}, Admin: true},
+	{ID: "ChangePassword", Method: "PUT", Path: "/api/users/{id}/password", Tag: "users", Summary: "Change your own password", Request: handlers.ChangePasswordRequest{}, Response: handlers.StatusResponse{}},
+
+	{ID: "ListAudit", Method: "GET", Path: "/api/audit", Tag: "audit", Summary: "Audit log entries, newest first", Params: auditParams, Response: []services.AuditEntry{}, Admin: true},
+	{ID: "ExportAudit", Method: "GET", Path: "/api/audit/export", Tag: "audit", Summary: "Download audit log entries",
+		Params: append(auditParams[:len(auditParams):len(auditParams)], query("format", "string", "csv (default) or json")), Response: Stream("text/csv"), Admin: true},
+}
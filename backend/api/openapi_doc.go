@@ -0,0 +1,292 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sunspear/api/apierror"
+	"sunspear/config"
+	"sync"
+	"time"
+)
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+)
+
+// serveOpenAPI serves the OpenAPI 3 document of the API
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIJSON, _ = json.MarshalIndent(OpenAPIDocument(), "", "  ")
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIJSON)
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// PathParams returns the names of the path parameters of a route template
+func PathParams(path string) []string {
+	var names []string
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// OpenAPIDocument describes Operations as an OpenAPI 3 document. Schemas
+// are derived from the Go types by their JSON encoding.
+func OpenAPIDocument() map[string]interface{} {
+	gen := &schemaGenerator{schemas: map[string]interface{}{}, names: map[reflect.Type]string{}}
+	paths := map[string]map[string]interface{}{}
+
+	for _, op := range Operations {
+		params := []interface{}{}
+		for _, name := range PathParams(op.Path) {
+			params = append(params, map[string]interface{}{
+				"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, p := range op.Params {
+			param := map[string]interface{}{"name": p.Name, "in": p.In, "schema": map[string]interface{}{"type": p.Type}}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			if p.Required {
+				param["required"] = true
+			}
+			params = append(params, param)
+		}
+
+		operation := map[string]interface{}{
+			"operationId": op.ID,
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  gen.content(op.Request),
+			}
+		}
+
+		responses := map[string]interface{}{
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     gen.content(apierror.Error{}),
+			},
+		}
+		switch {
+		case op.WebSocket:
+			responses["101"] = map[string]interface{}{"description": "Switching to the WebSocket protocol"}
+		case op.Response != nil:
+			responses[statusCode(op)] = map[string]interface{}{"description": "OK", "content": gen.content(op.Response)}
+		default:
+			responses[statusCode(op)] = map[string]interface{}{"description": "OK"}
+		}
+		operation["responses"] = responses
+
+		if op.Public {
+			operation["security"] = []interface{}{}
+		}
+		if op.Admin {
+			operation["x-sunspear-admin"] = true
+		}
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Sunspear API",
+			"version": config.Version,
+		},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+		"paths":    paths,
+		"components": map[string]interface{}{
+			"schemas": gen.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func statusCode(op Operation) string {
+	if op.Status == 0 {
+		return "200"
+	}
+	return strconv.Itoa(op.Status)
+}
+
+// content is the content map of a request or response body
+func (g *schemaGenerator) content(body interface{}) map[string]interface{} {
+	if stream, ok := body.(Stream); ok {
+		schema := map[string]interface{}{"type": "string", "format": "binary"}
+		if strings.HasPrefix(string(stream), "text/") {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{string(stream): map[string]interface{}{"schema": schema}}
+	}
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(body))}}
+}
+
+// schemaGenerator turns Go types into JSON schemas, collecting named
+// struct types as components
+type schemaGenerator struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawJSONType:
+		return map[string]interface{}{}
+	case t.Kind() == reflect.Pointer:
+		return g.schema(t.Elem())
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// Custom encodings have no shape to derive
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + g.component(t)}
+	}
+	// Interfaces and anything else accept any value
+	return map[string]interface{}{}
+}
+
+// component registers a named struct type and returns its component name
+func (g *schemaGenerator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := ComponentName(t)
+	if _, taken := g.schemas[name]; taken {
+		name = schemaNameReplacer.ReplaceAllString(t.PkgPath(), "_") + "." + name
+	}
+	g.names[t] = name
+	g.schemas[name] = nil // reserve the name for recursive types
+	g.schemas[name] = g.object(t)
+	return name
+}
+
+var schemaNameReplacer = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// ComponentName names a Go type in the document: Sunspear's own types by
+// their name, others qualified by package
+func ComponentName(t reflect.Type) string {
+	name := schemaNameReplacer.ReplaceAllString(t.Name(), "_")
+	if strings.HasPrefix(t.PkgPath(), "sunspear/") {
+		return name
+	}
+	return t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:] + "." + name
+}
+
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, field := range JSONFields(t) {
+		properties[field.Name] = g.schema(field.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// JSONField is a struct field as encoding/json sees it
+type JSONField struct {
+	Name      string
+	Type      reflect.Type
+	OmitEmpty bool
+	Index     []int
+}
+
+// JSONFields lists the fields encoding/json writes for a struct type,
+// including those promoted from embedded structs
+func JSONFields(t reflect.Type) []JSONField {
+	var fields []JSONField
+	seen := map[string]bool{}
+	var embedded []reflect.StructField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, f)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		seen[name] = true
+		fields = append(fields, JSONField{Name: name, Type: f.Type, OmitEmpty: strings.Contains(opts, "omitempty"), Index: f.Index})
+	}
+
+	// Promoted fields lose to fields declared at a shallower depth
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		for _, inner := range JSONFields(ft) {
+			if seen[inner.Name] {
+				continue
+			}
+			seen[inner.Name] = true
+			inner.Index = append([]int{f.Index[0]}, inner.Index...)
+			fields = append(fields, inner)
+		}
+	}
+	return fields
+}
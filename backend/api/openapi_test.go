@@ -0,0 +1,68 @@
+package api
+
+import (
+	"strings"
+	"sunspear/config"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestOperationsMatchRouter fails when a route is added to or removed from
+// the router without updating Operations, or the other way round
+func TestOperationsMatchRouter(t *testing.T) {
+	r := newRouter(&config.Config{JWTSecret: "test"}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	routes := map[string]bool{}
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Subrouter prefixes have no methods
+			return nil
+		}
+		for _, method := range methods {
+			if method == "HEAD" {
+				continue
+			}
+			routes[method+" "+path] = len(ancestors) == 0
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]bool{}
+	documented := map[string]bool{}
+	for _, op := range Operations {
+		key := op.Method + " " + op.Path
+		if ids[op.ID] {
+			t.Errorf("duplicate operation ID %s", op.ID)
+		}
+		ids[op.ID] = true
+		if documented[key] {
+			t.Errorf("%s documented twice", key)
+		}
+		documented[key] = true
+
+		public, ok := routes[key]
+		switch {
+		case !ok:
+			t.Errorf("%s (%s) is not routed", key, op.ID)
+		case public != op.Public:
+			t.Errorf("%s: Public is %v but the route is %s", op.ID, op.Public, map[bool]string{true: "public", false: "protected"}[public])
+		}
+		if op.WebSocket != strings.HasPrefix(op.Path, "/api/ws/") {
+			t.Errorf("%s: WebSocket is %v", op.ID, op.WebSocket)
+		}
+	}
+	for key := range routes {
+		if !documented[key] {
+			t.Errorf("%s is routed but missing from Operations", key)
+		}
+	}
+}
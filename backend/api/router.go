@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"net/http"
 	"strings"
+	"sunspear/api/apierror"
 	"sunspear/api/handlers"
 	"sunspear/api/middleware"
 	"sunspear/config"
@@ -20,27 +21,124 @@ func NewRouter(
 	monitorService *services.MonitoringService,
 	marketplaceService *services.MarketplaceService,
 	composeService *services.ComposeService,
+	auditService *services.AuditService,
+	volumeBackupService *services.VolumeBackupService,
+	volumeFileService *services.VolumeFileService,
+	cloneService *services.ContainerCloneService,
+	imageUpdateService *services.ImageUpdateService,
+	autoUpdateService *services.AutoUpdateService,
+	imageBuildService *services.ImageBuildService,
+	imageAnalysisService *services.ImageAnalysisService,
+	registryCredentialService *services.RegistryCredentialService,
+	logCollector *services.LogCollectorService,
+	containerLogService *services.ContainerLogService,
+	eventService *services.EventService,
+	taskService *services.TaskService,
+	proxyService *services.ProxyService,
+	backupService *services.BackupService,
+	systemBackupService *services.SystemBackupService,
 ) http.Handler {
+	r := newRouter(
+		cfg,
+		db,
+		dockerService,
+		monitorService,
+		marketplaceService,
+		composeService,
+		auditService,
+		volumeBackupService,
+		volumeFileService,
+		cloneService,
+		imageUpdateService,
+		autoUpdateService,
+		imageBuildService,
+		imageAnalysisService,
+		registryCredentialService,
+		logCollector,
+		containerLogService,
+		eventService,
+		taskService,
+		proxyService,
+		backupService,
+		systemBackupService,
+	)
+
+	// CORS configuration
+	c := cors.New(cors.Options{
+		AllowedOrigins:   parseAllowedOrigins(cfg.FrontendURL),
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", middleware.CSRFHeaderName},
+		ExposedHeaders:   []string{apierror.RequestIDHeader, handlers.TotalCountHeader},
+		AllowCredentials: true,
+		Debug:            false,
+	})
+
+	// Outside the router so unmatched requests get an ID too
+	return c.Handler(middleware.RequestID(r))
+}
+
+// newRouter registers every route. Operations in openapi.go must be kept in
+// step with it.
+func newRouter(
+	cfg *config.Config,
+	db *sql.DB,
+	dockerService *services.DockerService,
+	monitorService *services.MonitoringService,
+	marketplaceService *services.MarketplaceService,
+	composeService *services.ComposeService,
+	auditService *services.AuditService,
+	volumeBackupService *services.VolumeBackupService,
+	volumeFileService *services.VolumeFileService,
+	cloneService *services.ContainerCloneService,
+	imageUpdateService *services.ImageUpdateService,
+	autoUpdateService *services.AutoUpdateService,
+	imageBuildService *services.ImageBuildService,
+	imageAnalysisService *services.ImageAnalysisService,
+	registryCredentialService *services.RegistryCredentialService,
+	logCollector *services.LogCollectorService,
+	containerLogService *services.ContainerLogService,
+	eventService *services.EventService,
+	taskService *services.TaskService,
+	proxyService *services.ProxyService,
+	backupService *services.BackupService,
+	systemBackupService *services.SystemBackupService,
+) *mux.Router {
 	r := mux.NewRouter()
 	r.Use(middleware.SecurityHeaders)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.NotFound("No such route"))
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed"))
+	})
 
-	// Parse allowed origins for CORS and WebSocket
-	allowedOrigins := strings.Split(cfg.FrontendURL, ",")
-	for i := range allowedOrigins {
-		allowedOrigins[i] = strings.TrimSpace(allowedOrigins[i])
-	}
+	// Client IP resolution, IP allow/deny lists and auth rate limits
+	ApplySettings(cfg.Live())
+	r.Use(middleware.APIIPFilter)
+	admin := middleware.AdminIPFilter
+
+	allowedOrigins := parseAllowedOrigins(cfg.FrontendURL)
 
 	// Initialize handlers
-	containerHandler := handlers.NewContainerHandler(dockerService)
-	imageHandler := handlers.NewImageHandler(dockerService)
+	containerHandler := handlers.NewContainerHandler(dockerService, cloneService, containerLogService)
+	imageHandler := handlers.NewImageHandler(dockerService, imageUpdateService, imageBuildService, imageAnalysisService)
 	systemHandler := handlers.NewSystemHandler(dockerService, monitorService)
-	appHandler := handlers.NewAppHandler(marketplaceService, dockerService)
-	authHandler := handlers.NewAuthHandler(cfg, db)
-	wsHandler := handlers.NewWSHandler(dockerService, monitorService, allowedOrigins)
-	volumeHandler := handlers.NewVolumeHandler(dockerService)
+	appHandler := handlers.NewAppHandler(marketplaceService, dockerService, proxyService)
+	authHandler := handlers.NewAuthHandler(cfg, db, auditService)
+	wsHandler := handlers.NewWSHandler(dockerService, monitorService, containerLogService, composeService, eventService, allowedOrigins)
+	volumeHandler := handlers.NewVolumeHandler(dockerService, volumeBackupService, volumeFileService)
 	networkHandler := handlers.NewNetworkHandler(dockerService)
-	composeHandler := handlers.NewComposeHandler(composeService)
+	composeHandler := handlers.NewComposeHandler(composeService, containerLogService)
 	settingsHandler := handlers.NewSettingsHandler(cfg, db)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	backupHandler := handlers.NewBackupHandler(backupService)
+	updateHandler := handlers.NewUpdateHandler(autoUpdateService)
+	registryHandler := handlers.NewRegistryHandler(registryCredentialService)
+	logHandler := handlers.NewLogHandler(logCollector)
+	eventHandler := handlers.NewEventHandler(eventService)
+	taskHandler := handlers.NewTaskHandler(taskService)
+	proxyHandler := handlers.NewProxyHandler(proxyService)
+	systemBackupHandler := handlers.NewSystemBackupHandler(systemBackupService)
 
 	// Public routes
 	r.HandleFunc("/health", healthCheck).Methods("GET", "HEAD")
@@ -51,6 +149,7 @@ func NewRouter(
 	// Protected routes
 	api := r.PathPrefix("/api").Subrouter()
 	api.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	api.Use(middleware.AuditMiddleware(auditService))
 
 	// Container routes (bulk routes before {id} routes)
 	api.HandleFunc("/containers", containerHandler.ListContainers).Methods("GET")
@@ -62,6 +161,9 @@ func NewRouter(
 	api.HandleFunc("/containers/{id}/stop", containerHandler.StopContainer).Methods("POST")
 	api.HandleFunc("/containers/{id}/restart", containerHandler.RestartContainer).Methods("POST")
 	api.HandleFunc("/containers/{id}/rename", containerHandler.RenameContainer).Methods("POST")
+	api.HandleFunc("/containers/{id}/commit", containerHandler.CommitContainer).Methods("POST")
+	api.HandleFunc("/containers/{id}/clone", containerHandler.CloneContainer).Methods("POST")
+	api.HandleFunc("/containers/{id}/update", updateHandler.UpdateContainer).Methods("POST")
 	api.HandleFunc("/containers/{id}/remove", containerHandler.RemoveContainer).Methods("DELETE")
 	api.HandleFunc("/containers/{id}/logs", containerHandler.GetLogs).Methods("GET")
 	api.HandleFunc("/containers/{id}/stats", containerHandler.GetStats).Methods("GET")
@@ -70,22 +172,71 @@ func NewRouter(
 	api.HandleFunc("/images", imageHandler.ListImages).Methods("GET")
 	api.HandleFunc("/images/pull", imageHandler.PullImage).Methods("POST")
 	api.HandleFunc("/images/build", imageHandler.BuildImage).Methods("POST")
+	api.HandleFunc("/images/builds", imageHandler.ListBuilds).Methods("GET")
+	api.HandleFunc("/images/builds/{id}", imageHandler.GetBuild).Methods("GET")
+	api.HandleFunc("/images/builds/{id}", imageHandler.DeleteBuild).Methods("DELETE")
 	api.HandleFunc("/images/prune", imageHandler.PruneImages).Methods("POST")
+	api.HandleFunc("/images/import", imageHandler.ImportImage).Methods("POST")
 	api.HandleFunc("/images/search", imageHandler.SearchImages).Methods("GET")
+	api.HandleFunc("/images/updates", imageHandler.ListImageUpdates).Methods("GET")
+	api.HandleFunc("/images/updates/check", imageHandler.CheckImageUpdates).Methods("POST")
 	api.HandleFunc("/images/{id}", imageHandler.InspectImage).Methods("GET")
 	api.HandleFunc("/images/{id}/tag", imageHandler.TagImage).Methods("POST")
 	api.HandleFunc("/images/{id}/history", imageHandler.GetImageHistory).Methods("GET")
+	api.HandleFunc("/images/{id}/export", imageHandler.ExportImage).Methods("GET")
+	api.HandleFunc("/images/{id}/analysis", imageHandler.AnalyzeImage).Methods("GET")
+	api.HandleFunc("/images/{id}/analysis/layers/{layer}", imageHandler.GetLayerTree).Methods("GET")
 	api.HandleFunc("/images/{id}/remove", imageHandler.RemoveImage).Methods("DELETE")
 
+	// Registry credential routes (admin)
+	api.Handle("/registries", admin(http.HandlerFunc(registryHandler.ListCredentials))).Methods("GET")
+	api.Handle("/registries", admin(http.HandlerFunc(registryHandler.CreateCredential))).Methods("POST")
+	api.Handle("/registries/{id}", admin(http.HandlerFunc(registryHandler.UpdateCredential))).Methods("PUT")
+	api.Handle("/registries/{id}", admin(http.HandlerFunc(registryHandler.DeleteCredential))).Methods("DELETE")
+	api.Handle("/registries/{id}/test", admin(http.HandlerFunc(registryHandler.TestCredential))).Methods("POST")
+
+	// Automatic update routes
+	api.HandleFunc("/updates/policies", updateHandler.ListPolicies).Methods("GET")
+	api.HandleFunc("/updates/policies", updateHandler.SetPolicy).Methods("PUT")
+	api.HandleFunc("/updates/policies/{id}", updateHandler.DeletePolicy).Methods("DELETE")
+	api.HandleFunc("/updates/containers", updateHandler.ListContainerPolicies).Methods("GET")
+	api.HandleFunc("/updates/history", updateHandler.ListHistory).Methods("GET")
+
+	// Captured log routes
+	api.HandleFunc("/logs/search", logHandler.SearchLogs).Methods("GET")
+	api.HandleFunc("/logs/status", logHandler.GetStatus).Methods("GET")
+	api.HandleFunc("/logs/targets", logHandler.ListTargets).Methods("GET")
+	api.HandleFunc("/logs/targets", logHandler.AddTarget).Methods("POST")
+	api.HandleFunc("/logs/targets/{id}", logHandler.DeleteTarget).Methods("DELETE")
+
+	// Docker event history
+	api.HandleFunc("/events", eventHandler.ListEvents).Methods("GET")
+
+	// Reverse-proxy routes
+	api.HandleFunc("/proxy/routes", proxyHandler.ListRoutes).Methods("GET")
+	api.HandleFunc("/proxy/sync", proxyHandler.SyncRoutes).Methods("POST")
+
+	// Scheduled task routes
+	api.HandleFunc("/tasks", taskHandler.ListTasks).Methods("GET")
+	api.HandleFunc("/tasks", taskHandler.CreateTask).Methods("POST")
+	api.HandleFunc("/tasks/{id}", taskHandler.GetTask).Methods("GET")
+	api.HandleFunc("/tasks/{id}", taskHandler.UpdateTask).Methods("PUT")
+	api.HandleFunc("/tasks/{id}", taskHandler.DeleteTask).Methods("DELETE")
+	api.HandleFunc("/tasks/{id}/run", taskHandler.RunTask).Methods("POST")
+	api.HandleFunc("/tasks/{id}/runs", taskHandler.ListRuns).Methods("GET")
+
 	// System routes
 	api.HandleFunc("/system/metrics", systemHandler.GetMetrics).Methods("GET")
 	api.HandleFunc("/system/info", systemHandler.GetInfo).Methods("GET")
 	api.HandleFunc("/system/version", systemHandler.GetVersion).Methods("GET")
+	api.Handle("/system/backup", admin(http.HandlerFunc(systemBackupHandler.Backup))).Methods("GET")
+	api.Handle("/system/restore", admin(http.HandlerFunc(systemBackupHandler.Restore))).Methods("POST")
 
 	// App marketplace routes
 	api.HandleFunc("/apps", appHandler.ListApps).Methods("GET")
 	api.HandleFunc("/apps/installed", appHandler.ListInstalledApps).Methods("GET")
 	api.HandleFunc("/apps/installed/{id}", appHandler.GetInstalledApp).Methods("GET")
+	api.HandleFunc("/apps/installed/{id}/routes", appHandler.GetInstalledAppRoutes).Methods("GET")
 	api.HandleFunc("/apps/installed/{id}/uninstall", appHandler.UninstallApp).Methods("POST")
 	api.HandleFunc("/apps/{id}", appHandler.GetApp).Methods("GET")
 	api.HandleFunc("/apps/{id}/install", appHandler.InstallApp).Methods("POST")
@@ -93,6 +244,7 @@ func NewRouter(
 	// WebSocket routes
 	api.HandleFunc("/ws/events", wsHandler.StreamEvents).Methods("GET")
 	api.HandleFunc("/ws/logs/{id}", wsHandler.StreamLogs).Methods("GET")
+	api.HandleFunc("/ws/compose/{id}/logs", wsHandler.StreamProjectLogs).Methods("GET")
 	api.HandleFunc("/ws/metrics", wsHandler.StreamMetrics).Methods("GET")
 
 	// Volume routes (static before {name})
@@ -101,6 +253,29 @@ func NewRouter(
 	api.HandleFunc("/volumes/prune", volumeHandler.PruneVolumes).Methods("POST")
 	api.HandleFunc("/volumes/{name}", volumeHandler.InspectVolume).Methods("GET")
 	api.HandleFunc("/volumes/{name}", volumeHandler.RemoveVolume).Methods("DELETE")
+	api.HandleFunc("/volumes/{name}/backup", volumeHandler.BackupVolume).Methods("POST")
+	api.HandleFunc("/volumes/{name}/restore", volumeHandler.RestoreVolume).Methods("POST")
+	api.HandleFunc("/volumes/{name}/archives", volumeHandler.ListVolumeArchives).Methods("GET")
+	api.HandleFunc("/volumes/{name}/files", volumeHandler.ListVolumeFiles).Methods("GET")
+	api.HandleFunc("/volumes/{name}/files", volumeHandler.DeleteVolumePath).Methods("DELETE")
+	api.HandleFunc("/volumes/{name}/files/content", volumeHandler.DownloadVolumeFile).Methods("GET")
+	api.HandleFunc("/volumes/{name}/files/content", volumeHandler.UploadVolumeFile).Methods("PUT")
+	api.HandleFunc("/volumes/{name}/files/mkdir", volumeHandler.CreateVolumeDirectory).Methods("POST")
+
+	// Scheduled backup routes (targets hold credentials, so they are admin-only)
+	api.Handle("/backups/targets", admin(http.HandlerFunc(backupHandler.ListTargets))).Methods("GET")
+	api.Handle("/backups/targets", admin(http.HandlerFunc(backupHandler.CreateTarget))).Methods("POST")
+	api.Handle("/backups/targets/{id}", admin(http.HandlerFunc(backupHandler.GetTarget))).Methods("GET")
+	api.Handle("/backups/targets/{id}", admin(http.HandlerFunc(backupHandler.UpdateTarget))).Methods("PUT")
+	api.Handle("/backups/targets/{id}", admin(http.HandlerFunc(backupHandler.DeleteTarget))).Methods("DELETE")
+	api.Handle("/backups/targets/{id}/test", admin(http.HandlerFunc(backupHandler.TestTarget))).Methods("POST")
+	api.HandleFunc("/backups/policies", backupHandler.ListPolicies).Methods("GET")
+	api.HandleFunc("/backups/policies", backupHandler.CreatePolicy).Methods("POST")
+	api.HandleFunc("/backups/policies/{id}", backupHandler.GetPolicy).Methods("GET")
+	api.HandleFunc("/backups/policies/{id}", backupHandler.UpdatePolicy).Methods("PUT")
+	api.HandleFunc("/backups/policies/{id}", backupHandler.DeletePolicy).Methods("DELETE")
+	api.HandleFunc("/backups/policies/{id}/run", backupHandler.RunPolicy).Methods("POST")
+	api.HandleFunc("/backups/policies/{id}/runs", backupHandler.ListRuns).Methods("GET")
 
 	// Network routes (static before {id})
 	api.HandleFunc("/networks", networkHandler.ListNetworks).Methods("GET")
@@ -119,34 +294,57 @@ func NewRouter(
 	api.HandleFunc("/compose/templates/{name}", composeHandler.GetTemplate).Methods("GET")
 	api.HandleFunc("/compose/projects/{id}", composeHandler.GetProject).Methods("GET")
 	api.HandleFunc("/compose/projects/{id}", composeHandler.DeleteProject).Methods("DELETE")
+	api.HandleFunc("/compose/projects/{id}/logs", composeHandler.GetProjectLogs).Methods("GET")
 	api.HandleFunc("/compose/projects/{id}/start", composeHandler.StartProject).Methods("POST")
 	api.HandleFunc("/compose/projects/{id}/stop", composeHandler.StopProject).Methods("POST")
 	api.HandleFunc("/compose/projects/{id}/restart", composeHandler.RestartProject).Methods("POST")
 
+	// OpenAPI description of these routes
+	api.HandleFunc("/openapi.json", serveOpenAPI).Methods("GET")
+
 	// Auth info routes
 	api.HandleFunc("/auth/verify", authHandler.Verify).Methods("GET")
 	api.HandleFunc("/auth/me", authHandler.Me).Methods("GET")
+	api.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
 
-	// Settings routes
-	api.HandleFunc("/settings", settingsHandler.GetSettings).Methods("GET")
-	api.HandleFunc("/settings", settingsHandler.UpdateSettings).Methods("PUT")
+	// Settings routes (admin)
+	api.Handle("/settings", admin(http.HandlerFunc(settingsHandler.GetSettings))).Methods("GET")
+	api.Handle("/settings", admin(http.HandlerFunc(settingsHandler.UpdateSettings))).Methods("PUT")
 
-	// User management routes
-	api.HandleFunc("/users", settingsHandler.ListUsers).Methods("GET")
-	api.HandleFunc("/users", settingsHandler.CreateUser).Methods("POST")
-	api.HandleFunc("/users/{id}", settingsHandler.DeleteUser).Methods("DELETE")
+	// User management routes (admin, except changing your own password)
+	api.Handle("/users", admin(http.HandlerFunc(settingsHandler.ListUsers))).Methods("GET")
+	api.Handle("/users", admin(http.HandlerFunc(settingsHandler.CreateUser))).Methods("POST")
+	api.Handle("/users/{id}", admin(http.HandlerFunc(settingsHandler.DeleteUser))).Methods("DELETE")
 	api.HandleFunc("/users/{id}/password", settingsHandler.ChangePassword).Methods("PUT")
 
-	// CORS configuration
-	c := cors.New(cors.Options{
-		AllowedOrigins:   allowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-		Debug:            false,
-	})
+	// Audit log routes (admin)
+	api.Handle("/audit", admin(http.HandlerFunc(auditHandler.ListAudit))).Methods("GET")
+	api.Handle("/audit/export", admin(http.HandlerFunc(auditHandler.ExportAudit))).Methods("GET")
+
+	return r
+}
+
+// parseAllowedOrigins splits the comma-separated origins allowed for CORS
+// and WebSocket
+func parseAllowedOrigins(frontendURL string) []string {
+	origins := strings.Split(frontendURL, ",")
+	for i := range origins {
+		origins[i] = strings.TrimSpace(origins[i])
+	}
+	return origins
+}
 
-	return c.Handler(r)
+// ApplySettings installs the reloadable settings enforced by middleware.
+// The lists must have passed config.Validate.
+func ApplySettings(live config.Reloadable) {
+	trustedProxies, _ := config.ParseCIDRs(live.TrustedProxies)
+	middleware.SetTrustedProxies(trustedProxies)
+	ipAllow, _ := config.ParseCIDRs(live.IPAllowList)
+	ipDeny, _ := config.ParseCIDRs(live.IPDenyList)
+	adminIPAllow, _ := config.ParseCIDRs(live.AdminIPAllowList)
+	adminIPDeny, _ := config.ParseCIDRs(live.AdminIPDenyList)
+	middleware.SetIPLists(ipAllow, ipDeny, adminIPAllow, adminIPDeny)
+	middleware.SetAuthRateLimit(live.AuthRateLimit, live.AuthRateWindow)
 }
 
 func healthCheck(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,169 @@
+// Package client is a typed Go client for the Sunspear API.
+//
+//	c := client.New("https://sunspear.example.com", token)
+//	containers, err := c.ListContainers(ctx, url.Values{"all": {"true"}})
+//
+// The methods and the Sunspear types they use are generated from
+// api.Operations, the table the OpenAPI document is built from; run
+// `go generate ./client` after changing it. Operations with a non-JSON
+// response return the *http.Response for the caller to read and close.
+package client
+
+//go:generate go run ./gen -o generated.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client calls one Sunspear server
+type Client struct {
+	server string
+	token  string
+	// HTTP is the client requests are sent with
+	HTTP *http.Client
+}
+
+// New creates a client for server, authenticating with a bearer token from
+// Login. The token may be empty for public operations.
+func New(server, token string) *Client {
+	return &Client{server: strings.TrimSuffix(server, "/"), token: token, HTTP: &http.Client{}}
+}
+
+// SetToken replaces the bearer token, e.g. after Login
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Error is a non-2xx response. Code is one of the API's machine-readable
+// error codes, such as "not_found" or "conflict".
+type Error struct {
+	StatusCode int         `json:"-"`
+	Code       string      `json:"code"`
+	Message    string      `json:"message"`
+	Details    interface{} `json:"details,omitempty"`
+	RequestID  string      `json:"requestId,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%d %s: %s (request %s)", e.StatusCode, e.Code, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// send sends a request and returns the response for 2xx statuses. The
+// caller closes the body.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader, contentType string) (*http.Response, error) {
+	target := c.server + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// responseError reads the JSON error body of a response, falling back to
+// the body as text for errors that did not come from the API
+func responseError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	e := &Error{}
+	if json.Unmarshal(msg, e) != nil || e.Code == "" {
+		e = &Error{Message: strings.TrimSpace(string(msg))}
+	}
+	e.StatusCode = resp.StatusCode
+	return e
+}
+
+// sendJSON sends in as JSON, if non-nil
+func (c *Client) sendJSON(ctx context.Context, method, path string, query url.Values, header http.Header, in interface{}) (*http.Response, error) {
+	if in == nil {
+		return c.send(ctx, method, path, query, header, nil, "")
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(ctx, method, path, query, header, bytes.NewReader(data), "application/json")
+}
+
+// call sends in as JSON, if non-nil, and decodes the JSON response into out
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, header http.Header, in, out interface{}) error {
+	resp, err := c.sendJSON(ctx, method, path, query, header, in)
+	if err != nil {
+		return err
+	}
+	return decode(resp, out)
+}
+
+// upload sends body as is and decodes the JSON response into out
+func (c *Client) upload(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader, contentType string, out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, header, body, contentType)
+	if err != nil {
+		return err
+	}
+	return decode(resp, out)
+}
+
+// decode reads a JSON response into out, if non-nil, and closes it
+func decode(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// dial opens a WebSocket, authenticating with the token
+func (c *Client) dial(ctx context.Context, path string, query url.Values) (*websocket.Conn, error) {
+	u, err := url.Parse(c.server + path)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.RawQuery = query.Encode()
+
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil && resp != nil {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return conn, err
+}
@@ -0,0 +1,331 @@
+// Command gen writes the methods of package client from api.Operations,
+// copying the Sunspear types they use so the client does not depend on the
+// server packages.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sunspear/api"
+	"unicode"
+)
+
+func main() {
+	out := flag.String("o", "generated.go", "output file")
+	flag.Parse()
+
+	src, err := generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// reserved are identifiers the generated methods declare, which package
+// aliases must not shadow
+var reserved = map[string]bool{
+	"c": true, "ctx": true, "query": true, "in": true, "out": true, "err": true,
+	"body": true, "contentType": true, "context": true, "io": true, "http": true,
+	"url": true, "websocket": true, "json": true, "bytes": true, "fmt": true, "strings": true,
+}
+
+type generator struct {
+	// imports maps package paths to their aliases
+	imports map[string]string
+	aliases map[string]string
+	// types maps the names of copied Sunspear types to their definitions
+	types   map[string]reflect.Type
+	pending []reflect.Type
+	methods bytes.Buffer
+}
+
+// generate returns the formatted source of the generated file
+func generate() ([]byte, error) {
+	g := &generator{imports: map[string]string{}, aliases: map[string]string{}, types: map[string]reflect.Type{}}
+
+	for _, op := range api.Operations {
+		if err := g.method(op); err != nil {
+			return nil, fmt.Errorf("%s: %w", op.ID, err)
+		}
+	}
+
+	// Copying a type can queue the types of its fields
+	defs := map[string]string{}
+	for len(g.pending) > 0 {
+		t := g.pending[0]
+		g.pending = g.pending[1:]
+		def, err := g.definition(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.PkgPath(), t.Name(), err)
+		}
+		defs[t.Name()] = def
+	}
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by go run ./gen; DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	// Standard library first
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		si, sj := !strings.Contains(paths[i], "."), !strings.Contains(paths[j], ".")
+		if si != sj {
+			return si
+		}
+		return paths[i] < paths[j]
+	})
+	for i, p := range paths {
+		if i > 0 && !strings.Contains(paths[i-1], ".") && strings.Contains(p, ".") {
+			src.WriteString("\n")
+		}
+		if alias := g.imports[p]; alias != "" && alias != path.Base(p) {
+			fmt.Fprintf(&src, "%s %q\n", alias, p)
+		} else {
+			fmt.Fprintf(&src, "%q\n", p)
+		}
+	}
+	src.WriteString(")\n\n")
+	src.Write(g.methods.Bytes())
+	for _, name := range names {
+		src.WriteString(defs[name])
+	}
+
+	return format.Source(src.Bytes())
+}
+
+// use records an import of a package by its own name
+func (g *generator) use(pkg string) {
+	g.imports[pkg] = ""
+}
+
+func (g *generator) method(op api.Operation) error {
+	var args []string
+	args = append(args, "ctx context.Context")
+	g.use("context")
+
+	// Path parameters become string arguments
+	target := `"` + op.Path + `"`
+	for _, name := range api.PathParams(op.Path) {
+		ident := identifier(name)
+		args = append(args, ident+" string")
+		target = strings.Replace(target, "{"+name+"}", `"+url.PathEscape(`+ident+`)+"`, 1)
+		g.use("net/url")
+	}
+	target = strings.TrimSuffix(strings.ReplaceAll(target, `+""`, ""), `+"`)
+	target = strings.TrimPrefix(target, `""+`)
+
+	query := "nil"
+	header := "nil"
+	var headers []string
+	for _, p := range op.Params {
+		switch p.In {
+		case "query":
+			query = "query"
+		case "header":
+			ident := identifier(p.Name)
+			args = append(args, ident+" string")
+			headers = append(headers, fmt.Sprintf("%q: {%s}", http.CanonicalHeaderKey(p.Name), ident))
+		}
+	}
+	if query == "query" {
+		args = append(args, "query url.Values")
+		g.use("net/url")
+	}
+	if len(headers) > 0 {
+		header = "http.Header{" + strings.Join(headers, ", ") + "}"
+		g.use("net/http")
+	}
+
+	in := "nil"
+	_, streamIn := op.Request.(api.Stream)
+	switch {
+	case streamIn:
+		args = append(args, "body io.Reader", "contentType string")
+		g.use("io")
+	case op.Request != nil:
+		typ, err := g.typeName(reflect.TypeOf(op.Request))
+		if err != nil {
+			return err
+		}
+		args = append(args, "in "+typ)
+		in = "in"
+	}
+
+	fmt.Fprintf(&g.methods, "// %s: %s (%s %s)\n", op.ID, op.Summary, op.Method, op.Path)
+	signature := fmt.Sprintf("func (c *Client) %s(%s)", op.ID, strings.Join(args, ", "))
+	_, streamOut := op.Response.(api.Stream)
+
+	switch {
+	case op.WebSocket:
+		g.use("github.com/gorilla/websocket")
+		fmt.Fprintf(&g.methods, "%s (*websocket.Conn, error) {\nreturn c.dial(ctx, %s, %s)\n}\n\n", signature, target, query)
+	case streamOut:
+		g.use("net/http")
+		call := fmt.Sprintf("c.sendJSON(ctx, %q, %s, %s, %s, %s)", op.Method, target, query, header, in)
+		if streamIn {
+			call = fmt.Sprintf("c.send(ctx, %q, %s, %s, %s, body, contentType)", op.Method, target, query, header)
+		}
+		fmt.Fprintf(&g.methods, "%s (*http.Response, error) {\nreturn %s\n}\n\n", signature, call)
+	case op.Response == nil:
+		if streamIn {
+			fmt.Fprintf(&g.methods, "%s error {\nreturn c.upload(ctx, %q, %s, %s, %s, body, contentType, nil)\n}\n\n",
+				signature, op.Method, target, query, header)
+		} else {
+			fmt.Fprintf(&g.methods, "%s error {\nreturn c.call(ctx, %q, %s, %s, %s, %s, nil)\n}\n\n",
+				signature, op.Method, target, query, header, in)
+		}
+	default:
+		typ, err := g.typeName(reflect.TypeOf(op.Response))
+		if err != nil {
+			return err
+		}
+		call := fmt.Sprintf("c.call(ctx, %q, %s, %s, %s, %s, &out)", op.Method, target, query, header, in)
+		if streamIn {
+			call = fmt.Sprintf("c.upload(ctx, %q, %s, %s, %s, body, contentType, &out)", op.Method, target, query, header)
+		}
+		fmt.Fprintf(&g.methods, "%s (%s, error) {\nvar out %s\nerr := %s\nreturn out, err\n}\n\n", signature, typ, typ, call)
+	}
+	return nil
+}
+
+// identifier turns a parameter name such as "X-Setup-Token" into a Go
+// identifier such as "xSetupToken"
+func identifier(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i, part := range parts {
+		if i == 0 {
+			parts[i] = strings.ToLower(part[:1]) + part[1:]
+		} else {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// typeName spells a type in the generated file
+func (g *generator) typeName(t reflect.Type) (string, error) {
+	if t.Name() == "" {
+		return g.literal(t)
+	}
+	if t.PkgPath() == "" {
+		return t.Name(), nil
+	}
+	if strings.HasPrefix(t.PkgPath(), "sunspear/") {
+		if prev, ok := g.types[t.Name()]; ok && prev != t {
+			return "", fmt.Errorf("%s.%s and %s.%s would both be copied as %s", prev.PkgPath(), prev.Name(), t.PkgPath(), t.Name(), t.Name())
+		}
+		if _, ok := g.types[t.Name()]; !ok {
+			g.types[t.Name()] = t
+			g.pending = append(g.pending, t)
+		}
+		return t.Name(), nil
+	}
+	return g.alias(t.PkgPath()) + "." + t.Name(), nil
+}
+
+// alias imports an external package under a name unique in the file
+func (g *generator) alias(pkg string) string {
+	if alias, ok := g.imports[pkg]; ok && alias != "" {
+		return alias
+	}
+	elems := strings.Split(pkg, "/")
+	name := ""
+	for i := len(elems) - 1; i >= 0; i-- {
+		elem := strings.TrimPrefix(elems[i], "go-")
+		elem = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, elem)
+		name = elem + name
+		if owner, taken := g.aliases[name]; (!taken || owner == pkg) && !reserved[name] {
+			break
+		}
+	}
+	g.aliases[name] = pkg
+	g.imports[pkg] = name
+	return name
+}
+
+// literal spells the structure of a type, ignoring its name
+func (g *generator) literal(t reflect.Type) (string, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, err := g.typeName(t.Elem())
+		return "*" + elem, err
+	case reflect.Slice:
+		elem, err := g.typeName(t.Elem())
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := g.typeName(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), err
+	case reflect.Map:
+		key, err := g.typeName(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.typeName(t.Elem())
+		return "map[" + key + "]" + elem, err
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			return "", fmt.Errorf("non-empty interface %s", t)
+		}
+		return "interface{}", nil
+	case reflect.Struct:
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			typ, err := g.typeName(f.Type)
+			if err != nil {
+				return "", err
+			}
+			if f.Anonymous {
+				b.WriteString(typ)
+			} else {
+				b.WriteString(f.Name + " " + typ)
+			}
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				fmt.Fprintf(&b, " `json:%q`", tag)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("}")
+		return b.String(), nil
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return "", fmt.Errorf("cannot encode %s", t)
+	}
+	// Basic kinds
+	return t.Kind().String(), nil
+}
+
+// definition declares a copied Sunspear type
+func (g *generator) definition(t reflect.Type) (string, error) {
+	underlying, err := g.literal(t)
+	if err != nil {
+		return "", err
+	}
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	return fmt.Sprintf("// %s is %s.%s\ntype %s %s\n\n", t.Name(), pkg, t.Name(), t.Name(), underlying), nil
+}
@@ -0,0 +1,1699 @@
+// Code generated by go run ./gen; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gorilla/websocket"
+)
+
+// Health: Liveness check (GET /health)
+func (c *Client) Health(ctx context.Context) (*http.Response, error) {
+	return c.sendJSON(ctx, "GET", "/health", nil, nil, nil)
+}
+
+// OpenAPI: This document (GET /api/openapi.json)
+func (c *Client) OpenAPI(ctx context.Context) (*http.Response, error) {
+	return c.sendJSON(ctx, "GET", "/api/openapi.json", nil, nil, nil)
+}
+
+// Login: Log in and get a token or session cookie (POST /api/auth/login)
+func (c *Client) Login(ctx context.Context, in LoginRequest) (LoginResponse, error) {
+	var out LoginResponse
+	err := c.call(ctx, "POST", "/api/auth/login", nil, nil, in, &out)
+	return out, err
+}
+
+// Setup: Create the first user (POST /api/auth/setup)
+func (c *Client) Setup(ctx context.Context, xSetupToken string, in SetupRequest) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/auth/setup", nil, http.Header{"X-Setup-Token": {xSetupToken}}, in, &out)
+	return out, err
+}
+
+// SetupStatus: Whether the first user still has to be created (GET /api/auth/setup/status)
+func (c *Client) SetupStatus(ctx context.Context) (SetupStatusResponse, error) {
+	var out SetupStatusResponse
+	err := c.call(ctx, "GET", "/api/auth/setup/status", nil, nil, nil, &out)
+	return out, err
+}
+
+// Verify: Check the token (GET /api/auth/verify)
+func (c *Client) Verify(ctx context.Context) (VerifyResponse, error) {
+	var out VerifyResponse
+	err := c.call(ctx, "GET", "/api/auth/verify", nil, nil, nil, &out)
+	return out, err
+}
+
+// Me: Current user (GET /api/auth/me)
+func (c *Client) Me(ctx context.Context) (User, error) {
+	var out User
+	err := c.call(ctx, "GET", "/api/auth/me", nil, nil, nil, &out)
+	return out, err
+}
+
+// Logout: Clear the session cookies (POST /api/auth/logout)
+func (c *Client) Logout(ctx context.Context) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/auth/logout", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListContainers: List containers (GET /api/containers)
+func (c *Client) ListContainers(ctx context.Context, query url.Values) ([]types.Container, error) {
+	var out []types.Container
+	err := c.call(ctx, "GET", "/api/containers", query, nil, nil, &out)
+	return out, err
+}
+
+// CreateContainer: Create a container (POST /api/containers)
+func (c *Client) CreateContainer(ctx context.Context, in CreateContainerRequest) (container.CreateResponse, error) {
+	var out container.CreateResponse
+	err := c.call(ctx, "POST", "/api/containers", nil, nil, in, &out)
+	return out, err
+}
+
+// BulkStopContainers: Stop every running container (POST /api/containers/bulk/stop)
+func (c *Client) BulkStopContainers(ctx context.Context) (BulkStopResponse, error) {
+	var out BulkStopResponse
+	err := c.call(ctx, "POST", "/api/containers/bulk/stop", nil, nil, nil, &out)
+	return out, err
+}
+
+// BulkRestartContainers: Restart every running container (POST /api/containers/bulk/restart)
+func (c *Client) BulkRestartContainers(ctx context.Context) (BulkRestartResponse, error) {
+	var out BulkRestartResponse
+	err := c.call(ctx, "POST", "/api/containers/bulk/restart", nil, nil, nil, &out)
+	return out, err
+}
+
+// GetContainer: Inspect a container (GET /api/containers/{id})
+func (c *Client) GetContainer(ctx context.Context, id string) (types.ContainerJSON, error) {
+	var out types.ContainerJSON
+	err := c.call(ctx, "GET", "/api/containers/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// StartContainer: Start a container (POST /api/containers/{id}/start)
+func (c *Client) StartContainer(ctx context.Context, id string) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/containers/"+url.PathEscape(id)+"/start", nil, nil, nil, &out)
+	return out, err
+}
+
+// StopContainer: Stop a container (POST /api/containers/{id}/stop)
+func (c *Client) StopContainer(ctx context.Context, id string, query url.Values) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/containers/"+url.PathEscape(id)+"/stop", query, nil, nil, &out)
+	return out, err
+}
+
+// RestartContainer: Restart a container (POST /api/containers/{id}/restart)
+func (c *Client) RestartContainer(ctx context.Context, id string, query url.Values) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/containers/"+url.PathEscape(id)+"/restart", query, nil, nil, &out)
+	return out, err
+}
+
+// RenameContainer: Rename a container (POST /api/containers/{id}/rename)
+func (c *Client) RenameContainer(ctx context.Context, id string, in RenameContainerRequest) (RenameContainerResponse, error) {
+	var out RenameContainerResponse
+	err := c.call(ctx, "POST", "/api/containers/"+url.PathEscape(id)+"/rename", nil, nil, in, &out)
+	return out, err
+}
+
+// CommitContainer: Snapshot a container into an image (POST /api/containers/{id}/commit)
+func (c *Client) CommitContainer(ctx context.Context, id string, in CommitContainerRequest) (CommitContainerResponse, error) {
+	var out CommitContainerResponse
+	err := c.call(ctx, "POST", "/api/containers/"+url.PathEscape(id)+"/commit", nil, nil, in, &out)
+	return out, err
+}
+
+// CloneContainer: Copy a container under a new name (POST /api/containers/{id}/clone)
+func (c *Client) CloneContainer(ctx context.Context, id string, in CloneOptions) (CloneResult, error) {
+	var out CloneResult
+	err := c.call(ctx, "POST", "/api/containers/"+url.PathEscape(id)+"/clone", nil, nil, in, &out)
+	return out, err
+}
+
+// UpdateContainer: Pull the container's image and recreate it if it changed (POST /api/containers/{id}/update)
+func (c *Client) UpdateContainer(ctx context.Context, id string) (UpdateHistoryEntry, error) {
+	var out UpdateHistoryEntry
+	err := c.call(ctx, "POST", "/api/containers/"+url.PathEscape(id)+"/update", nil, nil, nil, &out)
+	return out, err
+}
+
+// RemoveContainer: Remove a container (DELETE /api/containers/{id}/remove)
+func (c *Client) RemoveContainer(ctx context.Context, id string, query url.Values) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "DELETE", "/api/containers/"+url.PathEscape(id)+"/remove", query, nil, nil, &out)
+	return out, err
+}
+
+// GetContainerLogs: Container logs as text (GET /api/containers/{id}/logs)
+func (c *Client) GetContainerLogs(ctx context.Context, id string, query url.Values) (*http.Response, error) {
+	return c.sendJSON(ctx, "GET", "/api/containers/"+url.PathEscape(id)+"/logs", query, nil, nil)
+}
+
+// GetContainerStats: One resource usage sample (GET /api/containers/{id}/stats)
+func (c *Client) GetContainerStats(ctx context.Context, id string) (types.StatsJSON, error) {
+	var out types.StatsJSON
+	err := c.call(ctx, "GET", "/api/containers/"+url.PathEscape(id)+"/stats", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListImages: List images (GET /api/images)
+func (c *Client) ListImages(ctx context.Context, query url.Values) ([]image.Summary, error) {
+	var out []image.Summary
+	err := c.call(ctx, "GET", "/api/images", query, nil, nil, &out)
+	return out, err
+}
+
+// PullImage: Pull an image, streaming Docker progress messages (POST /api/images/pull)
+func (c *Client) PullImage(ctx context.Context, in PullImageRequest) (*http.Response, error) {
+	return c.sendJSON(ctx, "POST", "/api/images/pull", nil, nil, in)
+}
+
+// BuildImage: Build an image from form fields and an optional context archive (POST /api/images/build)
+func (c *Client) BuildImage(ctx context.Context, body io.Reader, contentType string) (*http.Response, error) {
+	return c.send(ctx, "POST", "/api/images/build", nil, nil, body, contentType)
+}
+
+// ListBuilds: List image builds (GET /api/images/builds)
+func (c *Client) ListBuilds(ctx context.Context, query url.Values) ([]ImageBuildRecord, error) {
+	var out []ImageBuildRecord
+	err := c.call(ctx, "GET", "/api/images/builds", query, nil, nil, &out)
+	return out, err
+}
+
+// GetBuild: Get a build with its log (GET /api/images/builds/{id})
+func (c *Client) GetBuild(ctx context.Context, id string) (ImageBuildRecord, error) {
+	var out ImageBuildRecord
+	err := c.call(ctx, "GET", "/api/images/builds/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// DeleteBuild: Delete a finished build (DELETE /api/images/builds/{id})
+func (c *Client) DeleteBuild(ctx context.Context, id string) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.call(ctx, "DELETE", "/api/images/builds/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// PruneImages: Remove dangling images (POST /api/images/prune)
+func (c *Client) PruneImages(ctx context.Context) (PruneImagesResponse, error) {
+	var out PruneImagesResponse
+	err := c.call(ctx, "POST", "/api/images/prune", nil, nil, nil, &out)
+	return out, err
+}
+
+// ImportImage: Load a docker save archive (POST /api/images/import)
+func (c *Client) ImportImage(ctx context.Context, body io.Reader, contentType string) (ImageLoadResult, error) {
+	var out ImageLoadResult
+	err := c.upload(ctx, "POST", "/api/images/import", nil, nil, body, contentType, &out)
+	return out, err
+}
+
+// SearchImages: Search Docker Hub (GET /api/images/search)
+func (c *Client) SearchImages(ctx context.Context, query url.Values) ([]registry.SearchResult, error) {
+	var out []registry.SearchResult
+	err := c.call(ctx, "GET", "/api/images/search", query, nil, nil, &out)
+	return out, err
+}
+
+// ListImageUpdates: Latest registry check for each image in use (GET /api/images/updates)
+func (c *Client) ListImageUpdates(ctx context.Context) ([]ImageUpdate, error) {
+	var out []ImageUpdate
+	err := c.call(ctx, "GET", "/api/images/updates", nil, nil, nil, &out)
+	return out, err
+}
+
+// CheckImageUpdates: Start a registry check (POST /api/images/updates/check)
+func (c *Client) CheckImageUpdates(ctx context.Context) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/images/updates/check", nil, nil, nil, &out)
+	return out, err
+}
+
+// InspectImage: Inspect an image (GET /api/images/{id})
+func (c *Client) InspectImage(ctx context.Context, id string) (types.ImageInspect, error) {
+	var out types.ImageInspect
+	err := c.call(ctx, "GET", "/api/images/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// TagImage: Tag an image (POST /api/images/{id}/tag)
+func (c *Client) TagImage(ctx context.Context, id string, in TagImageRequest) (TagImageResponse, error) {
+	var out TagImageResponse
+	err := c.call(ctx, "POST", "/api/images/"+url.PathEscape(id)+"/tag", nil, nil, in, &out)
+	return out, err
+}
+
+// GetImageHistory: Image layer history (GET /api/images/{id}/history)
+func (c *Client) GetImageHistory(ctx context.Context, id string) ([]image.HistoryResponseItem, error) {
+	var out []image.HistoryResponseItem
+	err := c.call(ctx, "GET", "/api/images/"+url.PathEscape(id)+"/history", nil, nil, nil, &out)
+	return out, err
+}
+
+// ExportImage: Download a docker save archive (GET /api/images/{id}/export)
+func (c *Client) ExportImage(ctx context.Context, id string, query url.Values) (*http.Response, error) {
+	return c.sendJSON(ctx, "GET", "/api/images/"+url.PathEscape(id)+"/export", query, nil, nil)
+}
+
+// AnalyzeImage: Layer sizes, largest files and wasted space (GET /api/images/{id}/analysis)
+func (c *Client) AnalyzeImage(ctx context.Context, id string, query url.Values) (ImageAnalysis, error) {
+	var out ImageAnalysis
+	err := c.call(ctx, "GET", "/api/images/"+url.PathEscape(id)+"/analysis", query, nil, nil, &out)
+	return out, err
+}
+
+// GetLayerTree: File tree of one layer (GET /api/images/{id}/analysis/layers/{layer})
+func (c *Client) GetLayerTree(ctx context.Context, id string, layer string, query url.Values) (LayerTreeNode, error) {
+	var out LayerTreeNode
+	err := c.call(ctx, "GET", "/api/images/"+url.PathEscape(id)+"/analysis/layers/"+url.PathEscape(layer), query, nil, nil, &out)
+	return out, err
+}
+
+// RemoveImage: Remove an image (DELETE /api/images/{id}/remove)
+func (c *Client) RemoveImage(ctx context.Context, id string, query url.Values) ([]image.DeleteResponse, error) {
+	var out []image.DeleteResponse
+	err := c.call(ctx, "DELETE", "/api/images/"+url.PathEscape(id)+"/remove", query, nil, nil, &out)
+	return out, err
+}
+
+// ListRegistryCredentials: List registry credentials (GET /api/registries)
+func (c *Client) ListRegistryCredentials(ctx context.Context) ([]RegistryCredential, error) {
+	var out []RegistryCredential
+	err := c.call(ctx, "GET", "/api/registries", nil, nil, nil, &out)
+	return out, err
+}
+
+// CreateRegistryCredential: Add registry credentials (POST /api/registries)
+func (c *Client) CreateRegistryCredential(ctx context.Context, in RegistryCredential) (RegistryCredential, error) {
+	var out RegistryCredential
+	err := c.call(ctx, "POST", "/api/registries", nil, nil, in, &out)
+	return out, err
+}
+
+// UpdateRegistryCredential: Update registry credentials (PUT /api/registries/{id})
+func (c *Client) UpdateRegistryCredential(ctx context.Context, id string, in RegistryCredential) (RegistryCredential, error) {
+	var out RegistryCredential
+	err := c.call(ctx, "PUT", "/api/registries/"+url.PathEscape(id), nil, nil, in, &out)
+	return out, err
+}
+
+// DeleteRegistryCredential: Delete registry credentials (DELETE /api/registries/{id})
+func (c *Client) DeleteRegistryCredential(ctx context.Context, id string) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.call(ctx, "DELETE", "/api/registries/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// TestRegistryCredential: Log in to the registry with the credentials (POST /api/registries/{id}/test)
+func (c *Client) TestRegistryCredential(ctx context.Context, id string) (ConnectionTestResponse, error) {
+	var out ConnectionTestResponse
+	err := c.call(ctx, "POST", "/api/registries/"+url.PathEscape(id)+"/test", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListUpdatePolicies: List automatic update policies (GET /api/updates/policies)
+func (c *Client) ListUpdatePolicies(ctx context.Context) ([]UpdatePolicy, error) {
+	var out []UpdatePolicy
+	err := c.call(ctx, "GET", "/api/updates/policies", nil, nil, nil, &out)
+	return out, err
+}
+
+// SetUpdatePolicy: Create or replace an update policy (PUT /api/updates/policies)
+func (c *Client) SetUpdatePolicy(ctx context.Context, in UpdatePolicy) (UpdatePolicy, error) {
+	var out UpdatePolicy
+	err := c.call(ctx, "PUT", "/api/updates/policies", nil, nil, in, &out)
+	return out, err
+}
+
+// DeleteUpdatePolicy: Delete an update policy (DELETE /api/updates/policies/{id})
+func (c *Client) DeleteUpdatePolicy(ctx context.Context, id string) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.call(ctx, "DELETE", "/api/updates/policies/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// ListContainerUpdatePolicies: Effective update policy of each container (GET /api/updates/containers)
+func (c *Client) ListContainerUpdatePolicies(ctx context.Context) ([]EffectiveUpdatePolicy, error) {
+	var out []EffectiveUpdatePolicy
+	err := c.call(ctx, "GET", "/api/updates/containers", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListUpdateHistory: Recent container updates (GET /api/updates/history)
+func (c *Client) ListUpdateHistory(ctx context.Context, query url.Values) ([]UpdateHistoryEntry, error) {
+	var out []UpdateHistoryEntry
+	err := c.call(ctx, "GET", "/api/updates/history", query, nil, nil, &out)
+	return out, err
+}
+
+// SearchLogs: Search captured log lines, newest first (GET /api/logs/search)
+func (c *Client) SearchLogs(ctx context.Context, query url.Values) ([]LogEntry, error) {
+	var out []LogEntry
+	err := c.call(ctx, "GET", "/api/logs/search", query, nil, nil, &out)
+	return out, err
+}
+
+// GetLogCaptureStatus: Log capture status (GET /api/logs/status)
+func (c *Client) GetLogCaptureStatus(ctx context.Context) (LogCollectorStatus, error) {
+	var out LogCollectorStatus
+	err := c.call(ctx, "GET", "/api/logs/status", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListLogCaptureTargets: List log capture targets (GET /api/logs/targets)
+func (c *Client) ListLogCaptureTargets(ctx context.Context) ([]LogCaptureTarget, error) {
+	var out []LogCaptureTarget
+	err := c.call(ctx, "GET", "/api/logs/targets", nil, nil, nil, &out)
+	return out, err
+}
+
+// AddLogCaptureTarget: Capture the logs of a container or project (POST /api/logs/targets)
+func (c *Client) AddLogCaptureTarget(ctx context.Context, in LogCaptureTarget) (LogCaptureTarget, error) {
+	var out LogCaptureTarget
+	err := c.call(ctx, "POST", "/api/logs/targets", nil, nil, in, &out)
+	return out, err
+}
+
+// DeleteLogCaptureTarget: Stop capturing a target (DELETE /api/logs/targets/{id})
+func (c *Client) DeleteLogCaptureTarget(ctx context.Context, id string) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.call(ctx, "DELETE", "/api/logs/targets/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// ListEvents: Recorded Docker events (GET /api/events)
+func (c *Client) ListEvents(ctx context.Context, query url.Values) ([]DockerEvent, error) {
+	var out []DockerEvent
+	err := c.call(ctx, "GET", "/api/events", query, nil, nil, &out)
+	return out, err
+}
+
+// ListProxyRoutes: Reverse-proxy routes and their state in Caddy (GET /api/proxy/routes)
+func (c *Client) ListProxyRoutes(ctx context.Context, query url.Values) (ProxyStatus, error) {
+	var out ProxyStatus
+	err := c.call(ctx, "GET", "/api/proxy/routes", query, nil, nil, &out)
+	return out, err
+}
+
+// SyncProxyRoutes: Push routes to Caddy now (POST /api/proxy/sync)
+func (c *Client) SyncProxyRoutes(ctx context.Context) (ProxyStatus, error) {
+	var out ProxyStatus
+	err := c.call(ctx, "POST", "/api/proxy/sync", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListTasks: List scheduled tasks (GET /api/tasks)
+func (c *Client) ListTasks(ctx context.Context) ([]ScheduledTask, error) {
+	var out []ScheduledTask
+	err := c.call(ctx, "GET", "/api/tasks", nil, nil, nil, &out)
+	return out, err
+}
+
+// CreateTask: Create a scheduled task (POST /api/tasks)
+func (c *Client) CreateTask(ctx context.Context, in ScheduledTask) (ScheduledTask, error) {
+	var out ScheduledTask
+	err := c.call(ctx, "POST", "/api/tasks", nil, nil, in, &out)
+	return out, err
+}
+
+// GetTask: Get a scheduled task (GET /api/tasks/{id})
+func (c *Client) GetTask(ctx context.Context, id string) (ScheduledTask, error) {
+	var out ScheduledTask
+	err := c.call(ctx, "GET", "/api/tasks/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// UpdateTask: Update a scheduled task (PUT /api/tasks/{id})
+func (c *Client) UpdateTask(ctx context.Context, id string, in ScheduledTask) (ScheduledTask, error) {
+	var out ScheduledTask
+	err := c.call(ctx, "PUT", "/api/tasks/"+url.PathEscape(id), nil, nil, in, &out)
+	return out, err
+}
+
+// DeleteTask: Delete a scheduled task (DELETE /api/tasks/{id})
+func (c *Client) DeleteTask(ctx context.Context, id string) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.call(ctx, "DELETE", "/api/tasks/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// RunTask: Run a task now (POST /api/tasks/{id}/run)
+func (c *Client) RunTask(ctx context.Context, id string) (RunStartedResponse, error) {
+	var out RunStartedResponse
+	err := c.call(ctx, "POST", "/api/tasks/"+url.PathEscape(id)+"/run", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListTaskRuns: Recent runs of a task (GET /api/tasks/{id}/runs)
+func (c *Client) ListTaskRuns(ctx context.Context, id string, query url.Values) ([]TaskRun, error) {
+	var out []TaskRun
+	err := c.call(ctx, "GET", "/api/tasks/"+url.PathEscape(id)+"/runs", query, nil, nil, &out)
+	return out, err
+}
+
+// GetSystemMetrics: Host and container resource usage (GET /api/system/metrics)
+func (c *Client) GetSystemMetrics(ctx context.Context) (SystemMetrics, error) {
+	var out SystemMetrics
+	err := c.call(ctx, "GET", "/api/system/metrics", nil, nil, nil, &out)
+	return out, err
+}
+
+// GetSystemInfo: Docker daemon information (GET /api/system/info)
+func (c *Client) GetSystemInfo(ctx context.Context) (system.Info, error) {
+	var out system.Info
+	err := c.call(ctx, "GET", "/api/system/info", nil, nil, nil, &out)
+	return out, err
+}
+
+// GetSystemVersion: Docker daemon version (GET /api/system/version)
+func (c *Client) GetSystemVersion(ctx context.Context) (types.Version, error) {
+	var out types.Version
+	err := c.call(ctx, "GET", "/api/system/version", nil, nil, nil, &out)
+	return out, err
+}
+
+// BackupSystem: Download an archive of Sunspear's own state (GET /api/system/backup)
+func (c *Client) BackupSystem(ctx context.Context) (*http.Response, error) {
+	return c.sendJSON(ctx, "GET", "/api/system/backup", nil, nil, nil)
+}
+
+// RestoreSystem: Replace Sunspear's state with a backup archive (POST /api/system/restore)
+func (c *Client) RestoreSystem(ctx context.Context, query url.Values, body io.Reader, contentType string) (RestoreResult, error) {
+	var out RestoreResult
+	err := c.upload(ctx, "POST", "/api/system/restore", query, nil, body, contentType, &out)
+	return out, err
+}
+
+// ListApps: Marketplace catalog (GET /api/apps)
+func (c *Client) ListApps(ctx context.Context) ([]App, error) {
+	var out []App
+	err := c.call(ctx, "GET", "/api/apps", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListInstalledApps: List installed apps (GET /api/apps/installed)
+func (c *Client) ListInstalledApps(ctx context.Context, query url.Values) ([]InstalledApp, error) {
+	var out []InstalledApp
+	err := c.call(ctx, "GET", "/api/apps/installed", query, nil, nil, &out)
+	return out, err
+}
+
+// GetInstalledApp: Get an installed app (GET /api/apps/installed/{id})
+func (c *Client) GetInstalledApp(ctx context.Context, id string) (InstalledApp, error) {
+	var out InstalledApp
+	err := c.call(ctx, "GET", "/api/apps/installed/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// GetInstalledAppRoutes: Proxy routes of an installed app (GET /api/apps/installed/{id}/routes)
+func (c *Client) GetInstalledAppRoutes(ctx context.Context, id string) ([]ProxyRoute, error) {
+	var out []ProxyRoute
+	err := c.call(ctx, "GET", "/api/apps/installed/"+url.PathEscape(id)+"/routes", nil, nil, nil, &out)
+	return out, err
+}
+
+// UninstallApp: Remove an installed app and its containers (POST /api/apps/installed/{id}/uninstall)
+func (c *Client) UninstallApp(ctx context.Context, id string) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/apps/installed/"+url.PathEscape(id)+"/uninstall", nil, nil, nil, &out)
+	return out, err
+}
+
+// GetApp: Get a catalog app (GET /api/apps/{id})
+func (c *Client) GetApp(ctx context.Context, id string) (App, error) {
+	var out App
+	err := c.call(ctx, "GET", "/api/apps/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// InstallApp: Install a catalog app (POST /api/apps/{id}/install)
+func (c *Client) InstallApp(ctx context.Context, id string, in InstallAppRequest) (InstallAppResponse, error) {
+	var out InstallAppResponse
+	err := c.call(ctx, "POST", "/api/apps/"+url.PathEscape(id)+"/install", nil, nil, in, &out)
+	return out, err
+}
+
+// StreamEvents: Docker events over WebSocket (GET /api/ws/events)
+func (c *Client) StreamEvents(ctx context.Context, query url.Values) (*websocket.Conn, error) {
+	return c.dial(ctx, "/api/ws/events", query)
+}
+
+// StreamContainerLogs: Container logs over WebSocket (GET /api/ws/logs/{id})
+func (c *Client) StreamContainerLogs(ctx context.Context, id string, query url.Values) (*websocket.Conn, error) {
+	return c.dial(ctx, "/api/ws/logs/"+url.PathEscape(id), query)
+}
+
+// StreamProjectLogs: Project logs over WebSocket (GET /api/ws/compose/{id}/logs)
+func (c *Client) StreamProjectLogs(ctx context.Context, id string, query url.Values) (*websocket.Conn, error) {
+	return c.dial(ctx, "/api/ws/compose/"+url.PathEscape(id)+"/logs", query)
+}
+
+// StreamMetrics: System metrics over WebSocket (GET /api/ws/metrics)
+func (c *Client) StreamMetrics(ctx context.Context) (*websocket.Conn, error) {
+	return c.dial(ctx, "/api/ws/metrics", nil)
+}
+
+// ListVolumes: List volumes (GET /api/volumes)
+func (c *Client) ListVolumes(ctx context.Context, query url.Values) ([]*volume.Volume, error) {
+	var out []*volume.Volume
+	err := c.call(ctx, "GET", "/api/volumes", query, nil, nil, &out)
+	return out, err
+}
+
+// CreateVolume: Create a volume (POST /api/volumes)
+func (c *Client) CreateVolume(ctx context.Context, in CreateVolumeRequest) (volume.Volume, error) {
+	var out volume.Volume
+	err := c.call(ctx, "POST", "/api/volumes", nil, nil, in, &out)
+	return out, err
+}
+
+// PruneVolumes: Remove unused volumes (POST /api/volumes/prune)
+func (c *Client) PruneVolumes(ctx context.Context) (PruneVolumesResponse, error) {
+	var out PruneVolumesResponse
+	err := c.call(ctx, "POST", "/api/volumes/prune", nil, nil, nil, &out)
+	return out, err
+}
+
+// InspectVolume: Inspect a volume (GET /api/volumes/{name})
+func (c *Client) InspectVolume(ctx context.Context, name string) (volume.Volume, error) {
+	var out volume.Volume
+	err := c.call(ctx, "GET", "/api/volumes/"+url.PathEscape(name), nil, nil, nil, &out)
+	return out, err
+}
+
+// RemoveVolume: Remove a volume (DELETE /api/volumes/{name})
+func (c *Client) RemoveVolume(ctx context.Context, name string, query url.Values) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "DELETE", "/api/volumes/"+url.PathEscape(name), query, nil, nil, &out)
+	return out, err
+}
+
+// BackupVolume: Download an archive of the volume; the SHA-256 follows in the X-Checksum-Sha256 trailer (POST /api/volumes/{name}/backup)
+func (c *Client) BackupVolume(ctx context.Context, name string, query url.Values) (*http.Response, error) {
+	return c.sendJSON(ctx, "POST", "/api/volumes/"+url.PathEscape(name)+"/backup", query, nil, nil)
+}
+
+// RestoreVolume: Extract an archive into the volume (POST /api/volumes/{name}/restore)
+func (c *Client) RestoreVolume(ctx context.Context, name string, query url.Values, body io.Reader, contentType string) (VolumeArchiveRecord, error) {
+	var out VolumeArchiveRecord
+	err := c.upload(ctx, "POST", "/api/volumes/"+url.PathEscape(name)+"/restore", query, nil, body, contentType, &out)
+	return out, err
+}
+
+// ListVolumeArchives: Backup and restore history of a volume (GET /api/volumes/{name}/archives)
+func (c *Client) ListVolumeArchives(ctx context.Context, name string) ([]VolumeArchiveRecord, error) {
+	var out []VolumeArchiveRecord
+	err := c.call(ctx, "GET", "/api/volumes/"+url.PathEscape(name)+"/archives", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListVolumeFiles: List a directory (GET /api/volumes/{name}/files)
+func (c *Client) ListVolumeFiles(ctx context.Context, name string, query url.Values) ([]VolumeFileEntry, error) {
+	var out []VolumeFileEntry
+	err := c.call(ctx, "GET", "/api/volumes/"+url.PathEscape(name)+"/files", query, nil, nil, &out)
+	return out, err
+}
+
+// DeleteVolumePath: Delete a file or directory (DELETE /api/volumes/{name}/files)
+func (c *Client) DeleteVolumePath(ctx context.Context, name string, query url.Values) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.call(ctx, "DELETE", "/api/volumes/"+url.PathEscape(name)+"/files", query, nil, nil, &out)
+	return out, err
+}
+
+// DownloadVolumeFile: Download a file (GET /api/volumes/{name}/files/content)
+func (c *Client) DownloadVolumeFile(ctx context.Context, name string, query url.Values) (*http.Response, error) {
+	return c.sendJSON(ctx, "GET", "/api/volumes/"+url.PathEscape(name)+"/files/content", query, nil, nil)
+}
+
+// UploadVolumeFile: Create or replace a file (PUT /api/volumes/{name}/files/content)
+func (c *Client) UploadVolumeFile(ctx context.Context, name string, query url.Values, body io.Reader, contentType string) (VolumeFileEntry, error) {
+	var out VolumeFileEntry
+	err := c.upload(ctx, "PUT", "/api/volumes/"+url.PathEscape(name)+"/files/content", query, nil, body, contentType, &out)
+	return out, err
+}
+
+// CreateVolumeDirectory: Create a directory (POST /api/volumes/{name}/files/mkdir)
+func (c *Client) CreateVolumeDirectory(ctx context.Context, name string, in CreateVolumeDirectoryRequest) (VolumeFileEntry, error) {
+	var out VolumeFileEntry
+	err := c.call(ctx, "POST", "/api/volumes/"+url.PathEscape(name)+"/files/mkdir", nil, nil, in, &out)
+	return out, err
+}
+
+// ListBackupTargets: List backup targets (GET /api/backups/targets)
+func (c *Client) ListBackupTargets(ctx context.Context) ([]BackupTargetRecord, error) {
+	var out []BackupTargetRecord
+	err := c.call(ctx, "GET", "/api/backups/targets", nil, nil, nil, &out)
+	return out, err
+}
+
+// CreateBackupTarget: Create a backup target (POST /api/backups/targets)
+func (c *Client) CreateBackupTarget(ctx context.Context, in BackupTargetRequest) (BackupTargetRecord, error) {
+	var out BackupTargetRecord
+	err := c.call(ctx, "POST", "/api/backups/targets", nil, nil, in, &out)
+	return out, err
+}
+
+// GetBackupTarget: Get a backup target (GET /api/backups/targets/{id})
+func (c *Client) GetBackupTarget(ctx context.Context, id string) (BackupTargetRecord, error) {
+	var out BackupTargetRecord
+	err := c.call(ctx, "GET", "/api/backups/targets/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// UpdateBackupTarget: Update a backup target (PUT /api/backups/targets/{id})
+func (c *Client) UpdateBackupTarget(ctx context.Context, id string, in BackupTargetRequest) (BackupTargetRecord, error) {
+	var out BackupTargetRecord
+	err := c.call(ctx, "PUT", "/api/backups/targets/"+url.PathEscape(id), nil, nil, in, &out)
+	return out, err
+}
+
+// DeleteBackupTarget: Delete an unused backup target (DELETE /api/backups/targets/{id})
+func (c *Client) DeleteBackupTarget(ctx context.Context, id string) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.call(ctx, "DELETE", "/api/backups/targets/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// TestBackupTarget: Check that the target is writable (POST /api/backups/targets/{id}/test)
+func (c *Client) TestBackupTarget(ctx context.Context, id string) (ConnectionTestResponse, error) {
+	var out ConnectionTestResponse
+	err := c.call(ctx, "POST", "/api/backups/targets/"+url.PathEscape(id)+"/test", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListBackupPolicies: List backup policies (GET /api/backups/policies)
+func (c *Client) ListBackupPolicies(ctx context.Context) ([]BackupPolicy, error) {
+	var out []BackupPolicy
+	err := c.call(ctx, "GET", "/api/backups/policies", nil, nil, nil, &out)
+	return out, err
+}
+
+// CreateBackupPolicy: Create a backup policy (POST /api/backups/policies)
+func (c *Client) CreateBackupPolicy(ctx context.Context, in BackupPolicy) (BackupPolicy, error) {
+	var out BackupPolicy
+	err := c.call(ctx, "POST", "/api/backups/policies", nil, nil, in, &out)
+	return out, err
+}
+
+// GetBackupPolicy: Get a backup policy (GET /api/backups/policies/{id})
+func (c *Client) GetBackupPolicy(ctx context.Context, id string) (BackupPolicy, error) {
+	var out BackupPolicy
+	err := c.call(ctx, "GET", "/api/backups/policies/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// UpdateBackupPolicy: Update a backup policy (PUT /api/backups/policies/{id})
+func (c *Client) UpdateBackupPolicy(ctx context.Context, id string, in BackupPolicy) (BackupPolicy, error) {
+	var out BackupPolicy
+	err := c.call(ctx, "PUT", "/api/backups/policies/"+url.PathEscape(id), nil, nil, in, &out)
+	return out, err
+}
+
+// DeleteBackupPolicy: Delete a backup policy (DELETE /api/backups/policies/{id})
+func (c *Client) DeleteBackupPolicy(ctx context.Context, id string) (MessageResponse, error) {
+	var out MessageResponse
+	err := c.call(ctx, "DELETE", "/api/backups/policies/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// RunBackupPolicy: Run a backup policy now (POST /api/backups/policies/{id}/run)
+func (c *Client) RunBackupPolicy(ctx context.Context, id string) (RunStartedResponse, error) {
+	var out RunStartedResponse
+	err := c.call(ctx, "POST", "/api/backups/policies/"+url.PathEscape(id)+"/run", nil, nil, nil, &out)
+	return out, err
+}
+
+// ListBackupRuns: Recent runs of a policy (GET /api/backups/policies/{id}/runs)
+func (c *Client) ListBackupRuns(ctx context.Context, id string, query url.Values) ([]BackupRun, error) {
+	var out []BackupRun
+	err := c.call(ctx, "GET", "/api/backups/policies/"+url.PathEscape(id)+"/runs", query, nil, nil, &out)
+	return out, err
+}
+
+// ListNetworks: List networks (GET /api/networks)
+func (c *Client) ListNetworks(ctx context.Context, query url.Values) ([]types.NetworkResource, error) {
+	var out []types.NetworkResource
+	err := c.call(ctx, "GET", "/api/networks", query, nil, nil, &out)
+	return out, err
+}
+
+// CreateNetwork: Create a network (POST /api/networks)
+func (c *Client) CreateNetwork(ctx context.Context, in CreateNetworkRequest) (types.NetworkCreateResponse, error) {
+	var out types.NetworkCreateResponse
+	err := c.call(ctx, "POST", "/api/networks", nil, nil, in, &out)
+	return out, err
+}
+
+// PruneNetworks: Remove unused networks (POST /api/networks/prune)
+func (c *Client) PruneNetworks(ctx context.Context) (PruneNetworksResponse, error) {
+	var out PruneNetworksResponse
+	err := c.call(ctx, "POST", "/api/networks/prune", nil, nil, nil, &out)
+	return out, err
+}
+
+// InspectNetwork: Inspect a network (GET /api/networks/{id})
+func (c *Client) InspectNetwork(ctx context.Context, id string) (types.NetworkResource, error) {
+	var out types.NetworkResource
+	err := c.call(ctx, "GET", "/api/networks/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// RemoveNetwork: Remove a network (DELETE /api/networks/{id})
+func (c *Client) RemoveNetwork(ctx context.Context, id string) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "DELETE", "/api/networks/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// ConnectNetwork: Connect a container (POST /api/networks/{id}/connect)
+func (c *Client) ConnectNetwork(ctx context.Context, id string, in NetworkContainerRequest) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/networks/"+url.PathEscape(id)+"/connect", nil, nil, in, &out)
+	return out, err
+}
+
+// DisconnectNetwork: Disconnect a container (POST /api/networks/{id}/disconnect)
+func (c *Client) DisconnectNetwork(ctx context.Context, id string, in NetworkContainerRequest) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/networks/"+url.PathEscape(id)+"/disconnect", nil, nil, in, &out)
+	return out, err
+}
+
+// ListProjects: List compose projects (GET /api/compose/projects)
+func (c *Client) ListProjects(ctx context.Context, query url.Values) ([]ComposeProject, error) {
+	var out []ComposeProject
+	err := c.call(ctx, "GET", "/api/compose/projects", query, nil, nil, &out)
+	return out, err
+}
+
+// DeployProject: Deploy a compose file as a project (POST /api/compose/projects)
+func (c *Client) DeployProject(ctx context.Context, in DeployProjectRequest) (ComposeProject, error) {
+	var out ComposeProject
+	err := c.call(ctx, "POST", "/api/compose/projects", nil, nil, in, &out)
+	return out, err
+}
+
+// ValidateCompose: Parse a compose file (POST /api/compose/validate)
+func (c *Client) ValidateCompose(ctx context.Context, in ValidateComposeRequest) (ValidateComposeResponse, error) {
+	var out ValidateComposeResponse
+	err := c.call(ctx, "POST", "/api/compose/validate", nil, nil, in, &out)
+	return out, err
+}
+
+// ListStackTemplates: List stack templates (GET /api/compose/templates)
+func (c *Client) ListStackTemplates(ctx context.Context) ([]StackTemplate, error) {
+	var out []StackTemplate
+	err := c.call(ctx, "GET", "/api/compose/templates", nil, nil, nil, &out)
+	return out, err
+}
+
+// GetStackTemplate: Get a stack template (GET /api/compose/templates/{name})
+func (c *Client) GetStackTemplate(ctx context.Context, name string) (StackTemplate, error) {
+	var out StackTemplate
+	err := c.call(ctx, "GET", "/api/compose/templates/"+url.PathEscape(name), nil, nil, nil, &out)
+	return out, err
+}
+
+// GetProject: Get a compose project (GET /api/compose/projects/{id})
+func (c *Client) GetProject(ctx context.Context, id string) (ComposeProject, error) {
+	var out ComposeProject
+	err := c.call(ctx, "GET", "/api/compose/projects/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// DeleteProject: Remove a project and its resources (DELETE /api/compose/projects/{id})
+func (c *Client) DeleteProject(ctx context.Context, id string) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "DELETE", "/api/compose/projects/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// GetProjectLogs: Logs of every service, prefixed with the service name (GET /api/compose/projects/{id}/logs)
+func (c *Client) GetProjectLogs(ctx context.Context, id string, query url.Values) (*http.Response, error) {
+	return c.sendJSON(ctx, "GET", "/api/compose/projects/"+url.PathEscape(id)+"/logs", query, nil, nil)
+}
+
+// StartProject: Start a project (POST /api/compose/projects/{id}/start)
+func (c *Client) StartProject(ctx context.Context, id string) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/compose/projects/"+url.PathEscape(id)+"/start", nil, nil, nil, &out)
+	return out, err
+}
+
+// StopProject: Stop a project (POST /api/compose/projects/{id}/stop)
+func (c *Client) StopProject(ctx context.Context, id string) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/compose/projects/"+url.PathEscape(id)+"/stop", nil, nil, nil, &out)
+	return out, err
+}
+
+// RestartProject: Restart a project (POST /api/compose/projects/{id}/restart)
+func (c *Client) RestartProject(ctx context.Context, id string) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "POST", "/api/compose/projects/"+url.PathEscape(id)+"/restart", nil, nil, nil, &out)
+	return out, err
+}
+
+// GetSettings: All settings (GET /api/settings)
+func (c *Client) GetSettings(ctx context.Context) (Settings, error) {
+	var out Settings
+	err := c.call(ctx, "GET", "/api/settings", nil, nil, nil, &out)
+	return out, err
+}
+
+// UpdateSettings: Set several settings (PUT /api/settings)
+func (c *Client) UpdateSettings(ctx context.Context, in Settings) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "PUT", "/api/settings", nil, nil, in, &out)
+	return out, err
+}
+
+// ListUsers: List users (GET /api/users)
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var out []User
+	err := c.call(ctx, "GET", "/api/users", nil, nil, nil, &out)
+	return out, err
+}
+
+// CreateUser: Create a user (POST /api/users)
+func (c *Client) CreateUser(ctx context.Context, in CreateUserRequest) (User, error) {
+	var out User
+	err := c.call(ctx, "POST", "/api/users", nil, nil, in, &out)
+	return out, err
+}
+
+// DeleteUser: Delete a user other than the last (DELETE /api/users/{id})
+func (c *Client) DeleteUser(ctx context.Context, id string) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "DELETE", "/api/users/"+url.PathEscape(id), nil, nil, nil, &out)
+	return out, err
+}
+
+// ChangePassword: Change your own password (PUT /api/users/{id}/password)
+func (c *Client) ChangePassword(ctx context.Context, id string, in ChangePasswordRequest) (StatusResponse, error) {
+	var out StatusResponse
+	err := c.call(ctx, "PUT", "/api/users/"+url.PathEscape(id)+"/password", nil, nil, in, &out)
+	return out, err
+}
+
+// ListAudit: Audit log entries, newest first (GET /api/audit)
+func (c *Client) ListAudit(ctx context.Context, query url.Values) ([]AuditEntry, error) {
+	var out []AuditEntry
+	err := c.call(ctx, "GET", "/api/audit", query, nil, nil, &out)
+	return out, err
+}
+
+// ExportAudit: Download audit log entries (GET /api/audit/export)
+func (c *Client) ExportAudit(ctx context.Context, query url.Values) (*http.Response, error) {
+	return c.sendJSON(ctx, "GET", "/api/audit/export", query, nil, nil)
+}
+
+// AnalyzedFile is services.AnalyzedFile
+type AnalyzedFile struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Layer int    `json:"layer"`
+}
+
+// App is services.App
+type App struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Icon        string         `json:"icon"`
+	Category    string         `json:"category"`
+	Version     string         `json:"version"`
+	Image       string         `json:"image"`
+	Ports       map[string]int `json:"ports"`
+	Volumes     []AppVolume    `json:"volumes"`
+	EnvVars     AppEnvVars     `json:"envVars"`
+	ComposeFile string         `json:"composeFile"`
+}
+
+// AppEnvVar is services.AppEnvVar
+type AppEnvVar struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+}
+
+// AppEnvVars is services.AppEnvVars
+type AppEnvVars struct {
+	Required []AppEnvVar `json:"required"`
+	Optional []AppEnvVar `json:"optional"`
+}
+
+// AppVolume is services.AppVolume
+type AppVolume struct {
+	Container string `json:"container"`
+	Host      string `json:"host"`
+}
+
+// AuditEntry is services.AuditEntry
+type AuditEntry struct {
+	ID        int64  `json:"id"`
+	Timestamp string `json:"timestamp"`
+	UserID    int    `json:"userId"`
+	Username  string `json:"username"`
+	Action    string `json:"action"`
+	Resource  string `json:"resource"`
+	Params    string `json:"params"`
+	Result    string `json:"result"`
+	Status    int    `json:"status"`
+	Error     string `json:"error,omitempty"`
+	ClientIP  string `json:"clientIp"`
+}
+
+// BackupArtifact is services.BackupArtifact
+type BackupArtifact struct {
+	Volume    string `json:"volume"`
+	Object    string `json:"object"`
+	SizeBytes int64  `json:"sizeBytes"`
+	SHA256    string `json:"sha256"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BackupPolicy is services.BackupPolicy
+type BackupPolicy struct {
+	ID             int           `json:"id"`
+	Name           string        `json:"name"`
+	TargetID       int           `json:"targetId"`
+	Sources        BackupSources `json:"sources"`
+	Schedule       string        `json:"schedule"`
+	KeepLast       int           `json:"keepLast"`
+	KeepDaily      int           `json:"keepDaily"`
+	KeepWeekly     int           `json:"keepWeekly"`
+	StopContainers bool          `json:"stopContainers"`
+	Enabled        bool          `json:"enabled"`
+	CreatedAt      string        `json:"createdAt"`
+	UpdatedAt      string        `json:"updatedAt"`
+}
+
+// BackupRun is services.BackupRun
+type BackupRun struct {
+	ID         int              `json:"id"`
+	PolicyID   int              `json:"policyId"`
+	Trigger    string           `json:"trigger"`
+	Status     string           `json:"status"`
+	Message    string           `json:"message"`
+	Artifacts  []BackupArtifact `json:"artifacts"`
+	Pruned     []string         `json:"pruned"`
+	StartedAt  string           `json:"startedAt"`
+	FinishedAt string           `json:"finishedAt"`
+}
+
+// BackupSources is services.BackupSources
+type BackupSources struct {
+	Volumes  []string `json:"volumes"`
+	Projects []string `json:"projects"`
+	Apps     []int    `json:"apps"`
+}
+
+// BackupTargetConfig is services.BackupTargetConfig
+type BackupTargetConfig struct {
+	Type                string `json:"type"`
+	Path                string `json:"path,omitempty"`
+	Host                string `json:"host,omitempty"`
+	Port                int    `json:"port,omitempty"`
+	Username            string `json:"username,omitempty"`
+	Password            string `json:"password,omitempty"`
+	PrivateKey          string `json:"privateKey,omitempty"`
+	HostKey             string `json:"hostKey,omitempty"`
+	InsecureSkipHostKey bool   `json:"insecureSkipHostKey,omitempty"`
+	Endpoint            string `json:"endpoint,omitempty"`
+	Bucket              string `json:"bucket,omitempty"`
+	Region              string `json:"region,omitempty"`
+	AccessKey           string `json:"accessKey,omitempty"`
+	SecretKey           string `json:"secretKey,omitempty"`
+	UseSSL              bool   `json:"useSSL,omitempty"`
+	Prefix              string `json:"prefix,omitempty"`
+}
+
+// BackupTargetRecord is services.BackupTargetRecord
+type BackupTargetRecord struct {
+	ID        int                `json:"id"`
+	Name      string             `json:"name"`
+	Config    BackupTargetConfig `json:"config"`
+	CreatedAt string             `json:"createdAt"`
+	UpdatedAt string             `json:"updatedAt"`
+}
+
+// BackupTargetRequest is handlers.BackupTargetRequest
+type BackupTargetRequest struct {
+	Name   string             `json:"name"`
+	Config BackupTargetConfig `json:"config"`
+}
+
+// BuildOptions is services.BuildOptions
+type BuildOptions struct {
+	Tags           []string          `json:"tags"`
+	Dockerfile     string            `json:"-"`
+	DockerfilePath string            `json:"dockerfilePath,omitempty"`
+	BuildArgs      map[string]string `json:"buildArgs,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Target         string            `json:"target,omitempty"`
+	Platform       string            `json:"platform,omitempty"`
+	NoCache        bool              `json:"noCache,omitempty"`
+	GitURL         string            `json:"gitUrl,omitempty"`
+	GitRef         string            `json:"gitRef,omitempty"`
+	GitSubdir      string            `json:"gitSubdir,omitempty"`
+}
+
+// BulkRestartResponse is handlers.BulkRestartResponse
+type BulkRestartResponse struct {
+	Restarted int      `json:"restarted"`
+	Total     int      `json:"total"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// BulkStopResponse is handlers.BulkStopResponse
+type BulkStopResponse struct {
+	Stopped int      `json:"stopped"`
+	Total   int      `json:"total"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// CPUMetrics is services.CPUMetrics
+type CPUMetrics struct {
+	UsagePercent float64   `json:"usagePercent"`
+	Cores        int       `json:"cores"`
+	PerCore      []float64 `json:"perCore"`
+}
+
+// ChangePasswordRequest is handlers.ChangePasswordRequest
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// CloneOptions is services.CloneOptions
+type CloneOptions struct {
+	Name        string            `json:"name"`
+	Ports       map[string]string `json:"ports"`
+	Env         []string          `json:"env"`
+	CopyVolumes bool              `json:"copyVolumes"`
+	Start       bool              `json:"start"`
+}
+
+// CloneResult is services.CloneResult
+type CloneResult struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Volumes  map[string]string `json:"volumes,omitempty"`
+	Networks []string          `json:"networks"`
+	Warnings []string          `json:"warnings"`
+}
+
+// CommitContainerRequest is handlers.CommitContainerRequest
+type CommitContainerRequest struct {
+	Reference string   `json:"reference"`
+	Author    string   `json:"author"`
+	Message   string   `json:"message"`
+	Changes   []string `json:"changes"`
+	Pause     *bool    `json:"pause"`
+}
+
+// CommitContainerResponse is handlers.CommitContainerResponse
+type CommitContainerResponse struct {
+	ID        string `json:"id"`
+	Reference string `json:"reference"`
+}
+
+// ComposeProject is services.ComposeProject
+type ComposeProject struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	YAMLContent  string `json:"yamlContent"`
+	Status       string `json:"status"`
+	ContainerIDs string `json:"containerIds"`
+	NetworkIDs   string `json:"networkIds"`
+	VolumeNames  string `json:"volumeNames"`
+	CreatedAt    string `json:"createdAt"`
+	UpdatedAt    string `json:"updatedAt"`
+}
+
+// ConnectionTestResponse is handlers.ConnectionTestResponse
+type ConnectionTestResponse struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+// CreateContainerRequest is handlers.CreateContainerRequest
+type CreateContainerRequest struct {
+	Image         string            `json:"image"`
+	Name          string            `json:"name"`
+	Ports         map[string]string `json:"ports"`
+	Volumes       map[string]string `json:"volumes"`
+	Env           []string          `json:"env"`
+	RestartPolicy string            `json:"restartPolicy"`
+}
+
+// CreateNetworkRequest is handlers.CreateNetworkRequest
+type CreateNetworkRequest struct {
+	Name     string `json:"name"`
+	Driver   string `json:"driver"`
+	Internal bool   `json:"internal"`
+}
+
+// CreateUserRequest is handlers.CreateUserRequest
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CreateVolumeDirectoryRequest is handlers.CreateVolumeDirectoryRequest
+type CreateVolumeDirectoryRequest struct {
+	Path string `json:"path"`
+}
+
+// CreateVolumeRequest is handlers.CreateVolumeRequest
+type CreateVolumeRequest struct {
+	Name   string            `json:"name"`
+	Driver string            `json:"driver"`
+	Labels map[string]string `json:"labels"`
+}
+
+// DeployProjectRequest is handlers.DeployProjectRequest
+type DeployProjectRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	YAML        string `json:"yaml"`
+}
+
+// DiskMetrics is services.DiskMetrics
+type DiskMetrics struct {
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	Free        uint64  `json:"free"`
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+// DockerEvent is services.DockerEvent
+type DockerEvent struct {
+	ID           int64             `json:"id"`
+	Time         string            `json:"time"`
+	Type         string            `json:"type"`
+	Action       string            `json:"action"`
+	ResourceID   string            `json:"resourceId"`
+	ResourceName string            `json:"resourceName,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// EffectiveUpdatePolicy is services.EffectiveUpdatePolicy
+type EffectiveUpdatePolicy struct {
+	ContainerID     string `json:"containerId"`
+	ContainerName   string `json:"containerName"`
+	Image           string `json:"image"`
+	Project         string `json:"project,omitempty"`
+	Policy          string `json:"policy"`
+	Window          string `json:"window"`
+	Source          string `json:"source"`
+	UpdateAvailable bool   `json:"updateAvailable"`
+}
+
+// ImageAnalysis is services.ImageAnalysis
+type ImageAnalysis struct {
+	ImageID      string          `json:"imageId"`
+	TotalBytes   int64           `json:"totalBytes"`
+	FinalBytes   int64           `json:"finalBytes"`
+	WastedBytes  int64           `json:"wastedBytes"`
+	Efficiency   float64         `json:"efficiency"`
+	Layers       []LayerAnalysis `json:"layers"`
+	LargestFiles []AnalyzedFile  `json:"largestFiles"`
+	WastedFiles  []WastedFile    `json:"wastedFiles"`
+	AnalyzedAt   string          `json:"analyzedAt"`
+}
+
+// ImageBuildRecord is services.ImageBuildRecord
+type ImageBuildRecord struct {
+	ID         int          `json:"id"`
+	Source     string       `json:"source"`
+	Tags       []string     `json:"tags"`
+	Options    BuildOptions `json:"options"`
+	Status     string       `json:"status"`
+	ImageID    string       `json:"imageId"`
+	Error      string       `json:"error"`
+	Log        string       `json:"log,omitempty"`
+	StartedAt  string       `json:"startedAt"`
+	FinishedAt string       `json:"finishedAt"`
+}
+
+// ImageLoadResult is services.ImageLoadResult
+type ImageLoadResult struct {
+	Tags []string `json:"tags"`
+	IDs  []string `json:"ids"`
+}
+
+// ImageUpdate is services.ImageUpdate
+type ImageUpdate struct {
+	Image           string                 `json:"image"`
+	LocalDigest     string                 `json:"localDigest"`
+	RemoteDigest    string                 `json:"remoteDigest"`
+	DigestChanged   bool                   `json:"digestChanged"`
+	NewerTag        string                 `json:"newerTag,omitempty"`
+	UpdateAvailable bool                   `json:"updateAvailable"`
+	Error           string                 `json:"error,omitempty"`
+	CheckedAt       string                 `json:"checkedAt"`
+	Containers      []ImageUpdateContainer `json:"containers"`
+	Apps            []ImageUpdateApp       `json:"apps"`
+}
+
+// ImageUpdateApp is services.ImageUpdateApp
+type ImageUpdateApp struct {
+	ID      int    `json:"id"`
+	AppID   string `json:"appId"`
+	AppName string `json:"appName"`
+}
+
+// ImageUpdateContainer is services.ImageUpdateContainer
+type ImageUpdateContainer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// InstallAppEnvVar is handlers.InstallAppEnvVar
+type InstallAppEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// InstallAppProxy is handlers.InstallAppProxy
+type InstallAppProxy struct {
+	Host string `json:"host"`
+	Path string `json:"path"`
+	Port int    `json:"port"`
+}
+
+// InstallAppRequest is handlers.InstallAppRequest
+type InstallAppRequest struct {
+	Name    string             `json:"name"`
+	Env     []InstallAppEnvVar `json:"env"`
+	Ports   map[string]string  `json:"ports"`
+	Volumes map[string]string  `json:"volumes"`
+	Proxy   *InstallAppProxy   `json:"proxy"`
+}
+
+// InstallAppResponse is handlers.InstallAppResponse
+type InstallAppResponse struct {
+	ID          int    `json:"id"`
+	AppID       string `json:"appId"`
+	AppName     string `json:"appName"`
+	ContainerID string `json:"containerId"`
+	Status      string `json:"status"`
+}
+
+// InstalledApp is services.InstalledApp
+type InstalledApp struct {
+	ID           int    `json:"id"`
+	AppID        string `json:"appId"`
+	AppName      string `json:"appName"`
+	ContainerIDs string `json:"containerIds"`
+	Config       string `json:"config"`
+	InstalledAt  string `json:"installedAt"`
+	Status       string `json:"status"`
+}
+
+// LayerAnalysis is services.LayerAnalysis
+type LayerAnalysis struct {
+	Index     int    `json:"index"`
+	DiffID    string `json:"diffId"`
+	CreatedBy string `json:"createdBy"`
+	Bytes     int64  `json:"bytes"`
+	Files     int    `json:"files"`
+	Deletions int    `json:"deletions"`
+}
+
+// LayerTreeNode is services.LayerTreeNode
+type LayerTreeNode struct {
+	Name      string           `json:"name"`
+	Type      string           `json:"type"`
+	Size      int64            `json:"size"`
+	Children  []*LayerTreeNode `json:"children,omitempty"`
+	Truncated bool             `json:"truncated,omitempty"`
+}
+
+// LogCaptureTarget is services.LogCaptureTarget
+type LogCaptureTarget struct {
+	ID        int    `json:"id"`
+	Scope     string `json:"scope"`
+	Target    string `json:"target"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// LogCollectorStatus is services.LogCollectorStatus
+type LogCollectorStatus struct {
+	Following  []string `json:"following"`
+	Lines      int64    `json:"lines"`
+	Bytes      int64    `json:"bytes"`
+	FullText   bool     `json:"fullText"`
+	OldestTime string   `json:"oldestTime,omitempty"`
+}
+
+// LogEntry is services.LogEntry
+type LogEntry struct {
+	ID          int64  `json:"id"`
+	ContainerID string `json:"containerId"`
+	Container   string `json:"container"`
+	Project     string `json:"project,omitempty"`
+	Stream      string `json:"stream"`
+	Time        string `json:"time"`
+	Message     string `json:"message"`
+}
+
+// LoginRequest is handlers.LoginRequest
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Mode     string `json:"mode,omitempty"`
+}
+
+// LoginResponse is handlers.LoginResponse
+type LoginResponse struct {
+	Token     string `json:"token,omitempty"`
+	Mode      string `json:"mode,omitempty"`
+	CSRFToken string `json:"csrfToken,omitempty"`
+}
+
+// MemoryMetrics is services.MemoryMetrics
+type MemoryMetrics struct {
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	Available   uint64  `json:"available"`
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+// MessageResponse is handlers.MessageResponse
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// NetworkContainerRequest is handlers.NetworkContainerRequest
+type NetworkContainerRequest struct {
+	ContainerID string `json:"containerId"`
+}
+
+// NetworkMetrics is services.NetworkMetrics
+type NetworkMetrics struct {
+	BytesSent   uint64 `json:"bytesSent"`
+	BytesRecv   uint64 `json:"bytesRecv"`
+	PacketsSent uint64 `json:"packetsSent"`
+	PacketsRecv uint64 `json:"packetsRecv"`
+}
+
+// ProxyRoute is services.ProxyRoute
+type ProxyRoute struct {
+	ID          string `json:"id"`
+	Container   string `json:"container"`
+	ContainerID string `json:"containerId"`
+	Project     string `json:"project,omitempty"`
+	App         string `json:"app,omitempty"`
+	Host        string `json:"host,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Upstream    string `json:"upstream,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ProxyStatus is services.ProxyStatus
+type ProxyStatus struct {
+	Enabled    bool         `json:"enabled"`
+	Domain     string       `json:"domain,omitempty"`
+	Server     string       `json:"server,omitempty"`
+	LastSyncAt string       `json:"lastSyncAt,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Routes     []ProxyRoute `json:"routes"`
+}
+
+// PruneImagesResponse is handlers.PruneImagesResponse
+type PruneImagesResponse struct {
+	Deleted        []image.DeleteResponse `json:"deleted"`
+	SpaceReclaimed uint64                 `json:"spaceReclaimed"`
+}
+
+// PruneNetworksResponse is handlers.PruneNetworksResponse
+type PruneNetworksResponse struct {
+	Deleted []string `json:"deleted"`
+}
+
+// PruneVolumesResponse is handlers.PruneVolumesResponse
+type PruneVolumesResponse struct {
+	Deleted        []string `json:"deleted"`
+	SpaceReclaimed uint64   `json:"spaceReclaimed"`
+}
+
+// PullImageRequest is handlers.PullImageRequest
+type PullImageRequest struct {
+	Image string `json:"image"`
+}
+
+// RedeployResult is services.RedeployResult
+type RedeployResult struct {
+	Kind   string `json:"kind"`
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RegistryCredential is services.RegistryCredential
+type RegistryCredential struct {
+	ID          int     `json:"id"`
+	Registry    string  `json:"registry"`
+	Username    string  `json:"username"`
+	Password    string  `json:"password,omitempty"`
+	LastLoginAt *string `json:"lastLoginAt"`
+	CreatedAt   string  `json:"createdAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+}
+
+// RenameContainerRequest is handlers.RenameContainerRequest
+type RenameContainerRequest struct {
+	Name string `json:"name"`
+}
+
+// RenameContainerResponse is handlers.RenameContainerResponse
+type RenameContainerResponse struct {
+	Status string `json:"status"`
+	Name   string `json:"name"`
+}
+
+// RestoreResult is services.RestoreResult
+type RestoreResult struct {
+	Manifest         SystemBackupManifest `json:"manifest"`
+	SchemaVersion    int                  `json:"schemaVersion"`
+	PreRestoreBackup string               `json:"preRestoreBackup"`
+	Redeployed       []RedeployResult     `json:"redeployed"`
+	Warnings         []string             `json:"warnings,omitempty"`
+	Restarting       bool                 `json:"restarting"`
+}
+
+// RunStartedResponse is handlers.RunStartedResponse
+type RunStartedResponse struct {
+	RunID int `json:"runId"`
+}
+
+// ScheduledTask is services.ScheduledTask
+type ScheduledTask struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	Schedule        string     `json:"schedule"`
+	Action          string     `json:"action"`
+	Target          string     `json:"target"`
+	Config          TaskConfig `json:"config"`
+	Concurrency     string     `json:"concurrency"`
+	MissedRuns      string     `json:"missedRuns"`
+	Enabled         bool       `json:"enabled"`
+	LastScheduledAt string     `json:"lastScheduledAt,omitempty"`
+	NextRunAt       string     `json:"nextRunAt,omitempty"`
+	CreatedAt       string     `json:"createdAt"`
+	UpdatedAt       string     `json:"updatedAt"`
+}
+
+// Settings is handlers.Settings
+type Settings map[string]string
+
+// SetupRequest is handlers.SetupRequest
+type SetupRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SetupStatusResponse is handlers.SetupStatusResponse
+type SetupStatusResponse struct {
+	NeedsSetup bool `json:"needs_setup"`
+}
+
+// StackTemplate is services.StackTemplate
+type StackTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	YAML        string `json:"yaml"`
+}
+
+// StatusResponse is handlers.StatusResponse
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+// SystemBackupFile is services.SystemBackupFile
+type SystemBackupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// SystemBackupManifest is services.SystemBackupManifest
+type SystemBackupManifest struct {
+	Format                int                `json:"format"`
+	Version               string             `json:"version"`
+	SchemaVersion         int                `json:"schemaVersion"`
+	CreatedAt             time.Time          `json:"createdAt"`
+	SecretsKeyFingerprint string             `json:"secretsKeyFingerprint,omitempty"`
+	Files                 []SystemBackupFile `json:"files"`
+}
+
+// SystemMetrics is services.SystemMetrics
+type SystemMetrics struct {
+	CPU     CPUMetrics     `json:"cpu"`
+	Memory  MemoryMetrics  `json:"memory"`
+	Disk    DiskMetrics    `json:"disk"`
+	Network NetworkMetrics `json:"network"`
+	Updated time.Time      `json:"updated"`
+}
+
+// TagImageRequest is handlers.TagImageRequest
+type TagImageRequest struct {
+	Repo string `json:"repo"`
+	Tag  string `json:"tag"`
+}
+
+// TagImageResponse is handlers.TagImageResponse
+type TagImageResponse struct {
+	Status string `json:"status"`
+	Tag    string `json:"tag"`
+}
+
+// TaskConfig is services.TaskConfig
+type TaskConfig struct {
+	Command        []string `json:"command,omitempty"`
+	Env            []string `json:"env,omitempty"`
+	Image          string   `json:"image,omitempty"`
+	Volumes        []string `json:"volumes,omitempty"`
+	Network        string   `json:"network,omitempty"`
+	Prune          []string `json:"prune,omitempty"`
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"`
+}
+
+// TaskRun is services.TaskRun
+type TaskRun struct {
+	ID         int    `json:"id"`
+	TaskID     int    `json:"taskId"`
+	Trigger    string `json:"trigger"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	Output     string `json:"output"`
+	ExitCode   *int   `json:"exitCode,omitempty"`
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt"`
+}
+
+// UpdateHistoryEntry is services.UpdateHistoryEntry
+type UpdateHistoryEntry struct {
+	ID            int    `json:"id"`
+	ContainerName string `json:"containerName"`
+	ContainerID   string `json:"containerId"`
+	Image         string `json:"image"`
+	Digest        string `json:"digest"`
+	Action        string `json:"action"`
+	Status        string `json:"status"`
+	Message       string `json:"message"`
+	Trigger       string `json:"trigger"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+// UpdatePolicy is services.UpdatePolicy
+type UpdatePolicy struct {
+	ID        int    `json:"id"`
+	Scope     string `json:"scope"`
+	Target    string `json:"target"`
+	Policy    string `json:"policy"`
+	Window    string `json:"window"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// User is handlers.User
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// ValidateComposeRequest is handlers.ValidateComposeRequest
+type ValidateComposeRequest struct {
+	YAML string `json:"yaml"`
+}
+
+// ValidateComposeResponse is handlers.ValidateComposeResponse
+type ValidateComposeResponse struct {
+	Valid    bool     `json:"valid"`
+	Services []string `json:"services"`
+	Version  string   `json:"version"`
+}
+
+// VerifyResponse is handlers.VerifyResponse
+type VerifyResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// VolumeArchiveRecord is services.VolumeArchiveRecord
+type VolumeArchiveRecord struct {
+	ID         int    `json:"id"`
+	VolumeName string `json:"volumeName"`
+	Operation  string `json:"operation"`
+	Compressed bool   `json:"compressed"`
+	SizeBytes  int64  `json:"sizeBytes"`
+	SHA256     string `json:"sha256"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+// VolumeFileEntry is services.VolumeFileEntry
+type VolumeFileEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	UID     int       `json:"uid"`
+	GID     int       `json:"gid"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// WastedFile is services.WastedFile
+type WastedFile struct {
+	Path        string `json:"path"`
+	WastedBytes int64  `json:"wastedBytes"`
+	Occurrences int    `json:"occurrences"`
+	Layers      []int  `json:"layers"`
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+)
+
+func (c *cli) apps(ctx context.Context, args []string) error {
+	return subcommand(ctx, "apps", args, map[string]func(context.Context, []string) error{
+		"install": c.appsInstall,
+	})
+}
+
+// appsInstall installs a marketplace app. Ports are given per catalog port
+// label and volumes docker-style as HOST_PATH:CONTAINER_PATH.
+func (c *cli) appsInstall(ctx context.Context, args []string) error {
+	fs := newFlags("apps install", "[--name NAME] [-e KEY=VALUE]... [-p LABEL=HOST_PORT]... [-v HOST_PATH:CONTAINER_PATH]... APP")
+	name := fs.String("name", "", "container name (default: APP-app)")
+	var env, ports, volumes stringList
+	fs.Var(&env, "e", "environment variable KEY=VALUE (repeatable)")
+	fs.Var(&ports, "p", "publish a catalog port LABEL=HOST_PORT (repeatable)")
+	fs.Var(&volumes, "v", "bind HOST_PATH:CONTAINER_PATH (repeatable)")
+	proxyHost := fs.String("proxy-host", "", "publish through Caddy at this hostname")
+	proxyPath := fs.String("proxy-path", "", "publish through Caddy under this path")
+	proxyPort := fs.Int("proxy-port", 0, "container port Caddy forwards to")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	type envVar struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	req := struct {
+		Name    string                 `json:"name"`
+		Env     []envVar               `json:"env"`
+		Ports   map[string]string      `json:"ports"`
+		Volumes map[string]string      `json:"volumes"`
+		Proxy   map[string]interface{} `json:"proxy,omitempty"`
+	}{Name: *name, Env: []envVar{}, Ports: map[string]string{}, Volumes: map[string]string{}}
+
+	for _, e := range env {
+		key, value, ok := strings.Cut(e, "=")
+		if !ok {
+			return fmt.Errorf("-e %q: expected KEY=VALUE", e)
+		}
+		req.Env = append(req.Env, envVar{key, value})
+	}
+	for _, p := range ports {
+		label, hostPort, ok := strings.Cut(p, "=")
+		if !ok {
+			return fmt.Errorf("-p %q: expected LABEL=HOST_PORT", p)
+		}
+		req.Ports[label] = hostPort
+	}
+	for _, v := range volumes {
+		hostPath, containerPath, ok := strings.Cut(v, ":")
+		if !ok {
+			return fmt.Errorf("-v %q: expected HOST_PATH:CONTAINER_PATH", v)
+		}
+		req.Volumes[containerPath] = hostPath
+	}
+	if *proxyHost != "" || *proxyPath != "" {
+		req.Proxy = map[string]interface{}{"host": *proxyHost, "path": *proxyPath, "port": *proxyPort}
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		ID          int    `json:"id"`
+		AppID       string `json:"appId"`
+		AppName     string `json:"appName"`
+		ContainerID string `json:"containerId"`
+		Status      string `json:"status"`
+	}
+	if err := api.do(ctx, "POST", "/api/apps/"+url.PathEscape(args[0])+"/install", req, &resp); err != nil {
+		return err
+	}
+
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tAPP\tCONTAINER ID\tSTATUS")
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", resp.ID, resp.AppName, shortID(resp.ContainerID), resp.Status)
+	})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+)
+
+// login exchanges a username and password for a token and stores it in a
+// context, creating the context if needed and making it current
+func (c *cli) login(ctx context.Context, args []string) error {
+	fs := newFlags("login", "[--server URL] [--username NAME] [--password-stdin]")
+	server := fs.String("server", "", "Sunspear URL, e.g. https://sunspear.example.com")
+	username := fs.String("username", "", "username")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	name := c.context
+	if name == "" {
+		name = c.cfg.Current
+	}
+	if name == "" {
+		name = "default"
+	}
+	p := c.cfg.Contexts[name]
+	if p == nil {
+		p = &profile{}
+	}
+	if *server != "" {
+		u, err := url.Parse(*server)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("--server must be an http or https URL")
+		}
+		p.Server = strings.TrimSuffix(*server, "/")
+	}
+	if p.Server == "" {
+		return fmt.Errorf("context %q has no server; pass --server", name)
+	}
+	if *username != "" {
+		p.Username = *username
+	}
+	if p.Username == "" {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("pass --username when stdin is not a terminal")
+		}
+		if p.Username, err = prompt("Username: "); err != nil {
+			return err
+		}
+	}
+
+	password, err := readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	req := map[string]string{"username": p.Username, "password": password}
+	if err := newClient(p.Server, "").do(ctx, "POST", "/api/auth/login", req, &resp); err != nil {
+		return err
+	}
+	if resp.Token == "" {
+		return fmt.Errorf("server did not return a token")
+	}
+
+	p.Token = resp.Token
+	c.cfg.Contexts[name] = p
+	c.cfg.Current = name
+	if err := c.cfg.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Logged in to %s as %s (context %q)\n", p.Server, p.Username, name)
+	return nil
+}
+
+func (c *cli) logout(ctx context.Context, args []string) error {
+	name, p, err := c.profile()
+	if err != nil {
+		return err
+	}
+	p.Token = ""
+	if err := c.cfg.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Logged out of context %q\n", name)
+	return nil
+}
+
+func (c *cli) contextCmd(ctx context.Context, args []string) error {
+	return subcommand(ctx, "context", args, map[string]func(context.Context, []string) error{
+		"ls":  c.contextList,
+		"use": c.contextUse,
+		"rm":  c.contextRemove,
+	})
+}
+
+func (c *cli) contextList(ctx context.Context, args []string) error {
+	type contextInfo struct {
+		Name     string `json:"name"`
+		Server   string `json:"server"`
+		Username string `json:"username"`
+		LoggedIn bool   `json:"loggedIn"`
+		Current  bool   `json:"current"`
+	}
+	list := []contextInfo{}
+	for _, name := range c.cfg.names() {
+		p := c.cfg.Contexts[name]
+		list = append(list, contextInfo{name, p.Server, p.Username, p.Token != "", name == c.cfg.Current})
+	}
+
+	return c.print(list, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "CURRENT\tNAME\tSERVER\tUSER\tLOGGED IN")
+		for _, info := range list {
+			current := ""
+			if info.Current {
+				current = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", current, info.Name, info.Server, info.Username, info.LoggedIn)
+		}
+	})
+}
+
+func (c *cli) contextUse(ctx context.Context, args []string) error {
+	fs := newFlags("context use", "NAME")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if _, ok := c.cfg.Contexts[args[0]]; !ok {
+		return fmt.Errorf("context %q does not exist", args[0])
+	}
+	c.cfg.Current = args[0]
+	if err := c.cfg.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Switched to context %q\n", args[0])
+	return nil
+}
+
+func (c *cli) contextRemove(ctx context.Context, args []string) error {
+	fs := newFlags("context rm", "NAME")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if _, ok := c.cfg.Contexts[args[0]]; !ok {
+		return fmt.Errorf("context %q does not exist", args[0])
+	}
+	delete(c.cfg.Contexts, args[0])
+	if c.cfg.Current == args[0] {
+		c.cfg.Current = ""
+	}
+	if err := c.cfg.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Removed context %q\n", args[0])
+	return nil
+}
+
+func prompt(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// readPassword reads the password from stdin, or prompts without echo when
+// stdin is a terminal
+func readPassword(fromStdin bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if fromStdin || !term.IsTerminal(fd) {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(password), err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// project is the part of a compose project the CLI shows
+type project struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Status       string `json:"status"`
+	ContainerIDs string `json:"containerIds"`
+	CreatedAt    string `json:"createdAt"`
+	UpdatedAt    string `json:"updatedAt"`
+}
+
+func (c *cli) compose(ctx context.Context, args []string) error {
+	return subcommand(ctx, "compose", args, map[string]func(context.Context, []string) error{
+		"ls":     c.composeList,
+		"deploy": c.composeDeploy,
+		"start":  c.composeAction("start", "POST", "/start", "Started"),
+		"stop":   c.composeAction("stop", "POST", "/stop", "Stopped"),
+		"rm":     c.composeAction("rm", "DELETE", "", "Removed"),
+	})
+}
+
+func (c *cli) printProjects(projects []project) error {
+	return c.print(projects, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tSTATUS\tCONTAINERS\tCREATED")
+		for _, p := range projects {
+			var ids []string
+			json.Unmarshal([]byte(p.ContainerIDs), &ids)
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", p.ID, p.Name, p.Status, len(ids), p.CreatedAt)
+		}
+	})
+}
+
+func (c *cli) composeList(ctx context.Context, args []string) error {
+	fs := newFlags("compose ls", "")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var projects []project
+	if err := api.do(ctx, "GET", "/api/compose/projects", nil, &projects); err != nil {
+		return err
+	}
+	return c.printProjects(projects)
+}
+
+// composeDeploy deploys a compose file as a new project. The project is
+// named after the file's directory unless --name is given.
+func (c *cli) composeDeploy(ctx context.Context, args []string) error {
+	fs := newFlags("compose deploy", "-f FILE [--name NAME] [--description TEXT]")
+	file := fs.String("f", "", "compose file")
+	name := fs.String("name", "", "project name (default: the file's directory name)")
+	description := fs.String("description", "", "project description")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return fmt.Errorf("-f is required")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		abs, err := filepath.Abs(*file)
+		if err != nil {
+			return err
+		}
+		*name = strings.ToLower(filepath.Base(filepath.Dir(abs)))
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	var p project
+	req := map[string]string{"name": *name, "description": *description, "yaml": string(data)}
+	if err := api.do(ctx, "POST", "/api/compose/projects", req, &p); err != nil {
+		return err
+	}
+	return c.printProjects([]project{p})
+}
+
+// composeAction runs a project action on a project given by ID or name
+func (c *cli) composeAction(name, method, suffix, done string) func(context.Context, []string) error {
+	return func(ctx context.Context, args []string) error {
+		fs := newFlags("compose "+name, "PROJECT")
+		args, err := parseFlags(fs, args)
+		if err != nil {
+			return err
+		}
+		if err := exactArgs(fs, args, 1); err != nil {
+			return err
+		}
+		api, err := c.client()
+		if err != nil {
+			return err
+		}
+
+		p, err := findProject(ctx, api, args[0])
+		if err != nil {
+			return err
+		}
+		if err := api.do(ctx, method, "/api/compose/projects/"+strconv.Itoa(p.ID)+suffix, nil, nil); err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return c.print(map[string]interface{}{"id": p.ID, "name": p.Name, "action": name}, nil)
+		}
+		fmt.Fprintf(c.stdout, "%s project %s\n", done, p.Name)
+		return nil
+	}
+}
+
+// findProject resolves a project ID or name
+func findProject(ctx context.Context, api *client, ref string) (*project, error) {
+	var projects []project
+	if err := api.do(ctx, "GET", "/api/compose/projects", nil, &projects); err != nil {
+		return nil, err
+	}
+	for i, p := range projects {
+		if p.Name == ref || strconv.Itoa(p.ID) == ref {
+			return &projects[i], nil
+		}
+	}
+	return nil, fmt.Errorf("project %q not found", ref)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// profile is one Sunspear server the CLI can talk to
+type profile struct {
+	Server   string `json:"server"`
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// cliConfig holds the context profiles. It is stored as JSON in
+// $SUNSPEAR_CLI_CONFIG, or sunspear/cli.json under the user config directory.
+type cliConfig struct {
+	Current  string              `json:"current"`
+	Contexts map[string]*profile `json:"contexts"`
+
+	path string
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("SUNSPEAR_CLI_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sunspear", "cli.json"), nil
+}
+
+func loadConfig() (*cliConfig, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &cliConfig{Contexts: make(map[string]*profile), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.Contexts == nil {
+		cfg.Contexts = make(map[string]*profile)
+	}
+	return cfg, nil
+}
+
+// save writes the config readable only by the user, since it holds tokens
+func (c *cliConfig) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, append(data, '\n'), 0600)
+}
+
+// names returns the context names in order
+func (c *cliConfig) names() []string {
+	names := make([]string, 0, len(c.Contexts))
+	for name := range c.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
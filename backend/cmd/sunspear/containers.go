@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/gorilla/websocket"
+)
+
+func (c *cli) ps(ctx context.Context, args []string) error {
+	fs := newFlags("ps", "[-a]")
+	all := fs.Bool("a", false, "show stopped containers too")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var containers []types.Container
+	if err := api.do(ctx, "GET", "/api/containers?all="+strconv.FormatBool(*all), nil, &containers); err != nil {
+		return err
+	}
+
+	return c.print(containers, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "CONTAINER ID\tNAME\tIMAGE\tSTATE\tSTATUS\tPROJECT")
+		for _, ctr := range containers {
+			name := ""
+			if len(ctr.Names) > 0 {
+				name = strings.TrimPrefix(ctr.Names[0], "/")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", shortID(ctr.ID), name, ctr.Image, ctr.State, ctr.Status, ctr.Labels["com.sunspear.project"])
+		}
+	})
+}
+
+// logs prints container logs, or streams them over the WebSocket with -f.
+// With -o json each line is printed as a JSON object.
+func (c *cli) logs(ctx context.Context, args []string) error {
+	fs := newFlags("logs", "[-f] [--tail N] [--since TIME] [--until TIME] [--filter REGEX] CONTAINER")
+	follow := fs.Bool("f", false, "follow new output")
+	tail := fs.String("tail", "100", "number of lines from the end, or \"all\"")
+	since := fs.String("since", "", "show lines since a timestamp (RFC 3339) or duration (e.g. 10m)")
+	until := fs.String("until", "", "show lines before a timestamp or duration")
+	filter := fs.String("filter", "", "only show lines matching a regular expression")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 1); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("tail", *tail)
+	if *filter != "" {
+		query.Set("filter", *filter)
+	}
+	for key, value := range map[string]string{"since": *since, "until": *until} {
+		if value != "" {
+			query.Set(key, timeArg(value))
+		}
+	}
+	id := url.PathEscape(args[0])
+
+	if !*follow {
+		if c.output == "json" {
+			query.Set("format", "json")
+		}
+		resp, err := api.request(ctx, "GET", "/api/containers/"+id+"/logs?"+query.Encode(), nil, "")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = io.Copy(c.stdout, resp.Body)
+		return err
+	}
+
+	conn, err := api.websocket(ctx, "/api/ws/logs/"+id, query)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil || websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+
+		var msg struct {
+			Type    string `json:"type"`
+			Data    string `json:"data"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case "log":
+			if c.output == "json" {
+				fmt.Fprintln(c.stdout, string(data))
+			} else {
+				fmt.Fprintln(c.stdout, strings.TrimRight(msg.Data, "\n"))
+			}
+		case "error":
+			return fmt.Errorf("%s", msg.Message)
+		}
+	}
+}
+
+// timeArg turns a duration such as 10m into the RFC 3339 time that long
+// ago; other values are passed to the server unchanged
+func timeArg(value string) string {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d).UTC().Format(time.RFC3339)
+	}
+	return value
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	"golang.org/x/term"
+)
+
+func (c *cli) images(ctx context.Context, args []string) error {
+	return subcommand(ctx, "images", args, map[string]func(context.Context, []string) error{
+		"pull": c.imagesPull,
+	})
+}
+
+// imagesPull pulls an image on the server, showing Docker's progress. With
+// -o json the raw progress messages are printed.
+func (c *cli) imagesPull(ctx context.Context, args []string) error {
+	fs := newFlags("images pull", "IMAGE")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 1); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]string{"image": args[0]})
+	if err != nil {
+		return err
+	}
+	resp, err := api.request(ctx, "POST", "/api/images/pull", bytes.NewReader(body), "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if c.output == "json" {
+		_, err = io.Copy(c.stdout, resp.Body)
+		return err
+	}
+	fd := os.Stdout.Fd()
+	return jsonmessage.DisplayJSONMessagesStream(resp.Body, c.stdout, fd, term.IsTerminal(int(fd)), nil)
+}
@@ -0,0 +1,220 @@
+// Command sunspear is a command-line client for the Sunspear API.
+//
+//	sunspear login --server https://sunspear.example.com --username admin
+//	sunspear ps -a
+//	sunspear logs -f web
+//	sunspear compose deploy -f stack.yml
+//
+// Credentials are kept per context, so several servers can be used side by
+// side with --context or `sunspear context use`.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+const usage = `Usage: sunspear [--context NAME] [-o table|json] COMMAND
+
+Commands:
+  login                   Log in to a server and save the token in a context
+  logout                  Forget the token of the current context
+  context ls|use|rm       Manage context profiles
+  ps                      List containers
+  logs                    Show or follow container logs
+  compose ls|deploy|start|stop|rm
+                          Manage compose projects
+  apps install            Install a marketplace app
+  images pull             Pull an image
+  volumes backup          Download a volume archive
+
+Run 'sunspear COMMAND -h' for the options of a command.
+`
+
+// cli carries the global options into commands
+type cli struct {
+	cfg     *cliConfig
+	context string
+	output  string
+	stdout  io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	global := flag.NewFlagSet("sunspear", flag.ContinueOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	contextName := global.String("context", os.Getenv("SUNSPEAR_CONTEXT"), "context profile to use")
+	output := global.String("o", "table", "output format: table or json")
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return flag.ErrHelp
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	c := &cli{cfg: cfg, context: *contextName, output: *output, stdout: os.Stdout}
+
+	command, rest := global.Arg(0), global.Args()[1:]
+	commands := map[string]func(context.Context, []string) error{
+		"login":   c.login,
+		"logout":  c.logout,
+		"context": c.contextCmd,
+		"ps":      c.ps,
+		"logs":    c.logs,
+		"compose": c.compose,
+		"apps":    c.apps,
+		"images":  c.images,
+		"volumes": c.volumes,
+	}
+	if command == "help" {
+		global.Usage()
+		return nil
+	}
+	fn, ok := commands[command]
+	if !ok {
+		return fmt.Errorf("unknown command %q (see 'sunspear help')", command)
+	}
+	return fn(ctx, rest)
+}
+
+// subcommand dispatches to the named subcommand of a command group
+func subcommand(ctx context.Context, group string, args []string, commands map[string]func(context.Context, []string) error) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(args) == 0 {
+		return fmt.Errorf("usage: sunspear %s %s", group, strings.Join(names, "|"))
+	}
+	fn, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q for %s", args[0], group)
+	}
+	return fn(ctx, args[1:])
+}
+
+// profile returns the selected context
+func (c *cli) profile() (string, *profile, error) {
+	name := c.context
+	if name == "" {
+		name = c.cfg.Current
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("no context selected; run 'sunspear login --server URL'")
+	}
+	p, ok := c.cfg.Contexts[name]
+	if !ok {
+		return "", nil, fmt.Errorf("context %q does not exist", name)
+	}
+	return name, p, nil
+}
+
+// client returns an API client for the selected context
+func (c *cli) client() (*client, error) {
+	name, p, err := c.profile()
+	if err != nil {
+		return nil, err
+	}
+	if p.Token == "" {
+		return nil, fmt.Errorf("not logged in to context %q; run 'sunspear login'", name)
+	}
+	return newClient(p.Server, p.Token), nil
+}
+
+// newFlags creates a flag set for a command. Usage errors are returned
+// rather than exiting.
+func newFlags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sunspear %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses flags appearing before or after positional arguments
+// and returns the positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// exactArgs checks the number of positional arguments
+func exactArgs(fs *flag.FlagSet, args []string, n int) error {
+	if len(args) != n {
+		fs.Usage()
+		return fmt.Errorf("%s expects %d argument(s), got %d", fs.Name(), n, len(args))
+	}
+	return nil
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// print writes v as indented JSON, or runs table when the output is a table
+func (c *cli) print(v interface{}, table func(w *tabwriter.Writer)) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 3, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// shortID truncates a Docker ID for display
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
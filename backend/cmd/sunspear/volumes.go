@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func (c *cli) volumes(ctx context.Context, args []string) error {
+	return subcommand(ctx, "volumes", args, map[string]func(context.Context, []string) error{
+		"backup": c.volumesBackup,
+	})
+}
+
+// volumesBackup downloads a volume archive and checks it against the
+// checksum the server sends after the body
+func (c *cli) volumesBackup(ctx context.Context, args []string) error {
+	fs := newFlags("volumes backup", "[-f FILE] [--stop] [--no-compress] VOLUME")
+	file := fs.String("f", "", "output file, - for stdout (default: VOLUME-TIMESTAMP.tar.gz)")
+	stop := fs.Bool("stop", false, "stop containers using the volume during the backup")
+	noCompress := fs.Bool("no-compress", false, "write a plain tar archive")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(fs, args, 1); err != nil {
+		return err
+	}
+	volume := args[0]
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *stop {
+		query.Set("stop", "true")
+	}
+	if *noCompress {
+		query.Set("compress", "none")
+	}
+	resp, err := api.request(ctx, "POST", "/api/volumes/"+url.PathEscape(volume)+"/backup?"+query.Encode(), nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	path := *file
+	if path == "" {
+		path = fmt.Sprintf("%s-%s.tar", volume, time.Now().UTC().Format("20060102-150405"))
+		if !*noCompress {
+			path += ".gz"
+		}
+	}
+	var out io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), resp.Body)
+	if err != nil {
+		if path != "-" {
+			os.Remove(path)
+		}
+		return fmt.Errorf("backup transfer failed: %w", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if expected := resp.Trailer.Get("X-Checksum-Sha256"); expected != "" && expected != sum {
+		return fmt.Errorf("checksum mismatch: server sent %s, received %s", expected, sum)
+	}
+	if path == "-" {
+		return nil
+	}
+
+	result := map[string]interface{}{"volume": volume, "file": path, "size": size, "sha256": sum}
+	return c.print(result, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "VOLUME\tFILE\tSIZE\tSHA256")
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", volume, path, size, sum)
+	})
+}
@@ -1,41 +1,474 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Version is the Sunspear build version, set at build time with
+// -ldflags "-X sunspear/config.Version=<version>"
+var Version = "dev"
+
+// Config is read from an optional YAML file, then environment variables,
+// then command-line flags, each overriding the last. File keys are the
+// lowercase environment variable names (e.g. jwt_secret, ip_allowlist).
 type Config struct {
-	Port              string
-	JWTSecret         string
-	AdminPasswordHash string
-	FrontendURL       string
-	SetupBootstrapToken string
+	Port                string `yaml:"port"`
+	JWTSecret           string `yaml:"jwt_secret"`
+	AdminPasswordHash   string `yaml:"admin_password_hash"`
+	FrontendURL         string `yaml:"frontend_url"`
+	SetupBootstrapToken string `yaml:"setup_bootstrap_token"`
+	// SessionCookieSecure marks session cookies Secure. Only disable for
+	// local development over plain HTTP.
+	SessionCookieSecure bool `yaml:"session_cookie_secure"`
+
+	// DataDir holds the database, the app catalog and compose templates.
+	DataDir string `yaml:"data_dir"`
+	// ListenAddr is the address the API server binds; it defaults to :Port.
+	ListenAddr      string        `yaml:"listen_addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// DockerHost overrides the Docker daemon address (e.g.
+	// unix:///var/run/docker.sock or tcp://host:2375).
+	DockerHost string `yaml:"docker_host"`
+
+	// InsecureRegistries (host or host:port) are queried over plain HTTP
+	// when checking for image updates. Loopback registries always are.
+	InsecureRegistries []string `yaml:"insecure_registries"`
+
+	// SecretsKey encrypts credentials stored in the database. It defaults
+	// to JWTSecret; set it separately so the JWT secret can be rotated.
+	SecretsKey string `yaml:"secrets_key"`
+
+	// CaddyAdminURL is the Caddy admin API that app routes are pushed to,
+	// an http(s) URL or unix:///path/to/socket; empty disables automatic
+	// routes. Routes without a full hostname are placed under ProxyDomain,
+	// and routed containers are connected to ProxyNetwork.
+	CaddyAdminURL string `yaml:"caddy_admin_url"`
+	ProxyDomain   string `yaml:"proxy_domain"`
+	ProxyNetwork  string `yaml:"proxy_network"`
+
+	// BackupRoot is the directory local backup targets must live in; empty
+	// disables local targets.
+	BackupRoot string `yaml:"backup_root"`
+
+	Reloadable `yaml:",inline"`
+
+	// path is the config file that was read, if any
+	path string
+	// origin names settings, keyed by environment variable, that were set
+	// by the config file or a flag, so errors can name them as written
+	origin map[string]string
+}
+
+// Reloadable settings are re-read from the config file and environment on
+// SIGHUP. Read them through Config.Live once the server is running.
+type Reloadable struct {
+	// TrustedProxies are peers (CIDRs or IPs) whose X-Forwarded-For and
+	// X-Real-IP headers are honored when determining the client IP.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// IPAllowList/IPDenyList apply to every /api route; the Admin variants
+	// additionally apply to administrative routes (users, settings, audit).
+	IPAllowList      []string `yaml:"ip_allowlist"`
+	IPDenyList       []string `yaml:"ip_denylist"`
+	AdminIPAllowList []string `yaml:"admin_ip_allowlist"`
+	AdminIPDenyList  []string `yaml:"admin_ip_denylist"`
+
+	// LoginLockoutThreshold failed logins for one username lock it for
+	// LoginLockoutDuration. A threshold of 0 disables lockout.
+	LoginLockoutThreshold int           `yaml:"login_lockout_threshold"`
+	LoginLockoutDuration  time.Duration `yaml:"login_lockout_duration"`
+
+	// AuthRateLimit requests per client IP are allowed to the login and
+	// setup endpoints within AuthRateWindow.
+	AuthRateLimit  int           `yaml:"auth_rate_limit"`
+	AuthRateWindow time.Duration `yaml:"auth_rate_window"`
+
+	// SessionLifetime is how long issued tokens and session cookies stay
+	// valid. Changes apply to sessions issued afterwards.
+	SessionLifetime time.Duration `yaml:"session_lifetime"`
+
+	// MonitorInterval is how often host metrics are sampled.
+	MonitorInterval time.Duration `yaml:"monitor_interval"`
+}
+
+// Flags are command-line overrides; empty values leave a setting alone
+type Flags struct {
+	ConfigFile string
+	DataDir    string
+	ListenAddr string
+	DockerHost string
+}
+
+// defaultConfigFile is read when present and no file is named explicitly
+const defaultConfigFile = "sunspear.yaml"
+
+var liveMu sync.RWMutex
+
+// Load builds the configuration from defaults, the config file, the
+// environment and flags. The file is flags.ConfigFile, else SUNSPEAR_CONFIG,
+// else sunspear.yaml in the working directory if it exists.
+func Load(flags Flags) (*Config, error) {
+	cfg := &Config{
+		Port:                "8080",
+		FrontendURL:         "http://localhost:3000",
+		SessionCookieSecure: true,
+		DataDir:             "./data",
+		ReadTimeout:         15 * time.Second,
+		WriteTimeout:        600 * time.Second,
+		IdleTimeout:         60 * time.Second,
+		ShutdownTimeout:     30 * time.Second,
+		ProxyNetwork:        "sunspear-proxy",
+		ProxyDomain:         os.Getenv("PUBLIC_DOMAIN"),
+		Reloadable: Reloadable{
+			LoginLockoutThreshold: 5,
+			LoginLockoutDuration:  15 * time.Minute,
+			AuthRateLimit:         5,
+			AuthRateWindow:        time.Minute,
+			SessionLifetime:       7 * 24 * time.Hour,
+			MonitorInterval:       5 * time.Second,
+		},
+		origin: make(map[string]string),
+	}
+
+	path, explicit := flags.ConfigFile, true
+	if path == "" {
+		path = os.Getenv("SUNSPEAR_CONFIG")
+	}
+	if path == "" {
+		path, explicit = defaultConfigFile, false
+	}
+	if err := cfg.readFile(path); err != nil {
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	} else {
+		cfg.path = path
+	}
+
+	env := envReader{cfg: cfg}
+	cfg.Port = env.str("PORT", cfg.Port)
+	cfg.JWTSecret = env.str("JWT_SECRET", cfg.JWTSecret)
+	cfg.AdminPasswordHash = env.str("ADMIN_PASSWORD_HASH", cfg.AdminPasswordHash)
+	cfg.FrontendURL = env.str("FRONTEND_URL", cfg.FrontendURL)
+	cfg.SetupBootstrapToken = env.str("SETUP_BOOTSTRAP_TOKEN", cfg.SetupBootstrapToken)
+	cfg.SessionCookieSecure = env.bool("SESSION_COOKIE_SECURE", cfg.SessionCookieSecure)
+	cfg.DataDir = env.str("DATA_DIR", cfg.DataDir)
+	cfg.ListenAddr = env.str("LISTEN_ADDR", cfg.ListenAddr)
+	cfg.ReadTimeout = env.duration("READ_TIMEOUT", cfg.ReadTimeout)
+	cfg.WriteTimeout = env.duration("WRITE_TIMEOUT", cfg.WriteTimeout)
+	cfg.IdleTimeout = env.duration("IDLE_TIMEOUT", cfg.IdleTimeout)
+	cfg.ShutdownTimeout = env.duration("SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout)
+	cfg.DockerHost = env.str("DOCKER_HOST", cfg.DockerHost)
+	cfg.InsecureRegistries = env.list("INSECURE_REGISTRIES", cfg.InsecureRegistries)
+	cfg.SecretsKey = env.str("SECRETS_KEY", cfg.SecretsKey)
+	cfg.CaddyAdminURL = env.str("CADDY_ADMIN_URL", cfg.CaddyAdminURL)
+	cfg.ProxyDomain = env.str("PROXY_DOMAIN", cfg.ProxyDomain)
+	cfg.ProxyNetwork = env.str("PROXY_NETWORK", cfg.ProxyNetwork)
+	cfg.BackupRoot = env.str("BACKUP_ROOT", cfg.BackupRoot)
+	cfg.TrustedProxies = env.list("TRUSTED_PROXIES", cfg.TrustedProxies)
+	cfg.IPAllowList = env.list("IP_ALLOWLIST", cfg.IPAllowList)
+	cfg.IPDenyList = env.list("IP_DENYLIST", cfg.IPDenyList)
+	cfg.AdminIPAllowList = env.list("ADMIN_IP_ALLOWLIST", cfg.AdminIPAllowList)
+	cfg.AdminIPDenyList = env.list("ADMIN_IP_DENYLIST", cfg.AdminIPDenyList)
+	cfg.LoginLockoutThreshold = env.int("LOGIN_LOCKOUT_THRESHOLD", cfg.LoginLockoutThreshold)
+	cfg.LoginLockoutDuration = env.duration("LOGIN_LOCKOUT_DURATION", cfg.LoginLockoutDuration)
+	cfg.AuthRateLimit = env.int("AUTH_RATE_LIMIT", cfg.AuthRateLimit)
+	cfg.AuthRateWindow = env.duration("AUTH_RATE_WINDOW", cfg.AuthRateWindow)
+	cfg.SessionLifetime = env.duration("SESSION_LIFETIME", cfg.SessionLifetime)
+	cfg.MonitorInterval = env.duration("MONITOR_INTERVAL", cfg.MonitorInterval)
+	if err := errors.Join(env.errs...); err != nil {
+		return nil, err
+	}
+
+	if flags.DataDir != "" {
+		cfg.DataDir = flags.DataDir
+		cfg.origin["DATA_DIR"] = "--data-dir"
+	}
+	if flags.ListenAddr != "" {
+		cfg.ListenAddr = flags.ListenAddr
+		cfg.origin["LISTEN_ADDR"] = "--listen"
+	}
+	if flags.DockerHost != "" {
+		cfg.DockerHost = flags.DockerHost
+		cfg.origin["DOCKER_HOST"] = "--docker-host"
+	}
+
+	if cfg.ListenAddr == "" {
+		cfg.ListenAddr = ":" + cfg.Port
+	}
+	if cfg.SecretsKey == "" {
+		cfg.SecretsKey = cfg.JWTSecret
+	}
+	return cfg, nil
+}
+
+// readFile decodes a YAML config file over the current values. Unknown keys
+// are rejected so typos do not silently fall back to defaults.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var keys map[string]interface{}
+	yaml.Unmarshal(data, &keys)
+	for key := range keys {
+		c.origin[strings.ToUpper(key)] = key
+	}
+	return nil
+}
+
+// File returns the config file that was read, or "" if none was
+func (c *Config) File() string {
+	return c.path
+}
+
+// DatabasePath is the SQLite database inside DataDir
+func (c *Config) DatabasePath() string {
+	return filepath.Join(c.DataDir, "database", "sunspear.db")
+}
+
+// AppsDir holds the app catalog and compose templates inside DataDir
+func (c *Config) AppsDir() string {
+	return filepath.Join(c.DataDir, "apps")
 }
 
-func Load() *Config {
-	return &Config{
-		Port:              getEnv("PORT", "8080"),
-		JWTSecret:         getEnv("JWT_SECRET", "change-me-in-production"),
-		AdminPasswordHash: getEnv("ADMIN_PASSWORD_HASH", ""),
-		FrontendURL:       getEnv("FRONTEND_URL", "http://localhost:3000"),
-		SetupBootstrapToken: getEnv("SETUP_BOOTSTRAP_TOKEN", ""),
+// Live returns the current reloadable settings
+func (c *Config) Live() Reloadable {
+	liveMu.RLock()
+	defer liveMu.RUnlock()
+	return c.Reloadable
+}
+
+// Reload takes the reloadable settings from next and returns the keys of
+// changed settings that only take effect after a restart
+func (c *Config) Reload(next *Config) []string {
+	liveMu.Lock()
+	c.Reloadable = next.Reloadable
+	liveMu.Unlock()
+
+	var pending []string
+	current, updated := reflect.ValueOf(*c), reflect.ValueOf(*next)
+	for i := 0; i < current.NumField(); i++ {
+		field := current.Type().Field(i)
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		if !reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			pending = append(pending, strings.Split(field.Tag.Get("yaml"), ",")[0])
+		}
 	}
+	return pending
+}
+
+// setting names a setting the way it was given: its config file key or flag,
+// else its environment variable
+func (c *Config) setting(env string) string {
+	if name, ok := c.origin[env]; ok {
+		return name
+	}
+	return env
 }
 
 func (c *Config) Validate() error {
 	if c.JWTSecret == "" || c.JWTSecret == "change-me-in-production" {
-		return fmt.Errorf("JWT_SECRET must be set to a strong, non-default value")
+		return fmt.Errorf("%s must be set to a strong, non-default value", c.setting("JWT_SECRET"))
 	}
 	if c.FrontendURL == "" {
-		return fmt.Errorf("FRONTEND_URL must be set")
+		return fmt.Errorf("%s must be set", c.setting("FRONTEND_URL"))
+	}
+
+	cidrLists := map[string][]string{
+		"TRUSTED_PROXIES":    c.TrustedProxies,
+		"IP_ALLOWLIST":       c.IPAllowList,
+		"IP_DENYLIST":        c.IPDenyList,
+		"ADMIN_IP_ALLOWLIST": c.AdminIPAllowList,
+		"ADMIN_IP_DENYLIST":  c.AdminIPDenyList,
+	}
+	for name, list := range cidrLists {
+		if _, err := ParseCIDRs(list); err != nil {
+			return fmt.Errorf("%s: %w", c.setting(name), err)
+		}
+	}
+
+	if c.CaddyAdminURL != "" {
+		u, err := url.Parse(c.CaddyAdminURL)
+		valid := err == nil && (((u.Scheme == "http" || u.Scheme == "https") && u.Host != "") ||
+			(u.Scheme == "unix" && u.Host == "" && u.Path != ""))
+		if !valid {
+			return fmt.Errorf("%s must be an http or https URL or unix:///path/to/socket", c.setting("CADDY_ADMIN_URL"))
+		}
+	}
+
+	if c.BackupRoot != "" && !filepath.IsAbs(c.BackupRoot) {
+		return fmt.Errorf("%s must be an absolute path", c.setting("BACKUP_ROOT"))
+	}
+
+	if c.DataDir == "" {
+		return fmt.Errorf("%s must not be empty", c.setting("DATA_DIR"))
+	}
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		return fmt.Errorf("%s must be host:port: %w", c.setting("LISTEN_ADDR"), err)
+	}
+	durations := map[string]time.Duration{
+		"READ_TIMEOUT":     c.ReadTimeout,
+		"WRITE_TIMEOUT":    c.WriteTimeout,
+		"IDLE_TIMEOUT":     c.IdleTimeout,
+		"SHUTDOWN_TIMEOUT": c.ShutdownTimeout,
+		"SESSION_LIFETIME": c.SessionLifetime,
+		"AUTH_RATE_WINDOW": c.AuthRateWindow,
+	}
+	for name, d := range durations {
+		if d <= 0 {
+			return fmt.Errorf("%s must be positive", c.setting(name))
+		}
+	}
+	if c.MonitorInterval < time.Second {
+		return fmt.Errorf("%s must be at least 1s", c.setting("MONITOR_INTERVAL"))
+	}
+	if c.AuthRateLimit <= 0 {
+		return fmt.Errorf("%s must be positive", c.setting("AUTH_RATE_LIMIT"))
+	}
+	if c.DockerHost != "" {
+		if u, err := url.Parse(c.DockerHost); err != nil || u.Scheme == "" {
+			return fmt.Errorf("%s must be a URL such as unix:///var/run/docker.sock or tcp://host:2375", c.setting("DOCKER_HOST"))
+		}
+	}
+
+	if c.LoginLockoutThreshold < 0 {
+		return fmt.Errorf("%s must not be negative", c.setting("LOGIN_LOCKOUT_THRESHOLD"))
+	}
+	if c.LoginLockoutThreshold > 0 && c.LoginLockoutDuration <= 0 {
+		return fmt.Errorf("%s must be positive", c.setting("LOGIN_LOCKOUT_DURATION"))
 	}
 	return nil
 }
 
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
+// ParseCIDRs parses a list of CIDRs. Bare IP addresses are treated as
+// single-host networks.
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, entry := range list {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// envReader applies environment overrides, collecting malformed values so
+// they fail Load instead of silently keeping the previous value
+type envReader struct {
+	cfg  *Config
+	errs []error
+}
+
+// lookup returns a non-empty variable, which now overrides the config file
+func (e *envReader) lookup(key string) (string, bool) {
+	value := os.Getenv(key)
+	if value == "" {
+		return "", false
+	}
+	delete(e.cfg.origin, key)
+	return value, true
+}
+
+func (e *envReader) str(key, fallback string) string {
+	if value, ok := e.lookup(key); ok {
 		return value
 	}
 	return fallback
 }
+
+// list splits a comma-separated variable, dropping empty entries
+func (e *envReader) list(key string, fallback []string) []string {
+	value, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (e *envReader) bool(key string, fallback bool) bool {
+	value, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be true or false, got %q", key, value))
+		return fallback
+	}
+	return b
+}
+
+func (e *envReader) int(key string, fallback int) int {
+	value, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be an integer, got %q", key, value))
+		return fallback
+	}
+	return n
+}
+
+func (e *envReader) duration(key string, fallback time.Duration) time.Duration {
+	value, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a duration such as 30s or 5m, got %q", key, value))
+		return fallback
+	}
+	return d
+}
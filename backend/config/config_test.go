@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRejectsMalformedEnv(t *testing.T) {
+	t.Setenv("AUTH_RATE_LIMIT", "abc")
+	t.Setenv("MONITOR_INTERVAL", "5")
+	t.Setenv("SESSION_COOKIE_SECURE", "maybe")
+
+	_, err := Load(Flags{ConfigFile: writeConfig(t, "")})
+	if err == nil {
+		t.Fatal("Load accepted malformed values")
+	}
+	for _, want := range []string{"AUTH_RATE_LIMIT", "MONITOR_INTERVAL", "SESSION_COOKIE_SECURE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not name %s", err, want)
+		}
+	}
+}
+
+func TestValidateNamesSettingSource(t *testing.T) {
+	t.Setenv("JWT_SECRET", "a-strong-test-secret")
+	path := writeConfig(t, "monitor_interval: 10ms\n")
+
+	cfg, err := Load(Flags{ConfigFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), "monitor_interval ") {
+		t.Errorf("Validate = %v, want the YAML key", err)
+	}
+
+	t.Setenv("MONITOR_INTERVAL", "10ms")
+	if cfg, err = Load(Flags{ConfigFile: path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), "MONITOR_INTERVAL ") {
+		t.Errorf("Validate = %v, want the environment variable", err)
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sunspear.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
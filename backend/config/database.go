@@ -2,15 +2,16 @@ package config
 
 import (
 	"database/sql"
+	"errors"
+	"log"
 	"os"
 	"path/filepath"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
-func InitDB() (*sql.DB, error) {
+func InitDB(dbPath string) (*sql.DB, error) {
 	// Ensure database directory exists
-	dbPath := "./data/database/sunspear.db"
 	dbDir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
 		return nil, err
@@ -31,57 +32,59 @@ func InitDB() (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Create tables
-	if err := createTables(db); err != nil {
+	// Bring the schema up to date
+	if err := Migrate(db, dbPath); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func createTables(db *sql.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT UNIQUE NOT NULL,
-		password_hash TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
+// Migrate applies pending migrations to the database at dbPath and sets up
+// the optional search index
+func Migrate(db *sql.DB, dbPath string) error {
+	if err := migrate(db, dbPath); err != nil {
+		return err
+	}
+	createLogSearchIndex(db)
+	return nil
+}
 
-	CREATE TABLE IF NOT EXISTS installed_apps (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		app_id TEXT NOT NULL,
-		app_name TEXT NOT NULL,
-		container_ids TEXT NOT NULL,
-		config TEXT,
-		status TEXT DEFAULT 'unknown',
-		installed_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
+// IsUniqueViolation reports whether err is a UNIQUE or PRIMARY KEY
+// constraint failure
+func IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
 
-	CREATE TABLE IF NOT EXISTS settings (
-		key TEXT PRIMARY KEY,
-		value TEXT NOT NULL,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
+// createLogSearchIndex adds an FTS5 index over captured log lines. FTS5 is
+// only compiled into go-sqlite3 with the sqlite_fts5 build tag; without it
+// log search falls back to substring matching.
+func createLogSearchIndex(db *sql.DB) {
+	var name string
+	exists := db.QueryRow("SELECT name FROM sqlite_master WHERE name = 'log_entries_fts'").Scan(&name) == nil
 
-	CREATE TABLE IF NOT EXISTS compose_projects (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT UNIQUE NOT NULL,
-		description TEXT DEFAULT '',
-		yaml_content TEXT NOT NULL,
-		status TEXT DEFAULT 'stopped',
-		container_ids TEXT DEFAULT '[]',
-		network_ids TEXT DEFAULT '[]',
-		volume_names TEXT DEFAULT '[]',
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
+	_, err := db.Exec(`
+	CREATE VIRTUAL TABLE IF NOT EXISTS log_entries_fts USING fts5(message, content='log_entries', content_rowid='id');
 
-	CREATE INDEX IF NOT EXISTS idx_installed_apps_app_id ON installed_apps(app_id);
-	CREATE INDEX IF NOT EXISTS idx_installed_apps_status ON installed_apps(status);
-	CREATE INDEX IF NOT EXISTS idx_compose_projects_status ON compose_projects(status);
-	`
+	CREATE TRIGGER IF NOT EXISTS log_entries_fts_insert AFTER INSERT ON log_entries BEGIN
+		INSERT INTO log_entries_fts(rowid, message) VALUES (new.id, new.message);
+	END;
 
-	_, err := db.Exec(schema)
-	return err
+	CREATE TRIGGER IF NOT EXISTS log_entries_fts_delete AFTER DELETE ON log_entries BEGIN
+		INSERT INTO log_entries_fts(log_entries_fts, rowid, message) VALUES ('delete', old.id, old.message);
+	END;
+	`)
+	if err != nil {
+		log.Printf("Full-text log search unavailable: %v", err)
+		return
+	}
+	if !exists {
+		// Index lines captured while FTS5 was unavailable
+		if _, err := db.Exec("INSERT INTO log_entries_fts(log_entries_fts) VALUES ('rebuild')"); err != nil {
+			log.Printf("Failed to build log search index: %v", err)
+		}
+	}
 }
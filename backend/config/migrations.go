@@ -0,0 +1,229 @@
+package config
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationBackupsKept is how many pre-migration database copies are kept
+const migrationBackupsKept = 3
+
+// Migration is one schema change. SQL migrations are embedded files named
+// NNNN_description.sql; changes SQL cannot express register a Go function
+// in goMigrations. Each migration runs in its own transaction.
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Up       func(tx *sql.Tx) error
+	checksum string
+}
+
+// goMigrations are applied in version order alongside the SQL files
+var goMigrations = []Migration{}
+
+// loadMigrations returns every migration ordered by version
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", name)
+		}
+		data, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     strings.TrimSuffix(name, ".sql"),
+			SQL:      string(data),
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	for _, m := range goMigrations {
+		sum := sha256.Sum256([]byte(m.Name))
+		m.checksum = "go:" + hex.EncodeToString(sum[:])
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for _, m := range migrations {
+		if other, dup := seen[m.Version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, m.Name, m.Version)
+		}
+		seen[m.Version] = m.Name
+	}
+	return migrations, nil
+}
+
+// LatestSchemaVersion is the newest schema version this build knows
+func LatestSchemaVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// SchemaVersion returns the newest migration applied to db, 0 if none
+func SchemaVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// migrate applies pending migrations. Before changing an existing database
+// it saves a copy next to dbPath. A database migrated by a newer Sunspear is
+// refused rather than risk running against a schema this build does not know.
+func migrate(db *sql.DB, dbPath string) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[int]string)
+	rows, err := db.Query("SELECT version, checksum FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	current := 0
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = checksum
+		if version > current {
+			current = version
+		}
+	}
+	rows.Close()
+
+	latest := 0
+	var pending []Migration
+	for _, m := range migrations {
+		latest = m.Version
+		checksum, done := applied[m.Version]
+		if !done {
+			pending = append(pending, m)
+			continue
+		}
+		if checksum != m.checksum {
+			log.Printf("Warning: migration %s changed after it was applied", m.Name)
+		}
+	}
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d); upgrade Sunspear or restore a backup", current, latest)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if hasUserTables(db) {
+		backup, err := backupDatabase(db, dbPath, current)
+		if err != nil {
+			return fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+		log.Printf("Saved database backup to %s", backup)
+	}
+
+	for _, m := range pending {
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.Name, err)
+		}
+		log.Printf("Applied migration %s", m.Name)
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.SQL != "" {
+		if _, err := tx.Exec(m.SQL); err != nil {
+			return err
+		}
+	}
+	if m.Up != nil {
+		if err := m.Up(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)", m.Version, m.Name, m.checksum); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hasUserTables reports whether the database holds anything besides the
+// migration bookkeeping, i.e. whether there is data worth backing up
+func hasUserTables(db *sql.DB) bool {
+	var n int
+	db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')
+	`).Scan(&n)
+	return n > 0
+}
+
+// backupDatabase writes a consistent copy of the database (including WAL
+// contents) and prunes older copies
+func backupDatabase(db *sql.DB, dbPath string, version int) (string, error) {
+	backup := fmt.Sprintf("%s.v%d-%s.bak", dbPath, version, time.Now().UTC().Format("20060102-150405"))
+	if _, err := db.Exec("VACUUM INTO ?", backup); err != nil {
+		return "", err
+	}
+
+	old, _ := filepath.Glob(dbPath + ".v*.bak")
+	sort.Slice(old, func(i, j int) bool {
+		a, _ := os.Stat(old[i])
+		b, _ := os.Stat(old[j])
+		return a != nil && b != nil && a.ModTime().After(b.ModTime())
+	})
+	for i := migrationBackupsKept; i < len(old); i++ {
+		os.Remove(old[i])
+	}
+	return backup, nil
+}
@@ -0,0 +1,242 @@
+-- Baseline schema. Statements are idempotent so databases created before
+-- versioned migrations adopt it without changes.
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS installed_apps (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	app_id TEXT NOT NULL,
+	app_name TEXT NOT NULL,
+	container_ids TEXT NOT NULL,
+	config TEXT,
+	status TEXT DEFAULT 'unknown',
+	installed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS compose_projects (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	description TEXT DEFAULT '',
+	yaml_content TEXT NOT NULL,
+	status TEXT DEFAULT 'stopped',
+	container_ids TEXT DEFAULT '[]',
+	network_ids TEXT DEFAULT '[]',
+	volume_names TEXT DEFAULT '[]',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	user_id INTEGER DEFAULT 0,
+	username TEXT DEFAULT '',
+	action TEXT NOT NULL,
+	resource TEXT DEFAULT '',
+	params TEXT DEFAULT '',
+	result TEXT NOT NULL,
+	status INTEGER DEFAULT 0,
+	error TEXT DEFAULT '',
+	client_ip TEXT DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS login_failures (
+	username TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL DEFAULT 0,
+	last_failure DATETIME,
+	locked_until DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS volume_archives (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	volume_name TEXT NOT NULL,
+	operation TEXT NOT NULL,
+	compressed BOOLEAN DEFAULT 0,
+	size_bytes INTEGER DEFAULT 0,
+	sha256 TEXT DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS backup_targets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	type TEXT NOT NULL,
+	config TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS backup_policies (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	target_id INTEGER NOT NULL,
+	sources TEXT NOT NULL,
+	schedule TEXT NOT NULL,
+	keep_last INTEGER DEFAULT 0,
+	keep_daily INTEGER DEFAULT 0,
+	keep_weekly INTEGER DEFAULT 0,
+	stop_containers BOOLEAN DEFAULT 0,
+	enabled BOOLEAN DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (target_id) REFERENCES backup_targets(id)
+);
+
+CREATE TABLE IF NOT EXISTS backup_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	policy_id INTEGER NOT NULL,
+	trigger TEXT NOT NULL,
+	status TEXT NOT NULL,
+	message TEXT DEFAULT '',
+	artifacts TEXT DEFAULT '[]',
+	pruned TEXT DEFAULT '[]',
+	started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	finished_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS image_updates (
+	image TEXT PRIMARY KEY,
+	local_digest TEXT DEFAULT '',
+	remote_digest TEXT DEFAULT '',
+	newer_tag TEXT DEFAULT '',
+	update_available BOOLEAN DEFAULT 0,
+	error TEXT DEFAULT '',
+	checked_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS update_policies (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	scope TEXT NOT NULL,
+	target TEXT NOT NULL,
+	policy TEXT NOT NULL,
+	window TEXT DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(scope, target)
+);
+
+CREATE TABLE IF NOT EXISTS update_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	container_name TEXT NOT NULL,
+	container_id TEXT DEFAULT '',
+	image TEXT DEFAULT '',
+	digest TEXT DEFAULT '',
+	action TEXT NOT NULL,
+	status TEXT NOT NULL,
+	message TEXT DEFAULT '',
+	trigger TEXT DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS image_builds (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	source TEXT NOT NULL,
+	tags TEXT DEFAULT '[]',
+	options TEXT DEFAULT '{}',
+	status TEXT NOT NULL,
+	image_id TEXT DEFAULT '',
+	error TEXT DEFAULT '',
+	log TEXT DEFAULT '',
+	started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	finished_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS image_analyses (
+	image_id TEXT PRIMARY KEY,
+	result TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS registry_credentials (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	registry TEXT UNIQUE NOT NULL,
+	username TEXT NOT NULL,
+	password TEXT NOT NULL,
+	last_login_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS log_capture_targets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	scope TEXT NOT NULL,
+	target TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(scope, target)
+);
+
+CREATE TABLE IF NOT EXISTS log_entries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	container_id TEXT NOT NULL,
+	container TEXT NOT NULL,
+	project TEXT DEFAULT '',
+	stream TEXT NOT NULL,
+	ts INTEGER NOT NULL,
+	message TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS docker_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	ts INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	action TEXT NOT NULL,
+	resource_id TEXT DEFAULT '',
+	resource_name TEXT DEFAULT '',
+	attributes TEXT DEFAULT '{}'
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	schedule TEXT NOT NULL,
+	action TEXT NOT NULL,
+	target TEXT DEFAULT '',
+	config TEXT DEFAULT '{}',
+	concurrency TEXT DEFAULT 'forbid',
+	missed_runs TEXT DEFAULT 'skip',
+	enabled BOOLEAN DEFAULT 1,
+	last_scheduled_at INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS task_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	trigger TEXT NOT NULL,
+	status TEXT NOT NULL,
+	message TEXT DEFAULT '',
+	output TEXT DEFAULT '',
+	exit_code INTEGER,
+	started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_installed_apps_app_id ON installed_apps(app_id);
+CREATE INDEX IF NOT EXISTS idx_installed_apps_status ON installed_apps(status);
+CREATE INDEX IF NOT EXISTS idx_compose_projects_status ON compose_projects(status);
+CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log(timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
+CREATE INDEX IF NOT EXISTS idx_volume_archives_volume ON volume_archives(volume_name);
+CREATE INDEX IF NOT EXISTS idx_backup_runs_policy ON backup_runs(policy_id);
+CREATE INDEX IF NOT EXISTS idx_update_history_container ON update_history(container_name, digest);
+CREATE INDEX IF NOT EXISTS idx_log_entries_ts ON log_entries(ts);
+CREATE INDEX IF NOT EXISTS idx_log_entries_container ON log_entries(container_id, ts);
+CREATE INDEX IF NOT EXISTS idx_log_entries_project ON log_entries(project, ts);
+CREATE INDEX IF NOT EXISTS idx_task_runs_task ON task_runs(task_id);
+CREATE INDEX IF NOT EXISTS idx_docker_events_ts ON docker_events(ts);
+CREATE INDEX IF NOT EXISTS idx_docker_events_type ON docker_events(type, action);
+CREATE INDEX IF NOT EXISTS idx_docker_events_resource ON docker_events(resource_name);
+
@@ -2,87 +2,198 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sunspear/api"
 	"sunspear/config"
 	"sunspear/services"
 	"syscall"
-	"time"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	var flags config.Flags
+	migrateOnly := flag.Bool("migrate-only", false, "apply database migrations and exit")
+	flag.StringVar(&flags.ConfigFile, "config", "", "YAML config file (default $SUNSPEAR_CONFIG or ./sunspear.yaml)")
+	flag.StringVar(&flags.DataDir, "data-dir", "", "directory for the database, app catalog and templates")
+	flag.StringVar(&flags.ListenAddr, "listen", "", "address to listen on, e.g. :8080")
+	flag.StringVar(&flags.DockerHost, "docker-host", "", "Docker daemon address")
+	restoreFile := flag.String("restore", "", "restore a Sunspear backup archive before starting")
+	redeploy := flag.Bool("redeploy", false, "with --restore, recreate all compose projects and apps")
+	acceptKeyMismatch := flag.Bool("accept-key-mismatch", false, "with --restore, restore a backup made with a different SECRETS_KEY")
+	flag.Parse()
+
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
 
 	// Initialize configuration
-	cfg := config.Load()
+	cfg, err := config.Load(flags)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
+	if cfg.File() != "" {
+		log.Printf("Loaded configuration from %s", cfg.File())
+	}
 
 	// Initialize database
-	db, err := config.InitDB()
+	db, err := config.InitDB(cfg.DatabasePath())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
+	if *migrateOnly {
+		log.Println("Database migrations applied")
+		return
+	}
+
 	// Initialize Docker service
-	dockerService, err := services.NewDockerService()
+	dockerService, err := services.NewDockerService(cfg.DockerHost)
 	if err != nil {
 		log.Fatalf("Failed to initialize Docker service: %v", err)
 	}
 	defer dockerService.Close()
 
 	// Initialize monitoring service
-	monitorService := services.NewMonitoringService()
+	monitorService := services.NewMonitoringService(cfg.MonitorInterval)
 	monitorService.Start()
 	defer monitorService.Stop()
 
 	// Initialize marketplace service
-	marketplaceService := services.NewMarketplaceService(db)
+	marketplaceService := services.NewMarketplaceService(db, cfg.AppsDir())
 	if err := marketplaceService.LoadApps(); err != nil {
 		log.Printf("Warning: Failed to load marketplace apps: %v", err)
 	}
 
 	// Initialize compose service
-	composeService := services.NewComposeService(db, dockerService)
+	composeService := services.NewComposeService(db, dockerService, filepath.Join(cfg.AppsDir(), "compose-templates"))
+
+	// Registry credentials and backup target secrets are encrypted with it,
+	// and self-backups record its fingerprint
+	secretBox, err := services.NewSecretBox(cfg.SecretsKey)
+	if err != nil {
+		log.Fatalf("Failed to initialize secret encryption: %v", err)
+	}
+
+	// Restore a self-backup before anything else reads the database
+	systemBackupService := services.NewSystemBackupService(db, cfg.DatabasePath(), cfg.AppsDir(), dockerService, composeService, marketplaceService, secretBox)
+	if *restoreFile != "" {
+		restoreBackup(systemBackupService, *restoreFile, services.RestoreOptions{Redeploy: *redeploy, AcceptKeyMismatch: *acceptKeyMismatch})
+	}
+
+	// Initialize audit service
+	auditService := services.NewAuditService(db)
+	auditService.Start()
+	defer auditService.Stop()
+
+	// Initialize volume backup service
+	volumeBackupService := services.NewVolumeBackupService(db, dockerService)
+	volumeFileService := services.NewVolumeFileService(dockerService)
+	cloneService := services.NewContainerCloneService(dockerService)
+
+	registryCredentialService := services.NewRegistryCredentialService(db, dockerService, secretBox)
+	dockerService.SetRegistryAuth(registryCredentialService)
+
+	imageBuildService := services.NewImageBuildService(db, dockerService)
+	imageBuildService.RecoverInterrupted()
+	imageAnalysisService := services.NewImageAnalysisService(db, dockerService)
+
+	// Initialize notifications
+	notificationService := services.NewNotificationService(db)
+
+	// Initialize image update checker and automatic updates
+	registryClient := services.NewRegistryClient(cfg.InsecureRegistries)
+	registryClient.SetCredentials(registryCredentialService.Lookup)
+	imageUpdateService := services.NewImageUpdateService(db, dockerService, marketplaceService, registryClient)
+	imageUpdateService.Start()
+	defer imageUpdateService.Stop()
+	autoUpdateService := services.NewAutoUpdateService(db, dockerService, imageUpdateService, notificationService, auditService)
+	autoUpdateService.Start()
+	defer autoUpdateService.Stop()
+
+	// Initialize log capture
+	logCollector := services.NewLogCollectorService(db, dockerService)
+	logCollector.Start()
+	defer logCollector.Stop()
+
+	containerLogService := services.NewContainerLogService(dockerService)
+
+	// Initialize Docker event recording
+	eventService := services.NewEventService(db, dockerService)
+	eventService.Start()
+	defer eventService.Stop()
+
+	// Initialize reverse-proxy routes for labelled containers
+	proxyService := services.NewProxyService(dockerService, eventService, cfg.CaddyAdminURL, cfg.ProxyDomain, cfg.ProxyNetwork)
+	proxyService.Start()
+	defer proxyService.Stop()
+
+	// Initialize task scheduler
+	taskService := services.NewTaskService(db, dockerService, composeService, notificationService, auditService)
+	taskService.Start()
+	defer taskService.Stop()
+
+	// Initialize scheduled backup service
+	backupService := services.NewBackupService(db, dockerService, volumeBackupService, marketplaceService, notificationService, auditService, secretBox, cfg.BackupRoot)
+	backupService.Start()
+	defer backupService.Stop()
 
 	// Create router
-	router := api.NewRouter(cfg, db, dockerService, monitorService, marketplaceService, composeService)
+	router := api.NewRouter(cfg, db, dockerService, monitorService, marketplaceService, composeService, auditService, volumeBackupService, volumeFileService, cloneService, imageUpdateService, autoUpdateService, imageBuildService, imageAnalysisService, registryCredentialService, logCollector, containerLogService, eventService, taskService, proxyService, backupService, systemBackupService)
 
 	// Configure server
 	server := &http.Server{
-		Addr:         ":" + cfg.Port,
+		Addr:         cfg.ListenAddr,
 		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 600 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Starting Sunspear backend on port %s", cfg.Port)
+		log.Printf("Starting Sunspear backend on %s", cfg.ListenAddr)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
 
-	// Graceful shutdown
+	// Reload safe settings on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig(cfg, flags, monitorService)
+		}
+	}()
+
+	// Graceful shutdown, also used to restart after a restore through the
+	// API; the container's restart policy brings the backend back up
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	systemBackupService.SetRestartHook(func() {
+		log.Println("Restarting to load the restored state")
+		select {
+		case quit <- syscall.SIGTERM:
+		default:
+		}
+	})
 	<-quit
 
 	log.Println("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
@@ -91,3 +202,47 @@ func main() {
 
 	log.Println("Server exited")
 }
+
+// restoreBackup applies a self-backup archive at startup, exiting on failure
+func restoreBackup(systemBackupService *services.SystemBackupService, path string, opts services.RestoreOptions) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open backup: %v", err)
+	}
+	defer f.Close()
+
+	result, err := systemBackupService.Restore(context.Background(), f, opts)
+	if err != nil {
+		log.Fatalf("Failed to restore backup: %v", err)
+	}
+	log.Printf("Previous database saved to %s", result.PreRestoreBackup)
+	for _, res := range result.Redeployed {
+		if res.Error != "" {
+			log.Printf("Redeploy %s %s: %s: %s", res.Kind, res.Name, res.Status, res.Error)
+		} else {
+			log.Printf("Redeploy %s %s: %s", res.Kind, res.Name, res.Status)
+		}
+	}
+}
+
+// reloadConfig re-reads the configuration and applies the settings that can
+// change without a restart. An invalid configuration is logged and ignored.
+func reloadConfig(cfg *config.Config, flags config.Flags, monitorService *services.MonitoringService) {
+	next, err := config.Load(flags)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		log.Printf("Configuration reload rejected: %v", err)
+		return
+	}
+
+	pending := cfg.Reload(next)
+	live := cfg.Live()
+	api.ApplySettings(live)
+	monitorService.SetInterval(live.MonitorInterval)
+	log.Println("Configuration reloaded")
+	if len(pending) > 0 {
+		log.Printf("Restart to apply changed settings: %s", strings.Join(pending, ", "))
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEntry is a single record in the audit log
+type AuditEntry struct {
+	ID        int64  `json:"id"`
+	Timestamp string `json:"timestamp"`
+	UserID    int    `json:"userId"`
+	Username  string `json:"username"`
+	Action    string `json:"action"`
+	Resource  string `json:"resource"`
+	Params    string `json:"params"`
+	Result    string `json:"result"`
+	Status    int    `json:"status"`
+	Error     string `json:"error,omitempty"`
+	ClientIP  string `json:"clientIp"`
+}
+
+// AuditFilter narrows an audit log query
+type AuditFilter struct {
+	UserID   int
+	Username string
+	Action   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+
+	// AuditSystemUser is the username of entries for background work such
+	// as scheduled tasks, automatic updates and backup runs
+	AuditSystemUser = "system"
+
+	// auditRetentionSetting is the settings key holding the retention period in days
+	auditRetentionSetting  = "audit_retention_days"
+	defaultAuditRetention  = 90
+	auditPruneInterval     = time.Hour
+	auditTimestampLayout   = "2006-01-02 15:04:05"
+	maxAuditQueryLimit     = 10000
+	defaultAuditQueryLimit = 100
+)
+
+// AuditService records mutating operations and prunes old entries
+type AuditService struct {
+	db       *sql.DB
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{
+		db:       db,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start launches the background retention loop
+func (s *AuditService) Start() {
+	go s.pruneLoop()
+}
+
+func (s *AuditService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+}
+
+// Record stores an audit entry. Failures are logged rather than returned to
+// callers so that auditing never blocks the operation being audited.
+func (s *AuditService) Record(entry AuditEntry) {
+	if entry.Result == "" {
+		entry.Result = AuditResultSuccess
+		if entry.Status >= 400 {
+			entry.Result = AuditResultFailure
+		}
+	}
+	if entry.Username == "" && entry.UserID > 0 {
+		s.db.QueryRow("SELECT username FROM users WHERE id = ?", entry.UserID).Scan(&entry.Username)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO audit_log (user_id, username, action, resource, params, result, status, error, client_ip)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.UserID, entry.Username, entry.Action, entry.Resource, entry.Params, entry.Result, entry.Status, entry.Error, entry.ClientIP)
+	if err != nil {
+		log.Printf("Failed to record audit entry %s: %v", entry.Action, err)
+	}
+}
+
+// RecordSystem records a change made in the background rather than by a
+// request. params is stored as redacted JSON; a non-nil err marks the entry
+// failed.
+func (s *AuditService) RecordSystem(action, resource string, params interface{}, err error) {
+	if s == nil {
+		return
+	}
+	entry := AuditEntry{Username: AuditSystemUser, Action: action, Resource: resource, Result: AuditResultSuccess}
+	if params != nil {
+		if data, marshalErr := json.Marshal(params); marshalErr == nil {
+			entry.Params = RedactParams(data)
+		}
+	}
+	if err != nil {
+		entry.Result = AuditResultFailure
+		entry.Error = err.Error()
+	}
+	s.Record(entry)
+}
+
+// Query returns audit entries matching the filter, newest first
+func (s *AuditService) Query(filter AuditFilter) ([]AuditEntry, error) {
+	var where []string
+	var args []interface{}
+
+	if filter.UserID > 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Username != "" {
+		where = append(where, "username = ?")
+		args = append(args, filter.Username)
+	}
+	if filter.Action != "" {
+		// A trailing "*" matches every action with that prefix, e.g. "containers.*"
+		if strings.HasSuffix(filter.Action, "*") {
+			where = append(where, "action LIKE ? ESCAPE '\\'")
+			args = append(args, escapeLike(strings.TrimSuffix(filter.Action, "*"))+"%")
+		} else {
+			where = append(where, "action = ?")
+			args = append(args, filter.Action)
+		}
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, filter.From.UTC().Format(auditTimestampLayout))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "timestamp <= ?")
+		args = append(args, filter.To.UTC().Format(auditTimestampLayout))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditQueryLimit
+	}
+	if limit > maxAuditQueryLimit {
+		limit = maxAuditQueryLimit
+	}
+
+	query := `SELECT id, timestamp, user_id, username, action, resource, params, result, status, error, client_ip FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.UserID, &e.Username, &e.Action, &e.Resource, &e.Params, &e.Result, &e.Status, &e.Error, &e.ClientIP); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// RetentionDays returns the configured audit retention period
+func (s *AuditService) RetentionDays() int {
+	var value string
+	if err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", auditRetentionSetting).Scan(&value); err != nil {
+		return defaultAuditRetention
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return defaultAuditRetention
+	}
+	return days
+}
+
+// Prune deletes entries older than the retention period. A retention of 0
+// keeps entries forever.
+func (s *AuditService) Prune() (int64, error) {
+	days := s.RetentionDays()
+	if days == 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -days).Format(auditTimestampLayout)
+	result, err := s.db.Exec("DELETE FROM audit_log WHERE timestamp < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *AuditService) pruneLoop() {
+	ticker := time.NewTicker(auditPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if removed, err := s.Prune(); err != nil {
+			log.Printf("Failed to prune audit log: %v", err)
+		} else if removed > 0 {
+			log.Printf("Pruned %d audit log entries", removed)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// sensitiveParamKeys are substrings of parameter names whose values are
+// never written to the audit log
+var sensitiveParamKeys = []string{"password", "secret", "token", "key", "authorization", "credential", "buildargs"}
+
+// fileParamKeys are parameters carrying whole files, such as compose YAML,
+// which may embed secrets anywhere. Only their size and hash are logged.
+var fileParamKeys = map[string]bool{"yaml": true, "yamlcontent": true, "content": true, "dockerfile": true}
+
+// RedactParams serializes request parameters with secret values masked.
+// Non-JSON payloads are summarized by size rather than stored.
+func RedactParams(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(raw))
+	}
+
+	data, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// RedactQuery is RedactParams for a URL query string
+func RedactQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(rawQuery))
+	}
+	for key := range values {
+		if isSensitiveParam(key) {
+			for i := range values[key] {
+				values[key][i] = "[REDACTED]"
+			}
+		}
+	}
+	return values.Encode()
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// Name/value pairs such as app install env entries
+		if name, ok := v["name"].(string); ok && isSensitiveParam(name) {
+			if _, hasValue := v["value"]; hasValue {
+				v["value"] = "[REDACTED]"
+			}
+		}
+		for key, val := range v {
+			if isSensitiveParam(key) {
+				v[key] = "[REDACTED]"
+			} else if str, ok := val.(string); ok && fileParamKeys[strings.ToLower(key)] {
+				v[key] = fmt.Sprintf("[%d bytes, sha256:%x]", len(str), sha256.Sum256([]byte(str)))
+			} else {
+				v[key] = redactValue(val)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+		// Env-style entries ("NAME=value") carry secrets in their value half
+		for i, item := range v {
+			if str, ok := item.(string); ok {
+				if name, _, found := strings.Cut(str, "="); found && isSensitiveParam(name) {
+					v[i] = name + "=[REDACTED]"
+				}
+			}
+		}
+		return v
+	}
+	return value
+}
+
+func isSensitiveParam(name string) bool {
+	lower := strings.ToLower(name)
+	for _, key := range sensitiveParamKeys {
+		if strings.Contains(lower, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
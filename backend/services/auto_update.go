@@ -0,0 +1,752 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+)
+
+const (
+	UpdatePolicyOff    = "off"
+	UpdatePolicyNotify = "notify"
+	UpdatePolicyAuto   = "auto"
+
+	UpdateScopeContainer = "container"
+	UpdateScopeProject   = "project"
+
+	// Container labels that set a policy without touching the database
+	updatePolicyLabel = "com.sunspear.update.policy"
+	updateWindowLabel = "com.sunspear.update.window"
+
+	updatePolicyDefaultSetting = "update_policy_default"
+	updateHealthTimeoutSetting = "update_health_timeout_seconds"
+
+	defaultUpdateHealthTimeout = 120 * time.Second
+	// updateGracePeriod is how long a container without a healthcheck must
+	// keep running before an update counts as successful
+	updateGracePeriod = 15 * time.Second
+)
+
+// ErrUpdateInProgress is returned when a container is already being updated
+var ErrUpdateInProgress = errors.New("an update for this container is already running")
+
+// UpdatePolicy is a stored per-container or per-project update policy.
+// Target is a container name or a project name.
+type UpdatePolicy struct {
+	ID     int    `json:"id"`
+	Scope  string `json:"scope"`
+	Target string `json:"target"`
+	Policy string `json:"policy"`
+	// Window is a cron expression; auto-updates only run during minutes it
+	// matches. Empty means any time.
+	Window    string `json:"window"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// EffectiveUpdatePolicy is the policy that applies to a running container
+type EffectiveUpdatePolicy struct {
+	ContainerID     string `json:"containerId"`
+	ContainerName   string `json:"containerName"`
+	Image           string `json:"image"`
+	Project         string `json:"project,omitempty"`
+	Policy          string `json:"policy"`
+	Window          string `json:"window"`
+	Source          string `json:"source"` // container, label, project or default
+	UpdateAvailable bool   `json:"updateAvailable"`
+}
+
+// UpdateHistoryEntry records one action taken by the updater
+type UpdateHistoryEntry struct {
+	ID            int    `json:"id"`
+	ContainerName string `json:"containerName"`
+	ContainerID   string `json:"containerId"`
+	Image         string `json:"image"`
+	Digest        string `json:"digest"`
+	Action        string `json:"action"` // notify, update
+	Status        string `json:"status"` // success, failed, rolled_back, skipped
+	Message       string `json:"message"`
+	Trigger       string `json:"trigger"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+// AutoUpdateService applies update policies: it notifies about or
+// automatically applies image updates found by the ImageUpdateService,
+// recreating containers with identical configuration and rolling back when
+// the new container does not become healthy.
+type AutoUpdateService struct {
+	db                 *sql.DB
+	dockerService      *DockerService
+	imageUpdateService *ImageUpdateService
+	notifications      *NotificationService
+	audit              *AuditService
+
+	updating sync.Map // container name -> struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewAutoUpdateService(db *sql.DB, dockerService *DockerService, imageUpdateService *ImageUpdateService, notifications *NotificationService, audit *AuditService) *AutoUpdateService {
+	return &AutoUpdateService{
+		db:                 db,
+		dockerService:      dockerService,
+		imageUpdateService: imageUpdateService,
+		notifications:      notifications,
+		audit:              audit,
+		stopChan:           make(chan struct{}),
+	}
+}
+
+func (s *AutoUpdateService) Start() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop halts the scheduler, waiting for an in-flight update to finish so a
+// container is never left half replaced
+func (s *AutoUpdateService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+	s.wg.Wait()
+}
+
+func (s *AutoUpdateService) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.apply(now)
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// apply runs one scheduler pass over all running containers
+func (s *AutoUpdateService) apply(now time.Time) {
+	ctx := context.Background()
+	policies, err := s.Effective(ctx)
+	if err != nil {
+		log.Printf("Auto-update failed to resolve policies: %v", err)
+		return
+	}
+
+	updated := false
+	for _, p := range policies {
+		if p.Policy == UpdatePolicyOff || !p.UpdateAvailable {
+			continue
+		}
+		available, err := s.imageUpdateService.UpdateForImage(p.Image)
+		if err != nil || !available.DigestChanged {
+			continue
+		}
+
+		switch p.Policy {
+		case UpdatePolicyNotify:
+			if s.hasHistory(p.ContainerName, available.RemoteDigest, "notify") {
+				continue
+			}
+			s.notifications.Notify("update.available", NotificationInfo,
+				fmt.Sprintf("Update available for %s", p.ContainerName),
+				fmt.Sprintf("%s has a new image upstream (%s)", p.Image, available.RemoteDigest))
+			s.record(UpdateHistoryEntry{
+				ContainerName: p.ContainerName, ContainerID: p.ContainerID, Image: p.Image,
+				Digest: available.RemoteDigest, Action: "notify", Status: "success", Trigger: "schedule",
+			})
+
+		case UpdatePolicyAuto:
+			if !inUpdateWindow(p.Window, now) {
+				continue
+			}
+			// One attempt per upstream digest; a failed attempt is not retried
+			// every minute until a newer image appears
+			if s.hasHistory(p.ContainerName, available.RemoteDigest, "update") {
+				continue
+			}
+			select {
+			case <-s.stopChan:
+				return
+			default:
+			}
+			if _, err := s.UpdateContainer(ctx, p.ContainerID, "schedule"); err == nil {
+				updated = true
+			}
+		}
+	}
+
+	if updated {
+		// Refresh so the updated containers no longer show as outdated
+		go func() {
+			checkCtx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			defer cancel()
+			if err := s.imageUpdateService.Check(checkCtx); err != nil {
+				log.Printf("Image update check failed: %v", err)
+			}
+		}()
+	}
+}
+
+// inUpdateWindow reports whether an auto-update may run at now
+func inUpdateWindow(window string, now time.Time) bool {
+	if window == "" {
+		return true
+	}
+	schedule, err := ParseCron(window)
+	if err != nil {
+		return false
+	}
+	return !schedule.Prev(now, 0).IsZero()
+}
+
+// Policies
+
+func (s *AutoUpdateService) ListPolicies() ([]UpdatePolicy, error) {
+	rows, err := s.db.Query("SELECT id, scope, target, policy, window, created_at, updated_at FROM update_policies ORDER BY scope, target")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []UpdatePolicy{}
+	for rows.Next() {
+		var p UpdatePolicy
+		if err := rows.Scan(&p.ID, &p.Scope, &p.Target, &p.Policy, &p.Window, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// SetPolicy creates or replaces the policy for a container or project
+func (s *AutoUpdateService) SetPolicy(p UpdatePolicy) (*UpdatePolicy, error) {
+	if p.Scope != UpdateScopeContainer && p.Scope != UpdateScopeProject {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("scope must be %q or %q", UpdateScopeContainer, UpdateScopeProject))
+	}
+	p.Target = strings.TrimPrefix(strings.TrimSpace(p.Target), "/")
+	if p.Target == "" {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("target is required"))
+	}
+	if err := ValidateUpdatePolicy(p.Policy); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	if p.Window != "" {
+		if _, err := ParseCron(p.Window); err != nil {
+			return nil, errdefs.InvalidParameter(fmt.Errorf("invalid window: %w", err))
+		}
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO update_policies (scope, target, policy, window) VALUES (?, ?, ?, ?)
+		ON CONFLICT(scope, target) DO UPDATE SET policy = excluded.policy, window = excluded.window, updated_at = CURRENT_TIMESTAMP
+	`, p.Scope, p.Target, p.Policy, p.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved UpdatePolicy
+	err = s.db.QueryRow("SELECT id, scope, target, policy, window, created_at, updated_at FROM update_policies WHERE scope = ? AND target = ?",
+		p.Scope, p.Target).Scan(&saved.ID, &saved.Scope, &saved.Target, &saved.Policy, &saved.Window, &saved.CreatedAt, &saved.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (s *AutoUpdateService) DeletePolicy(id int) error {
+	result, err := s.db.Exec("DELETE FROM update_policies WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ValidateUpdatePolicy checks a policy name
+func ValidateUpdatePolicy(policy string) error {
+	switch policy {
+	case UpdatePolicyOff, UpdatePolicyNotify, UpdatePolicyAuto:
+		return nil
+	}
+	return fmt.Errorf("policy must be off, notify or auto")
+}
+
+// Effective resolves the policy of every running container. Precedence:
+// container policy in the database, container labels, project policy in the
+// database, then the update_policy_default setting.
+func (s *AutoUpdateService) Effective(ctx context.Context) ([]EffectiveUpdatePolicy, error) {
+	policies, err := s.ListPolicies()
+	if err != nil {
+		return nil, err
+	}
+	byContainer := make(map[string]UpdatePolicy)
+	byProject := make(map[string]UpdatePolicy)
+	for _, p := range policies {
+		if p.Scope == UpdateScopeContainer {
+			byContainer[p.Target] = p
+		} else {
+			byProject[p.Target] = p
+		}
+	}
+
+	updates, err := s.imageUpdateService.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[string]bool)
+	for _, u := range updates {
+		available[u.Image] = u.DigestChanged
+	}
+
+	containers, err := s.dockerService.ListContainers(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	self, _ := os.Hostname()
+	defaultPolicy := s.defaultPolicy()
+	result := []EffectiveUpdatePolicy{}
+	for _, c := range containers {
+		// Never replace helpers or the container running this backend
+		if c.Labels["com.sunspear.helper"] != "" || (self != "" && strings.HasPrefix(c.ID, self)) {
+			continue
+		}
+
+		name := strings.TrimPrefix(firstName(c.Names), "/")
+		project := containerProject(c.Labels)
+
+		eff := EffectiveUpdatePolicy{
+			ContainerID:     c.ID,
+			ContainerName:   name,
+			Image:           c.Image,
+			Project:         project,
+			UpdateAvailable: available[c.Image],
+		}
+		if p, ok := byContainer[name]; ok {
+			eff.Policy, eff.Window, eff.Source = p.Policy, p.Window, "container"
+		} else if label := c.Labels[updatePolicyLabel]; ValidateUpdatePolicy(label) == nil {
+			eff.Policy, eff.Window, eff.Source = label, c.Labels[updateWindowLabel], "label"
+		} else if p, ok := byProject[project]; ok && project != "" {
+			eff.Policy, eff.Window, eff.Source = p.Policy, p.Window, "project"
+		} else {
+			eff.Policy, eff.Source = defaultPolicy, "default"
+		}
+		result = append(result, eff)
+	}
+	return result, nil
+}
+
+func (s *AutoUpdateService) defaultPolicy() string {
+	var value string
+	s.db.QueryRow("SELECT value FROM settings WHERE key = ?", updatePolicyDefaultSetting).Scan(&value)
+	if ValidateUpdatePolicy(value) != nil {
+		return UpdatePolicyOff
+	}
+	return value
+}
+
+func (s *AutoUpdateService) healthTimeout() time.Duration {
+	var value string
+	s.db.QueryRow("SELECT value FROM settings WHERE key = ?", updateHealthTimeoutSetting).Scan(&value)
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return defaultUpdateHealthTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// History
+
+func (s *AutoUpdateService) ListHistory(limit int) ([]UpdateHistoryEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.Query(`
+		SELECT id, container_name, container_id, image, digest, action, status, message, trigger, created_at
+		FROM update_history ORDER BY id DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []UpdateHistoryEntry{}
+	for rows.Next() {
+		var e UpdateHistoryEntry
+		if err := rows.Scan(&e.ID, &e.ContainerName, &e.ContainerID, &e.Image, &e.Digest, &e.Action, &e.Status, &e.Message, &e.Trigger, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *AutoUpdateService) record(e UpdateHistoryEntry) {
+	log.Printf("Update %s %s for %s (%s): %s", e.Action, e.Status, e.ContainerName, e.Image, e.Message)
+	_, err := s.db.Exec(`
+		INSERT INTO update_history (container_name, container_id, image, digest, action, status, message, trigger)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.ContainerName, e.ContainerID, e.Image, e.Digest, e.Action, e.Status, e.Message, e.Trigger)
+	if err != nil {
+		log.Printf("Failed to record update history: %v", err)
+	}
+
+	// Manual updates are audited as the request that started them
+	if e.Action != "update" || e.Status == "skipped" || e.Trigger == "manual" {
+		return
+	}
+	action, auditErr := "containers.update", error(nil)
+	switch e.Status {
+	case "rolled_back":
+		action, auditErr = "containers.rollback", errors.New(e.Message)
+	case "failed":
+		auditErr = errors.New(e.Message)
+	}
+	s.audit.RecordSystem(action, "containers/"+e.ContainerName, map[string]interface{}{
+		"containerId": e.ContainerID, "image": e.Image, "digest": e.Digest, "trigger": e.Trigger,
+	}, auditErr)
+}
+
+func (s *AutoUpdateService) hasHistory(containerName, digest, action string) bool {
+	var count int
+	s.db.QueryRow("SELECT COUNT(*) FROM update_history WHERE container_name = ? AND digest = ? AND action = ?",
+		containerName, digest, action).Scan(&count)
+	return count > 0
+}
+
+// Updating
+
+// UpdateContainer pulls the container's image and, if it changed, replaces
+// the container with one using identical configuration. The old container is
+// kept (stopped and renamed) until the new one is healthy, and restored if it
+// is not.
+func (s *AutoUpdateService) UpdateContainer(ctx context.Context, containerID, trigger string) (*UpdateHistoryEntry, error) {
+	old, err := s.dockerService.GetContainer(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(old.Name, "/")
+	if _, busy := s.updating.LoadOrStore(name, struct{}{}); busy {
+		return nil, ErrUpdateInProgress
+	}
+	defer s.updating.Delete(name)
+
+	entry := UpdateHistoryEntry{
+		ContainerName: name,
+		ContainerID:   old.ID,
+		Image:         old.Config.Image,
+		Action:        "update",
+		Trigger:       trigger,
+	}
+	if available, err := s.imageUpdateService.UpdateForImage(old.Config.Image); err == nil {
+		entry.Digest = available.RemoteDigest
+	}
+	fail := func(status string, err error) (*UpdateHistoryEntry, error) {
+		entry.Status, entry.Message = status, err.Error()
+		s.record(entry)
+		if status != "skipped" {
+			s.notifications.Notify("update.failed", NotificationError,
+				fmt.Sprintf("Update of %s failed", name), entry.Message)
+		}
+		return &entry, err
+	}
+
+	if err := s.dockerService.PullImageAndWait(ctx, old.Config.Image); err != nil {
+		return fail("failed", fmt.Errorf("failed to pull %s: %w", old.Config.Image, err))
+	}
+	newImage, err := s.dockerService.InspectImage(ctx, old.Config.Image)
+	if err != nil {
+		return fail("failed", fmt.Errorf("failed to inspect pulled image: %w", err))
+	}
+	if ref, err := ParseImageRef(old.Config.Image); err == nil {
+		if digest := repoDigestFor(newImage.RepoDigests, ref); digest != "" {
+			entry.Digest = digest
+		}
+	}
+	if newImage.ID == old.Image {
+		entry.Status, entry.Message = "skipped", "already running the latest image"
+		s.record(entry)
+		return &entry, nil
+	}
+
+	oldImage, err := s.dockerService.InspectImage(ctx, old.Image)
+	if err != nil {
+		return fail("failed", fmt.Errorf("failed to inspect current image: %w", err))
+	}
+
+	config, hostConfig, networking, extraNetworks := recreateConfig(old, oldImage.Config)
+
+	// Move the old container aside
+	backupName := name + "-sunspear-old"
+	wasRunning := old.State != nil && old.State.Running
+	if wasRunning {
+		if err := s.dockerService.StopContainer(ctx, old.ID, 30); err != nil {
+			return fail("failed", fmt.Errorf("failed to stop container: %w", err))
+		}
+	}
+	if err := s.dockerService.RenameContainer(ctx, old.ID, backupName); err != nil {
+		s.restore(old.ID, name, "", wasRunning)
+		return fail("failed", fmt.Errorf("failed to rename container: %w", err))
+	}
+
+	created, err := s.dockerService.CreateContainerWithNetworking(ctx, config, hostConfig, networking, name)
+	if err != nil {
+		s.restore(old.ID, name, "", wasRunning)
+		return fail("rolled_back", fmt.Errorf("failed to create container: %w", err))
+	}
+	for networkName, endpoint := range extraNetworks {
+		if err := s.dockerService.ConnectNetworkWithAliases(ctx, networkName, created.ID, endpoint.Aliases); err != nil {
+			s.restore(old.ID, name, created.ID, wasRunning)
+			return fail("rolled_back", fmt.Errorf("failed to connect network %s: %w", networkName, err))
+		}
+	}
+
+	if wasRunning {
+		if err := s.dockerService.StartContainer(ctx, created.ID); err != nil {
+			s.restore(old.ID, name, created.ID, wasRunning)
+			return fail("rolled_back", fmt.Errorf("failed to start container: %w", err))
+		}
+		if err := s.waitHealthy(ctx, created.ID); err != nil {
+			s.restore(old.ID, name, created.ID, wasRunning)
+			return fail("rolled_back", err)
+		}
+	}
+
+	if err := s.replaceStoredContainerID(old.ID, created.ID); err != nil {
+		s.restore(old.ID, name, created.ID, wasRunning)
+		return fail("rolled_back", fmt.Errorf("failed to update stored container ID: %w", err))
+	}
+
+	if err := s.dockerService.RemoveContainer(ctx, old.ID, true); err != nil {
+		log.Printf("Failed to remove replaced container %s: %v", backupName, err)
+	}
+
+	entry.ContainerID = created.ID
+	entry.Status = "success"
+	entry.Message = fmt.Sprintf("updated from %s to %s", shortImageID(old.Image), shortImageID(newImage.ID))
+	s.record(entry)
+	s.notifications.Notify("update.applied", NotificationInfo, fmt.Sprintf("Updated %s", name), entry.Message)
+	return &entry, nil
+}
+
+// replaceStoredContainerID points the compose project or installed app that
+// lists the old container at its replacement, so starting, stopping and
+// removing them keeps working after an update
+func (s *AutoUpdateService) replaceStoredContainerID(oldID, newID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// container_ids holds a JSON array of full IDs
+	oldJSON, newJSON := strconv.Quote(oldID), strconv.Quote(newID)
+	for _, table := range []string{"compose_projects", "installed_apps"} {
+		if _, err := tx.Exec("UPDATE "+table+" SET container_ids = REPLACE(container_ids, ?, ?) WHERE instr(container_ids, ?) > 0",
+			oldJSON, newJSON, oldJSON); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// restore removes a failed replacement and puts the original container back
+func (s *AutoUpdateService) restore(oldID, name, newID string, start bool) {
+	ctx := context.Background()
+	if newID != "" {
+		if err := s.dockerService.RemoveContainer(ctx, newID, true); err != nil {
+			log.Printf("Rollback: failed to remove new container for %s: %v", name, err)
+		}
+	}
+	if err := s.dockerService.RenameContainer(ctx, oldID, name); err != nil {
+		log.Printf("Rollback: failed to rename %s back: %v", name, err)
+	}
+	if start {
+		if err := s.dockerService.StartContainer(ctx, oldID); err != nil {
+			log.Printf("Rollback: failed to restart %s: %v", name, err)
+		}
+	}
+}
+
+// waitHealthy waits for a healthcheck to pass or, for images without one,
+// for the container to stay up through a grace period without restarting
+func (s *AutoUpdateService) waitHealthy(ctx context.Context, containerID string) error {
+	deadline := time.Now().Add(s.healthTimeout())
+	started := time.Now()
+
+	for {
+		info, err := s.dockerService.GetContainer(ctx, containerID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect new container: %w", err)
+		}
+		state := info.State
+		if state == nil || (!state.Running && !state.Restarting) {
+			return fmt.Errorf("new container exited")
+		}
+		if info.RestartCount > 0 || state.Restarting {
+			return fmt.Errorf("new container is restarting")
+		}
+
+		if state.Health != nil {
+			switch state.Health.Status {
+			case types.Healthy:
+				return nil
+			case types.Unhealthy:
+				return fmt.Errorf("new container is unhealthy")
+			}
+		} else if time.Since(started) >= updateGracePeriod {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("new container did not become healthy within %s", s.healthTimeout())
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// recreateConfig derives the configuration for a replacement container.
+// Settings the old container inherited from its image are cleared so the new
+// image's defaults apply, anonymous volumes are carried over, and endpoint
+// settings are split into the network attached at creation and the rest.
+func recreateConfig(old types.ContainerJSON, oldImage *container.Config) (*container.Config, *container.HostConfig, *network.NetworkingConfig, map[string]*network.EndpointSettings) {
+	config := *old.Config
+	hostConfig := *old.HostConfig
+
+	if len(old.ID) >= 12 && config.Hostname == old.ID[:12] {
+		config.Hostname = ""
+	}
+
+	if oldImage != nil {
+		config.Env = withoutImageDefaults(config.Env, oldImage.Env)
+		if reflect.DeepEqual([]string(config.Cmd), []string(oldImage.Cmd)) {
+			config.Cmd = nil
+		}
+		if reflect.DeepEqual([]string(config.Entrypoint), []string(oldImage.Entrypoint)) {
+			config.Entrypoint = nil
+		}
+		if config.WorkingDir == oldImage.WorkingDir {
+			config.WorkingDir = ""
+		}
+		if config.User == oldImage.User {
+			config.User = ""
+		}
+		labels := make(map[string]string)
+		for k, v := range config.Labels {
+			if imageValue, ok := oldImage.Labels[k]; !ok || imageValue != v {
+				labels[k] = v
+			}
+		}
+		config.Labels = labels
+		if reflect.DeepEqual(config.Healthcheck, oldImage.Healthcheck) {
+			config.Healthcheck = nil
+		}
+	}
+
+	// Reattach anonymous volumes, which would otherwise be replaced by empty ones
+	explicit := make(map[string]bool)
+	for _, bind := range hostConfig.Binds {
+		if parts := strings.Split(bind, ":"); len(parts) >= 2 {
+			explicit[parts[1]] = true
+		}
+	}
+	for _, m := range hostConfig.Mounts {
+		explicit[m.Target] = true
+	}
+	binds := append([]string(nil), hostConfig.Binds...)
+	for _, m := range old.Mounts {
+		if m.Type == "volume" && m.Name != "" && !explicit[m.Destination] {
+			binds = append(binds, m.Name+":"+m.Destination)
+		}
+	}
+	hostConfig.Binds = binds
+
+	primary := string(hostConfig.NetworkMode)
+	if primary == "default" {
+		primary = "bridge"
+	}
+	networking := &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{}}
+	extra := make(map[string]*network.EndpointSettings)
+	if old.NetworkSettings != nil && !hostConfig.NetworkMode.IsContainer() {
+		for networkName, endpoint := range old.NetworkSettings.Networks {
+			settings := &network.EndpointSettings{
+				Aliases: withoutShortID(endpoint.Aliases, old.ID),
+				Links:   endpoint.Links,
+			}
+			if endpoint.IPAMConfig != nil {
+				settings.IPAMConfig = endpoint.IPAMConfig
+			}
+			if networkName == primary {
+				networking.EndpointsConfig[networkName] = settings
+			} else {
+				extra[networkName] = settings
+			}
+		}
+	}
+
+	return &config, &hostConfig, networking, extra
+}
+
+// withoutImageDefaults drops environment entries identical to the image's
+func withoutImageDefaults(env, imageEnv []string) []string {
+	defaults := make(map[string]bool, len(imageEnv))
+	for _, e := range imageEnv {
+		defaults[e] = true
+	}
+	var out []string
+	for _, e := range env {
+		if !defaults[e] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// withoutShortID drops the alias Docker adds for the container's short ID
+func withoutShortID(aliases []string, containerID string) []string {
+	var out []string
+	for _, alias := range aliases {
+		if len(containerID) >= 12 && alias == containerID[:12] {
+			continue
+		}
+		out = append(out, alias)
+	}
+	return out
+}
+
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func firstName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
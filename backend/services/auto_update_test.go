@@ -0,0 +1,87 @@
+package services
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"sunspear/config"
+	"testing"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := config.InitDB(filepath.Join(t.TempDir(), "sunspear.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReplaceStoredContainerID(t *testing.T) {
+	db := newTestDB(t)
+	oldID := strings.Repeat("a", 64)
+	newID := strings.Repeat("b", 64)
+	otherID := strings.Repeat("c", 64)
+
+	mustExec(t, db, `INSERT INTO compose_projects (name, yaml_content, container_ids) VALUES ('web', '', ?)`,
+		`["`+otherID+`","`+oldID+`"]`)
+	mustExec(t, db, `INSERT INTO installed_apps (app_id, app_name, container_ids) VALUES ('nextcloud', 'Nextcloud', ?)`,
+		`["`+oldID+`"]`)
+	mustExec(t, db, `INSERT INTO installed_apps (app_id, app_name, container_ids) VALUES ('gitea', 'Gitea', ?)`,
+		`["`+otherID+`"]`)
+
+	s := &AutoUpdateService{db: db}
+	if err := s.replaceStoredContainerID(oldID, newID); err != nil {
+		t.Fatal(err)
+	}
+
+	var project, app, other string
+	db.QueryRow("SELECT container_ids FROM compose_projects WHERE name = 'web'").Scan(&project)
+	db.QueryRow("SELECT container_ids FROM installed_apps WHERE app_id = 'nextcloud'").Scan(&app)
+	db.QueryRow("SELECT container_ids FROM installed_apps WHERE app_id = 'gitea'").Scan(&other)
+
+	if want := `["` + otherID + `","` + newID + `"]`; project != want {
+		t.Errorf("project container_ids = %s, want %s", project, want)
+	}
+	if want := `["` + newID + `"]`; app != want {
+		t.Errorf("app container_ids = %s, want %s", app, want)
+	}
+	if want := `["` + otherID + `"]`; other != want {
+		t.Errorf("unrelated app changed to %s", other)
+	}
+}
+
+func TestUpdateHistoryIsAudited(t *testing.T) {
+	db := newTestDB(t)
+	s := &AutoUpdateService{db: db, audit: NewAuditService(db)}
+
+	s.record(UpdateHistoryEntry{ContainerName: "web", Image: "nginx:1.27", Action: "update", Status: "success", Trigger: "schedule"})
+	s.record(UpdateHistoryEntry{ContainerName: "db", Image: "postgres:16", Action: "update", Status: "rolled_back", Message: "new container is unhealthy", Trigger: "schedule"})
+	s.record(UpdateHistoryEntry{ContainerName: "web", Action: "update", Status: "skipped", Trigger: "schedule"})
+	s.record(UpdateHistoryEntry{ContainerName: "web", Action: "update", Status: "success", Trigger: "manual"})
+	s.record(UpdateHistoryEntry{ContainerName: "web", Action: "notify", Status: "success", Trigger: "schedule"})
+
+	entries, err := s.audit.Query(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2: %+v", len(entries), entries)
+	}
+
+	rollback, update := entries[0], entries[1]
+	if update.Action != "containers.update" || update.Resource != "containers/web" || update.Result != AuditResultSuccess || update.Username != AuditSystemUser {
+		t.Errorf("update entry = %+v", update)
+	}
+	if rollback.Action != "containers.rollback" || rollback.Result != AuditResultFailure || rollback.Error != "new container is unhealthy" {
+		t.Errorf("rollback entry = %+v", rollback)
+	}
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,820 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// ErrBackupRunning is returned when a policy is triggered while a previous run is still in progress
+var ErrBackupRunning = errors.New("a backup for this policy is already running")
+
+// ErrBackupTargetInUse is returned when deleting a target that policies still reference
+var ErrBackupTargetInUse = errors.New("backup target is used by one or more policies")
+
+const (
+	BackupRunRunning = "running"
+	BackupRunSuccess = "success"
+	BackupRunPartial = "partial"
+	BackupRunFailed  = "failed"
+
+	backupSchedulerInterval = 30 * time.Second
+	backupTimestampLayout   = "20060102-150405"
+	redactedValue           = "[REDACTED]"
+)
+
+var backupNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+// BackupTargetRecord is a stored backup destination
+type BackupTargetRecord struct {
+	ID        int                `json:"id"`
+	Name      string             `json:"name"`
+	Config    BackupTargetConfig `json:"config"`
+	CreatedAt string             `json:"createdAt"`
+	UpdatedAt string             `json:"updatedAt"`
+}
+
+// BackupSources selects what a policy backs up. Compose projects and
+// installed apps are expanded to the named volumes their containers mount.
+type BackupSources struct {
+	Volumes  []string `json:"volumes"`
+	Projects []string `json:"projects"`
+	Apps     []int    `json:"apps"`
+}
+
+// BackupPolicy schedules backups of a set of sources to a target
+type BackupPolicy struct {
+	ID       int           `json:"id"`
+	Name     string        `json:"name"`
+	TargetID int           `json:"targetId"`
+	Sources  BackupSources `json:"sources"`
+	Schedule string        `json:"schedule"`
+	// Retention: the newest KeepLast archives, the newest archive of each of
+	// the last KeepDaily days and of each of the last KeepWeekly ISO weeks
+	// are kept per volume. All zero keeps everything.
+	KeepLast       int    `json:"keepLast"`
+	KeepDaily      int    `json:"keepDaily"`
+	KeepWeekly     int    `json:"keepWeekly"`
+	StopContainers bool   `json:"stopContainers"`
+	Enabled        bool   `json:"enabled"`
+	CreatedAt      string `json:"createdAt"`
+	UpdatedAt      string `json:"updatedAt"`
+}
+
+// BackupArtifact is the outcome of backing up one volume during a run
+type BackupArtifact struct {
+	Volume    string `json:"volume"`
+	Object    string `json:"object"`
+	SizeBytes int64  `json:"sizeBytes"`
+	SHA256    string `json:"sha256"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BackupRun is one execution of a policy
+type BackupRun struct {
+	ID         int              `json:"id"`
+	PolicyID   int              `json:"policyId"`
+	Trigger    string           `json:"trigger"`
+	Status     string           `json:"status"`
+	Message    string           `json:"message"`
+	Artifacts  []BackupArtifact `json:"artifacts"`
+	Pruned     []string         `json:"pruned"`
+	StartedAt  string           `json:"startedAt"`
+	FinishedAt string           `json:"finishedAt"`
+}
+
+// BackupService manages backup targets and policies and runs policies on
+// their cron schedules
+type BackupService struct {
+	db                  *sql.DB
+	dockerService       *DockerService
+	volumeBackupService *VolumeBackupService
+	marketplaceService  *MarketplaceService
+	notifications       *NotificationService
+	audit               *AuditService
+	secrets             *SecretBox
+	// localRoot confines local targets; empty disables them
+	localRoot string
+
+	running  sync.Map // policy ID -> struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewBackupService(db *sql.DB, dockerService *DockerService, volumeBackupService *VolumeBackupService, marketplaceService *MarketplaceService, notifications *NotificationService, audit *AuditService, secrets *SecretBox, localRoot string) *BackupService {
+	return &BackupService{
+		db:                  db,
+		dockerService:       dockerService,
+		volumeBackupService: volumeBackupService,
+		marketplaceService:  marketplaceService,
+		notifications:       notifications,
+		audit:               audit,
+		secrets:             secrets,
+		localRoot:           localRoot,
+		stopChan:            make(chan struct{}),
+	}
+}
+
+// Start launches the scheduler. Runs that were in progress when the backend
+// last stopped are marked failed.
+func (s *BackupService) Start() {
+	if _, err := s.db.Exec(`
+		UPDATE backup_runs SET status = ?, message = 'interrupted by backend restart', finished_at = CURRENT_TIMESTAMP
+		WHERE status = ?
+	`, BackupRunFailed, BackupRunRunning); err != nil {
+		log.Printf("Failed to reset interrupted backup runs: %v", err)
+	}
+	s.encryptStoredSecrets()
+	go s.schedule()
+}
+
+// Stop halts the scheduler and waits for in-flight runs to finish
+func (s *BackupService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+	s.wg.Wait()
+}
+
+func (s *BackupService) schedule() {
+	ticker := time.NewTicker(backupSchedulerInterval)
+	defer ticker.Stop()
+
+	lastCheck := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.runDuePolicies(lastCheck, now)
+			lastCheck = now
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// runDuePolicies starts every enabled policy whose schedule fired in (since, now]
+func (s *BackupService) runDuePolicies(since, now time.Time) {
+	policies, err := s.ListPolicies()
+	if err != nil {
+		log.Printf("Backup scheduler failed to load policies: %v", err)
+		return
+	}
+
+	for _, policy := range policies {
+		if !policy.Enabled {
+			continue
+		}
+		schedule, err := ParseCron(policy.Schedule)
+		if err != nil {
+			continue
+		}
+		if next := schedule.Next(since); next.IsZero() || next.After(now) {
+			continue
+		}
+		if _, err := s.StartRun(policy.ID, "schedule"); err != nil && !errors.Is(err, ErrBackupRunning) {
+			log.Printf("Failed to start backup policy %s: %v", policy.Name, err)
+		}
+	}
+}
+
+// Targets
+
+func (s *BackupService) ListTargets() ([]BackupTargetRecord, error) {
+	rows, err := s.db.Query("SELECT id, name, config, created_at, updated_at FROM backup_targets ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []BackupTargetRecord{}
+	for rows.Next() {
+		t, err := scanBackupTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, rows.Err()
+}
+
+func (s *BackupService) GetTarget(id int) (*BackupTargetRecord, error) {
+	row := s.db.QueryRow("SELECT id, name, config, created_at, updated_at FROM backup_targets WHERE id = ?", id)
+	return scanBackupTarget(row)
+}
+
+func (s *BackupService) CreateTarget(name string, cfg BackupTargetConfig) (*BackupTargetRecord, error) {
+	if !backupNamePattern.MatchString(name) {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid target name"))
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	if err := s.checkLocalPath(cfg); err != nil {
+		return nil, err
+	}
+
+	cfg, err := cfg.encryptSecrets(s.secrets)
+	if err != nil {
+		return nil, err
+	}
+	configJSON, _ := json.Marshal(cfg)
+	result, err := s.db.Exec("INSERT INTO backup_targets (name, type, config) VALUES (?, ?, ?)", name, cfg.Type, string(configJSON))
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return s.GetTarget(int(id))
+}
+
+// UpdateTarget replaces a target's settings. Secret fields sent back in
+// their redacted form keep their stored value.
+func (s *BackupService) UpdateTarget(id int, name string, cfg BackupTargetConfig) (*BackupTargetRecord, error) {
+	existing, err := s.GetTarget(id)
+	if err != nil {
+		return nil, err
+	}
+	if !backupNamePattern.MatchString(name) {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid target name"))
+	}
+
+	if cfg.Password == redactedValue {
+		cfg.Password = existing.Config.Password
+	}
+	if cfg.PrivateKey == redactedValue {
+		cfg.PrivateKey = existing.Config.PrivateKey
+	}
+	if cfg.SecretKey == redactedValue {
+		cfg.SecretKey = existing.Config.SecretKey
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	if err := s.checkLocalPath(cfg); err != nil {
+		return nil, err
+	}
+
+	cfg, err = cfg.encryptSecrets(s.secrets)
+	if err != nil {
+		return nil, err
+	}
+	configJSON, _ := json.Marshal(cfg)
+	_, err = s.db.Exec(`
+		UPDATE backup_targets SET name = ?, type = ?, config = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, name, cfg.Type, string(configJSON), id)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTarget(id)
+}
+
+func (s *BackupService) DeleteTarget(id int) error {
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM backup_policies WHERE target_id = ?", id).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrBackupTargetInUse
+	}
+
+	result, err := s.db.Exec("DELETE FROM backup_targets WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TestTarget connects to a target and checks that it is writable
+func (s *BackupService) TestTarget(ctx context.Context, id int) error {
+	record, err := s.GetTarget(id)
+	if err != nil {
+		return err
+	}
+	target, err := s.openTarget(record)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	return target.Test(ctx)
+}
+
+// openTarget decrypts a stored target's secrets and connects to it. Local
+// targets saved before the backup root was enforced, or outside a changed
+// root, are refused.
+func (s *BackupService) openTarget(record *BackupTargetRecord) (BackupTarget, error) {
+	if err := s.checkLocalPath(record.Config); err != nil {
+		return nil, err
+	}
+	cfg, err := record.Config.decryptSecrets(s.secrets)
+	if err != nil {
+		return nil, err
+	}
+	return OpenBackupTarget(cfg)
+}
+
+// checkLocalPath keeps local targets inside the backup root, away from the
+// data directory and the rest of the filesystem
+func (s *BackupService) checkLocalPath(cfg BackupTargetConfig) error {
+	if cfg.Type != BackupTargetLocal {
+		return nil
+	}
+	if s.localRoot == "" {
+		return errdefs.InvalidParameter(fmt.Errorf("local targets are disabled; set BACKUP_ROOT to allow them"))
+	}
+	rel, err := filepath.Rel(filepath.Clean(s.localRoot), filepath.Clean(cfg.Path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errdefs.InvalidParameter(fmt.Errorf("local target path must be inside %s", s.localRoot))
+	}
+	return nil
+}
+
+// encryptStoredSecrets encrypts target secrets saved in plaintext by
+// earlier versions
+func (s *BackupService) encryptStoredSecrets() {
+	targets, err := s.ListTargets()
+	if err != nil {
+		log.Printf("Failed to load backup targets: %v", err)
+		return
+	}
+	for _, t := range targets {
+		if !t.Config.hasPlaintextSecrets() {
+			continue
+		}
+		cfg, err := t.Config.encryptSecrets(s.secrets)
+		if err == nil {
+			configJSON, _ := json.Marshal(cfg)
+			_, err = s.db.Exec("UPDATE backup_targets SET config = ? WHERE id = ?", string(configJSON), t.ID)
+		}
+		if err != nil {
+			log.Printf("Failed to encrypt secrets of backup target %s: %v", t.Name, err)
+		}
+	}
+}
+
+// Policies
+
+const backupPolicyColumns = `id, name, target_id, sources, schedule, keep_last, keep_daily, keep_weekly, stop_containers, enabled, created_at, updated_at`
+
+func (s *BackupService) ListPolicies() ([]BackupPolicy, error) {
+	rows, err := s.db.Query("SELECT " + backupPolicyColumns + " FROM backup_policies ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []BackupPolicy{}
+	for rows.Next() {
+		p, err := scanBackupPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *p)
+	}
+	return policies, rows.Err()
+}
+
+func (s *BackupService) GetPolicy(id int) (*BackupPolicy, error) {
+	return scanBackupPolicy(s.db.QueryRow("SELECT "+backupPolicyColumns+" FROM backup_policies WHERE id = ?", id))
+}
+
+func (s *BackupService) CreatePolicy(ctx context.Context, p BackupPolicy) (*BackupPolicy, error) {
+	if err := s.validatePolicy(ctx, p); err != nil {
+		return nil, err
+	}
+
+	sourcesJSON, _ := json.Marshal(p.Sources)
+	result, err := s.db.Exec(`
+		INSERT INTO backup_policies (name, target_id, sources, schedule, keep_last, keep_daily, keep_weekly, stop_containers, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.Name, p.TargetID, string(sourcesJSON), p.Schedule, p.KeepLast, p.KeepDaily, p.KeepWeekly, p.StopContainers, p.Enabled)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return s.GetPolicy(int(id))
+}
+
+func (s *BackupService) UpdatePolicy(ctx context.Context, id int, p BackupPolicy) (*BackupPolicy, error) {
+	if _, err := s.GetPolicy(id); err != nil {
+		return nil, err
+	}
+	if err := s.validatePolicy(ctx, p); err != nil {
+		return nil, err
+	}
+
+	sourcesJSON, _ := json.Marshal(p.Sources)
+	_, err := s.db.Exec(`
+		UPDATE backup_policies SET name = ?, target_id = ?, sources = ?, schedule = ?, keep_last = ?, keep_daily = ?,
+		keep_weekly = ?, stop_containers = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, p.Name, p.TargetID, string(sourcesJSON), p.Schedule, p.KeepLast, p.KeepDaily, p.KeepWeekly, p.StopContainers, p.Enabled, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPolicy(id)
+}
+
+func (s *BackupService) DeletePolicy(id int) error {
+	result, err := s.db.Exec("DELETE FROM backup_policies WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = s.db.Exec("DELETE FROM backup_runs WHERE policy_id = ?", id)
+	return err
+}
+
+// validatePolicy returns errdefs.InvalidParameter errors for bad input
+func (s *BackupService) validatePolicy(ctx context.Context, p BackupPolicy) error {
+	invalid := func(format string, args ...interface{}) error {
+		return errdefs.InvalidParameter(fmt.Errorf(format, args...))
+	}
+	if !backupNamePattern.MatchString(p.Name) {
+		return invalid("invalid policy name (letters, digits, '.', '_' and '-' only)")
+	}
+	if _, err := ParseCron(p.Schedule); err != nil {
+		return invalid("invalid schedule: %w", err)
+	}
+	if p.KeepLast < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 {
+		return invalid("retention counts must not be negative")
+	}
+	if len(p.Sources.Volumes) == 0 && len(p.Sources.Projects) == 0 && len(p.Sources.Apps) == 0 {
+		return invalid("at least one volume, project or app is required")
+	}
+	if _, err := s.GetTarget(p.TargetID); errors.Is(err, sql.ErrNoRows) {
+		return invalid("target %d not found", p.TargetID)
+	} else if err != nil {
+		return err
+	}
+	// Projects and apps are resolved at run time, as their volumes change
+	for _, name := range p.Sources.Volumes {
+		if _, err := s.dockerService.InspectVolume(ctx, name); errdefs.IsNotFound(err) {
+			return invalid("volume %s does not exist", name)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Runs
+
+// ListRuns returns the run history of a policy, newest first
+func (s *BackupService) ListRuns(policyID int, limit int) ([]BackupRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.Query(`
+		SELECT id, policy_id, trigger, status, message, artifacts, pruned, started_at, COALESCE(finished_at, '')
+		FROM backup_runs WHERE policy_id = ? ORDER BY id DESC LIMIT ?
+	`, policyID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []BackupRun{}
+	for rows.Next() {
+		var run BackupRun
+		var artifacts, pruned string
+		if err := rows.Scan(&run.ID, &run.PolicyID, &run.Trigger, &run.Status, &run.Message, &artifacts, &pruned, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(artifacts), &run.Artifacts)
+		json.Unmarshal([]byte(pruned), &run.Pruned)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// StartRun begins a policy run in the background and returns its run ID
+func (s *BackupService) StartRun(policyID int, trigger string) (int, error) {
+	policy, err := s.GetPolicy(policyID)
+	if err != nil {
+		return 0, err
+	}
+	if _, busy := s.running.LoadOrStore(policyID, struct{}{}); busy {
+		return 0, ErrBackupRunning
+	}
+
+	result, err := s.db.Exec("INSERT INTO backup_runs (policy_id, trigger, status) VALUES (?, ?, ?)", policyID, trigger, BackupRunRunning)
+	if err != nil {
+		s.running.Delete(policyID)
+		return 0, err
+	}
+	runID, _ := result.LastInsertId()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.running.Delete(policyID)
+		s.executeRun(int(runID), policy, trigger)
+	}()
+
+	return int(runID), nil
+}
+
+func (s *BackupService) executeRun(runID int, policy *BackupPolicy, trigger string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	artifacts, pruned, err := s.backupPolicy(ctx, runID, policy)
+
+	status := BackupRunSuccess
+	message := fmt.Sprintf("backed up %d volume(s)", len(artifacts))
+	failed := 0
+	for _, a := range artifacts {
+		if a.Error != "" {
+			failed++
+		}
+	}
+	switch {
+	case err != nil:
+		status = BackupRunFailed
+		message = err.Error()
+	case failed == len(artifacts) && failed > 0:
+		status = BackupRunFailed
+		message = fmt.Sprintf("all %d volume backup(s) failed", failed)
+	case failed > 0:
+		status = BackupRunPartial
+		message = fmt.Sprintf("%d of %d volume backup(s) failed", failed, len(artifacts))
+	}
+
+	artifactsJSON, _ := json.Marshal(artifacts)
+	prunedJSON, _ := json.Marshal(pruned)
+	if _, dbErr := s.db.Exec(`
+		UPDATE backup_runs SET status = ?, message = ?, artifacts = ?, pruned = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, status, message, string(artifactsJSON), string(prunedJSON), runID); dbErr != nil {
+		log.Printf("Failed to record backup run %d: %v", runID, dbErr)
+	}
+
+	var runErr error
+	if status != BackupRunSuccess {
+		runErr = errors.New(message)
+	}
+	s.audit.RecordSystem("backups.run", fmt.Sprintf("backups/policies/%d", policy.ID), map[string]interface{}{
+		"run": runID, "trigger": trigger, "volumes": len(artifacts), "pruned": pruned,
+	}, runErr)
+
+	if status != BackupRunSuccess {
+		s.notifications.Notify("backup.failed", NotificationError,
+			fmt.Sprintf("Backup policy %s %s", policy.Name, status), message)
+	}
+}
+
+// backupPolicy archives every source volume to the policy's target and then
+// applies retention. Per-volume failures are recorded in the artifacts.
+func (s *BackupService) backupPolicy(ctx context.Context, runID int, policy *BackupPolicy) ([]BackupArtifact, []string, error) {
+	targetRecord, err := s.GetTarget(policy.TargetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("target %d not found", policy.TargetID)
+	}
+
+	volumes, err := s.resolveVolumes(ctx, policy.Sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(volumes) == 0 {
+		return nil, nil, fmt.Errorf("sources resolved to no named volumes")
+	}
+
+	target, err := s.openTarget(targetRecord)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open target %s: %w", targetRecord.Name, err)
+	}
+	defer target.Close()
+
+	var artifacts []BackupArtifact
+	var pruned []string
+	for _, volumeName := range volumes {
+		artifact := s.backupVolume(ctx, target, policy, volumeName)
+		artifacts = append(artifacts, artifact)
+		if artifact.Error != "" {
+			continue
+		}
+
+		removed, err := s.applyRetention(ctx, target, policy, volumeName)
+		if err != nil {
+			log.Printf("Backup run %d: retention for %s failed: %v", runID, volumeName, err)
+		}
+		pruned = append(pruned, removed...)
+	}
+
+	return artifacts, pruned, nil
+}
+
+func (s *BackupService) backupVolume(ctx context.Context, target BackupTarget, policy *BackupPolicy, volumeName string) BackupArtifact {
+	object := path.Join(policy.Name, volumeName, time.Now().UTC().Format(backupTimestampLayout)+".tar.gz")
+	artifact := BackupArtifact{Volume: volumeName, Object: object}
+
+	// Stream the archive straight into the target
+	pr, pw := io.Pipe()
+	type backupResult struct {
+		record *VolumeArchiveRecord
+		err    error
+	}
+	resultCh := make(chan backupResult, 1)
+	go func() {
+		record, err := s.volumeBackupService.Backup(ctx, volumeName, pw, VolumeArchiveOptions{
+			StopContainers: policy.StopContainers,
+			Compress:       true,
+		})
+		pw.CloseWithError(err)
+		resultCh <- backupResult{record, err}
+	}()
+
+	putErr := target.Put(ctx, object, pr)
+	pr.CloseWithError(putErr)
+	result := <-resultCh
+
+	// A failed backup makes Put fail too, with a less useful pipe error; a
+	// failed Put surfaces in the backup error as the write error
+	if result.err != nil {
+		artifact.Error = result.err.Error()
+		return artifact
+	}
+	if putErr != nil {
+		artifact.Error = putErr.Error()
+		return artifact
+	}
+	record := result.record
+	if record == nil {
+		artifact.Error = "backup did not complete"
+		return artifact
+	}
+	artifact.SizeBytes = record.SizeBytes
+	artifact.SHA256 = record.SHA256
+	return artifact
+}
+
+func (s *BackupService) applyRetention(ctx context.Context, target BackupTarget, policy *BackupPolicy, volumeName string) ([]string, error) {
+	if policy.KeepLast == 0 && policy.KeepDaily == 0 && policy.KeepWeekly == 0 {
+		return nil, nil
+	}
+
+	objects, err := target.List(ctx, path.Join(policy.Name, volumeName)+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, obj := range selectExpiredBackups(objects, policy.KeepLast, policy.KeepDaily, policy.KeepWeekly) {
+		if err := target.Delete(ctx, obj.Name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, obj.Name)
+	}
+	return removed, nil
+}
+
+// resolveVolumes expands policy sources into a de-duplicated list of volume names
+func (s *BackupService) resolveVolumes(ctx context.Context, sources BackupSources) ([]string, error) {
+	seen := make(map[string]bool)
+	var volumes []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			volumes = append(volumes, name)
+		}
+	}
+
+	for _, name := range sources.Volumes {
+		add(name)
+	}
+
+	for _, project := range sources.Projects {
+		containers, err := s.dockerService.ListContainersByLabel(ctx, "com.sunspear.project", project)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers of project %s: %w", project, err)
+		}
+		for _, c := range containers {
+			for _, m := range c.Mounts {
+				if m.Type == "volume" {
+					add(m.Name)
+				}
+			}
+		}
+	}
+
+	for _, appID := range sources.Apps {
+		app, err := s.marketplaceService.GetInstalledApp(appID)
+		if err != nil {
+			return nil, fmt.Errorf("installed app %d not found", appID)
+		}
+		var containerIDs []string
+		json.Unmarshal([]byte(app.ContainerIDs), &containerIDs)
+		for _, id := range containerIDs {
+			info, err := s.dockerService.GetContainer(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to inspect container of app %s: %w", app.AppName, err)
+			}
+			for _, m := range info.Mounts {
+				if m.Type == "volume" {
+					add(m.Name)
+				}
+			}
+		}
+	}
+
+	return volumes, nil
+}
+
+// selectExpiredBackups returns the archives not retained by the keep-last,
+// keep-daily and keep-weekly rules. Archive times come from the timestamp
+// in the object name, falling back to the modification time.
+func selectExpiredBackups(objects []BackupObject, keepLast, keepDaily, keepWeekly int) []BackupObject {
+	type dated struct {
+		obj BackupObject
+		at  time.Time
+	}
+	items := make([]dated, 0, len(objects))
+	for _, obj := range objects {
+		at := obj.ModTime
+		stamp := strings.TrimSuffix(strings.TrimSuffix(path.Base(obj.Name), ".gz"), ".tar")
+		if parsed, err := time.Parse(backupTimestampLayout, stamp); err == nil {
+			at = parsed
+		}
+		items = append(items, dated{obj: obj, at: at})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].at.After(items[j].at) })
+
+	keep := make(map[int]bool)
+	for i := 0; i < len(items) && i < keepLast; i++ {
+		keep[i] = true
+	}
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for i, item := range items {
+		day := item.at.Format("2006-01-02")
+		if !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep[i] = true
+		}
+		year, week := item.at.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%02d", year, week)
+		if !weeks[weekKey] && len(weeks) < keepWeekly {
+			weeks[weekKey] = true
+			keep[i] = true
+		}
+	}
+
+	var expired []BackupObject
+	for i, item := range items {
+		if !keep[i] {
+			expired = append(expired, item.obj)
+		}
+	}
+	return expired
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBackupTarget(row rowScanner) (*BackupTargetRecord, error) {
+	var t BackupTargetRecord
+	var configJSON string
+	if err := row.Scan(&t.ID, &t.Name, &configJSON, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(configJSON), &t.Config); err != nil {
+		return nil, fmt.Errorf("corrupt target config: %w", err)
+	}
+	return &t, nil
+}
+
+func scanBackupPolicy(row rowScanner) (*BackupPolicy, error) {
+	var p BackupPolicy
+	var sourcesJSON string
+	if err := row.Scan(&p.ID, &p.Name, &p.TargetID, &sourcesJSON, &p.Schedule, &p.KeepLast, &p.KeepDaily, &p.KeepWeekly, &p.StopContainers, &p.Enabled, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(sourcesJSON), &p.Sources); err != nil {
+		return nil, fmt.Errorf("corrupt policy sources: %w", err)
+	}
+	return &p, nil
+}
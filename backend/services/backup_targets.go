@@ -0,0 +1,498 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	BackupTargetLocal = "local"
+	BackupTargetSFTP  = "sftp"
+	BackupTargetS3    = "s3"
+)
+
+// s3PartSize bounds memory used by streaming multipart uploads of unknown size
+const s3PartSize = 16 << 20
+
+// BackupTargetConfig holds the settings for every target type; only the
+// fields relevant to Type are used.
+type BackupTargetConfig struct {
+	Type string `json:"type"`
+
+	// local and sftp: base directory for archives
+	Path string `json:"path,omitempty"`
+
+	// sftp
+	Host       string `json:"host,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	// HostKey is the server's SHA256 key fingerprint ("SHA256:...").
+	// InsecureSkipHostKey must be set explicitly to connect without one.
+	HostKey             string `json:"hostKey,omitempty"`
+	InsecureSkipHostKey bool   `json:"insecureSkipHostKey,omitempty"`
+
+	// s3 (AWS or any S3-compatible service such as MinIO)
+	Endpoint  string `json:"endpoint,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Region    string `json:"region,omitempty"`
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty"`
+	UseSSL    bool   `json:"useSSL,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+}
+
+// Redacted returns a copy safe to return from the API
+func (c BackupTargetConfig) Redacted() BackupTargetConfig {
+	if c.Password != "" {
+		c.Password = redactedValue
+	}
+	if c.PrivateKey != "" {
+		c.PrivateKey = redactedValue
+	}
+	if c.SecretKey != "" {
+		c.SecretKey = redactedValue
+	}
+	return c
+}
+
+func (c *BackupTargetConfig) secretFields() []*string {
+	return []*string{&c.Password, &c.PrivateKey, &c.SecretKey}
+}
+
+// encryptSecrets returns a copy with the secret fields encrypted for
+// storage. Values that are already encrypted are kept.
+func (c BackupTargetConfig) encryptSecrets(box *SecretBox) (BackupTargetConfig, error) {
+	for _, field := range c.secretFields() {
+		if *field == "" || strings.HasPrefix(*field, secretBoxPrefix) {
+			continue
+		}
+		encrypted, err := box.Encrypt(*field)
+		if err != nil {
+			return c, err
+		}
+		*field = encrypted
+	}
+	return c, nil
+}
+
+// decryptSecrets reverses encryptSecrets. Plaintext values stored before
+// secrets were encrypted are returned as they are.
+func (c BackupTargetConfig) decryptSecrets(box *SecretBox) (BackupTargetConfig, error) {
+	for _, field := range c.secretFields() {
+		if !strings.HasPrefix(*field, secretBoxPrefix) {
+			continue
+		}
+		plaintext, err := box.Decrypt(*field)
+		if err != nil {
+			return c, err
+		}
+		*field = plaintext
+	}
+	return c, nil
+}
+
+// hasPlaintextSecrets reports whether a stored config predates encryption
+func (c BackupTargetConfig) hasPlaintextSecrets() bool {
+	for _, field := range c.secretFields() {
+		if *field != "" && !strings.HasPrefix(*field, secretBoxPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the fields required by the target type are present
+func (c BackupTargetConfig) Validate() error {
+	switch c.Type {
+	case BackupTargetLocal:
+		if c.Path == "" || !filepath.IsAbs(c.Path) {
+			return fmt.Errorf("local target requires an absolute path")
+		}
+	case BackupTargetSFTP:
+		if c.Host == "" || c.Username == "" {
+			return fmt.Errorf("sftp target requires host and username")
+		}
+		if c.Password == "" && c.PrivateKey == "" {
+			return fmt.Errorf("sftp target requires a password or private key")
+		}
+		if c.HostKey == "" && !c.InsecureSkipHostKey {
+			return fmt.Errorf("sftp target requires hostKey (or insecureSkipHostKey)")
+		}
+	case BackupTargetS3:
+		if c.Endpoint == "" || c.Bucket == "" {
+			return fmt.Errorf("s3 target requires endpoint and bucket")
+		}
+	default:
+		return fmt.Errorf("unknown target type %q (expected local, sftp or s3)", c.Type)
+	}
+	return nil
+}
+
+// BackupObject is an archive stored on a target
+type BackupObject struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// BackupTarget stores backup archives. Object names are slash-separated
+// paths relative to the target's base location.
+type BackupTarget interface {
+	Put(ctx context.Context, name string, r io.Reader) error
+	List(ctx context.Context, prefix string) ([]BackupObject, error)
+	Delete(ctx context.Context, name string) error
+	// Test verifies that the target is reachable and writable. Its errors
+	// carry errdefs types, which marks their text as fit to show the user.
+	Test(ctx context.Context) error
+	Close() error
+}
+
+// OpenBackupTarget connects to the target described by cfg
+func OpenBackupTarget(cfg BackupTargetConfig) (BackupTarget, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+
+	switch cfg.Type {
+	case BackupTargetLocal:
+		return &localBackupTarget{dir: cfg.Path}, nil
+	case BackupTargetSFTP:
+		return openSFTPTarget(cfg)
+	case BackupTargetS3:
+		return openS3Target(cfg)
+	}
+	return nil, fmt.Errorf("unknown target type %q", cfg.Type)
+}
+
+// cleanObjectName rejects names that could escape the target's base location
+func cleanObjectName(name string) (string, error) {
+	cleaned := path.Clean("/" + name)
+	if cleaned == "/" || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
+
+// Local directory target
+
+type localBackupTarget struct {
+	dir string
+}
+
+func (t *localBackupTarget) Put(ctx context.Context, name string, r io.Reader) error {
+	name, err := cleanObjectName(name)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(t.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+
+	// Write to a temporary file so a failed backup never leaves a partial archive
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".partial-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (t *localBackupTarget) List(ctx context.Context, prefix string) ([]BackupObject, error) {
+	var objects []BackupObject
+	err := filepath.WalkDir(t.dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".partial-") {
+			return nil
+		}
+		rel, err := filepath.Rel(t.dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, BackupObject{Name: rel, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+func (t *localBackupTarget) Delete(ctx context.Context, name string) error {
+	name, err := cleanObjectName(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(t.dir, filepath.FromSlash(name)))
+}
+
+func (t *localBackupTarget) Test(ctx context.Context) error {
+	if err := os.MkdirAll(t.dir, 0750); err != nil {
+		return errdefs.Forbidden(fmt.Errorf("cannot create directory %s", t.dir))
+	}
+	probe, err := os.CreateTemp(t.dir, ".partial-probe-*")
+	if err != nil {
+		return errdefs.Forbidden(fmt.Errorf("directory %s is not writable", t.dir))
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func (t *localBackupTarget) Close() error {
+	return nil
+}
+
+// SFTP target
+
+type sftpBackupTarget struct {
+	sshClient *ssh.Client
+	client    *sftp.Client
+	dir       string
+}
+
+func openSFTPTarget(cfg BackupTargetConfig) (*sftpBackupTarget, error) {
+	var auth []ssh.AuthMethod
+	if cfg.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(cfg.PrivateKey))
+		if err != nil {
+			return nil, errdefs.InvalidParameter(fmt.Errorf("invalid private key: %w", err))
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if cfg.HostKey != "" {
+		expected := cfg.HostKey
+		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if actual := ssh.FingerprintSHA256(key); actual != expected {
+				return errdefs.Forbidden(fmt.Errorf("host key mismatch: got %s", actual))
+			}
+			return nil
+		}
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+
+	sshClient, err := ssh.Dial("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(port)), &ssh.ClientConfig{
+		User:            cfg.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         15 * time.Second,
+	})
+	if errdefs.IsForbidden(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errdefs.Unavailable(fmt.Errorf("ssh connection failed: %w", err))
+	}
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, errdefs.Unavailable(fmt.Errorf("sftp session failed: %w", err))
+	}
+
+	dir := cfg.Path
+	if dir == "" {
+		dir = "."
+	}
+	return &sftpBackupTarget{sshClient: sshClient, client: client, dir: dir}, nil
+}
+
+func (t *sftpBackupTarget) Put(ctx context.Context, name string, r io.Reader) error {
+	name, err := cleanObjectName(name)
+	if err != nil {
+		return err
+	}
+	dst := path.Join(t.dir, name)
+	if err := t.client.MkdirAll(path.Dir(dst)); err != nil {
+		return err
+	}
+
+	tmp := path.Join(path.Dir(dst), ".partial-"+path.Base(dst))
+	f, err := t.client.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.ReadFrom(r); err != nil {
+		f.Close()
+		t.client.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		t.client.Remove(tmp)
+		return err
+	}
+	return t.client.PosixRename(tmp, dst)
+}
+
+func (t *sftpBackupTarget) List(ctx context.Context, prefix string) ([]BackupObject, error) {
+	var objects []BackupObject
+	walker := t.client.Walk(t.dir)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		info := walker.Stat()
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".partial-") {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), t.dir), "/")
+		if !strings.HasPrefix(rel, prefix) {
+			continue
+		}
+		objects = append(objects, BackupObject{Name: rel, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return objects, nil
+}
+
+func (t *sftpBackupTarget) Delete(ctx context.Context, name string) error {
+	name, err := cleanObjectName(name)
+	if err != nil {
+		return err
+	}
+	return t.client.Remove(path.Join(t.dir, name))
+}
+
+func (t *sftpBackupTarget) Test(ctx context.Context) error {
+	if err := t.client.MkdirAll(t.dir); err != nil {
+		return errdefs.Forbidden(fmt.Errorf("cannot create directory %s: %w", t.dir, err))
+	}
+	probe := path.Join(t.dir, ".partial-probe")
+	f, err := t.client.Create(probe)
+	if err != nil {
+		return errdefs.Forbidden(fmt.Errorf("directory %s is not writable: %w", t.dir, err))
+	}
+	f.Close()
+	return t.client.Remove(probe)
+}
+
+func (t *sftpBackupTarget) Close() error {
+	t.client.Close()
+	return t.sshClient.Close()
+}
+
+// S3-compatible target
+
+type s3BackupTarget struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func openS3Target(cfg BackupTargetConfig) (*s3BackupTarget, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &s3BackupTarget{client: client, bucket: cfg.Bucket, prefix: prefix}, nil
+}
+
+func (t *s3BackupTarget) Put(ctx context.Context, name string, r io.Reader) error {
+	name, err := cleanObjectName(name)
+	if err != nil {
+		return err
+	}
+	_, err = t.client.PutObject(ctx, t.bucket, t.prefix+name, r, -1, minio.PutObjectOptions{
+		ContentType: "application/gzip",
+		PartSize:    s3PartSize,
+	})
+	return err
+}
+
+func (t *s3BackupTarget) List(ctx context.Context, prefix string) ([]BackupObject, error) {
+	var objects []BackupObject
+	for obj := range t.client.ListObjects(ctx, t.bucket, minio.ListObjectsOptions{Prefix: t.prefix + prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		objects = append(objects, BackupObject{
+			Name:    strings.TrimPrefix(obj.Key, t.prefix),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+func (t *s3BackupTarget) Delete(ctx context.Context, name string) error {
+	name, err := cleanObjectName(name)
+	if err != nil {
+		return err
+	}
+	return t.client.RemoveObject(ctx, t.bucket, t.prefix+name, minio.RemoveObjectOptions{})
+}
+
+func (t *s3BackupTarget) Test(ctx context.Context) error {
+	exists, err := t.client.BucketExists(ctx, t.bucket)
+	if err != nil {
+		return errdefs.Unavailable(fmt.Errorf("cannot reach bucket %s: %w", t.bucket, err))
+	}
+	if !exists {
+		return errdefs.NotFound(fmt.Errorf("bucket %s does not exist", t.bucket))
+	}
+
+	probe := t.prefix + ".partial-probe"
+	if _, err := t.client.PutObject(ctx, t.bucket, probe, strings.NewReader("probe"), 5, minio.PutObjectOptions{}); err != nil {
+		return errdefs.Forbidden(fmt.Errorf("bucket %s is not writable: %w", t.bucket, err))
+	}
+	return t.client.RemoveObject(ctx, t.bucket, probe, minio.RemoveObjectOptions{})
+}
+
+func (t *s3BackupTarget) Close() error {
+	return nil
+}
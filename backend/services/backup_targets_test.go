@@ -0,0 +1,429 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// exerciseBackupTarget runs the operations a backup policy performs: a
+// Test, three uploads, a listing and a keep-last-1 retention pass
+func exerciseBackupTarget(t *testing.T, target BackupTarget) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := target.Test(ctx); err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+
+	names := []string{
+		"nightly/data/20240101-020000.tar.gz",
+		"nightly/data/20240102-020000.tar.gz",
+		"nightly/data/20240103-020000.tar.gz",
+	}
+	for _, name := range names {
+		if err := target.Put(ctx, name, strings.NewReader("archive "+name)); err != nil {
+			t.Fatalf("Put %s: %v", name, err)
+		}
+	}
+	if err := target.Put(ctx, "nightly/other/20240101-020000.tar.gz", strings.NewReader("other")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := target.Put(ctx, "../escape.tar.gz", strings.NewReader("x")); err == nil {
+		t.Error("Put accepted a name outside the target")
+	}
+
+	objects, err := target.List(ctx, "nightly/data/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got := objectNames(objects); got != strings.Join(names, " ") {
+		t.Fatalf("List = %s, want %s", got, strings.Join(names, " "))
+	}
+	if want := int64(len("archive " + names[0])); objects[0].Size != want {
+		t.Errorf("size = %d, want %d", objects[0].Size, want)
+	}
+
+	s := &BackupService{}
+	removed, err := s.applyRetention(ctx, target, &BackupPolicy{Name: "nightly", KeepLast: 1}, "data")
+	if err != nil {
+		t.Fatalf("applyRetention: %v", err)
+	}
+	sort.Strings(removed)
+	if got := strings.Join(removed, " "); got != names[0]+" "+names[1] {
+		t.Errorf("retention removed %s", got)
+	}
+
+	objects, err = target.List(ctx, "nightly/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := objectNames(objects), names[2]+" nightly/other/20240101-020000.tar.gz"; got != want {
+		t.Errorf("after retention List = %s, want %s", got, want)
+	}
+}
+
+func objectNames(objects []BackupObject) string {
+	var names []string
+	for _, obj := range objects {
+		names = append(names, obj.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func TestLocalBackupTarget(t *testing.T) {
+	target, err := OpenBackupTarget(BackupTargetConfig{Type: BackupTargetLocal, Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	exerciseBackupTarget(t, target)
+}
+
+func TestLocalTargetsStayInBackupRoot(t *testing.T) {
+	s := &BackupService{localRoot: "/backups"}
+	for path, ok := range map[string]bool{
+		"/backups":             true,
+		"/backups/nightly":     true,
+		"/backups/../app/data": false,
+		"/backups-other":       false,
+		"/":                    false,
+	} {
+		err := s.checkLocalPath(BackupTargetConfig{Type: BackupTargetLocal, Path: path})
+		if (err == nil) != ok {
+			t.Errorf("checkLocalPath(%s) = %v, want allowed %v", path, err, ok)
+		}
+	}
+
+	disabled := &BackupService{}
+	if err := disabled.checkLocalPath(BackupTargetConfig{Type: BackupTargetLocal, Path: "/backups"}); err == nil {
+		t.Error("local target allowed without a backup root")
+	}
+	if err := disabled.checkLocalPath(BackupTargetConfig{Type: BackupTargetS3}); err != nil {
+		t.Errorf("s3 target refused: %v", err)
+	}
+}
+
+func TestSFTPBackupTarget(t *testing.T) {
+	addr, fingerprint := newFakeSFTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNumber, _ := strconv.Atoi(port)
+	cfg := BackupTargetConfig{
+		Type:     BackupTargetSFTP,
+		Path:     t.TempDir(),
+		Host:     host,
+		Port:     portNumber,
+		Username: "backup",
+		Password: "s3cret",
+		HostKey:  fingerprint,
+	}
+
+	target, err := OpenBackupTarget(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	exerciseBackupTarget(t, target)
+
+	wrongKey := cfg
+	wrongKey.HostKey = "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	if _, err := OpenBackupTarget(wrongKey); err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Errorf("wrong host key: err = %v", err)
+	}
+
+	wrongPassword := cfg
+	wrongPassword.Password = "nope"
+	if _, err := OpenBackupTarget(wrongPassword); err == nil {
+		t.Error("wrong password accepted")
+	}
+}
+
+func TestS3BackupTarget(t *testing.T) {
+	server := newFakeS3Server(t, "backups")
+	cfg := BackupTargetConfig{
+		Type:      BackupTargetS3,
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Bucket:    "backups",
+		Region:    "us-east-1",
+		AccessKey: "AKID",
+		SecretKey: "s3cret",
+		Prefix:    "/sunspear/",
+	}
+
+	target, err := OpenBackupTarget(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	exerciseBackupTarget(t, target)
+
+	// Objects live under the prefix and the probe was cleaned up
+	for key := range server.objects {
+		if !strings.HasPrefix(key, "sunspear/nightly/") {
+			t.Errorf("unexpected object %s", key)
+		}
+	}
+
+	missing := cfg
+	missing.Bucket = "missing"
+	target, err = OpenBackupTarget(missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Test(context.Background()); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("missing bucket: err = %v", err)
+	}
+
+	server.readOnly = true
+	target, _ = OpenBackupTarget(cfg)
+	if err := target.Test(context.Background()); err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Errorf("read-only bucket: err = %v", err)
+	}
+}
+
+// newFakeSFTPServer serves SFTP over SSH on a loopback port, accepting the
+// user "backup" with password "s3cret". It returns the address and the
+// host key fingerprint.
+func newFakeSFTPServer(t *testing.T) (string, string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "backup" && string(password) == "s3cret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("access denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSFTP(conn, config)
+		}
+	}()
+	return listener.Addr().String(), ssh.FingerprintSHA256(signer.PublicKey())
+}
+
+func serveFakeSFTP(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range channelRequests {
+				isSFTP := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(isSFTP, nil)
+				if isSFTP {
+					go func() {
+						defer channel.Close()
+						if server, err := sftp.NewServer(channel); err == nil {
+							server.Serve()
+						}
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// fakeS3Server implements the parts of the S3 API minio-go uses for one
+// bucket: HEAD bucket, ListObjectsV2, PUT, multipart uploads and DELETE
+type fakeS3Server struct {
+	*httptest.Server
+	bucket   string
+	readOnly bool
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func newFakeS3Server(t *testing.T, bucket string) *fakeS3Server {
+	s := &fakeS3Server{bucket: bucket, objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeS3Server) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	q := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, q.Get("prefix"))
+	case s.readOnly && r.Method != http.MethodGet && r.Method != http.MethodHead:
+		s3Error(w, http.StatusForbidden, "AccessDenied")
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		id := strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[id] = map[int][]byte{}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: id})
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		part, _ := strconv.Atoi(q.Get("partNumber"))
+		s.uploads[q.Get("uploadId")][part] = readS3Body(r)
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, part))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		io.Copy(io.Discard, r.Body)
+		parts := s.uploads[q.Get("uploadId")]
+		var data []byte
+		for i := 1; i <= len(parts); i++ {
+			data = append(data, parts[i]...)
+		}
+		s.objects[key] = data
+		delete(s.uploads, q.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: `"complete"`})
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(s.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		s.objects[key] = readS3Body(r)
+		w.Header().Set("ETag", `"object"`)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *fakeS3Server) list(w http.ResponseWriter, prefix string) {
+	type object struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []object
+	}{Name: s.bucket, Prefix: prefix, MaxKeys: 1000}
+
+	for key, data := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, object{
+				Key:          key,
+				LastModified: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+				ETag:         `"object"`,
+				Size:         len(data),
+			})
+		}
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents)
+	writeXML(w, result)
+}
+
+// readS3Body reads a request body, decoding the aws-chunked encoding
+// minio-go uses for signed uploads over plain HTTP
+func readS3Body(r *http.Request) []byte {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		data, _ := io.ReadAll(r.Body)
+		return data
+	}
+
+	var data []byte
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 {
+			break
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			break
+		}
+		data = append(data, chunk...)
+		reader.ReadString('\n')
+	}
+	io.Copy(io.Discard, reader)
+	return data
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: code})
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
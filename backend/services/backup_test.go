@@ -0,0 +1,99 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectExpiredBackups(t *testing.T) {
+	// Two archives a day, at 02:00 and 14:00, from Mon 2024-01-01 to Sun 2024-01-21
+	start := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	var objects []BackupObject
+	for i := 0; i < 42; i++ {
+		at := start.Add(time.Duration(i) * 12 * time.Hour)
+		objects = append(objects, BackupObject{Name: "nightly/data/" + at.Format(backupTimestampLayout) + ".tar.gz"})
+	}
+
+	tests := []struct {
+		name                          string
+		keepLast, keepDaily, keepWeek int
+		kept                          []string
+	}{
+		{"last", 3, 0, 0, []string{"20240121-140000", "20240121-020000", "20240120-140000"}},
+		{"daily keeps the newest of each day", 0, 2, 0, []string{"20240121-140000", "20240120-140000"}},
+		{"weekly keeps the newest of each ISO week", 0, 0, 3, []string{"20240121-140000", "20240114-140000", "20240107-140000"}},
+		{"rules combine", 1, 2, 2, []string{"20240121-140000", "20240120-140000", "20240114-140000"}},
+	}
+
+	for _, tt := range tests {
+		expired := selectExpiredBackups(objects, tt.keepLast, tt.keepDaily, tt.keepWeek)
+		expiredNames := map[string]bool{}
+		for _, obj := range expired {
+			expiredNames[obj.Name] = true
+		}
+
+		var kept []string
+		for _, obj := range objects {
+			if !expiredNames[obj.Name] {
+				kept = append(kept, strings.TrimSuffix(strings.TrimPrefix(obj.Name, "nightly/data/"), ".tar.gz"))
+			}
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(kept)))
+
+		if strings.Join(kept, " ") != strings.Join(tt.kept, " ") {
+			t.Errorf("%s: kept %v, want %v", tt.name, kept, tt.kept)
+		}
+	}
+}
+
+func TestSelectExpiredBackupsFallsBackToModTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	objects := []BackupObject{
+		{Name: "old.tar.gz", ModTime: now.Add(-48 * time.Hour)},
+		{Name: "new.tar.gz", ModTime: now},
+		{Name: "older.tar.gz", ModTime: now.Add(-72 * time.Hour)},
+	}
+	expired := selectExpiredBackups(objects, 1, 0, 0)
+	if len(expired) != 2 || expired[0].Name == "new.tar.gz" || expired[1].Name == "new.tar.gz" {
+		t.Errorf("expired %v, want old and older", expired)
+	}
+}
+
+func TestBackupTargetSecretsAreEncrypted(t *testing.T) {
+	box, err := NewSecretBox("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := BackupTargetConfig{Type: BackupTargetS3, Endpoint: "s3.example.com", Bucket: "b", AccessKey: "AKID", SecretKey: "s3cret"}
+
+	stored, err := cfg.encryptSecrets(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SecretKey == cfg.SecretKey || !strings.HasPrefix(stored.SecretKey, secretBoxPrefix) {
+		t.Errorf("secret key stored as %q", stored.SecretKey)
+	}
+	if stored.Password != "" || stored.PrivateKey != "" {
+		t.Error("empty secrets should stay empty")
+	}
+
+	// Encrypting again, as UpdateTarget does for kept values, is a no-op
+	again, err := stored.encryptSecrets(box)
+	if err != nil || again.SecretKey != stored.SecretKey {
+		t.Errorf("re-encrypting changed the stored value")
+	}
+
+	opened, err := stored.decryptSecrets(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != cfg {
+		t.Errorf("decrypted config = %+v, want %+v", opened, cfg)
+	}
+
+	if !cfg.hasPlaintextSecrets() || stored.hasPlaintextSecrets() {
+		t.Error("hasPlaintextSecrets is wrong")
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errCaddyNotFound is returned for config paths or @id tags Caddy does not know
+var errCaddyNotFound = errors.New("not found in Caddy config")
+
+// CaddyClient talks to the Caddy admin API
+// (https://caddyserver.com/docs/api)
+type CaddyClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewCaddyClient accepts an http(s) URL or unix:///path/to/admin.sock
+func NewCaddyClient(adminURL string) *CaddyClient {
+	c := &CaddyClient{
+		baseURL: strings.TrimSuffix(adminURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	if socket, ok := strings.CutPrefix(adminURL, "unix://"); ok {
+		// Caddy accepts 127.0.0.1 as the Host of admin requests over a socket
+		c.baseURL = "http://127.0.0.1"
+		c.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+	return c
+}
+
+// caddyServer is the part of an HTTP server config used to pick a server
+type caddyServer struct {
+	Listen []string `json:"listen"`
+}
+
+// Server returns the name of the HTTP server routes are added to: the one
+// listening on :443, otherwise the first by name
+func (c *CaddyClient) Server() (string, error) {
+	var servers map[string]caddyServer
+	if err := c.do(http.MethodGet, "/config/apps/http/servers", nil, &servers); err != nil {
+		if errors.Is(err, errCaddyNotFound) {
+			return "", fmt.Errorf("Caddy has no HTTP servers configured")
+		}
+		return "", err
+	}
+	if len(servers) == 0 {
+		return "", fmt.Errorf("Caddy has no HTTP servers configured")
+	}
+
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, addr := range servers[name].Listen {
+			if strings.HasSuffix(addr, ":443") {
+				return name, nil
+			}
+		}
+	}
+	return names[0], nil
+}
+
+// Routes returns the top-level routes of a server
+func (c *CaddyClient) Routes(server string) ([]map[string]interface{}, error) {
+	var routes []map[string]interface{}
+	err := c.do(http.MethodGet, "/config/apps/http/servers/"+url.PathEscape(server)+"/routes", nil, &routes)
+	if errors.Is(err, errCaddyNotFound) {
+		return nil, nil
+	}
+	return routes, err
+}
+
+// AppendRoute adds a route after all existing routes of a server, so site
+// blocks from the Caddyfile keep precedence over it
+func (c *CaddyClient) AppendRoute(server string, route interface{}) error {
+	path := "/config/apps/http/servers/" + url.PathEscape(server) + "/routes"
+	routes, err := c.Routes(server)
+	if err != nil {
+		return err
+	}
+	if routes == nil {
+		// Appending needs an existing array
+		return c.do(http.MethodPut, path, []interface{}{route}, nil)
+	}
+	return c.do(http.MethodPost, path, route, nil)
+}
+
+// ReplaceRoute replaces the config object tagged with id
+func (c *CaddyClient) ReplaceRoute(id string, route interface{}) error {
+	return c.do(http.MethodPatch, "/id/"+url.PathEscape(id), route, nil)
+}
+
+// DeleteRoute removes the config object tagged with id
+func (c *CaddyClient) DeleteRoute(id string) error {
+	err := c.do(http.MethodDelete, "/id/"+url.PathEscape(id), nil, nil)
+	if errors.Is(err, errCaddyNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (c *CaddyClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Caddy admin API unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var caddyErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(msg, &caddyErr) == nil && caddyErr.Error != "" {
+			msg = []byte(caddyErr.Error)
+		}
+		// Caddy reports unknown paths and IDs as 400 or 404 depending on where
+		// the lookup fails
+		text := strings.TrimSpace(string(msg))
+		if resp.StatusCode == http.StatusNotFound || strings.Contains(text, "unknown object ID") ||
+			strings.Contains(text, "invalid traversal path") {
+			return fmt.Errorf("%w: %s", errCaddyNotFound, text)
+		}
+		return fmt.Errorf("Caddy admin API %s %s: %s (%d)", method, path, text, resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// Missing config paths read back as null
+	if len(bytes.TrimSpace(data)) == 0 || string(bytes.TrimSpace(data)) == "null" {
+		return errCaddyNotFound
+	}
+	return json.Unmarshal(data, out)
+}
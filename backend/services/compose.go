@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
 	"github.com/docker/go-connections/nat"
 	"gopkg.in/yaml.v3"
 )
@@ -52,6 +53,9 @@ type ComposeProject struct {
 	UpdatedAt    string `json:"updatedAt"`
 }
 
+// ProjectLabel names the compose project a container belongs to
+const ProjectLabel = "com.sunspear.project"
+
 // StackTemplate represents a predefined compose template
 type StackTemplate struct {
 	Name        string `json:"name"`
@@ -63,12 +67,14 @@ type StackTemplate struct {
 type ComposeService struct {
 	db            *sql.DB
 	dockerService *DockerService
+	templatesDir  string
 }
 
-func NewComposeService(db *sql.DB, dockerService *DockerService) *ComposeService {
+func NewComposeService(db *sql.DB, dockerService *DockerService, templatesDir string) *ComposeService {
 	service := &ComposeService{
 		db:            db,
 		dockerService: dockerService,
+		templatesDir:  templatesDir,
 	}
 	service.createDefaultTemplates()
 	return service
@@ -78,42 +84,41 @@ func NewComposeService(db *sql.DB, dockerService *DockerService) *ComposeService
 func (s *ComposeService) ParseYAML(yamlContent string) (*ComposeSpec, error) {
 	var spec ComposeSpec
 	if err := yaml.Unmarshal([]byte(yamlContent), &spec); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		return nil, errdefs.InvalidParameter(fmt.Errorf("failed to parse YAML: %w", err))
 	}
 
 	if len(spec.Services) == 0 {
-		return nil, fmt.Errorf("no services defined in compose file")
+		return nil, errdefs.InvalidParameter(fmt.Errorf("no services defined in compose file"))
 	}
 
 	return &spec, nil
 }
 
-// Deploy creates and starts a new compose project
-func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlContent string) (*ComposeProject, error) {
+// deployServices creates the project network and starts every service in
+// dependency order, rolling back on failure
+func (s *ComposeService) deployServices(ctx context.Context, name, yamlContent string) (containerIDs, networkIDs, volumeNames []string, err error) {
 	// Parse YAML
 	spec, err := s.ParseYAML(yamlContent)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// Create project network
 	networkName := "sunspear-" + name
 	networkResp, err := s.dockerService.CreateNetwork(ctx, networkName, "bridge", false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create network: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to create network: %w", err)
 	}
 
-	var containerIDs []string
-	var volumeNames []string
-	networkIDs := []string{networkResp.ID}
+	networkIDs = []string{networkResp.ID}
 
 	// Resolve service order
 	serviceOrder, err := s.resolveServiceOrder(spec.Services)
 	if err != nil {
 		if cleanupErr := s.dockerService.RemoveNetwork(ctx, networkResp.ID); cleanupErr != nil {
-			return nil, fmt.Errorf("failed to resolve service order: %v (cleanup failed: %v)", err, cleanupErr)
+			return nil, nil, nil, fmt.Errorf("failed to resolve service order: %v (cleanup failed: %v)", err, cleanupErr)
 		}
-		return nil, fmt.Errorf("failed to resolve service order: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to resolve service order: %w", err)
 	}
 
 	// Deploy services in order
@@ -125,9 +130,9 @@ func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlCont
 		if err != nil {
 			rollbackErr := s.rollback(ctx, containerIDs, networkIDs)
 			if rollbackErr != nil {
-				return nil, fmt.Errorf("failed to pull image %s: %v (rollback failed: %v)", serviceSpec.Image, err, rollbackErr)
+				return nil, nil, nil, fmt.Errorf("failed to pull image %s: %v (rollback failed: %v)", serviceSpec.Image, err, rollbackErr)
 			}
-			return nil, fmt.Errorf("failed to pull image %s: %w", serviceSpec.Image, err)
+			return nil, nil, nil, fmt.Errorf("failed to pull image %s: %w", serviceSpec.Image, err)
 		}
 		io.Copy(io.Discard, pullReader)
 		pullReader.Close()
@@ -138,9 +143,9 @@ func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlCont
 		if err != nil {
 			rollbackErr := s.rollback(ctx, containerIDs, networkIDs)
 			if rollbackErr != nil {
-				return nil, fmt.Errorf("invalid port definition for %s: %v (rollback failed: %v)", serviceName, err, rollbackErr)
+				return nil, nil, nil, fmt.Errorf("invalid port definition for %s: %v (rollback failed: %v)", serviceName, err, rollbackErr)
 			}
-			return nil, fmt.Errorf("invalid port definition for %s: %w", serviceName, err)
+			return nil, nil, nil, fmt.Errorf("invalid port definition for %s: %w", serviceName, err)
 		}
 		volumes := s.parseVolumes(serviceSpec.Volumes, name)
 		labels := s.parseLabels(serviceSpec.Labels)
@@ -150,7 +155,7 @@ func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlCont
 		if labels == nil {
 			labels = make(map[string]string)
 		}
-		labels["com.sunspear.project"] = name
+		labels[ProjectLabel] = name
 		labels["com.sunspear.service"] = serviceName
 
 		// Create container config
@@ -184,9 +189,9 @@ func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlCont
 		if err != nil {
 			rollbackErr := s.rollback(ctx, containerIDs, networkIDs)
 			if rollbackErr != nil {
-				return nil, fmt.Errorf("failed to create container %s: %v (rollback failed: %v)", serviceName, err, rollbackErr)
+				return nil, nil, nil, fmt.Errorf("failed to create container %s: %v (rollback failed: %v)", serviceName, err, rollbackErr)
 			}
-			return nil, fmt.Errorf("failed to create container %s: %w", serviceName, err)
+			return nil, nil, nil, fmt.Errorf("failed to create container %s: %w", serviceName, err)
 		}
 
 		containerIDs = append(containerIDs, resp.ID)
@@ -195,18 +200,18 @@ func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlCont
 		if err := s.dockerService.ConnectNetworkWithAliases(ctx, networkResp.ID, resp.ID, []string{serviceName}); err != nil {
 			rollbackErr := s.rollback(ctx, containerIDs, networkIDs)
 			if rollbackErr != nil {
-				return nil, fmt.Errorf("failed to connect %s to network: %v (rollback failed: %v)", serviceName, err, rollbackErr)
+				return nil, nil, nil, fmt.Errorf("failed to connect %s to network: %v (rollback failed: %v)", serviceName, err, rollbackErr)
 			}
-			return nil, fmt.Errorf("failed to connect %s to network: %w", serviceName, err)
+			return nil, nil, nil, fmt.Errorf("failed to connect %s to network: %w", serviceName, err)
 		}
 
 		// Start container
 		if err := s.dockerService.StartContainer(ctx, resp.ID); err != nil {
 			rollbackErr := s.rollback(ctx, containerIDs, networkIDs)
 			if rollbackErr != nil {
-				return nil, fmt.Errorf("failed to start container %s: %v (rollback failed: %v)", serviceName, err, rollbackErr)
+				return nil, nil, nil, fmt.Errorf("failed to start container %s: %v (rollback failed: %v)", serviceName, err, rollbackErr)
 			}
-			return nil, fmt.Errorf("failed to start container %s: %w", serviceName, err)
+			return nil, nil, nil, fmt.Errorf("failed to start container %s: %w", serviceName, err)
 		}
 
 		// Track volumes
@@ -217,6 +222,16 @@ func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlCont
 		}
 	}
 
+	return containerIDs, networkIDs, volumeNames, nil
+}
+
+// Deploy creates and starts a new compose project
+func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlContent string) (*ComposeProject, error) {
+	containerIDs, networkIDs, volumeNames, err := s.deployServices(ctx, name, yamlContent)
+	if err != nil {
+		return nil, err
+	}
+
 	// Save project to database
 	containerIDsJSON, _ := json.Marshal(containerIDs)
 	networkIDsJSON, _ := json.Marshal(networkIDs)
@@ -240,6 +255,43 @@ func (s *ComposeService) Deploy(ctx context.Context, name, description, yamlCont
 	return s.GetProject(int(projectID))
 }
 
+// Redeploy recreates a project's containers and network from its stored YAML,
+// e.g. after restoring Sunspear on a new host. Projects that were stopped are
+// stopped again once created.
+func (s *ComposeService) Redeploy(ctx context.Context, id int) (*ComposeProject, error) {
+	project, err := s.GetProject(id)
+	if err != nil {
+		return nil, err
+	}
+
+	containerIDs, networkIDs, volumeNames, err := s.deployServices(ctx, project.Name, project.YAMLContent)
+	if err != nil {
+		return nil, err
+	}
+
+	containerIDsJSON, _ := json.Marshal(containerIDs)
+	networkIDsJSON, _ := json.Marshal(networkIDs)
+	volumeNamesJSON, _ := json.Marshal(volumeNames)
+	_, err = s.db.Exec(`
+		UPDATE compose_projects
+		SET status = 'running', container_ids = ?, network_ids = ?, volume_names = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, string(containerIDsJSON), string(networkIDsJSON), string(volumeNamesJSON), id)
+	if err != nil {
+		if rollbackErr := s.rollback(ctx, containerIDs, networkIDs); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to save project: %v (rollback failed: %v)", err, rollbackErr)
+		}
+		return nil, fmt.Errorf("failed to save project: %w", err)
+	}
+
+	if project.Status == "stopped" {
+		if err := s.StopProject(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetProject(id)
+}
+
 // StopProject stops all containers in a project
 func (s *ComposeService) StopProject(ctx context.Context, id int) error {
 	project, err := s.GetProject(id)
@@ -385,8 +437,7 @@ func (s *ComposeService) GetProject(id int) (*ComposeProject, error) {
 
 // ListTemplates returns all available compose templates
 func (s *ComposeService) ListTemplates() ([]StackTemplate, error) {
-	templatesDir := "./data/apps/compose-templates"
-	files, err := os.ReadDir(templatesDir)
+	files, err := os.ReadDir(s.templatesDir)
 	if err != nil {
 		return []StackTemplate{}, nil
 	}
@@ -410,12 +461,12 @@ func (s *ComposeService) GetTemplate(name string) (*StackTemplate, error) {
 	if strings.ContainsAny(name, "/\\..") || name != filepath.Base(name) {
 		return nil, fmt.Errorf("invalid template name")
 	}
-	filePath := filepath.Join("./data/apps/compose-templates", name+".yml")
+	filePath := filepath.Join(s.templatesDir, name+".yml")
 	absPath, err := filepath.Abs(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("invalid template path")
 	}
-	templatesDir, _ := filepath.Abs("./data/apps/compose-templates")
+	templatesDir, _ := filepath.Abs(s.templatesDir)
 	if !strings.HasPrefix(absPath, templatesDir) {
 		return nil, fmt.Errorf("invalid template name")
 	}
@@ -638,7 +689,7 @@ func (s *ComposeService) resolveServiceOrder(services map[string]ComposeServiceS
 		deps := s.parseDependsOn(service.DependsOn)
 		for _, dep := range deps {
 			if _, exists := services[dep]; !exists {
-				return nil, fmt.Errorf("service %s depends on undefined service %s", name, dep)
+				return nil, errdefs.InvalidParameter(fmt.Errorf("service %s depends on undefined service %s", name, dep))
 			}
 			dependents[dep] = append(dependents[dep], name)
 			inDegree[name]++
@@ -668,7 +719,7 @@ func (s *ComposeService) resolveServiceOrder(services map[string]ComposeServiceS
 	}
 
 	if len(result) != len(services) {
-		return nil, fmt.Errorf("circular dependency detected in service definitions")
+		return nil, errdefs.InvalidParameter(fmt.Errorf("circular dependency detected in service definitions"))
 	}
 
 	return result, nil
@@ -689,8 +740,7 @@ func setRestartPolicyName(policy *container.RestartPolicy, name string) {
 
 // createDefaultTemplates creates the template directory and default templates
 func (s *ComposeService) createDefaultTemplates() {
-	templatesDir := "./data/apps/compose-templates"
-	os.MkdirAll(templatesDir, 0755)
+	os.MkdirAll(s.templatesDir, 0755)
 
 	templates := map[string]string{
 		"wordpress.yml": `version: "3.8"
@@ -860,7 +910,7 @@ volumes:
 	}
 
 	for filename, content := range templates {
-		filePath := filepath.Join(templatesDir, filename)
+		filePath := filepath.Join(s.templatesDir, filename)
 		if _, err := os.Stat(filePath); os.IsNotExist(err) {
 			os.WriteFile(filePath, []byte(content), 0644)
 		}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+)
+
+// cloneOfLabel marks a container created by CloneContainer with the ID of its source
+const cloneOfLabel = "com.sunspear.clone-of"
+
+// CloneOptions customizes a container clone
+type CloneOptions struct {
+	Name string `json:"name"`
+	// Ports maps container ports ("80" or "80/tcp") to new host ports. Ports
+	// published by the source but not listed here are republished on random
+	// host ports so the clone can run alongside the original; an empty host
+	// port unpublishes the port.
+	Ports map[string]string `json:"ports"`
+	// Env entries (KEY=value) replace or extend the source environment
+	Env []string `json:"env"`
+	// CopyVolumes gives the clone copies of the source's named and anonymous
+	// volumes instead of sharing them. Bind mounts are always shared.
+	CopyVolumes bool `json:"copyVolumes"`
+	Start       bool `json:"start"`
+}
+
+// CloneResult describes a newly created clone
+type CloneResult struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Volumes  map[string]string `json:"volumes,omitempty"` // source volume -> copy
+	Networks []string          `json:"networks"`
+	Warnings []string          `json:"warnings"`
+}
+
+// ContainerCloneService duplicates containers for safe experimentation
+type ContainerCloneService struct {
+	dockerService *DockerService
+}
+
+func NewContainerCloneService(dockerService *DockerService) *ContainerCloneService {
+	return &ContainerCloneService{dockerService: dockerService}
+}
+
+// Clone creates a new container with the source's Config, HostConfig and
+// network attachments. Compose and Sunspear project labels are dropped so the
+// clone is not mistaken for part of the source's project, and network
+// aliases are not copied so the clone never answers for the source's names.
+func (s *ContainerCloneService) Clone(ctx context.Context, sourceID string, opts CloneOptions) (*CloneResult, error) {
+	if opts.Name == "" {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("name is required"))
+	}
+
+	source, err := s.dockerService.GetContainer(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if source.Config == nil || source.HostConfig == nil {
+		return nil, fmt.Errorf("container %s has no configuration", sourceID)
+	}
+
+	config := *source.Config
+	hostConfig := *source.HostConfig
+
+	// Docker defaults the hostname to the short container ID
+	if len(source.ID) >= 12 && config.Hostname == source.ID[:12] {
+		config.Hostname = ""
+	}
+	config.MacAddress = ""
+	config.Labels = cloneLabels(config.Labels, source.ID)
+	config.Env = mergeEnv(config.Env, opts.Env)
+
+	if err := clonePorts(&config, &hostConfig, opts.Ports); err != nil {
+		return nil, err
+	}
+
+	result := &CloneResult{Name: opts.Name, Networks: []string{}, Warnings: []string{}}
+	if opts.CopyVolumes {
+		copies, err := s.copyVolumes(ctx, source.Mounts, &hostConfig, opts.Name)
+		if err != nil {
+			s.removeVolumes(copies)
+			return nil, err
+		}
+		result.Volumes = copies
+	}
+
+	resp, err := s.dockerService.CreateContainer(ctx, &config, &hostConfig, opts.Name)
+	if err != nil {
+		s.removeVolumes(result.Volumes)
+		return nil, err
+	}
+	result.ID = resp.ID
+	result.Warnings = append(result.Warnings, resp.Warnings...)
+
+	// The network in NetworkMode is attached at creation; connect the rest
+	primary := string(hostConfig.NetworkMode)
+	if source.NetworkSettings != nil {
+		for name := range source.NetworkSettings.Networks {
+			result.Networks = append(result.Networks, name)
+			if name == primary || (primary == "default" && name == "bridge") || hostConfig.NetworkMode.IsContainer() {
+				continue
+			}
+			if err := s.dockerService.ConnectNetwork(ctx, name, resp.ID); err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("failed to connect network %s: %v", name, err))
+			}
+		}
+	}
+
+	if opts.Start {
+		if err := s.dockerService.StartContainer(ctx, resp.ID); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to start clone: %v", err))
+		}
+	}
+
+	return result, nil
+}
+
+// copyVolumes creates a copy of every volume mounted by the source and
+// points the clone's mounts at the copies
+func (s *ContainerCloneService) copyVolumes(ctx context.Context, mounts []types.MountPoint, hostConfig *container.HostConfig, cloneName string) (map[string]string, error) {
+	copies := make(map[string]string)
+	destinations := make(map[string]string) // mount destination -> copy
+
+	for _, m := range mounts {
+		if m.Type != "volume" || m.Name == "" {
+			continue
+		}
+		copyName := cloneName + "_" + m.Name
+		if len(m.Name) == 64 {
+			// Anonymous volume: its generated name makes a poor suffix
+			copyName = cloneName + "_" + strings.Trim(strings.ReplaceAll(m.Destination, "/", "_"), "_")
+		}
+
+		if _, err := s.dockerService.InspectVolume(ctx, copyName); err == nil {
+			return copies, errdefs.Conflict(fmt.Errorf("volume %s already exists", copyName))
+		}
+		src, err := s.dockerService.InspectVolume(ctx, m.Name)
+		if err != nil {
+			return copies, fmt.Errorf("failed to inspect volume %s: %w", m.Name, err)
+		}
+		if _, err := s.dockerService.CreateVolume(ctx, copyName, src.Driver, cloneLabels(src.Labels, "")); err != nil {
+			return copies, fmt.Errorf("failed to create volume %s: %w", copyName, err)
+		}
+		copies[m.Name] = copyName
+		if err := copyVolume(ctx, s.dockerService, m.Name, copyName); err != nil {
+			return copies, fmt.Errorf("failed to copy volume %s: %w", m.Name, err)
+		}
+		destinations[m.Destination] = copyName
+	}
+
+	// Rewrite explicit binds and mounts; anything left over was anonymous
+	// and gets a bind to its copy
+	covered := make(map[string]bool)
+	for i, bind := range hostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) >= 2 {
+			if copyName, ok := copies[parts[0]]; ok {
+				parts[0] = copyName
+				hostConfig.Binds[i] = strings.Join(parts, ":")
+				covered[parts[1]] = true
+			}
+		}
+	}
+	for i, m := range hostConfig.Mounts {
+		if copyName, ok := copies[m.Source]; ok && m.Type == "volume" {
+			hostConfig.Mounts[i].Source = copyName
+			covered[m.Target] = true
+		}
+	}
+	for destination, copyName := range destinations {
+		if !covered[destination] {
+			hostConfig.Binds = append(hostConfig.Binds, copyName+":"+destination)
+		}
+	}
+
+	return copies, nil
+}
+
+// removeVolumes cleans up volume copies after a failed clone
+func (s *ContainerCloneService) removeVolumes(copies map[string]string) {
+	for _, copyName := range copies {
+		if err := s.dockerService.RemoveVolume(context.Background(), copyName, true); err != nil {
+			log.Printf("Failed to remove volume copy %s: %v", copyName, err)
+		}
+	}
+}
+
+// clonePorts applies port overrides. Published ports without an override
+// are moved to random host ports.
+func clonePorts(config *container.Config, hostConfig *container.HostConfig, overrides map[string]string) error {
+	bindings := nat.PortMap{}
+	for port, existing := range hostConfig.PortBindings {
+		var rebound []nat.PortBinding
+		for _, b := range existing {
+			rebound = append(rebound, nat.PortBinding{HostIP: b.HostIP})
+		}
+		bindings[port] = rebound
+	}
+
+	for containerPort, hostPort := range overrides {
+		portStr := containerPort
+		if !strings.Contains(portStr, "/") {
+			portStr += "/tcp"
+		}
+		port, err := nat.NewPort(nat.SplitProtoPort(portStr))
+		if err != nil {
+			return fmt.Errorf("invalid port %q: %w", containerPort, err)
+		}
+		if hostPort == "" {
+			delete(bindings, port)
+			continue
+		}
+		bindings[port] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: hostPort}}
+		if config.ExposedPorts == nil {
+			config.ExposedPorts = nat.PortSet{}
+		}
+		config.ExposedPorts[port] = struct{}{}
+	}
+
+	hostConfig.PortBindings = bindings
+	return nil
+}
+
+// cloneLabels copies labels without compose and Sunspear project metadata and,
+// when sourceID is set, records the clone's origin
+func cloneLabels(labels map[string]string, sourceID string) map[string]string {
+	out := make(map[string]string)
+	for k, v := range labels {
+		if strings.HasPrefix(k, "com.docker.compose.") || strings.HasPrefix(k, "com.sunspear.") {
+			continue
+		}
+		out[k] = v
+	}
+	if sourceID != "" {
+		out[cloneOfLabel] = sourceID
+	}
+	return out
+}
+
+// mergeEnv applies KEY=value overrides to an environment list, keeping order
+func mergeEnv(env, overrides []string) []string {
+	merged := append([]string(nil), env...)
+	for _, override := range overrides {
+		key, _, _ := strings.Cut(override, "=")
+		replaced := false
+		for i, existing := range merged {
+			if k, _, _ := strings.Cut(existing, "="); k == key {
+				merged[i] = override
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, override)
+		}
+	}
+	return merged
+}
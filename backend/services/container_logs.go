@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// projectLogReorderDelay is how long followed project lines are held so
+// lines from different services can be put in timestamp order
+const projectLogReorderDelay = 250 * time.Millisecond
+
+// LogOptions selects container log output
+type LogOptions struct {
+	Tail string
+	// Since and Until accept RFC 3339 times, Unix timestamps or durations
+	// relative to now, e.g. "15m"
+	Since  string
+	Until  string
+	Stdout bool
+	Stderr bool
+	// Filter keeps only lines whose message matches
+	Filter *regexp.Regexp
+	Follow bool
+}
+
+// Validate checks the time bounds and defaults to both streams
+func (o *LogOptions) Validate() error {
+	if !o.Stdout && !o.Stderr {
+		o.Stdout, o.Stderr = true, true
+	}
+	if o.Tail != "" && o.Tail != "all" {
+		if n, err := strconv.Atoi(o.Tail); err != nil || n < 0 {
+			return fmt.Errorf("tail must be a non-negative number or \"all\"")
+		}
+	}
+	for name, value := range map[string]string{"since": o.Since, "until": o.Until} {
+		if value != "" && !validLogTime(value) {
+			return fmt.Errorf("%s must be an RFC 3339 time, Unix timestamp or duration", name)
+		}
+	}
+	return nil
+}
+
+func validLogTime(value string) bool {
+	if _, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return true
+	}
+	if _, err := time.ParseDuration(value); err == nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// LogLine is a demultiplexed log line
+type LogLine struct {
+	Container string    `json:"container,omitempty"`
+	Service   string    `json:"service,omitempty"`
+	Stream    string    `json:"stream"`
+	Time      time.Time `json:"time"`
+	Message   string    `json:"message"`
+}
+
+// ContainerLogService reads container logs as demultiplexed, timestamped
+// lines, for single containers and whole projects
+type ContainerLogService struct {
+	dockerService *DockerService
+}
+
+func NewContainerLogService(dockerService *DockerService) *ContainerLogService {
+	return &ContainerLogService{dockerService: dockerService}
+}
+
+// Read emits the lines of one container until the output ends (or, when
+// following, until ctx is cancelled or emit fails)
+func (s *ContainerLogService) Read(ctx context.Context, containerID string, opts LogOptions, emit func(LogLine) error) error {
+	info, err := s.dockerService.GetContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	base := LogLine{Container: strings.TrimPrefix(info.Name, "/")}
+	if info.Config != nil {
+		base.Service = containerService(info.Config.Labels)
+	}
+	tty := info.Config != nil && info.Config.Tty
+	return s.read(ctx, info.ID, tty, base, opts, emit)
+}
+
+func (s *ContainerLogService) read(ctx context.Context, containerID string, tty bool, base LogLine, opts LogOptions, emit func(LogLine) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader, err := s.dockerService.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: opts.Stdout,
+		ShowStderr: opts.Stderr,
+		Tail:       opts.Tail,
+		Since:      opts.Since,
+		Until:      opts.Until,
+		Follow:     opts.Follow,
+		Timestamps: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	go func() {
+		<-ctx.Done()
+		reader.Close()
+	}()
+
+	// emit errors are kept so a failed client write stops the copy
+	var emitErr error
+	write := func(stream, raw string) {
+		if emitErr != nil {
+			return
+		}
+		line := base
+		line.Stream = stream
+		line.Time, line.Message = parseLogTimestamp(raw)
+		if opts.Filter != nil && !opts.Filter.MatchString(line.Message) {
+			return
+		}
+		emitErr = emit(line)
+	}
+
+	stdout := &failingLineWriter{logLineWriter: newLogLineWriter("stdout", write), err: &emitErr}
+	stderr := &failingLineWriter{logLineWriter: newLogLineWriter("stderr", write), err: &emitErr}
+	if tty {
+		_, err = io.Copy(stdout, reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	}
+	stdout.Flush()
+	stderr.Flush()
+
+	if emitErr != nil {
+		return emitErr
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// failingLineWriter stops the copy once emitting has failed
+type failingLineWriter struct {
+	*logLineWriter
+	err *error
+}
+
+func (w *failingLineWriter) Write(p []byte) (int, error) {
+	if *w.err != nil {
+		return 0, *w.err
+	}
+	return w.logLineWriter.Write(p)
+}
+
+// ReadProject emits the lines of every container in a project, tagged with
+// its service. Without Follow, lines are merged by timestamp and Tail
+// applies to the merged output; with Follow, lines are emitted as they
+// arrive, held briefly so services stay in timestamp order.
+func (s *ContainerLogService) ReadProject(ctx context.Context, project string, opts LogOptions, emit func(LogLine) error) error {
+	containers, err := s.projectContainers(ctx, project)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("project %s has no containers", project)
+	}
+
+	if !opts.Follow {
+		var lines []LogLine
+		for _, c := range containers {
+			err := s.read(ctx, c.ID, s.isTTY(ctx, c.ID), projectLogBase(c), opts, func(l LogLine) error {
+				lines = append(lines, l)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time.Before(lines[j].Time) })
+		if n, err := strconv.Atoi(opts.Tail); err == nil && n < len(lines) {
+			lines = lines[len(lines)-n:]
+		}
+		for _, l := range lines {
+			if err := emit(l); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	incoming := make(chan LogLine, 256)
+	var wg sync.WaitGroup
+	for _, c := range containers {
+		c := c
+		tty := s.isTTY(ctx, c.ID)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.read(ctx, c.ID, tty, projectLogBase(c), opts, func(l LogLine) error {
+				select {
+				case incoming <- l:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(incoming)
+	}()
+
+	ticker := time.NewTicker(projectLogReorderDelay / 2)
+	defer ticker.Stop()
+
+	var pending []LogLine
+	release := func(all bool) error {
+		sort.SliceStable(pending, func(i, j int) bool { return pending[i].Time.Before(pending[j].Time) })
+		cutoff := time.Now().Add(-projectLogReorderDelay)
+		n := 0
+		for n < len(pending) && (all || pending[n].Time.Before(cutoff)) {
+			if err := emit(pending[n]); err != nil {
+				return err
+			}
+			n++
+		}
+		pending = pending[n:]
+		return nil
+	}
+
+	for {
+		select {
+		case l, ok := <-incoming:
+			if !ok {
+				return release(true)
+			}
+			pending = append(pending, l)
+		case <-ticker.C:
+			if err := release(false); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// isTTY reports whether a container's output is a raw terminal stream
+// rather than multiplexed stdout and stderr
+func (s *ContainerLogService) isTTY(ctx context.Context, containerID string) bool {
+	info, err := s.dockerService.GetContainer(ctx, containerID)
+	return err == nil && info.Config != nil && info.Config.Tty
+}
+
+// projectContainers finds a project's containers by Sunspear or compose label
+func (s *ContainerLogService) projectContainers(ctx context.Context, project string) ([]types.Container, error) {
+	seen := make(map[string]bool)
+	var result []types.Container
+	for _, label := range []string{"com.sunspear.project", "com.docker.compose.project"} {
+		containers, err := s.dockerService.ListContainersByLabel(ctx, label, project)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				result = append(result, c)
+			}
+		}
+	}
+	return result, nil
+}
+
+func projectLogBase(c types.Container) LogLine {
+	name := strings.TrimPrefix(firstName(c.Names), "/")
+	service := containerService(c.Labels)
+	if service == "" {
+		service = name
+	}
+	return LogLine{Container: name, Service: service}
+}
+
+// containerService returns the Sunspear or compose service of a container
+func containerService(labels map[string]string) string {
+	if service := labels["com.sunspear.service"]; service != "" {
+		return service
+	}
+	return labels["com.docker.compose.service"]
+}
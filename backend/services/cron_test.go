@@ -0,0 +1,151 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronFields(t *testing.T) {
+	tests := []struct {
+		expr  string
+		field func(*CronSchedule) uint64
+		want  []int
+	}{
+		{"* * * * *", func(s *CronSchedule) uint64 { return s.hours }, seq(0, 23, 1)},
+		{"5 * * * *", func(s *CronSchedule) uint64 { return s.minutes }, []int{5}},
+		{"10-14 * * * *", func(s *CronSchedule) uint64 { return s.minutes }, seq(10, 14, 1)},
+		{"*/15 * * * *", func(s *CronSchedule) uint64 { return s.minutes }, []int{0, 15, 30, 45}},
+		{"0-30/10 * * * *", func(s *CronSchedule) uint64 { return s.minutes }, []int{0, 10, 20, 30}},
+		{"50/5 * * * *", func(s *CronSchedule) uint64 { return s.minutes }, []int{50, 55}},
+		{"1,15,30 * * * *", func(s *CronSchedule) uint64 { return s.minutes }, []int{1, 15, 30}},
+		{"0 1-3,20-22/2 * * *", func(s *CronSchedule) uint64 { return s.hours }, []int{1, 2, 3, 20, 22}},
+		{"0 0 * jan,jun-aug *", func(s *CronSchedule) uint64 { return s.months }, []int{1, 6, 7, 8}},
+		{"0 0 * * MON-FRI", func(s *CronSchedule) uint64 { return s.weekdays }, seq(1, 5, 1)},
+		{"0 0 * * 7", func(s *CronSchedule) uint64 { return s.weekdays }, []int{0, 7}},
+		{"@weekly", func(s *CronSchedule) uint64 { return s.weekdays }, []int{0}},
+		{"@monthly", func(s *CronSchedule) uint64 { return s.days }, []int{1}},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got, want := tt.field(s), bitsOf(tt.want); got != want {
+			t.Errorf("ParseCron(%q) = %b, want %b", tt.expr, got, want)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"1,,2 * * * *",
+		"@often",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"* * * * *", "2024-03-10 12:00:30", "2024-03-10 12:01:00"},
+		{"*/15 * * * *", "2024-03-10 12:00:00", "2024-03-10 12:15:00"},
+		{"0 3 * * *", "2024-03-10 03:00:00", "2024-03-11 03:00:00"},
+		// Month and year boundaries
+		{"30 2 1 * *", "2024-01-31 23:59:00", "2024-02-01 02:30:00"},
+		{"0 0 * * *", "2024-12-31 23:30:00", "2025-01-01 00:00:00"},
+		{"@yearly", "2024-06-15 08:00:00", "2025-01-01 00:00:00"},
+		{"0 0 31 * *", "2024-04-01 00:00:00", "2024-05-31 00:00:00"},
+		{"0 12 29 2 *", "2024-03-01 00:00:00", "2028-02-29 12:00:00"},
+		{"0 0 1 jan,jul *", "2024-07-01 00:00:00", "2025-01-01 00:00:00"},
+		// Both day fields restricted: either may match (the 13th or a Friday)
+		{"0 0 13 * fri", "2024-09-01 00:00:00", "2024-09-06 00:00:00"},
+		{"0 0 13 * fri", "2024-09-06 00:00:00", "2024-09-13 00:00:00"},
+		{"0 0 13 * mon", "2024-09-09 00:00:00", "2024-09-13 00:00:00"},
+		// Only one restricted: it alone decides
+		{"0 0 13 * *", "2024-09-01 00:00:00", "2024-09-13 00:00:00"},
+		{"0 0 * * mon-fri", "2024-09-06 12:00:00", "2024-09-09 00:00:00"},
+		{"0 0 * * sun", "2024-12-29 00:00:00", "2025-01-05 00:00:00"},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		from := mustTime(t, tt.from)
+		if got, want := s.Next(from), mustTime(t, tt.want); !got.Equal(want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, tt.from, got.Format(time.DateTime), tt.want)
+		}
+	}
+}
+
+func TestCronNextNeverMatches(t *testing.T) {
+	s, err := ParseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(mustTime(t, "2024-01-01 00:00:00")); !next.IsZero() {
+		t.Errorf("Next = %s, want zero time", next)
+	}
+}
+
+func TestCronPrev(t *testing.T) {
+	s, err := ParseCron("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := mustTime(t, "2024-03-01 02:00:00")
+	if got, want := s.Prev(now, 48*time.Hour), mustTime(t, "2024-02-29 03:00:00"); !got.Equal(want) {
+		t.Errorf("Prev = %s, want %s", got, want)
+	}
+	if got := s.Prev(now, time.Hour); !got.IsZero() {
+		t.Errorf("Prev outside window = %s, want zero time", got)
+	}
+}
+
+func seq(lo, hi, step int) []int {
+	var values []int
+	for v := lo; v <= hi; v += step {
+		values = append(values, v)
+	}
+	return values
+}
+
+func bitsOf(values []int) uint64 {
+	var bits uint64
+	for _, v := range values {
+		bits |= 1 << uint(v)
+	}
+	return bits
+}
+
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.DateTime, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
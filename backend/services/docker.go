@@ -2,7 +2,12 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -13,16 +18,34 @@ import (
 	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 type DockerService struct {
-	client *client.Client
+	client       *client.Client
+	registryAuth RegistryAuthProvider
 }
 
-func NewDockerService() (*DockerService, error) {
+// RegistryAuthProvider supplies credentials for pulls and builds from
+// private registries
+type RegistryAuthProvider interface {
+	// RegistryAuth returns the encoded X-Registry-Auth value for an image, or ""
+	RegistryAuth(image string) string
+	AuthConfigs() map[string]registry.AuthConfig
+}
+
+// NewDockerService connects to the daemon at host, or the default socket
+// when host is empty
+func NewDockerService(host string) (*DockerService, error) {
 	// Avoid honoring DOCKER_API_VERSION from env, which can pin an old API version
 	// and fail against newer Docker daemons.
-	cli, err := client.NewClientWithOpts(client.WithAPIVersionNegotiation())
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -30,6 +53,12 @@ func NewDockerService() (*DockerService, error) {
 	return &DockerService{client: cli}, nil
 }
 
+// SetRegistryAuth installs the credential source used by pulls and builds.
+// Call it before the service is shared.
+func (s *DockerService) SetRegistryAuth(p RegistryAuthProvider) {
+	s.registryAuth = p
+}
+
 func (s *DockerService) Close() error {
 	return s.client.Close()
 }
@@ -40,6 +69,12 @@ func (s *DockerService) ListContainers(ctx context.Context, all bool) ([]types.C
 	return s.client.ContainerList(ctx, types.ContainerListOptions{All: all})
 }
 
+// ListContainersMatching lists containers matching Docker filters such as
+// label, name and status
+func (s *DockerService) ListContainersMatching(ctx context.Context, all bool, args filters.Args) ([]types.Container, error) {
+	return s.client.ContainerList(ctx, types.ContainerListOptions{All: all, Filters: args})
+}
+
 func (s *DockerService) GetContainer(ctx context.Context, containerID string) (types.ContainerJSON, error) {
 	return s.client.ContainerInspect(ctx, containerID)
 }
@@ -62,21 +97,17 @@ func (s *DockerService) RemoveContainer(ctx context.Context, containerID string,
 	return s.client.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: force})
 }
 
-func (s *DockerService) GetContainerLogs(ctx context.Context, containerID string, tail string) (io.ReadCloser, error) {
-	return s.client.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Tail:       tail,
-		Timestamps: true,
-	})
+func (s *DockerService) ContainerLogs(ctx context.Context, containerID string, opts types.ContainerLogsOptions) (io.ReadCloser, error) {
+	return s.client.ContainerLogs(ctx, containerID, opts)
 }
 
-func (s *DockerService) StreamContainerLogs(ctx context.Context, containerID string) (io.ReadCloser, error) {
+// FollowContainerLogs follows a container's logs with timestamps from since
+func (s *DockerService) FollowContainerLogs(ctx context.Context, containerID string, since time.Time) (io.ReadCloser, error) {
 	return s.client.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     true,
-		Tail:       "50",
+		Since:      since.Format(time.RFC3339Nano),
 		Timestamps: true,
 	})
 }
@@ -89,18 +120,142 @@ func (s *DockerService) CreateContainer(ctx context.Context, config *container.C
 	return s.client.ContainerCreate(ctx, config, hostConfig, nil, nil, containerName)
 }
 
+// CreateContainerWithNetworking creates a container with endpoint settings
+// (aliases, links) for its initial network
+func (s *DockerService) CreateContainerWithNetworking(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.CreateResponse, error) {
+	return s.client.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, containerName)
+}
+
 func (s *DockerService) RenameContainer(ctx context.Context, containerID string, newName string) error {
 	return s.client.ContainerRename(ctx, containerID, newName)
 }
 
+// CommitContainer creates an image from a container's current filesystem and configuration
+func (s *DockerService) CommitContainer(ctx context.Context, containerID string, options types.ContainerCommitOptions) (types.IDResponse, error) {
+	return s.client.ContainerCommit(ctx, containerID, options)
+}
+
+// ListContainersByLabel returns all containers (running or not) with the given label value
+func (s *DockerService) ListContainersByLabel(ctx context.Context, key, value string) ([]types.Container, error) {
+	return s.client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", key+"="+value)),
+	})
+}
+
+// ListContainersUsingVolume returns all containers (running or not) that mount the named volume
+func (s *DockerService) ListContainersUsingVolume(ctx context.Context, volumeName string) ([]types.Container, error) {
+	return s.client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("volume", volumeName)),
+	})
+}
+
+// WaitContainer blocks until the container exits and returns its exit code
+func (s *DockerService) WaitContainer(ctx context.Context, containerID string) (int64, error) {
+	statusCh, errCh := s.client.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		if status.Error != nil {
+			return status.StatusCode, fmt.Errorf("%s", status.Error.Message)
+		}
+		return status.StatusCode, nil
+	case err := <-errCh:
+		return -1, err
+	}
+}
+
+// CopyFromContainer returns a tar stream of srcPath inside the container
+func (s *DockerService) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, error) {
+	reader, _, err := s.client.CopyFromContainer(ctx, containerID, srcPath)
+	return reader, err
+}
+
+// CopyToContainer extracts a tar stream into dstPath inside the container
+func (s *DockerService) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader) error {
+	return s.client.CopyToContainer(ctx, containerID, dstPath, content, types.CopyToContainerOptions{})
+}
+
+// ReadContainerOutput copies the complete stdout and stderr of a container,
+// without timestamps, into the given writers
+func (s *DockerService) ReadContainerOutput(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	reader, err := s.client.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	return err
+}
+
+// ExecCommand runs a command in a running container, copies its output
+// into the given writers and returns the exit code
+func (s *DockerService) ExecCommand(ctx context.Context, containerID string, cmd, env []string, stdout, stderr io.Writer) (int, error) {
+	exec, err := s.client.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Cmd:          cmd,
+		Env:          env,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	resp, err := s.client.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Close()
+	go func() {
+		<-ctx.Done()
+		resp.Close()
+	}()
+
+	if _, err := stdcopy.StdCopy(stdout, stderr, resp.Reader); err != nil && ctx.Err() == nil {
+		return -1, err
+	}
+	if ctx.Err() != nil {
+		return -1, ctx.Err()
+	}
+
+	inspect, err := s.client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return -1, err
+	}
+	return inspect.ExitCode, nil
+}
+
+func (s *DockerService) PruneContainers(ctx context.Context) (types.ContainersPruneReport, error) {
+	return s.client.ContainersPrune(ctx, filters.NewArgs())
+}
+
 // Image operations
 
 func (s *DockerService) ListImages(ctx context.Context) ([]types.ImageSummary, error) {
 	return s.client.ImageList(ctx, types.ImageListOptions{All: false})
 }
 
+// ListImagesMatching lists images matching Docker filters such as
+// reference, label and dangling. With containerCount, each summary's
+// Containers field holds the number of containers using the image.
+func (s *DockerService) ListImagesMatching(ctx context.Context, args filters.Args, containerCount bool) ([]types.ImageSummary, error) {
+	return s.client.ImageList(ctx, types.ImageListOptions{Filters: args, ContainerCount: containerCount})
+}
+
 func (s *DockerService) PullImage(ctx context.Context, imageName string) (io.ReadCloser, error) {
-	return s.client.ImagePull(ctx, imageName, types.ImagePullOptions{})
+	opts := types.ImagePullOptions{}
+	if s.registryAuth != nil {
+		opts.RegistryAuth = s.registryAuth.RegistryAuth(imageName)
+	}
+	return s.client.ImagePull(ctx, imageName, opts)
+}
+
+func (s *DockerService) RegistryLogin(ctx context.Context, auth registry.AuthConfig) (registry.AuthenticateOKBody, error) {
+	return s.client.RegistryLogin(ctx, auth)
 }
 
 func (s *DockerService) RemoveImage(ctx context.Context, imageID string, force bool) ([]types.ImageDeleteResponseItem, error) {
@@ -128,12 +283,84 @@ func (s *DockerService) PruneImages(ctx context.Context) (types.ImagesPruneRepor
 	return s.client.ImagesPrune(ctx, filters.NewArgs())
 }
 
-func (s *DockerService) BuildImage(ctx context.Context, buildContext io.Reader, tags []string) (types.ImageBuildResponse, error) {
-	return s.client.ImageBuild(ctx, buildContext, types.ImageBuildOptions{
-		Tags:        tags,
-		Remove:      true,
-		ForceRemove: true,
-	})
+// PullImageAndWait pulls an image to completion, returning any error
+// reported in the progress stream
+func (s *DockerService) PullImageAndWait(ctx context.Context, imageName string) error {
+	reader, err := s.PullImage(ctx, imageName)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return jsonmessage.DisplayJSONMessagesStream(reader, io.Discard, 0, false, nil)
+}
+
+// EnsureImage pulls an image unless it is already present locally
+func (s *DockerService) EnsureImage(ctx context.Context, imageName string) error {
+	if _, err := s.InspectImage(ctx, imageName); err == nil {
+		return nil
+	}
+	reader, err := s.PullImage(ctx, imageName)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(io.Discard, reader)
+	return err
+}
+
+// SaveImages streams a `docker save` tarball containing the given images
+func (s *DockerService) SaveImages(ctx context.Context, images []string) (io.ReadCloser, error) {
+	return s.client.ImageSave(ctx, images)
+}
+
+// ImageLoadResult lists what a `docker load` added
+type ImageLoadResult struct {
+	Tags []string `json:"tags"`
+	IDs  []string `json:"ids"` // untagged images, by ID
+}
+
+// LoadImages loads a `docker save` tarball (the daemon also accepts it
+// gzip, bzip2 or xz compressed) and reports the loaded images
+func (s *DockerService) LoadImages(ctx context.Context, input io.Reader) (*ImageLoadResult, error) {
+	resp, err := s.client.ImageLoad(ctx, input, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ImageLoadResult{Tags: []string{}, IDs: []string{}}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if msg.Error != nil {
+			// Reported by the daemon after the load started, like its other
+			// server-side failures
+			return nil, errdefs.System(errors.New(msg.Error.Message))
+		}
+		for _, line := range strings.Split(msg.Stream, "\n") {
+			if id, ok := strings.CutPrefix(line, "Loaded image ID: "); ok {
+				result.IDs = append(result.IDs, strings.TrimSpace(id))
+			} else if tag, ok := strings.CutPrefix(line, "Loaded image: "); ok {
+				result.Tags = append(result.Tags, strings.TrimSpace(tag))
+			}
+		}
+	}
+	return result, nil
+}
+
+// BuildImage starts a build. Stored registry credentials are added to opts
+// so base images from private registries can be pulled.
+func (s *DockerService) BuildImage(ctx context.Context, buildContext io.Reader, opts types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+	if s.registryAuth != nil {
+		opts.AuthConfigs = s.registryAuth.AuthConfigs()
+	}
+	return s.client.ImageBuild(ctx, buildContext, opts)
 }
 
 // System operations
@@ -152,15 +379,16 @@ func (s *DockerService) GetDiskUsage(ctx context.Context) (types.DiskUsage, erro
 
 // Event stream
 
-func (s *DockerService) GetEvents(ctx context.Context) (<-chan events.Message, <-chan error) {
+// GetEvents streams container, image, volume and network events. since is
+// a Docker timestamp ("seconds.nanoseconds"); empty starts from now.
+func (s *DockerService) GetEvents(ctx context.Context, since string) (<-chan events.Message, <-chan error) {
 	return s.client.Events(ctx, types.EventsOptions{
+		Since: since,
 		Filters: filters.NewArgs(
-			filters.Arg("type", "container"),
-			filters.Arg("event", "start"),
-			filters.Arg("event", "stop"),
-			filters.Arg("event", "die"),
-			filters.Arg("event", "kill"),
-			filters.Arg("event", "restart"),
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("type", string(events.ImageEventType)),
+			filters.Arg("type", string(events.VolumeEventType)),
+			filters.Arg("type", string(events.NetworkEventType)),
 		),
 	})
 }
@@ -168,8 +396,14 @@ func (s *DockerService) GetEvents(ctx context.Context) (<-chan events.Message, <
 // Volume operations
 
 func (s *DockerService) ListVolumes(ctx context.Context) ([]*volume.Volume, error) {
+	return s.ListVolumesMatching(ctx, filters.NewArgs())
+}
+
+// ListVolumesMatching lists volumes matching Docker filters such as name,
+// label, driver and dangling
+func (s *DockerService) ListVolumesMatching(ctx context.Context, args filters.Args) ([]*volume.Volume, error) {
 	resp, err := s.client.VolumeList(ctx, volume.ListOptions{
-		Filters: filters.NewArgs(),
+		Filters: args,
 	})
 	if err != nil {
 		return nil, err
@@ -203,6 +437,12 @@ func (s *DockerService) ListNetworks(ctx context.Context) ([]types.NetworkResour
 	return s.client.NetworkList(ctx, types.NetworkListOptions{})
 }
 
+// ListNetworksMatching lists networks matching Docker filters such as name,
+// label, driver and dangling
+func (s *DockerService) ListNetworksMatching(ctx context.Context, args filters.Args) ([]types.NetworkResource, error) {
+	return s.client.NetworkList(ctx, types.NetworkListOptions{Filters: args})
+}
+
 func (s *DockerService) InspectNetwork(ctx context.Context, id string) (types.NetworkResource, error) {
 	return s.client.NetworkInspect(ctx, id, types.NetworkInspectOptions{})
 }
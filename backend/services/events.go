@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+const (
+	eventRetentionDaysSetting = "event_retention_days"
+	defaultEventRetentionDays = 14
+
+	// eventSubscriberBuffer is how many events a live subscriber may fall
+	// behind before it is dropped; clients resume from their last cursor
+	eventSubscriberBuffer = 256
+)
+
+// DockerEvent is a recorded daemon event. ID is a cursor: events are
+// numbered in the order they were received.
+type DockerEvent struct {
+	ID           int64             `json:"id"`
+	Time         string            `json:"time"`
+	Type         string            `json:"type"`
+	Action       string            `json:"action"`
+	ResourceID   string            `json:"resourceId"`
+	ResourceName string            `json:"resourceName,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// EventQuery filters recorded events. Action matches exactly or, for
+// actions with a detail such as "health_status: healthy", by prefix.
+// Resource matches a resource name or ID prefix.
+type EventQuery struct {
+	Type     string
+	Action   string
+	Resource string
+	From     time.Time
+	To       time.Time
+	// After returns events after this cursor, oldest first; otherwise the
+	// newest events are returned first
+	After int64
+	Limit int
+}
+
+// Matches reports whether a live event passes the type, action and
+// resource filters
+func (q EventQuery) Matches(e DockerEvent) bool {
+	if q.Type != "" && e.Type != q.Type {
+		return false
+	}
+	if q.Action != "" && e.Action != q.Action && !strings.HasPrefix(e.Action, q.Action+":") {
+		return false
+	}
+	if q.Resource != "" && e.ResourceName != q.Resource && !strings.HasPrefix(e.ResourceID, q.Resource) {
+		return false
+	}
+	return true
+}
+
+// EventService records Docker events in SQLite and fans them out to live
+// subscribers
+type EventService struct {
+	db            *sql.DB
+	dockerService *DockerService
+
+	mu          sync.Mutex
+	subscribers map[chan DockerEvent]struct{}
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func NewEventService(db *sql.DB, dockerService *DockerService) *EventService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &EventService{
+		db:            db,
+		dockerService: dockerService,
+		subscribers:   make(map[chan DockerEvent]struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+func (s *EventService) Start() {
+	s.wg.Add(2)
+	go s.recordLoop()
+	go s.retentionLoop()
+}
+
+func (s *EventService) Stop() {
+	s.stopOnce.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+		s.mu.Lock()
+		for ch := range s.subscribers {
+			close(ch)
+			delete(s.subscribers, ch)
+		}
+		s.mu.Unlock()
+	})
+}
+
+// Subscribe returns a channel of recorded events. The channel is closed
+// when the subscriber falls too far behind or the service stops.
+func (s *EventService) Subscribe() (<-chan DockerEvent, func()) {
+	ch := make(chan DockerEvent, eventSubscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *EventService) publish(e DockerEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Recording
+
+// recordLoop follows the daemon's event stream, reconnecting from the last
+// recorded event so restarts of the daemon or backend do not leave gaps
+func (s *EventService) recordLoop() {
+	defer s.wg.Done()
+
+	backoff := time.Second
+	for {
+		last := s.lastEventTime()
+		since := ""
+		if last > 0 {
+			since = fmt.Sprintf("%d.%09d", last/int64(time.Second), last%int64(time.Second))
+		}
+
+		msgs, errs := s.dockerService.GetEvents(s.ctx, since)
+		err := s.consume(msgs, errs, last)
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Event recorder: %v", err)
+		} else {
+			backoff = time.Second
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func (s *EventService) consume(msgs <-chan events.Message, errs <-chan error, last int64) error {
+	for {
+		select {
+		case msg := <-msgs:
+			// Since is inclusive, so skip what was stored before reconnecting
+			if msg.TimeNano <= last {
+				continue
+			}
+			e, err := s.record(msg)
+			if err != nil {
+				log.Printf("Event recorder: failed to store event: %v", err)
+				continue
+			}
+			s.publish(e)
+		case err := <-errs:
+			return err
+		case <-s.ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *EventService) record(msg events.Message) (DockerEvent, error) {
+	ts := msg.TimeNano
+	if ts == 0 {
+		ts = time.Unix(msg.Time, 0).UnixNano()
+	}
+	e := DockerEvent{
+		Time:         time.Unix(0, ts).UTC().Format(time.RFC3339Nano),
+		Type:         string(msg.Type),
+		Action:       string(msg.Action),
+		ResourceID:   msg.Actor.ID,
+		ResourceName: msg.Actor.Attributes["name"],
+		Attributes:   msg.Actor.Attributes,
+	}
+	attributes, _ := json.Marshal(e.Attributes)
+
+	res, err := s.db.Exec(`
+		INSERT INTO docker_events (ts, type, action, resource_id, resource_name, attributes)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, ts, e.Type, e.Action, e.ResourceID, e.ResourceName, string(attributes))
+	if err != nil {
+		return e, err
+	}
+	e.ID, err = res.LastInsertId()
+	return e, err
+}
+
+func (s *EventService) lastEventTime() int64 {
+	var ts int64
+	s.db.QueryRow("SELECT COALESCE(MAX(ts), 0) FROM docker_events").Scan(&ts)
+	return ts
+}
+
+// Retention
+
+func (s *EventService) retentionLoop() {
+	defer s.wg.Done()
+
+	s.applyRetention()
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.applyRetention()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// applyRetention deletes events older than event_retention_days
+func (s *EventService) applyRetention() {
+	days := defaultEventRetentionDays
+	var value string
+	if err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", eventRetentionDaysSetting).Scan(&value); err == nil {
+		if n, err := strconv.Atoi(value); err == nil {
+			days = n
+		}
+	}
+	if days <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days).UnixNano()
+	if _, err := s.db.Exec("DELETE FROM docker_events WHERE ts < ?", cutoff); err != nil {
+		log.Printf("Event recorder: retention failed: %v", err)
+	}
+}
+
+// Queries
+
+// List returns recorded events matching q
+func (s *EventService) List(q EventQuery) ([]DockerEvent, error) {
+	if q.Limit <= 0 || q.Limit > 5000 {
+		q.Limit = 500
+	}
+
+	query := "SELECT id, ts, type, action, resource_id, resource_name, attributes FROM docker_events"
+	var where []string
+	var args []interface{}
+
+	if q.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, q.Type)
+	}
+	if q.Action != "" {
+		where = append(where, "(action = ? OR action LIKE ? ESCAPE '\\')")
+		args = append(args, q.Action, escapeLike(q.Action)+":%")
+	}
+	if q.Resource != "" {
+		where = append(where, "(resource_name = ? OR resource_id LIKE ? ESCAPE '\\')")
+		args = append(args, q.Resource, escapeLike(q.Resource)+"%")
+	}
+	if !q.From.IsZero() {
+		where = append(where, "ts >= ?")
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "ts <= ?")
+		args = append(args, q.To.UnixNano())
+	}
+	if q.After > 0 {
+		where = append(where, "id > ?")
+		args = append(args, q.After)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if q.After > 0 {
+		query += " ORDER BY id ASC LIMIT ?"
+	} else {
+		query += " ORDER BY id DESC LIMIT ?"
+	}
+	args = append(args, q.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []DockerEvent{}
+	for rows.Next() {
+		var e DockerEvent
+		var ts int64
+		var attributes string
+		if err := rows.Scan(&e.ID, &ts, &e.Type, &e.Action, &e.ResourceID, &e.ResourceName, &attributes); err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+		json.Unmarshal([]byte(attributes), &e.Attributes)
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
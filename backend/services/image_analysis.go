@@ -0,0 +1,534 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/errdefs"
+)
+
+const (
+	// maxAnalysisJSONSize bounds non-layer files (manifest and config)
+	// kept in memory while scanning an exported image
+	maxAnalysisJSONSize = 8 << 20
+	analysisTopFiles    = 50
+
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// ErrAnalysisRunning is returned when the image is already being analyzed
+var ErrAnalysisRunning = errors.New("this image is already being analyzed")
+
+// ImageAnalysis reports where an image's size comes from
+type ImageAnalysis struct {
+	ImageID string `json:"imageId"`
+	// TotalBytes is the size of every file version in every layer;
+	// FinalBytes only counts files visible in the final filesystem
+	TotalBytes  int64 `json:"totalBytes"`
+	FinalBytes  int64 `json:"finalBytes"`
+	WastedBytes int64 `json:"wastedBytes"`
+	// Efficiency is FinalBytes/TotalBytes: 1 means no layer overwrites or
+	// deletes anything an earlier layer added
+	Efficiency   float64         `json:"efficiency"`
+	Layers       []LayerAnalysis `json:"layers"`
+	LargestFiles []AnalyzedFile  `json:"largestFiles"`
+	WastedFiles  []WastedFile    `json:"wastedFiles"`
+	AnalyzedAt   string          `json:"analyzedAt"`
+}
+
+// LayerAnalysis summarizes one layer
+type LayerAnalysis struct {
+	Index     int    `json:"index"`
+	DiffID    string `json:"diffId"`
+	CreatedBy string `json:"createdBy"`
+	Bytes     int64  `json:"bytes"`
+	Files     int    `json:"files"`
+	Deletions int    `json:"deletions"`
+}
+
+// AnalyzedFile is a file in the final filesystem
+type AnalyzedFile struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Layer int    `json:"layer"`
+}
+
+// WastedFile is a path whose content in some layer is overwritten or
+// deleted by a later one
+type WastedFile struct {
+	Path        string `json:"path"`
+	WastedBytes int64  `json:"wastedBytes"`
+	Occurrences int    `json:"occurrences"`
+	Layers      []int  `json:"layers"`
+}
+
+// LayerEntry is a file, directory, link or deletion in a layer
+type LayerEntry struct {
+	Path string `json:"p"`
+	Size int64  `json:"s,omitempty"`
+	Type string `json:"t"` // file, dir, symlink, hardlink, deleted, opaque
+}
+
+// LayerTreeNode is a directory tree of a layer with aggregated sizes
+type LayerTreeNode struct {
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`
+	Size     int64            `json:"size"`
+	Children []*LayerTreeNode `json:"children,omitempty"`
+	// Truncated is set on directories whose children were cut off by depth
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// imageAnalysisCache is what is stored per image ID
+type imageAnalysisCache struct {
+	Analysis ImageAnalysis  `json:"analysis"`
+	Entries  [][]LayerEntry `json:"entries"`
+}
+
+// ImageAnalysisService analyzes image layers from `docker save` output and
+// caches the result per image ID, which is the digest of the image config
+type ImageAnalysisService struct {
+	db            *sql.DB
+	dockerService *DockerService
+	running       sync.Map // image ID -> struct{}
+}
+
+func NewImageAnalysisService(db *sql.DB, dockerService *DockerService) *ImageAnalysisService {
+	return &ImageAnalysisService{db: db, dockerService: dockerService}
+}
+
+// Analyze returns the cached analysis of an image, computing it first when
+// missing or when refresh is set
+func (s *ImageAnalysisService) Analyze(ctx context.Context, image string, refresh bool) (*ImageAnalysis, error) {
+	cached, err := s.load(ctx, image, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return &cached.Analysis, nil
+}
+
+// LayerTree returns the file tree of one layer, depth levels deep (0 for
+// everything), rooted at dir
+func (s *ImageAnalysisService) LayerTree(ctx context.Context, image string, layer int, dir string, depth int) (*LayerTreeNode, error) {
+	cached, err := s.load(ctx, image, false)
+	if err != nil {
+		return nil, err
+	}
+	if layer < 0 || layer >= len(cached.Entries) {
+		return nil, errdefs.NotFound(fmt.Errorf("image has no layer %d", layer))
+	}
+	return buildLayerTree(cached.Entries[layer], strings.Trim(path.Clean("/"+dir), "/"), depth), nil
+}
+
+func (s *ImageAnalysisService) load(ctx context.Context, image string, refresh bool) (*imageAnalysisCache, error) {
+	inspect, err := s.dockerService.InspectImage(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	if !refresh {
+		var data string
+		err := s.db.QueryRow("SELECT result FROM image_analyses WHERE image_id = ?", inspect.ID).Scan(&data)
+		if err == nil {
+			var cached imageAnalysisCache
+			if err := json.Unmarshal([]byte(data), &cached); err == nil {
+				return &cached, nil
+			}
+		} else if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	if _, busy := s.running.LoadOrStore(inspect.ID, struct{}{}); busy {
+		return nil, ErrAnalysisRunning
+	}
+	defer s.running.Delete(inspect.ID)
+
+	reader, err := s.dockerService.SaveImages(ctx, []string{inspect.ID})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	cached, err := analyzeImageArchive(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze image: %w", err)
+	}
+	cached.Analysis.ImageID = inspect.ID
+
+	data, _ := json.Marshal(cached)
+	if _, err := s.db.Exec(`
+		INSERT INTO image_analyses (image_id, result) VALUES (?, ?)
+		ON CONFLICT(image_id) DO UPDATE SET result = excluded.result, created_at = CURRENT_TIMESTAMP
+	`, inspect.ID, string(data)); err != nil {
+		log.Printf("Failed to cache analysis of %s: %v", inspect.ID, err)
+	}
+	s.db.QueryRow("SELECT created_at FROM image_analyses WHERE image_id = ?", inspect.ID).Scan(&cached.Analysis.AnalyzedAt)
+	s.pruneCache(ctx)
+	return cached, nil
+}
+
+// pruneCache drops analyses of images that no longer exist
+func (s *ImageAnalysisService) pruneCache(ctx context.Context) {
+	images, err := s.dockerService.ListImages(ctx)
+	if err != nil {
+		return
+	}
+	present := make(map[string]bool, len(images))
+	for _, img := range images {
+		present[img.ID] = true
+	}
+
+	rows, err := s.db.Query("SELECT image_id FROM image_analyses")
+	if err != nil {
+		return
+	}
+	var stale []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil && !present[id] {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	for _, id := range stale {
+		s.db.Exec("DELETE FROM image_analyses WHERE image_id = ?", id)
+	}
+}
+
+// analyzeImageArchive scans a single-image `docker save` tarball. Layers may
+// come before manifest.json, so each tar-like entry is indexed as it streams
+// by and matched to the manifest's layer order afterwards.
+func analyzeImageArchive(r io.Reader) (*imageAnalysisCache, error) {
+	tr := tar.NewReader(r)
+	layers := make(map[string][]LayerEntry)
+	files := make(map[string][]byte)
+	links := make(map[string]string)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(hdr.Name)
+
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			// Legacy layouts link duplicate layers to the first copy
+			links[name] = path.Join(path.Dir(name), hdr.Linkname)
+			continue
+		case tar.TypeReg:
+		default:
+			continue
+		}
+
+		br := bufio.NewReader(tr)
+		if first, _ := br.Peek(1); len(first) == 1 && (first[0] == '{' || first[0] == '[') {
+			if hdr.Size <= maxAnalysisJSONSize {
+				data, err := io.ReadAll(br)
+				if err != nil {
+					return nil, err
+				}
+				files[name] = data
+			}
+			continue
+		}
+
+		entries, ok, err := scanLayer(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer %s: %w", name, err)
+		}
+		if ok {
+			layers[name] = entries
+		}
+	}
+
+	var manifest []struct {
+		Config string
+		Layers []string
+	}
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil || len(manifest) == 0 {
+		return nil, fmt.Errorf("archive has no usable manifest.json")
+	}
+
+	var config struct {
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+		History []struct {
+			CreatedBy  string `json:"created_by"`
+			EmptyLayer bool   `json:"empty_layer"`
+		} `json:"history"`
+	}
+	json.Unmarshal(files[path.Clean(manifest[0].Config)], &config)
+
+	// Non-empty history entries correspond to layers in order
+	var commands []string
+	for _, h := range config.History {
+		if !h.EmptyLayer {
+			commands = append(commands, h.CreatedBy)
+		}
+	}
+
+	ordered := make([][]LayerEntry, len(manifest[0].Layers))
+	for i, layerPath := range manifest[0].Layers {
+		name := path.Clean(layerPath)
+		for hops := 0; hops < 10; hops++ {
+			target, ok := links[name]
+			if !ok {
+				break
+			}
+			name = target
+		}
+		entries, ok := layers[name]
+		if !ok {
+			return nil, fmt.Errorf("layer %s is missing from the archive", layerPath)
+		}
+		ordered[i] = entries
+	}
+
+	analysis := analyzeLayers(ordered)
+	for i := range analysis.Layers {
+		if i < len(config.RootFS.DiffIDs) {
+			analysis.Layers[i].DiffID = config.RootFS.DiffIDs[i]
+		}
+		if i < len(commands) {
+			analysis.Layers[i].CreatedBy = commands[i]
+		}
+	}
+	return &imageAnalysisCache{Analysis: *analysis, Entries: ordered}, nil
+}
+
+// scanLayer lists the entries of a layer tarball, which may be gzip
+// compressed. ok is false when the data is not a tar archive.
+func scanLayer(br *bufio.Reader) (entries []LayerEntry, ok bool, err error) {
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, false, nil
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	entries = []LayerEntry{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, true, nil
+		}
+		if err != nil {
+			if len(entries) == 0 {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+
+		p := strings.Trim(path.Clean("/"+hdr.Name), "/")
+		if p == "" {
+			continue
+		}
+		dir, base := path.Split(p)
+		entry := LayerEntry{Path: p}
+		switch {
+		case base == whiteoutOpaque:
+			entry.Path, entry.Type = strings.TrimSuffix(dir, "/"), "opaque"
+		case strings.HasPrefix(base, whiteoutPrefix):
+			entry.Path, entry.Type = dir+strings.TrimPrefix(base, whiteoutPrefix), "deleted"
+		case hdr.Typeflag == tar.TypeDir:
+			entry.Type = "dir"
+		case hdr.Typeflag == tar.TypeSymlink:
+			entry.Type = "symlink"
+		case hdr.Typeflag == tar.TypeLink:
+			entry.Type = "hardlink"
+		case hdr.Typeflag == tar.TypeReg:
+			entry.Type, entry.Size = "file", hdr.Size
+		default:
+			continue
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// analyzeLayers replays the layers in order, tracking which file versions
+// survive to the final filesystem
+func analyzeLayers(layers [][]LayerEntry) *ImageAnalysis {
+	type liveFile struct {
+		size  int64
+		layer int
+	}
+	live := make(map[string]liveFile)
+	history := make(map[string]*WastedFile)
+
+	analysis := &ImageAnalysis{Layers: make([]LayerAnalysis, len(layers))}
+
+	discard := func(p string, f liveFile) {
+		w := history[p]
+		w.WastedBytes += f.size
+		delete(live, p)
+	}
+	removeTree := func(dir string, includeSelf bool) {
+		prefix := dir + "/"
+		if dir == "" {
+			prefix = ""
+		}
+		for p, f := range live {
+			if strings.HasPrefix(p, prefix) || (includeSelf && p == dir) {
+				discard(p, f)
+			}
+		}
+	}
+
+	for i, entries := range layers {
+		layer := &analysis.Layers[i]
+		layer.Index = i
+
+		// Whiteouts apply to lower layers only, so process them first
+		for _, e := range entries {
+			switch e.Type {
+			case "opaque":
+				removeTree(e.Path, false)
+				layer.Deletions++
+			case "deleted":
+				removeTree(e.Path, true)
+				layer.Deletions++
+			}
+		}
+
+		for _, e := range entries {
+			if e.Type != "file" {
+				continue
+			}
+			layer.Files++
+			layer.Bytes += e.Size
+			analysis.TotalBytes += e.Size
+
+			w, ok := history[e.Path]
+			if !ok {
+				w = &WastedFile{Path: "/" + e.Path}
+				history[e.Path] = w
+			}
+			w.Occurrences++
+			w.Layers = append(w.Layers, i)
+
+			if old, ok := live[e.Path]; ok {
+				discard(e.Path, old)
+			}
+			live[e.Path] = liveFile{size: e.Size, layer: i}
+		}
+	}
+
+	for p, f := range live {
+		analysis.FinalBytes += f.size
+		analysis.LargestFiles = append(analysis.LargestFiles, AnalyzedFile{Path: "/" + p, Size: f.size, Layer: f.layer})
+	}
+	sort.Slice(analysis.LargestFiles, func(a, b int) bool {
+		return analysis.LargestFiles[a].Size > analysis.LargestFiles[b].Size
+	})
+	if len(analysis.LargestFiles) > analysisTopFiles {
+		analysis.LargestFiles = analysis.LargestFiles[:analysisTopFiles]
+	}
+
+	analysis.WastedFiles = []WastedFile{}
+	for _, w := range history {
+		if w.WastedBytes > 0 {
+			analysis.WastedBytes += w.WastedBytes
+			analysis.WastedFiles = append(analysis.WastedFiles, *w)
+		}
+	}
+	sort.Slice(analysis.WastedFiles, func(a, b int) bool {
+		return analysis.WastedFiles[a].WastedBytes > analysis.WastedFiles[b].WastedBytes
+	})
+	if len(analysis.WastedFiles) > analysisTopFiles {
+		analysis.WastedFiles = analysis.WastedFiles[:analysisTopFiles]
+	}
+
+	analysis.Efficiency = 1
+	if analysis.TotalBytes > 0 {
+		analysis.Efficiency = float64(analysis.FinalBytes) / float64(analysis.TotalBytes)
+	}
+	return analysis
+}
+
+// buildLayerTree nests a layer's entries under root, aggregating file sizes
+// into their directories
+func buildLayerTree(entries []LayerEntry, root string, depth int) *LayerTreeNode {
+	tree := &LayerTreeNode{Name: "/" + root, Type: "dir"}
+	dirs := map[string]*LayerTreeNode{"": tree}
+
+	var node func(p string) *LayerTreeNode
+	node = func(p string) *LayerTreeNode {
+		if n, ok := dirs[p]; ok {
+			return n
+		}
+		parentPath, name := path.Split(p)
+		parent := node(strings.TrimSuffix(parentPath, "/"))
+		n := &LayerTreeNode{Name: name, Type: "dir"}
+		parent.Children = append(parent.Children, n)
+		dirs[p] = n
+		return n
+	}
+
+	for _, e := range entries {
+		rel := e.Path
+		if root != "" {
+			if !strings.HasPrefix(e.Path, root+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(e.Path, root+"/")
+		}
+
+		if e.Type == "dir" || e.Type == "opaque" {
+			n := node(rel)
+			if e.Type == "opaque" {
+				n.Type = "opaque"
+			}
+			continue
+		}
+
+		parentPath, name := path.Split(rel)
+		parent := node(strings.TrimSuffix(parentPath, "/"))
+		parent.Children = append(parent.Children, &LayerTreeNode{Name: name, Type: e.Type, Size: e.Size})
+	}
+
+	finishLayerTree(tree, depth, 1)
+	return tree
+}
+
+// finishLayerTree sums directory sizes, sorts children largest first and
+// cuts the tree off below depth
+func finishLayerTree(n *LayerTreeNode, depth, level int) int64 {
+	if len(n.Children) == 0 {
+		return n.Size
+	}
+	var total int64
+	for _, c := range n.Children {
+		total += finishLayerTree(c, depth, level+1)
+	}
+	n.Size = total
+	sort.Slice(n.Children, func(a, b int) bool { return n.Children[a].Size > n.Children[b].Size })
+	if depth > 0 && level > depth {
+		n.Children = nil
+		n.Truncated = true
+	}
+	return total
+}
@@ -0,0 +1,591 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+const (
+	BuildSourceDockerfile = "dockerfile" // inline Dockerfile, no other files
+	BuildSourceUpload     = "upload"     // uploaded tar, tar.gz or zip context
+	BuildSourceGit        = "git"
+
+	BuildStatusRunning = "running"
+	BuildStatusSuccess = "success"
+	BuildStatusFailed  = "failed"
+
+	// maxBuildLog bounds the stored log; the tail is kept since that is
+	// where failures are
+	maxBuildLog = 1 << 20
+)
+
+var (
+	buildStepPattern = regexp.MustCompile(`^Step (\d+)/(\d+) : `)
+	platformPattern  = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+)
+
+// BuildOptions describes an image build
+type BuildOptions struct {
+	Tags []string `json:"tags"`
+	// Dockerfile is inline Dockerfile content, used when no context is given
+	Dockerfile string `json:"-"`
+	// DockerfilePath is the Dockerfile's path within the context
+	DockerfilePath string            `json:"dockerfilePath,omitempty"`
+	BuildArgs      map[string]string `json:"buildArgs,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Target         string            `json:"target,omitempty"`
+	Platform       string            `json:"platform,omitempty"`
+	NoCache        bool              `json:"noCache,omitempty"`
+	GitURL         string            `json:"gitUrl,omitempty"`
+	GitRef         string            `json:"gitRef,omitempty"`
+	GitSubdir      string            `json:"gitSubdir,omitempty"`
+}
+
+// BuildEvent is one structured progress event of a running build
+type BuildEvent struct {
+	Type    string `json:"type"` // build, step, log, progress, error, done
+	BuildID int    `json:"buildId,omitempty"`
+	Step    int    `json:"step,omitempty"`
+	Total   int    `json:"total,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Progress fields, for layers pulled by FROM
+	Layer   string `json:"layer,omitempty"`
+	Current int64  `json:"current,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	ImageID string `json:"imageId,omitempty"`
+	Status  string `json:"status,omitempty"`
+}
+
+// ImageBuildRecord is a build in the history
+type ImageBuildRecord struct {
+	ID         int          `json:"id"`
+	Source     string       `json:"source"`
+	Tags       []string     `json:"tags"`
+	Options    BuildOptions `json:"options"`
+	Status     string       `json:"status"`
+	ImageID    string       `json:"imageId"`
+	Error      string       `json:"error"`
+	Log        string       `json:"log,omitempty"`
+	StartedAt  string       `json:"startedAt"`
+	FinishedAt string       `json:"finishedAt"`
+}
+
+// ImageBuildService runs image builds and keeps their history and logs
+type ImageBuildService struct {
+	db            *sql.DB
+	dockerService *DockerService
+}
+
+func NewImageBuildService(db *sql.DB, dockerService *DockerService) *ImageBuildService {
+	return &ImageBuildService{db: db, dockerService: dockerService}
+}
+
+// RecoverInterrupted marks builds left running by a previous process as
+// failed and masks build arg values and git credentials stored by earlier
+// versions
+func (s *ImageBuildService) RecoverInterrupted() {
+	if _, err := s.db.Exec(`
+		UPDATE image_builds SET status = ?, error = 'interrupted by backend restart', finished_at = CURRENT_TIMESTAMP
+		WHERE status = ?
+	`, BuildStatusFailed, BuildStatusRunning); err != nil {
+		log.Printf("Failed to reset interrupted builds: %v", err)
+	}
+	if err := s.maskStoredOptions(); err != nil {
+		log.Printf("Failed to mask stored build options: %v", err)
+	}
+}
+
+func (s *ImageBuildService) maskStoredOptions() error {
+	rows, err := s.db.Query(`SELECT id, options FROM image_builds WHERE options LIKE '%"buildArgs"%' OR options LIKE '%"gitUrl":"%@%'`)
+	if err != nil {
+		return err
+	}
+	type maskedBuild struct {
+		options        string
+		gitURL, masked string
+	}
+	masked := make(map[int]maskedBuild)
+	for rows.Next() {
+		var id int
+		var optsJSON string
+		if err := rows.Scan(&id, &optsJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		var opts BuildOptions
+		if json.Unmarshal([]byte(optsJSON), &opts) != nil {
+			continue
+		}
+		redacted := opts.Redacted()
+		if data, _ := json.Marshal(redacted); string(data) != optsJSON {
+			masked[id] = maskedBuild{options: string(data), gitURL: opts.GitURL, masked: redacted.GitURL}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The error and log may quote the git URL, so it is replaced there too
+	for id, b := range masked {
+		if _, err := s.db.Exec(
+			"UPDATE image_builds SET options = ?, error = REPLACE(error, ?, ?), log = REPLACE(log, ?, ?) WHERE id = ?",
+			b.options, b.gitURL, b.masked, b.gitURL, b.masked, id,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks build options. buildContext reports whether a context
+// archive accompanies them.
+func (o *BuildOptions) Validate(buildContext bool) error {
+	if len(o.Tags) == 0 {
+		return fmt.Errorf("at least one tag is required")
+	}
+	for _, tag := range o.Tags {
+		if _, err := reference.ParseNormalizedNamed(tag); err != nil {
+			return fmt.Errorf("invalid tag %q: %w", tag, err)
+		}
+	}
+
+	sources := 0
+	if buildContext {
+		sources++
+	}
+	if o.GitURL != "" {
+		sources++
+	}
+	if sources > 1 {
+		return fmt.Errorf("provide either a context archive or a git URL, not both")
+	}
+	if sources == 0 && o.Dockerfile == "" {
+		return fmt.Errorf("a context archive, git URL or dockerfile content is required")
+	}
+	if sources == 1 && o.Dockerfile != "" {
+		return fmt.Errorf("inline dockerfile content cannot be combined with a context; use dockerfilePath")
+	}
+
+	if o.GitURL != "" {
+		if !isGitURL(o.GitURL) {
+			return fmt.Errorf("gitUrl must be an http(s), git or ssh repository URL")
+		}
+		if strings.ContainsAny(o.GitRef+o.GitSubdir, "#: ") {
+			return fmt.Errorf("gitRef and gitSubdir cannot contain '#', ':' or spaces")
+		}
+	} else if o.GitRef != "" || o.GitSubdir != "" {
+		return fmt.Errorf("gitRef and gitSubdir require gitUrl")
+	}
+
+	if o.Platform != "" && !platformPattern.MatchString(o.Platform) {
+		return fmt.Errorf("platform must look like os/arch[/variant]")
+	}
+	return nil
+}
+
+func isGitURL(u string) bool {
+	for _, prefix := range []string{"https://", "http://", "git://", "ssh://", "git@"} {
+		if strings.HasPrefix(u, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy safe to store and return from the API. Build args
+// often carry tokens, so only their names are kept, and credentials are
+// stripped from the git URL.
+func (o BuildOptions) Redacted() BuildOptions {
+	o.GitURL = stripURLUserinfo(o.GitURL)
+	if len(o.BuildArgs) == 0 {
+		return o
+	}
+	args := make(map[string]string, len(o.BuildArgs))
+	for name := range o.BuildArgs {
+		args[name] = redactedValue
+	}
+	o.BuildArgs = args
+	return o
+}
+
+// stripURLUserinfo drops user:token@ from a URL. scp-style git@host:repo
+// addresses carry no secret and are returned unchanged.
+func stripURLUserinfo(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// Source reports where the build context comes from
+func (o *BuildOptions) Source(buildContext bool) string {
+	switch {
+	case o.GitURL != "":
+		return BuildSourceGit
+	case buildContext:
+		return BuildSourceUpload
+	}
+	return BuildSourceDockerfile
+}
+
+// Build runs a build, calling emit for each progress event. buildContext may
+// be nil for git and inline Dockerfile builds; uploaded contexts may be tar
+// (optionally gzip, bzip2 or xz compressed) or zip. The build is recorded in
+// the history whatever the outcome.
+func (s *ImageBuildService) Build(ctx context.Context, opts BuildOptions, buildContext io.Reader, emit func(BuildEvent)) (*ImageBuildRecord, error) {
+	if err := opts.Validate(buildContext != nil); err != nil {
+		return nil, err
+	}
+	source := opts.Source(buildContext != nil)
+
+	tagsJSON, _ := json.Marshal(opts.Tags)
+	optsJSON, _ := json.Marshal(opts.Redacted())
+	result, err := s.db.Exec(
+		"INSERT INTO image_builds (source, tags, options, status) VALUES (?, ?, ?, ?)",
+		source, string(tagsJSON), string(optsJSON), BuildStatusRunning,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id64, _ := result.LastInsertId()
+	id := int(id64)
+	emit(BuildEvent{Type: "build", BuildID: id})
+
+	buildLog := &tailBuffer{limit: maxBuildLog}
+	imageID, buildErr := s.run(ctx, opts, source, buildContext, buildLog, emit)
+
+	status, errMsg := BuildStatusSuccess, ""
+	if buildErr != nil {
+		status, errMsg = BuildStatusFailed, buildErr.Error()
+		buildLog.WriteString("ERROR: " + errMsg + "\n")
+		emit(BuildEvent{Type: "error", Message: errMsg})
+	}
+	// Git errors from the daemon quote the remote, credentials included
+	logText := buildLog.String()
+	if redactedURL := stripURLUserinfo(opts.GitURL); redactedURL != opts.GitURL {
+		errMsg = strings.ReplaceAll(errMsg, opts.GitURL, redactedURL)
+		logText = strings.ReplaceAll(logText, opts.GitURL, redactedURL)
+	}
+	if _, err := s.db.Exec(`
+		UPDATE image_builds SET status = ?, image_id = ?, error = ?, log = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, status, imageID, errMsg, logText, id); err != nil {
+		log.Printf("Failed to record build %d: %v", id, err)
+	}
+	emit(BuildEvent{Type: "done", BuildID: id, Status: status, ImageID: imageID})
+
+	record, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return record, buildErr
+}
+
+func (s *ImageBuildService) run(ctx context.Context, opts BuildOptions, source string, buildContext io.Reader, buildLog *tailBuffer, emit func(BuildEvent)) (string, error) {
+	buildOpts := types.ImageBuildOptions{
+		Tags:        opts.Tags,
+		Dockerfile:  opts.DockerfilePath,
+		Target:      opts.Target,
+		Platform:    opts.Platform,
+		NoCache:     opts.NoCache,
+		Labels:      opts.Labels,
+		Remove:      true,
+		ForceRemove: true,
+	}
+	if len(opts.BuildArgs) > 0 {
+		buildOpts.BuildArgs = make(map[string]*string, len(opts.BuildArgs))
+		for k, v := range opts.BuildArgs {
+			v := v
+			buildOpts.BuildArgs[k] = &v
+		}
+	}
+
+	var body io.Reader
+	switch source {
+	case BuildSourceGit:
+		// The daemon clones the repository itself: url#ref:subdir
+		remote := opts.GitURL
+		if opts.GitRef != "" || opts.GitSubdir != "" {
+			remote += "#" + opts.GitRef
+			if opts.GitSubdir != "" {
+				remote += ":" + opts.GitSubdir
+			}
+		}
+		buildOpts.RemoteContext = remote
+	case BuildSourceUpload:
+		reader, cleanup, err := normalizeBuildContext(buildContext)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		body = reader
+	default:
+		reader, err := dockerfileContext(opts.Dockerfile)
+		if err != nil {
+			return "", err
+		}
+		buildOpts.Dockerfile = "Dockerfile"
+		body = reader
+	}
+
+	resp, err := s.dockerService.BuildImage(ctx, body, buildOpts)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var imageID string
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return imageID, fmt.Errorf("failed to read build output: %w", err)
+		}
+
+		switch {
+		case msg.Error != nil:
+			return imageID, errors.New(msg.Error.Message)
+		case msg.Aux != nil:
+			var aux struct {
+				ID string `json:"ID"`
+			}
+			if json.Unmarshal(*msg.Aux, &aux) == nil && aux.ID != "" {
+				imageID = aux.ID
+			}
+		case msg.Stream != "":
+			buildLog.WriteString(msg.Stream)
+			for _, line := range strings.Split(strings.TrimRight(msg.Stream, "\n"), "\n") {
+				emit(streamEvent(line))
+			}
+		case msg.Status != "":
+			event := BuildEvent{Type: "progress", Layer: msg.ID, Message: msg.Status}
+			if msg.Progress != nil {
+				event.Current, event.Size = msg.Progress.Current, msg.Progress.Total
+			} else {
+				// Progress ticks are too noisy to keep; status changes are useful
+				buildLog.WriteString(strings.TrimSpace(msg.ID+" "+msg.Status) + "\n")
+			}
+			emit(event)
+		}
+	}
+
+	if imageID == "" && len(opts.Tags) > 0 {
+		if inspect, err := s.dockerService.InspectImage(ctx, opts.Tags[0]); err == nil {
+			imageID = inspect.ID
+		}
+	}
+	return imageID, nil
+}
+
+// streamEvent classifies a line of builder output
+func streamEvent(line string) BuildEvent {
+	if m := buildStepPattern.FindStringSubmatch(line); m != nil {
+		step, _ := strconv.Atoi(m[1])
+		total, _ := strconv.Atoi(m[2])
+		return BuildEvent{Type: "step", Step: step, Total: total, Message: line}
+	}
+	return BuildEvent{Type: "log", Message: line}
+}
+
+// dockerfileContext wraps inline Dockerfile content in a tar context
+func dockerfileContext(dockerfile string) (io.Reader, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Size: int64(len(dockerfile)), Mode: 0644}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write([]byte(dockerfile)); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// normalizeBuildContext passes tar archives (compressed or not) through to
+// the daemon, which detects the compression itself, and converts zip
+// archives to tar. Zip needs random access, so it is spooled to a temp file.
+func normalizeBuildContext(r io.Reader) (io.Reader, func(), error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	if !bytes.Equal(magic, []byte("PK\x03\x04")) {
+		return br, func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "sunspear-build-*.zip")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, br)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to receive context: %w", err)
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(zipToTar(zr, pw))
+	}()
+	return pr, func() {
+		pr.Close()
+		cleanup()
+	}, nil
+}
+
+func zipToTar(zr *zip.Reader, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, f := range zr.File {
+		hdr, err := tar.FileInfoHeader(f.FileInfo(), "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = f.Name
+
+		if f.Mode()&os.ModeSymlink != 0 {
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			target, err := io.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			hdr.Linkname, hdr.Size = string(target), 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from zip: %w", f.Name, err)
+		}
+	}
+	return tw.Close()
+}
+
+// ListBuilds returns the build history without logs, newest first
+func (s *ImageBuildService) ListBuilds(limit int) ([]ImageBuildRecord, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.Query(`
+		SELECT id, source, tags, options, status, image_id, error, '', started_at, COALESCE(finished_at, '')
+		FROM image_builds ORDER BY id DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	builds := []ImageBuildRecord{}
+	for rows.Next() {
+		b, err := scanImageBuild(rows)
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, *b)
+	}
+	return builds, rows.Err()
+}
+
+// Get returns a build including its log
+func (s *ImageBuildService) Get(id int) (*ImageBuildRecord, error) {
+	row := s.db.QueryRow(`
+		SELECT id, source, tags, options, status, image_id, error, log, started_at, COALESCE(finished_at, '')
+		FROM image_builds WHERE id = ?
+	`, id)
+	return scanImageBuild(row)
+}
+
+func (s *ImageBuildService) Delete(id int) error {
+	result, err := s.db.Exec("DELETE FROM image_builds WHERE id = ? AND status != ?", id, BuildStatusRunning)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanImageBuild(row rowScanner) (*ImageBuildRecord, error) {
+	var b ImageBuildRecord
+	var tags, opts string
+	if err := row.Scan(&b.ID, &b.Source, &tags, &opts, &b.Status, &b.ImageID, &b.Error, &b.Log, &b.StartedAt, &b.FinishedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(tags), &b.Tags)
+	json.Unmarshal([]byte(opts), &b.Options)
+	b.Options = b.Options.Redacted()
+	return &b, nil
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (t *tailBuffer) WriteString(s string) {
+	t.buf = append(t.buf, s...)
+	if len(t.buf) > t.limit {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.limit:]...)
+		t.truncated = true
+	}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.WriteString(string(p))
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	if t.truncated {
+		return "[earlier output truncated]\n" + string(t.buf)
+	}
+	return string(t.buf)
+}
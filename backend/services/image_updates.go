@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// imageUpdateIntervalSetting is the settings key holding the number of hours
+// between update checks (default 6, 0 disables background checks)
+const imageUpdateIntervalSetting = "image_update_check_hours"
+
+const defaultImageUpdateIntervalHours = 6
+
+// ImageUpdate is the result of checking one image reference against its registry
+type ImageUpdate struct {
+	Image        string `json:"image"`
+	LocalDigest  string `json:"localDigest"`
+	RemoteDigest string `json:"remoteDigest"`
+	// DigestChanged means the tag now points at a different image upstream
+	DigestChanged bool `json:"digestChanged"`
+	// NewerTag is the highest version tag above the current one, for
+	// version-style tags such as 1.25 or v2.4.1-alpine
+	NewerTag        string                 `json:"newerTag,omitempty"`
+	UpdateAvailable bool                   `json:"updateAvailable"`
+	Error           string                 `json:"error,omitempty"`
+	CheckedAt       string                 `json:"checkedAt"`
+	Containers      []ImageUpdateContainer `json:"containers"`
+	Apps            []ImageUpdateApp       `json:"apps"`
+}
+
+// ImageUpdateContainer is a running container using a checked image
+type ImageUpdateContainer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ImageUpdateApp is an installed app whose containers use a checked image
+type ImageUpdateApp struct {
+	ID      int    `json:"id"`
+	AppID   string `json:"appId"`
+	AppName string `json:"appName"`
+}
+
+// ImageUpdateService periodically compares the images of running containers
+// with their registries
+type ImageUpdateService struct {
+	db                 *sql.DB
+	dockerService      *DockerService
+	marketplaceService *MarketplaceService
+	registry           *RegistryClient
+
+	checking  sync.Mutex
+	lastCheck atomic.Int64 // unix seconds
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+func NewImageUpdateService(db *sql.DB, dockerService *DockerService, marketplaceService *MarketplaceService, registry *RegistryClient) *ImageUpdateService {
+	return &ImageUpdateService{
+		db:                 db,
+		dockerService:      dockerService,
+		marketplaceService: marketplaceService,
+		registry:           registry,
+		stopChan:           make(chan struct{}),
+	}
+}
+
+func (s *ImageUpdateService) Start() {
+	var last sql.NullString
+	s.db.QueryRow("SELECT MAX(checked_at) FROM image_updates").Scan(&last)
+	if t, err := time.Parse("2006-01-02 15:04:05", last.String); err == nil {
+		s.lastCheck.Store(t.Unix())
+	}
+	go s.loop()
+}
+
+func (s *ImageUpdateService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+}
+
+func (s *ImageUpdateService) loop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			hours := s.intervalHours()
+			if hours <= 0 || time.Since(time.Unix(s.lastCheck.Load(), 0)) < time.Duration(hours)*time.Hour {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			if err := s.Check(ctx); err != nil {
+				log.Printf("Image update check failed: %v", err)
+			}
+			cancel()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *ImageUpdateService) intervalHours() int {
+	var value string
+	if err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", imageUpdateIntervalSetting).Scan(&value); err != nil {
+		return defaultImageUpdateIntervalHours
+	}
+	hours, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultImageUpdateIntervalHours
+	}
+	return hours
+}
+
+// Check queries the registry for every image used by a running container.
+// Only one check runs at a time; a concurrent call returns immediately.
+func (s *ImageUpdateService) Check(ctx context.Context) error {
+	if !s.checking.TryLock() {
+		return nil
+	}
+	defer s.checking.Unlock()
+	defer func() { s.lastCheck.Store(time.Now().Unix()) }()
+
+	containers, err := s.dockerService.ListContainers(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	// Check each image reference once, using the image the first container runs
+	imageIDs := make(map[string]string)
+	for _, c := range containers {
+		if _, seen := imageIDs[c.Image]; !seen {
+			imageIDs[c.Image] = c.ImageID
+		}
+	}
+
+	checked := make([]string, 0, len(imageIDs))
+	for image, imageID := range imageIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ref, err := ParseImageRef(image)
+		if err != nil {
+			// Digest-pinned or ID references cannot be updated by tag
+			continue
+		}
+		update := s.checkImage(ctx, ref, imageID)
+		update.Image = image
+		if err := s.save(update); err != nil {
+			return err
+		}
+		checked = append(checked, image)
+	}
+
+	return s.pruneExcept(checked)
+}
+
+func (s *ImageUpdateService) checkImage(ctx context.Context, ref ImageRef, imageID string) ImageUpdate {
+	var update ImageUpdate
+
+	info, err := s.dockerService.InspectImage(ctx, imageID)
+	if err != nil {
+		update.Error = "failed to inspect local image: " + err.Error()
+		return update
+	}
+	update.LocalDigest = repoDigestFor(info.RepoDigests, ref)
+
+	remote, err := s.registry.ManifestDigest(ref)
+	if err != nil {
+		update.Error = err.Error()
+		return update
+	}
+	update.RemoteDigest = remote
+
+	if update.LocalDigest == "" {
+		update.Error = "image has no registry digest (built or loaded locally)"
+	} else {
+		update.DigestChanged = update.LocalDigest != remote
+	}
+
+	if current, ok := parseVersionTag(ref.Tag); ok {
+		tags, err := s.registry.ListTags(ref)
+		if err != nil {
+			update.Error = joinErrors(update.Error, "failed to list tags: "+err.Error())
+		} else {
+			update.NewerTag = newestVersionTag(current, tags)
+		}
+	}
+
+	update.UpdateAvailable = update.DigestChanged || update.NewerTag != ""
+	return update
+}
+
+func (s *ImageUpdateService) save(u ImageUpdate) error {
+	_, err := s.db.Exec(`
+		INSERT INTO image_updates (image, local_digest, remote_digest, newer_tag, update_available, error, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(image) DO UPDATE SET local_digest = excluded.local_digest, remote_digest = excluded.remote_digest,
+			newer_tag = excluded.newer_tag, update_available = excluded.update_available, error = excluded.error,
+			checked_at = excluded.checked_at
+	`, u.Image, u.LocalDigest, u.RemoteDigest, u.NewerTag, u.UpdateAvailable, u.Error)
+	return err
+}
+
+// pruneExcept forgets images that are no longer used by running containers
+func (s *ImageUpdateService) pruneExcept(images []string) error {
+	if len(images) == 0 {
+		_, err := s.db.Exec("DELETE FROM image_updates")
+		return err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(images)), ",")
+	args := make([]interface{}, len(images))
+	for i, image := range images {
+		args[i] = image
+	}
+	_, err := s.db.Exec("DELETE FROM image_updates WHERE image NOT IN ("+placeholders+")", args...)
+	return err
+}
+
+// List returns the latest check results with the running containers and
+// installed apps that use each image
+func (s *ImageUpdateService) List(ctx context.Context) ([]ImageUpdate, error) {
+	rows, err := s.db.Query(`
+		SELECT image, local_digest, remote_digest, newer_tag, update_available, error, checked_at
+		FROM image_updates ORDER BY update_available DESC, image
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	updates := []ImageUpdate{}
+	for rows.Next() {
+		var u ImageUpdate
+		if err := rows.Scan(&u.Image, &u.LocalDigest, &u.RemoteDigest, &u.NewerTag, &u.UpdateAvailable, &u.Error, &u.CheckedAt); err != nil {
+			return nil, err
+		}
+		u.DigestChanged = u.LocalDigest != "" && u.RemoteDigest != "" && u.LocalDigest != u.RemoteDigest
+		u.Containers = []ImageUpdateContainer{}
+		u.Apps = []ImageUpdateApp{}
+		updates = append(updates, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	containers, err := s.dockerService.ListContainers(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	appsByContainer := s.appsByContainer()
+
+	for i := range updates {
+		seenApps := make(map[int]bool)
+		for _, c := range containers {
+			if c.Image != updates[i].Image {
+				continue
+			}
+			name := c.ID[:12]
+			if len(c.Names) > 0 {
+				name = strings.TrimPrefix(c.Names[0], "/")
+			}
+			updates[i].Containers = append(updates[i].Containers, ImageUpdateContainer{ID: c.ID, Name: name})
+			if app, ok := appsByContainer[c.ID]; ok && !seenApps[app.ID] {
+				seenApps[app.ID] = true
+				updates[i].Apps = append(updates[i].Apps, app)
+			}
+		}
+	}
+
+	return updates, nil
+}
+
+// UpdateForImage returns the stored result for an image reference, if any
+func (s *ImageUpdateService) UpdateForImage(image string) (*ImageUpdate, error) {
+	var u ImageUpdate
+	err := s.db.QueryRow(`
+		SELECT image, local_digest, remote_digest, newer_tag, update_available, error, checked_at
+		FROM image_updates WHERE image = ?
+	`, image).Scan(&u.Image, &u.LocalDigest, &u.RemoteDigest, &u.NewerTag, &u.UpdateAvailable, &u.Error, &u.CheckedAt)
+	if err != nil {
+		return nil, err
+	}
+	u.DigestChanged = u.LocalDigest != "" && u.RemoteDigest != "" && u.LocalDigest != u.RemoteDigest
+	return &u, nil
+}
+
+func (s *ImageUpdateService) appsByContainer() map[string]ImageUpdateApp {
+	result := make(map[string]ImageUpdateApp)
+	apps, err := s.marketplaceService.GetInstalledApps()
+	if err != nil {
+		log.Printf("Failed to load installed apps for image updates: %v", err)
+		return result
+	}
+	for _, app := range apps {
+		var ids []string
+		json.Unmarshal([]byte(app.ContainerIDs), &ids)
+		for _, id := range ids {
+			result[id] = ImageUpdateApp{ID: app.ID, AppID: app.AppID, AppName: app.AppName}
+		}
+	}
+	return result
+}
+
+// repoDigestFor picks the RepoDigests entry ("name@sha256:...") for the
+// reference's repository
+func repoDigestFor(repoDigests []string, ref ImageRef) string {
+	for _, rd := range repoDigests {
+		named, err := reference.ParseNormalizedNamed(rd)
+		if err != nil {
+			continue
+		}
+		canonical, ok := named.(reference.Canonical)
+		if !ok {
+			continue
+		}
+		if reference.FamiliarName(named) == ref.Name {
+			return canonical.Digest().String()
+		}
+	}
+	return ""
+}
+
+// versionTagPattern matches tags like 1, 1.25, v2.4.1 and 16.2-alpine
+var versionTagPattern = regexp.MustCompile(`^(v?)(\d+(?:\.\d+)*)(-[0-9A-Za-z.-]+)?$`)
+
+// versionTag is a parsed version-style tag. Tags are only comparable when
+// they share the prefix, number of components and suffix, so 1.25-alpine is
+// never "upgraded" to 2.0 or 1.26-bookworm.
+type versionTag struct {
+	prefix  string
+	numbers []int
+	suffix  string
+}
+
+func parseVersionTag(tag string) (versionTag, bool) {
+	m := versionTagPattern.FindStringSubmatch(tag)
+	if m == nil {
+		return versionTag{}, false
+	}
+	parts := strings.Split(m[2], ".")
+	v := versionTag{prefix: m[1], suffix: m[3], numbers: make([]int, len(parts))}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return versionTag{}, false
+		}
+		v.numbers[i] = n
+	}
+	return v, true
+}
+
+func (v versionTag) comparable(o versionTag) bool {
+	return v.prefix == o.prefix && v.suffix == o.suffix && len(v.numbers) == len(o.numbers)
+}
+
+func (v versionTag) less(o versionTag) bool {
+	for i := range v.numbers {
+		if v.numbers[i] != o.numbers[i] {
+			return v.numbers[i] < o.numbers[i]
+		}
+	}
+	return false
+}
+
+// newestVersionTag returns the highest tag comparable with and newer than current
+func newestVersionTag(current versionTag, tags []string) string {
+	best, bestTag := current, ""
+	for _, tag := range tags {
+		v, ok := parseVersionTag(tag)
+		if !ok || !current.comparable(v) {
+			continue
+		}
+		if best.less(v) {
+			best, bestTag = v, tag
+		}
+	}
+	return bestTag
+}
+
+func joinErrors(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "; " + b
+}
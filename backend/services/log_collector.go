@@ -0,0 +1,639 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const (
+	LogCaptureScopeContainer = "container"
+	LogCaptureScopeProject   = "project"
+	LogCaptureScopeLabel     = "label"
+
+	// logCaptureLabel opts a container into capture without a stored target
+	logCaptureLabel = "com.sunspear.logs.capture"
+
+	logRetentionDaysSetting = "log_retention_days"
+	logRetentionMBSetting   = "log_retention_mb"
+
+	defaultLogRetentionDays = 7
+	defaultLogRetentionMB   = 1024
+
+	// maxLogLine bounds a stored line; longer lines are truncated
+	maxLogLine = 16 << 10
+	// logRowOverhead approximates the per-row storage beyond the message,
+	// for size-based retention
+	logRowOverhead = 96
+)
+
+// ErrInvalidLogQuery is returned for search queries that are not valid FTS5 syntax
+var ErrInvalidLogQuery = errors.New("invalid search query")
+
+// LogCaptureTarget selects containers whose logs are stored. Target is a
+// container name, a project name, or a label as key or key=value.
+type LogCaptureTarget struct {
+	ID        int    `json:"id"`
+	Scope     string `json:"scope"`
+	Target    string `json:"target"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// LogEntry is one stored log line
+type LogEntry struct {
+	ID          int64  `json:"id"`
+	ContainerID string `json:"containerId"`
+	Container   string `json:"container"`
+	Project     string `json:"project,omitempty"`
+	Stream      string `json:"stream"`
+	Time        string `json:"time"`
+	Message     string `json:"message"`
+}
+
+// LogSearchQuery filters stored log lines. Query uses FTS5 syntax when full
+// text search is available.
+type LogSearchQuery struct {
+	Query     string
+	Container string
+	Project   string
+	Stream    string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// LogCollectorStatus describes what the collector is doing
+type LogCollectorStatus struct {
+	Following  []string `json:"following"`
+	Lines      int64    `json:"lines"`
+	Bytes      int64    `json:"bytes"`
+	FullText   bool     `json:"fullText"`
+	OldestTime string   `json:"oldestTime,omitempty"`
+}
+
+// LogCollectorService follows the logs of selected containers and stores
+// them in SQLite for searching after the log driver has rotated them away
+type LogCollectorService struct {
+	db            *sql.DB
+	dockerService *DockerService
+	fullText      bool
+
+	lines chan LogEntry
+
+	mu        sync.Mutex
+	following map[string]*logFollower // container ID -> follower
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func NewLogCollectorService(db *sql.DB, dockerService *DockerService) *LogCollectorService {
+	ctx, cancel := context.WithCancel(context.Background())
+	var name string
+	fullText := db.QueryRow("SELECT name FROM sqlite_master WHERE name = 'log_entries_fts'").Scan(&name) == nil
+	return &LogCollectorService{
+		db:            db,
+		dockerService: dockerService,
+		fullText:      fullText,
+		lines:         make(chan LogEntry, 4096),
+		following:     make(map[string]*logFollower),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+func (s *LogCollectorService) Start() {
+	if !s.fullText {
+		log.Printf("SQLite was built without FTS5; log search will use substring matching")
+	}
+	s.wg.Add(3)
+	go s.reconcileLoop()
+	go s.writeLoop()
+	go s.retentionLoop()
+}
+
+func (s *LogCollectorService) Stop() {
+	s.stopOnce.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+	})
+}
+
+// Targets
+
+func (s *LogCollectorService) ListTargets() ([]LogCaptureTarget, error) {
+	rows, err := s.db.Query("SELECT id, scope, target, created_at FROM log_capture_targets ORDER BY scope, target")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []LogCaptureTarget{}
+	for rows.Next() {
+		var t LogCaptureTarget
+		if err := rows.Scan(&t.ID, &t.Scope, &t.Target, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+func (s *LogCollectorService) AddTarget(t LogCaptureTarget) (*LogCaptureTarget, error) {
+	t.Target = strings.TrimSpace(t.Target)
+	switch t.Scope {
+	case LogCaptureScopeContainer, LogCaptureScopeProject, LogCaptureScopeLabel:
+	default:
+		return nil, errdefs.InvalidParameter(fmt.Errorf("scope must be container, project or label"))
+	}
+	if t.Target == "" {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("target is required"))
+	}
+
+	result, err := s.db.Exec("INSERT OR IGNORE INTO log_capture_targets (scope, target) VALUES (?, ?)", t.Scope, t.Target)
+	if err != nil {
+		return nil, err
+	}
+	if id, _ := result.LastInsertId(); id == 0 {
+		return nil, errdefs.Conflict(fmt.Errorf("%s %s is already captured", t.Scope, t.Target))
+	}
+
+	var saved LogCaptureTarget
+	err = s.db.QueryRow("SELECT id, scope, target, created_at FROM log_capture_targets WHERE scope = ? AND target = ?", t.Scope, t.Target).
+		Scan(&saved.ID, &saved.Scope, &saved.Target, &saved.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	go s.reconcile()
+	return &saved, nil
+}
+
+// DeleteTarget stops capturing a target. Stored lines are kept until retention removes them.
+func (s *LogCollectorService) DeleteTarget(id int) error {
+	result, err := s.db.Exec("DELETE FROM log_capture_targets WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	go s.reconcile()
+	return nil
+}
+
+// Following
+
+func (s *LogCollectorService) reconcileLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	s.reconcile()
+	for {
+		select {
+		case <-ticker.C:
+			s.reconcile()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// reconcile starts following newly selected running containers and stops
+// following deselected ones. Followers of stopped containers end on their own.
+func (s *LogCollectorService) reconcile() {
+	targets, err := s.ListTargets()
+	if err != nil {
+		log.Printf("Log collector: failed to load targets: %v", err)
+		return
+	}
+	containers, err := s.dockerService.ListContainers(s.ctx, false)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			log.Printf("Log collector: failed to list containers: %v", err)
+		}
+		return
+	}
+
+	wanted := make(map[string]types.Container)
+	for _, c := range containers {
+		if c.Labels["com.sunspear.helper"] != "" {
+			continue
+		}
+		if c.Labels[logCaptureLabel] == "true" || matchesLogTarget(c, targets) {
+			wanted[c.ID] = c
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return
+	}
+	for id, f := range s.following {
+		if _, ok := wanted[id]; !ok {
+			f.cancel()
+			delete(s.following, id)
+		}
+	}
+	for id, c := range wanted {
+		if _, ok := s.following[id]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		f := &logFollower{cancel: cancel}
+		s.following[id] = f
+		s.wg.Add(1)
+		go s.follow(ctx, f, c)
+	}
+}
+
+type logFollower struct {
+	cancel context.CancelFunc
+}
+
+func matchesLogTarget(c types.Container, targets []LogCaptureTarget) bool {
+	name := strings.TrimPrefix(firstName(c.Names), "/")
+	project := containerProject(c.Labels)
+	for _, t := range targets {
+		switch t.Scope {
+		case LogCaptureScopeContainer:
+			if t.Target == name || strings.HasPrefix(c.ID, t.Target) {
+				return true
+			}
+		case LogCaptureScopeProject:
+			if project != "" && t.Target == project {
+				return true
+			}
+		case LogCaptureScopeLabel:
+			key, value, hasValue := strings.Cut(t.Target, "=")
+			if v, ok := c.Labels[key]; ok && (!hasValue || v == value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containerProject returns the Sunspear or compose project a container belongs to
+func containerProject(labels map[string]string) string {
+	if project := labels["com.sunspear.project"]; project != "" {
+		return project
+	}
+	return labels["com.docker.compose.project"]
+}
+
+// follow streams a container's logs into the writer until the container
+// stops or capture is cancelled, resuming after the last stored line
+func (s *LogCollectorService) follow(ctx context.Context, f *logFollower, c types.Container) {
+	defer s.wg.Done()
+	defer func() {
+		f.cancel()
+		s.mu.Lock()
+		if s.following[c.ID] == f {
+			delete(s.following, c.ID)
+		}
+		s.mu.Unlock()
+	}()
+
+	since := time.Now()
+	var last int64
+	if s.db.QueryRow("SELECT COALESCE(MAX(ts), 0) FROM log_entries WHERE container_id = ?", c.ID).Scan(&last) == nil && last > 0 {
+		since = time.Unix(0, last+1)
+	}
+
+	info, err := s.dockerService.GetContainer(ctx, c.ID)
+	if err != nil {
+		return
+	}
+	reader, err := s.dockerService.FollowContainerLogs(ctx, c.ID, since)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Log collector: failed to follow %s: %v", c.ID[:12], err)
+		}
+		return
+	}
+	defer reader.Close()
+	go func() {
+		<-ctx.Done()
+		reader.Close()
+	}()
+
+	base := LogEntry{
+		ContainerID: c.ID,
+		Container:   strings.TrimPrefix(firstName(c.Names), "/"),
+		Project:     containerProject(c.Labels),
+	}
+	emit := func(stream, line string) {
+		entry := base
+		entry.Stream = stream
+		ts, message := parseLogTimestamp(line)
+		entry.Time, entry.Message = ts.UTC().Format(time.RFC3339Nano), message
+		if len(entry.Message) > maxLogLine {
+			entry.Message = entry.Message[:maxLogLine]
+		}
+		select {
+		case s.lines <- entry:
+		case <-ctx.Done():
+		}
+	}
+
+	stdout := newLogLineWriter("stdout", emit)
+	stderr := newLogLineWriter("stderr", emit)
+	if info.Config != nil && info.Config.Tty {
+		io.Copy(stdout, reader)
+	} else {
+		stdcopy.StdCopy(stdout, stderr, reader)
+	}
+	stdout.Flush()
+	stderr.Flush()
+}
+
+// writeLoop batches captured lines into transactions
+func (s *LogCollectorService) writeLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var batch []LogEntry
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.insert(batch); err != nil {
+			log.Printf("Log collector: failed to store %d lines: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-s.lines:
+			batch = append(batch, entry)
+			if len(batch) >= 500 {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.ctx.Done():
+			// Drain what followers already produced
+			for {
+				select {
+				case entry := <-s.lines:
+					batch = append(batch, entry)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *LogCollectorService) insert(entries []LogEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO log_entries (container_id, container, project, stream, ts, message) VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		ts, _ := time.Parse(time.RFC3339Nano, e.Time)
+		if _, err := stmt.Exec(e.ContainerID, e.Container, e.Project, e.Stream, ts.UnixNano(), e.Message); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Retention
+
+func (s *LogCollectorService) retentionLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.applyRetention()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// applyRetention deletes lines older than log_retention_days, then the
+// oldest lines until the store is under log_retention_mb
+func (s *LogCollectorService) applyRetention() {
+	days := s.intSetting(logRetentionDaysSetting, defaultLogRetentionDays)
+	if days > 0 {
+		cutoff := time.Now().AddDate(0, 0, -days).UnixNano()
+		if _, err := s.db.Exec("DELETE FROM log_entries WHERE ts < ?", cutoff); err != nil {
+			log.Printf("Log collector: age retention failed: %v", err)
+		}
+	}
+
+	limitMB := s.intSetting(logRetentionMBSetting, defaultLogRetentionMB)
+	if limitMB <= 0 {
+		return
+	}
+	var lines, size, minID, maxID int64
+	err := s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(length(message)), 0), COALESCE(MIN(id), 0), COALESCE(MAX(id), 0) FROM log_entries
+	`).Scan(&lines, &size, &minID, &maxID)
+	if err != nil || lines == 0 {
+		return
+	}
+	total := size + lines*logRowOverhead
+	limit := int64(limitMB) << 20
+	if total <= limit {
+		return
+	}
+	// Drop the oldest share of rows proportional to the excess, plus 10%
+	// headroom so retention does not run on every pass
+	excess := float64(total-limit)/float64(total) + 0.1
+	cutoff := minID + int64(float64(maxID-minID)*excess)
+	if _, err := s.db.Exec("DELETE FROM log_entries WHERE id <= ?", cutoff); err != nil {
+		log.Printf("Log collector: size retention failed: %v", err)
+	}
+}
+
+func (s *LogCollectorService) intSetting(key string, fallback int) int {
+	var value string
+	if err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value); err != nil {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+// Search and status
+
+// Search returns matching lines, newest first
+func (s *LogCollectorService) Search(q LogSearchQuery) ([]LogEntry, error) {
+	if q.Limit <= 0 || q.Limit > 5000 {
+		q.Limit = 500
+	}
+
+	query := "SELECT e.id, e.container_id, e.container, e.project, e.stream, e.ts, e.message FROM log_entries e"
+	var where []string
+	var args []interface{}
+
+	if q.Query != "" {
+		if s.fullText {
+			// Check the FTS5 syntax separately so bad queries are reported as such
+			var n int
+			if err := s.db.QueryRow("SELECT COUNT(*) FROM (SELECT 1 FROM log_entries_fts WHERE log_entries_fts MATCH ? LIMIT 1)", q.Query).Scan(&n); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidLogQuery, err)
+			}
+			query += " JOIN log_entries_fts f ON f.rowid = e.id"
+			where = append(where, "log_entries_fts MATCH ?")
+			args = append(args, q.Query)
+		} else {
+			where = append(where, "e.message LIKE ? ESCAPE '\\'")
+			args = append(args, "%"+escapeLike(q.Query)+"%")
+		}
+	}
+	if q.Container != "" {
+		where = append(where, "(e.container = ? OR e.container_id LIKE ?)")
+		args = append(args, q.Container, escapeLike(q.Container)+"%")
+	}
+	if q.Project != "" {
+		where = append(where, "e.project = ?")
+		args = append(args, q.Project)
+	}
+	if q.Stream != "" {
+		where = append(where, "e.stream = ?")
+		args = append(args, q.Stream)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "e.ts >= ?")
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "e.ts <= ?")
+		args = append(args, q.To.UnixNano())
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY e.ts DESC, e.id DESC LIMIT ?"
+	args = append(args, q.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LogEntry{}
+	for rows.Next() {
+		var e LogEntry
+		var ts int64
+		if err := rows.Scan(&e.ID, &e.ContainerID, &e.Container, &e.Project, &e.Stream, &ts, &e.Message); err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *LogCollectorService) Status() LogCollectorStatus {
+	status := LogCollectorStatus{Following: []string{}, FullText: s.fullText}
+
+	s.mu.Lock()
+	for id := range s.following {
+		status.Following = append(status.Following, id)
+	}
+	s.mu.Unlock()
+
+	var oldest int64
+	s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(length(message)), 0), COALESCE(MIN(ts), 0) FROM log_entries
+	`).Scan(&status.Lines, &status.Bytes, &oldest)
+	if oldest > 0 {
+		status.OldestTime = time.Unix(0, oldest).UTC().Format(time.RFC3339Nano)
+	}
+	return status
+}
+
+// Log line helpers
+
+// parseLogTimestamp splits the RFC3339Nano prefix Docker adds to log lines
+// when timestamps are requested
+func parseLogTimestamp(line string) (time.Time, string) {
+	prefix, rest, ok := strings.Cut(line, " ")
+	if ok {
+		if ts, err := time.Parse(time.RFC3339Nano, prefix); err == nil {
+			return ts, rest
+		}
+	}
+	return time.Now(), line
+}
+
+// logLineWriter splits a byte stream into lines for one output stream
+type logLineWriter struct {
+	stream string
+	emit   func(stream, line string)
+	buf    []byte
+}
+
+func newLogLineWriter(stream string, emit func(stream, line string)) *logLineWriter {
+	return &logLineWriter{stream: stream, emit: emit}
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.stream, strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	// A runaway line without newlines is emitted in pieces
+	if len(w.buf) > maxLogLine {
+		w.emit(w.stream, string(w.buf))
+		w.buf = w.buf[:0]
+	}
+	return len(p), nil
+}
+
+// Flush emits a trailing partial line
+func (w *logLineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emit(w.stream, string(w.buf))
+		w.buf = w.buf[:0]
+	}
+}
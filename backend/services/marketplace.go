@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"os"
+	"path/filepath"
 )
 
 type App struct {
@@ -52,18 +53,20 @@ type InstalledApp struct {
 
 type MarketplaceService struct {
 	db      *sql.DB
+	appsDir string
 	catalog AppCatalog
 }
 
-func NewMarketplaceService(db *sql.DB) *MarketplaceService {
+func NewMarketplaceService(db *sql.DB, appsDir string) *MarketplaceService {
 	return &MarketplaceService{
-		db: db,
+		db:      db,
+		appsDir: appsDir,
 	}
 }
 
 func (s *MarketplaceService) LoadApps() error {
 	// Read apps.json
-	data, err := os.ReadFile("./data/apps/apps.json")
+	data, err := os.ReadFile(filepath.Join(s.appsDir, "apps.json"))
 	if err != nil {
 		// Create default apps.json if it doesn't exist
 		if os.IsNotExist(err) {
@@ -153,6 +156,13 @@ func (s *MarketplaceService) GetInstalledApp(id int) (*InstalledApp, error) {
 	return &app, nil
 }
 
+// SetInstalledContainers replaces the containers recorded for an installed app
+func (s *MarketplaceService) SetInstalledContainers(id int, containerIDs []string) error {
+	idsJSON, _ := json.Marshal(containerIDs)
+	_, err := s.db.Exec("UPDATE installed_apps SET container_ids = ?, status = 'running' WHERE id = ?", string(idsJSON), id)
+	return err
+}
+
 func (s *MarketplaceService) UninstallApp(id int) error {
 	_, err := s.db.Exec("DELETE FROM installed_apps WHERE id = ?", id)
 	return err
@@ -353,11 +363,11 @@ func (s *MarketplaceService) createDefaultCatalog() error {
 		return err
 	}
 
-	if err := os.MkdirAll("./data/apps", 0755); err != nil {
+	if err := os.MkdirAll(s.appsDir, 0755); err != nil {
 		return err
 	}
 
-	if err := os.WriteFile("./data/apps/apps.json", data, 0644); err != nil {
+	if err := os.WriteFile(filepath.Join(s.appsDir, "apps.json"), data, 0644); err != nil {
 		return err
 	}
 
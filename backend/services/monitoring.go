@@ -50,16 +50,27 @@ type MonitoringService struct {
 	mutex       sync.RWMutex
 	stopChan    chan struct{}
 	interval    time.Duration
+	intervalCh  chan time.Duration
 	lastNetStat net.IOCountersStat
 }
 
-func NewMonitoringService() *MonitoringService {
+func NewMonitoringService(interval time.Duration) *MonitoringService {
 	return &MonitoringService{
-		interval: 5 * time.Second,
-		stopChan: make(chan struct{}),
+		interval:   interval,
+		intervalCh: make(chan time.Duration, 1),
+		stopChan:   make(chan struct{}),
 	}
 }
 
+// SetInterval changes how often metrics are sampled
+func (s *MonitoringService) SetInterval(interval time.Duration) {
+	select {
+	case <-s.intervalCh:
+	default:
+	}
+	s.intervalCh <- interval
+}
+
 func (s *MonitoringService) Start() {
 	go s.collectMetrics()
 }
@@ -85,6 +96,8 @@ func (s *MonitoringService) collectMetrics() {
 		select {
 		case <-ticker.C:
 			s.updateMetrics()
+		case interval := <-s.intervalCh:
+			ticker.Reset(interval)
 		case <-s.stopChan:
 			return
 		}
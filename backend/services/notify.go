@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// notificationWebhookSetting is the settings key holding the webhook URL that
+// receives notifications. When unset, notifications are only logged.
+const notificationWebhookSetting = "notification_webhook_url"
+
+// Notification is the JSON payload posted to the notification webhook
+type Notification struct {
+	Event   string    `json:"event"`
+	Level   string    `json:"level"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+const (
+	NotificationInfo  = "info"
+	NotificationError = "error"
+)
+
+// NotificationService delivers notifications about background work
+// (scheduled backups, updates, tasks) to a configurable webhook
+type NotificationService struct {
+	db     *sql.DB
+	client *http.Client
+}
+
+func NewNotificationService(db *sql.DB) *NotificationService {
+	return &NotificationService{
+		db:     db,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify logs the notification and posts it to the webhook, if configured.
+// Delivery happens in the background and failures are only logged.
+func (s *NotificationService) Notify(event, level, title, message string) {
+	n := Notification{
+		Event:   event,
+		Level:   level,
+		Title:   title,
+		Message: message,
+		Time:    time.Now().UTC(),
+	}
+	log.Printf("[%s] %s: %s", level, title, message)
+
+	var url string
+	if err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", notificationWebhookSetting).Scan(&url); err != nil || url == "" {
+		return
+	}
+
+	go func() {
+		if err := s.post(url, n); err != nil {
+			log.Printf("Failed to deliver notification %s: %v", event, err)
+		}
+	}()
+}
+
+func (s *NotificationService) post(url string, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,523 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	// Container labels that request a reverse-proxy route. Host is a full
+	// hostname or a single label placed under the proxy domain; path is a
+	// prefix on the proxy domain that is stripped before forwarding; port is
+	// the container port, needed when the image exposes more than one.
+	ProxyHostLabel = "com.sunspear.proxy.host"
+	ProxyPathLabel = "com.sunspear.proxy.path"
+	ProxyPortLabel = "com.sunspear.proxy.port"
+
+	// AppLabel records the marketplace app a container was installed from
+	AppLabel = "com.sunspear.app"
+
+	ProxyRouteActive   = "active"
+	ProxyRouteError    = "error"
+	ProxyRouteConflict = "conflict"
+	ProxyRoutePending  = "pending"
+
+	// proxyRoutePrefix tags the routes Sunspear manages in the Caddy config
+	proxyRoutePrefix       = "sunspear-proxy-"
+	proxyReconcileInterval = time.Minute
+)
+
+var (
+	proxyHostPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+	proxyPathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+	proxyIDUnsafe    = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+	// reservedProxyPaths are served by Sunspear itself and cannot be routed
+	reservedProxyPaths = []string{"/api", "/health"}
+)
+
+// ProxyRoute is a route requested by a container and its state in Caddy
+type ProxyRoute struct {
+	ID          string `json:"id"`
+	Container   string `json:"container"`
+	ContainerID string `json:"containerId"`
+	Project     string `json:"project,omitempty"`
+	App         string `json:"app,omitempty"`
+	Host        string `json:"host,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Upstream    string `json:"upstream,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ProxyStatus summarises reverse-proxy routing
+type ProxyStatus struct {
+	Enabled    bool         `json:"enabled"`
+	Domain     string       `json:"domain,omitempty"`
+	Server     string       `json:"server,omitempty"`
+	LastSyncAt string       `json:"lastSyncAt,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Routes     []ProxyRoute `json:"routes"`
+}
+
+// ValidateProxyHost checks a proxy host label value
+func ValidateProxyHost(host string) error {
+	if !proxyHostPattern.MatchString(host) || len(host) > 253 {
+		return fmt.Errorf("invalid proxy host %q", host)
+	}
+	return nil
+}
+
+// NormalizeProxyPath checks a proxy path label value and trims a trailing slash
+func NormalizeProxyPath(path string) (string, error) {
+	path = strings.TrimSuffix(path, "/")
+	if !proxyPathPattern.MatchString(path) {
+		return "", fmt.Errorf("invalid proxy path %q (use a prefix like /app)", path)
+	}
+	for _, reserved := range reservedProxyPaths {
+		if strings.EqualFold(path, reserved) || strings.HasPrefix(strings.ToLower(path), reserved+"/") {
+			return "", fmt.Errorf("proxy path %s is reserved for Sunspear", path)
+		}
+	}
+	return path, nil
+}
+
+// ProxyService publishes reverse-proxy routes for labelled containers to
+// Caddy through its admin API and removes them when the containers go away.
+// Routes are re-applied periodically so they survive Caddy reloading its
+// Caddyfile, and always come after the Caddyfile's own routes.
+type ProxyService struct {
+	dockerService *DockerService
+	eventService  *EventService
+	caddy         *CaddyClient
+	domain        string
+	network       string
+
+	mu     sync.Mutex
+	status ProxyStatus
+	// syncMu serialises reconciles so routes are not inserted twice
+	syncMu sync.Mutex
+
+	trigger  chan struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewProxyService creates the service. An empty adminURL disables routing.
+// Routed containers are connected to network, which Caddy shares with them
+// and nothing else of Sunspear's.
+func NewProxyService(dockerService *DockerService, eventService *EventService, adminURL, domain, network string) *ProxyService {
+	s := &ProxyService{
+		dockerService: dockerService,
+		eventService:  eventService,
+		domain:        strings.ToLower(domain),
+		network:       network,
+		trigger:       make(chan struct{}, 1),
+		stopChan:      make(chan struct{}),
+	}
+	if adminURL != "" {
+		s.caddy = NewCaddyClient(adminURL)
+	}
+	s.status = ProxyStatus{Enabled: s.caddy != nil, Domain: s.domain, Routes: []ProxyRoute{}}
+	return s
+}
+
+func (s *ProxyService) Start() {
+	if s.caddy == nil {
+		return
+	}
+	s.wg.Add(2)
+	go s.reconcileLoop()
+	go s.watchEvents()
+}
+
+func (s *ProxyService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+	s.wg.Wait()
+}
+
+// Sync reconciles immediately and returns the result
+func (s *ProxyService) Sync() ProxyStatus {
+	if s.caddy != nil {
+		s.reconcile()
+	}
+	return s.Status()
+}
+
+// Status returns the routes from the last reconcile
+func (s *ProxyService) Status() ProxyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Routes = append([]ProxyRoute{}, s.status.Routes...)
+	return status
+}
+
+// Notify schedules a reconcile soon, e.g. after an app was uninstalled
+func (s *ProxyService) Notify() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ProxyService) reconcileLoop() {
+	defer s.wg.Done()
+
+	s.reconcile()
+	ticker := time.NewTicker(proxyReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.reconcile()
+		case <-s.trigger:
+			// Let bursts of container events settle
+			select {
+			case <-time.After(2 * time.Second):
+			case <-s.stopChan:
+				return
+			}
+			s.reconcile()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// watchEvents reconciles when containers start, stop or change networks
+func (s *ProxyService) watchEvents() {
+	defer s.wg.Done()
+
+	for {
+		events, unsubscribe := s.eventService.Subscribe()
+		for open := true; open; {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					open = false
+					break
+				}
+				switch {
+				case e.Type == "container" && (e.Action == "start" || e.Action == "die" || e.Action == "destroy" || e.Action == "rename"):
+					s.Notify()
+				case e.Type == "network" && e.Action == "disconnect":
+					s.Notify()
+				}
+			case <-s.stopChan:
+				unsubscribe()
+				return
+			}
+		}
+		unsubscribe()
+		// Fell behind; catch up with a full reconcile
+		s.Notify()
+	}
+}
+
+// reconcile makes the Sunspear routes in Caddy match the running containers
+func (s *ProxyService) reconcile() {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	routes, err := s.desiredRoutes(ctx)
+	status := ProxyStatus{Enabled: true, Domain: s.domain, LastSyncAt: time.Now().UTC().Format(time.RFC3339)}
+	if err == nil {
+		status.Server, err = s.apply(ctx, routes)
+	}
+	if err != nil {
+		status.Error = err.Error()
+		log.Printf("Proxy routes: %v", err)
+		for i := range routes {
+			if routes[i].Status == ProxyRoutePending {
+				routes[i].Status, routes[i].Error = ProxyRouteError, err.Error()
+			}
+		}
+	}
+	if routes == nil {
+		routes = []ProxyRoute{}
+	}
+	status.Routes = routes
+
+	s.mu.Lock()
+	s.status = status
+	s.mu.Unlock()
+}
+
+// desiredRoutes reads the proxy labels of running containers
+func (s *ProxyService) desiredRoutes(ctx context.Context) ([]ProxyRoute, error) {
+	containers, err := s.dockerService.ListContainers(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []ProxyRoute
+	for _, c := range containers {
+		host, path := c.Labels[ProxyHostLabel], c.Labels[ProxyPathLabel]
+		if host == "" && path == "" {
+			continue
+		}
+		name := strings.TrimPrefix(firstName(c.Names), "/")
+		route := ProxyRoute{
+			ID:          proxyRoutePrefix + proxyIDUnsafe.ReplaceAllString(name, "_"),
+			Container:   name,
+			ContainerID: c.ID,
+			Project:     containerProject(c.Labels),
+			App:         c.Labels[AppLabel],
+			Status:      ProxyRoutePending,
+		}
+		if err := s.resolveRoute(&route, c, host, path); err != nil {
+			route.Status, route.Error = ProxyRouteError, err.Error()
+		}
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Container < routes[j].Container })
+
+	// The first container to claim a host and path keeps it
+	claimed := make(map[string]string)
+	for i := range routes {
+		if routes[i].Status != ProxyRoutePending {
+			continue
+		}
+		key := routes[i].Host + routes[i].Path
+		if owner, ok := claimed[key]; ok {
+			routes[i].Status = ProxyRouteConflict
+			routes[i].Error = fmt.Sprintf("%s%s is already routed to %s", routes[i].Host, routes[i].Path, owner)
+			continue
+		}
+		claimed[key] = routes[i].Container
+	}
+	return routes, nil
+}
+
+func (s *ProxyService) resolveRoute(route *ProxyRoute, c types.Container, host, path string) error {
+	if host != "" {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if err := ValidateProxyHost(host); err != nil {
+			return err
+		}
+		if !strings.Contains(host, ".") {
+			if s.domain == "" {
+				return fmt.Errorf("host %q needs a proxy domain (PROXY_DOMAIN)", host)
+			}
+			host += "." + s.domain
+		}
+	} else {
+		if s.domain == "" {
+			return fmt.Errorf("path %q needs a host or a proxy domain (PROXY_DOMAIN)", path)
+		}
+		host = s.domain
+	}
+	if path != "" {
+		var err error
+		if path, err = NormalizeProxyPath(path); err != nil {
+			return err
+		}
+	}
+	route.Host, route.Path = host, path
+
+	port, err := proxyPort(c)
+	if err != nil {
+		return err
+	}
+	route.Upstream = fmt.Sprintf("%s:%d", route.Container, port)
+	route.URL = "https://" + host + path
+	return nil
+}
+
+// proxyPort returns the labelled port or the container's only private port
+func proxyPort(c types.Container) (int, error) {
+	if value := c.Labels[ProxyPortLabel]; value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			return 0, fmt.Errorf("invalid proxy port %q", value)
+		}
+		return port, nil
+	}
+	ports := make(map[uint16]bool)
+	for _, p := range c.Ports {
+		if p.Type == "tcp" {
+			ports[p.PrivatePort] = true
+		}
+	}
+	if len(ports) != 1 {
+		return 0, fmt.Errorf("set %s: the container exposes %d TCP ports", ProxyPortLabel, len(ports))
+	}
+	for port := range ports {
+		return int(port), nil
+	}
+	return 0, nil
+}
+
+// apply pushes routes to Caddy, removing stale Sunspear routes, and returns
+// the server name used
+func (s *ProxyService) apply(ctx context.Context, routes []ProxyRoute) (string, error) {
+	server, err := s.caddy.Server()
+	if err != nil {
+		return "", err
+	}
+	existing, err := s.caddy.Routes(server)
+	if err != nil {
+		return server, err
+	}
+	// Sunspear routes must follow every other route; ones placed ahead of
+	// the Caddyfile's routes by older versions are moved to the end
+	current := make(map[string]map[string]interface{})
+	misplaced := make(map[string]bool)
+	siteHosts := make(map[string]bool)
+	followed := false
+	for i := len(existing) - 1; i >= 0; i-- {
+		r := existing[i]
+		if id, _ := r["@id"].(string); strings.HasPrefix(id, proxyRoutePrefix) {
+			current[id] = r
+			misplaced[id] = followed
+			continue
+		}
+		followed = true
+		for _, host := range caddyRouteHosts(r) {
+			siteHosts[host] = true
+		}
+	}
+
+	wanted := make(map[string]bool)
+	for i := range routes {
+		route := &routes[i]
+		if route.Status != ProxyRoutePending {
+			continue
+		}
+		if siteHosts[route.Host] {
+			route.Status = ProxyRouteConflict
+			route.Error = fmt.Sprintf("%s is served by the Caddyfile", route.Host)
+			continue
+		}
+		wanted[route.ID] = true
+
+		if err := s.connectToProxyNetwork(ctx, route.ContainerID); err != nil {
+			route.Status, route.Error = ProxyRouteError, err.Error()
+			continue
+		}
+
+		config := caddyRouteConfig(*route)
+		old, ok := current[route.ID]
+		switch {
+		case ok && misplaced[route.ID]:
+			if err = s.caddy.DeleteRoute(route.ID); err == nil {
+				err = s.caddy.AppendRoute(server, config)
+			}
+		case ok:
+			if !caddyRouteEqual(old, config) {
+				err = s.caddy.ReplaceRoute(route.ID, config)
+			}
+		default:
+			err = s.caddy.AppendRoute(server, config)
+		}
+		if err != nil {
+			route.Status, route.Error = ProxyRouteError, err.Error()
+			continue
+		}
+		route.Status = ProxyRouteActive
+	}
+
+	for id := range current {
+		if !wanted[id] {
+			if err := s.caddy.DeleteRoute(id); err != nil {
+				log.Printf("Proxy routes: failed to remove %s: %v", id, err)
+			}
+		}
+	}
+	return server, nil
+}
+
+// connectToProxyNetwork attaches a container to the proxy network so Caddy
+// can reach it by name. The network carries only Caddy and routed apps; the
+// backend and Caddy's admin API are not on it.
+func (s *ProxyService) connectToProxyNetwork(ctx context.Context, containerID string) error {
+	if s.network == "" {
+		return nil
+	}
+	info, err := s.dockerService.GetContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if info.NetworkSettings != nil {
+		if _, ok := info.NetworkSettings.Networks[s.network]; ok {
+			return nil
+		}
+	}
+	if err := s.dockerService.ConnectNetwork(ctx, s.network, containerID); err != nil {
+		return fmt.Errorf("failed to connect to proxy network %s: %w", s.network, err)
+	}
+	return nil
+}
+
+// caddyRouteHosts returns the hosts a route from Caddy's config matches
+func caddyRouteHosts(route map[string]interface{}) []string {
+	var hosts []string
+	matchers, _ := route["match"].([]interface{})
+	for _, m := range matchers {
+		matcher, _ := m.(map[string]interface{})
+		list, _ := matcher["host"].([]interface{})
+		for _, h := range list {
+			if host, ok := h.(string); ok {
+				hosts = append(hosts, strings.ToLower(host))
+			}
+		}
+	}
+	return hosts
+}
+
+// caddyRouteConfig builds the Caddy JSON route for a proxy route
+func caddyRouteConfig(route ProxyRoute) map[string]interface{} {
+	match := map[string]interface{}{}
+	if route.Host != "" {
+		match["host"] = []interface{}{route.Host}
+	}
+
+	proxy := map[string]interface{}{
+		"handler":   "reverse_proxy",
+		"upstreams": []interface{}{map[string]interface{}{"dial": route.Upstream}},
+	}
+	handlers := []interface{}{}
+	if route.Path != "" {
+		match["path"] = []interface{}{route.Path, route.Path + "/*"}
+		handlers = append(handlers, map[string]interface{}{
+			"handler":           "rewrite",
+			"strip_path_prefix": route.Path,
+		})
+		proxy["headers"] = map[string]interface{}{
+			"request": map[string]interface{}{
+				"set": map[string]interface{}{"X-Forwarded-Prefix": []interface{}{route.Path}},
+			},
+		}
+	}
+	handlers = append(handlers, proxy)
+
+	return map[string]interface{}{
+		"@id":      route.ID,
+		"match":    []interface{}{match},
+		"handle":   handlers,
+		"terminal": true,
+	}
+}
+
+// caddyRouteEqual compares a route read back from Caddy with a built one.
+// Built routes only use JSON-decoded types, so they compare directly.
+func caddyRouteEqual(current, wanted map[string]interface{}) bool {
+	return reflect.DeepEqual(current, wanted)
+}
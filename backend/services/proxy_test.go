@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// fakeCaddy serves the parts of the Caddy admin API ProxyService uses for a
+// single server, srv0, whose routes start with a Caddyfile site
+type fakeCaddy struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	routes []map[string]interface{}
+}
+
+const fakeCaddyRoutes = "/config/apps/http/servers/srv0/routes"
+
+func newFakeCaddy(t *testing.T) *fakeCaddy {
+	c := &fakeCaddy{routes: []map[string]interface{}{
+		{"match": []interface{}{map[string]interface{}{"host": []interface{}{"sunspear.example.com"}}}, "terminal": true},
+	}}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *fakeCaddy) serve(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	id, byID := strings.CutPrefix(r.URL.Path, "/id/")
+	switch {
+	case r.URL.Path == "/config/apps/http/servers" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"srv0": map[string]interface{}{"listen": []string{":443"}}})
+	case r.URL.Path == fakeCaddyRoutes && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(c.routes)
+	case r.URL.Path == fakeCaddyRoutes && r.Method == http.MethodPost:
+		c.routes = append(c.routes, body)
+	case byID:
+		i := c.index(id)
+		if i < 0 {
+			http.Error(w, `{"error":"unknown object ID '`+id+`'"}`, http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodPatch:
+			c.routes[i] = body
+		case http.MethodDelete:
+			c.routes = append(c.routes[:i], c.routes[i+1:]...)
+		}
+	default:
+		http.Error(w, `{"error":"unexpected request"}`, http.StatusBadRequest)
+	}
+}
+
+func (c *fakeCaddy) index(id string) int {
+	for i, route := range c.routes {
+		if route["@id"] == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ids lists the route IDs in order, with "site" for Caddyfile routes
+func (c *fakeCaddy) ids() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []string
+	for _, route := range c.routes {
+		id, _ := route["@id"].(string)
+		if id == "" {
+			id = "site"
+		}
+		ids = append(ids, strings.TrimPrefix(id, proxyRoutePrefix))
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestResolveRoute(t *testing.T) {
+	web := types.Container{Ports: []types.Port{{PrivatePort: 8080, Type: "tcp"}}}
+	multi := types.Container{Ports: []types.Port{{PrivatePort: 80, Type: "tcp"}, {PrivatePort: 443, Type: "tcp"}}}
+	labelled := types.Container{Labels: map[string]string{ProxyPortLabel: "3001"}, Ports: multi.Ports}
+
+	tests := []struct {
+		domain     string
+		c          types.Container
+		host, path string
+		want       string
+		err        string
+	}{
+		{"apps.example.com", web, "wiki", "", "https://wiki.apps.example.com -> app:8080", ""},
+		{"apps.example.com", web, "Wiki.Example.org", "", "https://wiki.example.org -> app:8080", ""},
+		{"apps.example.com", web, "", "/kuma/", "https://apps.example.com/kuma -> app:8080", ""},
+		{"apps.example.com", web, "kuma.example.org", "/status", "https://kuma.example.org/status -> app:8080", ""},
+		{"apps.example.com", web, "", "/apiary", "https://apps.example.com/apiary -> app:8080", ""},
+		{"apps.example.com", labelled, "kuma", "", "https://kuma.apps.example.com -> app:3001", ""},
+		{"", web, "wiki", "", "", "needs a proxy domain"},
+		{"", web, "", "/kuma", "", "needs a host or a proxy domain"},
+		{"apps.example.com", web, "", "/api", "", "reserved"},
+		{"apps.example.com", web, "", "/API/v1", "", "reserved"},
+		{"apps.example.com", web, "", "/health", "", "reserved"},
+		{"apps.example.com", web, "", "kuma", "", "invalid proxy path"},
+		{"apps.example.com", web, "-bad", "", "", "invalid proxy host"},
+		{"apps.example.com", multi, "wiki", "", "", "exposes 2 TCP ports"},
+		{"apps.example.com", types.Container{Labels: map[string]string{ProxyPortLabel: "99999"}}, "wiki", "", "", "invalid proxy port"},
+	}
+	for _, tt := range tests {
+		s := NewProxyService(nil, nil, "", tt.domain, "")
+		route := ProxyRoute{Container: "app"}
+		err := s.resolveRoute(&route, tt.c, tt.host, tt.path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("resolveRoute(%q, %q) err = %v, want %q", tt.host, tt.path, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveRoute(%q, %q): %v", tt.host, tt.path, err)
+			continue
+		}
+		if got := route.URL + " -> " + route.Upstream; got != tt.want {
+			t.Errorf("resolveRoute(%q, %q) = %s, want %s", tt.host, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCaddyRouteConfig(t *testing.T) {
+	config := caddyRouteConfig(ProxyRoute{ID: proxyRoutePrefix + "kuma", Host: "apps.example.com", Path: "/kuma", Upstream: "kuma:3001"})
+	data, _ := json.Marshal(config)
+	for _, want := range []string{
+		`"@id":"sunspear-proxy-kuma"`,
+		`"host":["apps.example.com"]`,
+		`"path":["/kuma","/kuma/*"]`,
+		`"strip_path_prefix":"/kuma"`,
+		`"dial":"kuma:3001"`,
+		`"X-Forwarded-Prefix":["/kuma"]`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("route config lacks %s: %s", want, data)
+		}
+	}
+}
+
+func TestProxyApplyAgainstCaddy(t *testing.T) {
+	caddy := newFakeCaddy(t)
+	s := NewProxyService(nil, nil, caddy.URL, "apps.example.com", "")
+	ctx := context.Background()
+
+	pending := func(name, host, upstream string) ProxyRoute {
+		return ProxyRoute{ID: proxyRoutePrefix + name, Container: name, Host: host, Upstream: upstream, Status: ProxyRoutePending}
+	}
+	routes := []ProxyRoute{
+		pending("kuma", "kuma.apps.example.com", "kuma:3001"),
+		pending("wiki", "wiki.apps.example.com", "wiki:80"),
+		pending("hijack", "sunspear.example.com", "hijack:80"),
+	}
+	server, err := s.apply(ctx, routes)
+	if err != nil || server != "srv0" {
+		t.Fatalf("apply = %q, %v", server, err)
+	}
+	if got := caddy.ids(); got != "site kuma wiki" {
+		t.Errorf("routes = %s, want Sunspear routes after the site", got)
+	}
+	if routes[0].Status != ProxyRouteActive || routes[1].Status != ProxyRouteActive {
+		t.Errorf("statuses = %s, %s", routes[0].Status, routes[1].Status)
+	}
+	if routes[2].Status != ProxyRouteConflict || !strings.Contains(routes[2].Error, "served by the Caddyfile") {
+		t.Errorf("route for the Caddyfile host = %s %q", routes[2].Status, routes[2].Error)
+	}
+
+	// A changed upstream is replaced in place and a removed container's
+	// route is deleted
+	routes = []ProxyRoute{pending("kuma", "kuma.apps.example.com", "kuma:3002")}
+	if _, err := s.apply(ctx, routes); err != nil {
+		t.Fatal(err)
+	}
+	if got := caddy.ids(); got != "site kuma" {
+		t.Errorf("routes = %s, want site kuma", got)
+	}
+	caddy.mu.Lock()
+	dial := caddy.routes[1]["handle"].([]interface{})[0].(map[string]interface{})["upstreams"].([]interface{})[0].(map[string]interface{})["dial"]
+	caddy.mu.Unlock()
+	if dial != "kuma:3002" {
+		t.Errorf("upstream = %v, want kuma:3002", dial)
+	}
+
+	// Routes placed ahead of the Caddyfile by older versions move to the end
+	caddy.mu.Lock()
+	caddy.routes = append([]map[string]interface{}{caddy.routes[1]}, caddy.routes[0])
+	caddy.mu.Unlock()
+	routes = []ProxyRoute{pending("kuma", "kuma.apps.example.com", "kuma:3002")}
+	if _, err := s.apply(ctx, routes); err != nil {
+		t.Fatal(err)
+	}
+	if got := caddy.ids(); got != "site kuma" || routes[0].Status != ProxyRouteActive {
+		t.Errorf("routes = %s (%s), want the legacy route moved after the site", got, routes[0].Status)
+	}
+}
+
+func TestCaddyClientOverUnixSocket(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, too short for t.TempDir
+	dir, err := os.MkdirTemp("", "caddy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "admin.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caddy := newFakeCaddy(t)
+	hosts := make(chan string, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+		caddy.serve(w, r)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	name, err := NewCaddyClient("unix://" + socket).Server()
+	if err != nil || name != "srv0" {
+		t.Fatalf("Server() = %q, %v", name, err)
+	}
+	if host := <-hosts; host != "127.0.0.1" {
+		t.Errorf("Host = %q, want 127.0.0.1", host)
+	}
+}
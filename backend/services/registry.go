@@ -0,0 +1,350 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// manifestAcceptTypes are requested so the registry returns the digest of the
+// multi-arch index when one exists, which is what the daemon records in RepoDigests
+var manifestAcceptTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// maxTagPages bounds tag listing for repositories with thousands of tags
+const maxTagPages = 10
+
+// RegistryCredentialFunc returns the credentials to use for a registry host, if any
+type RegistryCredentialFunc func(host string) (username, password string, ok bool)
+
+// RegistryClient queries Docker Registry HTTP API v2 endpoints (Docker Hub,
+// GHCR, self-hosted registries) for manifest digests and tags
+type RegistryClient struct {
+	client      *http.Client
+	insecure    []string
+	credentials RegistryCredentialFunc
+
+	mu     sync.Mutex
+	tokens map[string]registryToken // host|scope -> token
+}
+
+type registryToken struct {
+	value   string
+	expires time.Time
+}
+
+// NewRegistryClient creates a client. Registries in insecure (host or
+// host:port) and loopback registries are contacted over plain HTTP.
+func NewRegistryClient(insecure []string) *RegistryClient {
+	return &RegistryClient{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		insecure: insecure,
+		tokens:   make(map[string]registryToken),
+	}
+}
+
+// SetCredentials installs the lookup used to authenticate to private registries
+func (c *RegistryClient) SetCredentials(fn RegistryCredentialFunc) {
+	c.mu.Lock()
+	c.credentials = fn
+	c.mu.Unlock()
+}
+
+// ImageRef is a parsed image reference
+type ImageRef struct {
+	Name       string // familiar name, e.g. nginx or ghcr.io/owner/app
+	Host       string // registry host, e.g. docker.io
+	Repository string // path within the registry, e.g. library/nginx
+	Tag        string
+}
+
+func (r ImageRef) String() string {
+	return r.Name + ":" + r.Tag
+}
+
+// ParseImageRef parses a tagged image reference, defaulting the tag to
+// "latest". Digest-pinned references are rejected because they cannot change.
+func ParseImageRef(image string) (ImageRef, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ImageRef{}, err
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return ImageRef{}, fmt.Errorf("%s is pinned to a digest", image)
+	}
+	named = reference.TagNameOnly(named)
+	tagged, _ := named.(reference.Tagged)
+	return ImageRef{
+		Name:       reference.FamiliarName(named),
+		Host:       reference.Domain(named),
+		Repository: reference.Path(named),
+		Tag:        tagged.Tag(),
+	}, nil
+}
+
+// ManifestDigest returns the current digest of a tag
+func (c *RegistryClient) ManifestDigest(ref ImageRef) (string, error) {
+	endpoint := c.baseURL(ref.Host) + "/v2/" + ref.Repository + "/manifests/" + url.PathEscape(ref.Tag)
+	resp, err := c.do(http.MethodHead, endpoint, ref, manifestAcceptTypes)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("manifest request for %s returned %s", ref, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("registry did not return a digest for %s", ref)
+	}
+	return digest, nil
+}
+
+// ListTags returns the tags of a repository, following pagination
+func (c *RegistryClient) ListTags(ref ImageRef) ([]string, error) {
+	base := c.baseURL(ref.Host)
+	endpoint := base + "/v2/" + ref.Repository + "/tags/list?n=1000"
+
+	var tags []string
+	for page := 0; page < maxTagPages && endpoint != ""; page++ {
+		resp, err := c.do(http.MethodGet, endpoint, ref, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("tag list for %s returned %s", ref.Repository, resp.Status)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&body)
+		}
+		link := resp.Header.Get("Link")
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, body.Tags...)
+		endpoint = nextPageURL(base, link)
+	}
+	return tags, nil
+}
+
+// do sends a request, answering a Bearer or Basic auth challenge once
+func (c *RegistryClient) do(method, endpoint string, ref ImageRef, accept []string) (*http.Response, error) {
+	scope := "repository:" + ref.Repository + ":pull"
+
+	send := func(auth string) (*http.Response, error) {
+		req, err := http.NewRequest(method, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accept {
+			req.Header.Add("Accept", a)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		return c.client.Do(req)
+	}
+
+	if token := c.cachedToken(ref.Host, scope); token != "" {
+		resp, err := send("Bearer " + token)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := send("")
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	scheme, params := parseAuthChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		token, err := c.fetchToken(ref.Host, params, scope)
+		if err != nil {
+			return nil, err
+		}
+		return send("Bearer " + token)
+	case "basic":
+		username, password, ok := c.lookupCredentials(ref.Host)
+		if !ok {
+			return nil, fmt.Errorf("registry %s requires credentials", ref.Host)
+		}
+		return send("Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	}
+	return nil, fmt.Errorf("registry %s returned an unsupported auth challenge %q", ref.Host, challenge)
+}
+
+func (c *RegistryClient) fetchToken(host string, params map[string]string, scope string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry %s auth challenge has no realm", host)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	} else {
+		query.Set("scope", scope)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username, password, ok := c.lookupCredentials(host); ok {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request to %s returned %s", realm, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("token response from %s has no token", realm)
+	}
+
+	// The spec's default lifetime is 60 seconds
+	lifetime := time.Duration(body.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = 60 * time.Second
+	}
+	c.mu.Lock()
+	c.tokens[host+"|"+scope] = registryToken{value: token, expires: time.Now().Add(lifetime - 5*time.Second)}
+	c.mu.Unlock()
+	return token, nil
+}
+
+func (c *RegistryClient) cachedToken(host, scope string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	token, ok := c.tokens[host+"|"+scope]
+	if !ok || time.Now().After(token.expires) {
+		return ""
+	}
+	return token.value
+}
+
+func (c *RegistryClient) lookupCredentials(host string) (string, string, bool) {
+	c.mu.Lock()
+	fn := c.credentials
+	c.mu.Unlock()
+	if fn == nil {
+		return "", "", false
+	}
+	return fn(host)
+}
+
+// baseURL maps a reference host to the registry API endpoint
+func (c *RegistryClient) baseURL(host string) string {
+	if host == "docker.io" {
+		return "https://registry-1.docker.io"
+	}
+	if c.isInsecure(host) {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+func (c *RegistryClient) isInsecure(host string) bool {
+	for _, h := range c.insecure {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	if hostname == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(hostname)
+	return ip != nil && ip.IsLoopback()
+}
+
+// parseAuthChallenge splits a WWW-Authenticate header such as
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`
+func parseAuthChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(after, `"`) {
+			end := strings.Index(after[1:], `"`)
+			if end < 0 {
+				value, rest = after[1:], ""
+			} else {
+				value, rest = after[1:end+1], after[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(after, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return scheme, params
+}
+
+// nextPageURL extracts the next page from a `Link: </v2/...>; rel="next"` header
+func nextPageURL(base, link string) string {
+	if link == "" || !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start < 0 || end <= start {
+		return ""
+	}
+	next := link[start+1 : end]
+	if strings.HasPrefix(next, "/") {
+		return base + next
+	}
+	return next
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sunspear/config"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/errdefs"
+)
+
+const (
+	dockerHubRegistry = "docker.io"
+	// dockerHubServerAddress is the key the daemon uses for Docker Hub auth
+	dockerHubServerAddress = "https://index.docker.io/v1/"
+)
+
+// RegistryCredential is a login for a private registry. Passwords are stored
+// encrypted and returned redacted.
+type RegistryCredential struct {
+	ID          int     `json:"id"`
+	Registry    string  `json:"registry"` // host[:port], docker.io for Docker Hub
+	Username    string  `json:"username"`
+	Password    string  `json:"password,omitempty"`
+	LastLoginAt *string `json:"lastLoginAt"`
+	CreatedAt   string  `json:"createdAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+}
+
+// RegistryCredentialService stores registry logins and supplies them to
+// image pulls, builds and registry queries
+type RegistryCredentialService struct {
+	db            *sql.DB
+	dockerService *DockerService
+	secrets       *SecretBox
+}
+
+func NewRegistryCredentialService(db *sql.DB, dockerService *DockerService, secrets *SecretBox) *RegistryCredentialService {
+	return &RegistryCredentialService{db: db, dockerService: dockerService, secrets: secrets}
+}
+
+// NormalizeRegistryHost reduces a registry address ("https://ghcr.io/",
+// "index.docker.io/v1/") to the host used in image references
+func NormalizeRegistryHost(address string) string {
+	host := strings.ToLower(strings.TrimSpace(address))
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	host, _, _ = strings.Cut(host, "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return dockerHubRegistry
+	}
+	return host
+}
+
+// registryHostForImage returns the registry host of an image reference
+func registryHostForImage(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+	return reference.Domain(named)
+}
+
+func registryServerAddress(host string) string {
+	if host == dockerHubRegistry {
+		return dockerHubServerAddress
+	}
+	return host
+}
+
+func (s *RegistryCredentialService) List() ([]RegistryCredential, error) {
+	rows, err := s.db.Query(`
+		SELECT id, registry, username, last_login_at, created_at, updated_at
+		FROM registry_credentials ORDER BY registry
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creds := []RegistryCredential{}
+	for rows.Next() {
+		c, err := scanRegistryCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, *c)
+	}
+	return creds, rows.Err()
+}
+
+func (s *RegistryCredentialService) Get(id int) (*RegistryCredential, error) {
+	row := s.db.QueryRow(`
+		SELECT id, registry, username, last_login_at, created_at, updated_at
+		FROM registry_credentials WHERE id = ?
+	`, id)
+	return scanRegistryCredential(row)
+}
+
+func (s *RegistryCredentialService) Create(c RegistryCredential) (*RegistryCredential, error) {
+	c.Registry = NormalizeRegistryHost(c.Registry)
+	if c.Registry == "" || c.Username == "" || c.Password == "" || c.Password == redactedValue {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("registry, username and password are required"))
+	}
+
+	encrypted, err := s.secrets.Encrypt(c.Password)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.Exec(
+		"INSERT INTO registry_credentials (registry, username, password) VALUES (?, ?, ?)",
+		c.Registry, c.Username, encrypted,
+	)
+	if err != nil {
+		if config.IsUniqueViolation(err) {
+			return nil, errdefs.Conflict(fmt.Errorf("credentials for %s already exist", c.Registry))
+		}
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return s.Get(int(id))
+}
+
+// Update replaces a credential. A redacted or empty password keeps the stored one.
+func (s *RegistryCredentialService) Update(id int, c RegistryCredential) (*RegistryCredential, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	c.Registry = NormalizeRegistryHost(c.Registry)
+	if c.Registry == "" || c.Username == "" {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("registry and username are required"))
+	}
+
+	if c.Password == "" || c.Password == redactedValue {
+		_, err := s.db.Exec(`
+			UPDATE registry_credentials SET registry = ?, username = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+		`, c.Registry, c.Username, id)
+		if err != nil {
+			return nil, err
+		}
+		return s.Get(id)
+	}
+
+	encrypted, err := s.secrets.Encrypt(c.Password)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.Exec(`
+		UPDATE registry_credentials SET registry = ?, username = ?, password = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, c.Registry, c.Username, encrypted, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+func (s *RegistryCredentialService) Delete(id int) error {
+	result, err := s.db.Exec("DELETE FROM registry_credentials WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Test logs in to the registry through the daemon and records the time of
+// a successful login
+func (s *RegistryCredentialService) Test(ctx context.Context, id int) (string, error) {
+	var host, username, encrypted string
+	err := s.db.QueryRow("SELECT registry, username, password FROM registry_credentials WHERE id = ?", id).
+		Scan(&host, &username, &encrypted)
+	if err != nil {
+		return "", err
+	}
+	password, err := s.secrets.Decrypt(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.dockerService.RegistryLogin(ctx, registry.AuthConfig{
+		Username:      username,
+		Password:      password,
+		ServerAddress: registryServerAddress(host),
+	})
+	if err != nil {
+		return "", err
+	}
+	s.db.Exec("UPDATE registry_credentials SET last_login_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+	return resp.Status, nil
+}
+
+// Lookup returns the stored login for a registry host. It satisfies
+// RegistryCredentialFunc.
+func (s *RegistryCredentialService) Lookup(host string) (string, string, bool) {
+	var username, encrypted string
+	err := s.db.QueryRow("SELECT username, password FROM registry_credentials WHERE registry = ?", NormalizeRegistryHost(host)).
+		Scan(&username, &encrypted)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to look up credentials for %s: %v", host, err)
+		}
+		return "", "", false
+	}
+	password, err := s.secrets.Decrypt(encrypted)
+	if err != nil {
+		log.Printf("Failed to decrypt credentials for %s: %v", host, err)
+		return "", "", false
+	}
+	return username, password, true
+}
+
+// RegistryAuth returns the encoded X-Registry-Auth header for pulling an
+// image, or "" when no credentials match its registry
+func (s *RegistryCredentialService) RegistryAuth(image string) string {
+	host := registryHostForImage(image)
+	if host == "" {
+		return ""
+	}
+	username, password, ok := s.Lookup(host)
+	if !ok {
+		return ""
+	}
+	encoded, err := registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      username,
+		Password:      password,
+		ServerAddress: registryServerAddress(host),
+	})
+	if err != nil {
+		return ""
+	}
+	return encoded
+}
+
+// AuthConfigs returns every stored login keyed by server address, as the
+// daemon expects for builds whose base images may come from any registry
+func (s *RegistryCredentialService) AuthConfigs() map[string]registry.AuthConfig {
+	configs := make(map[string]registry.AuthConfig)
+	rows, err := s.db.Query("SELECT registry, username, password FROM registry_credentials")
+	if err != nil {
+		log.Printf("Failed to load registry credentials: %v", err)
+		return configs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var host, username, encrypted string
+		if err := rows.Scan(&host, &username, &encrypted); err != nil {
+			continue
+		}
+		password, err := s.secrets.Decrypt(encrypted)
+		if err != nil {
+			log.Printf("Failed to decrypt credentials for %s: %v", host, err)
+			continue
+		}
+		address := registryServerAddress(host)
+		configs[address] = registry.AuthConfig{Username: username, Password: password, ServerAddress: address}
+	}
+	return configs
+}
+
+func scanRegistryCredential(row rowScanner) (*RegistryCredential, error) {
+	var c RegistryCredential
+	var lastLogin sql.NullString
+	if err := row.Scan(&c.ID, &c.Registry, &c.Username, &lastLogin, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if lastLogin.Valid {
+		c.LastLoginAt = &lastLogin.String
+	}
+	c.Password = redactedValue
+	return &c, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	digestV1 = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	digestV2 = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// fakeRegistry serves one repository behind Bearer token auth, as Docker
+// Hub and GHCR do
+type fakeRegistry struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	digest        string
+	tags          []string
+	tokenRequests int
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	r := &fakeRegistry{digest: digestV1}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.tokenRequests++
+		r.mu.Unlock()
+		if user, pass, ok := req.BasicAuth(); !ok || user != "alice" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Query().Get("scope") != "repository:team/app:pull" || req.URL.Query().Get("service") != "fake" {
+			http.Error(w, "bad scope", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"token": "tok-1", "expires_in": 300})
+	})
+
+	mux.HandleFunc("/v2/team/app/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer tok-1" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="repository:team/app:pull"`, r.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		switch {
+		case req.URL.Path == "/v2/team/app/manifests/1.2" && req.Method == http.MethodHead:
+			if !strings.Contains(strings.Join(req.Header.Values("Accept"), ","), "application/vnd.oci.image.index.v1+json") {
+				http.Error(w, "index not accepted", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", r.digest)
+		case req.URL.Path == "/v2/team/app/tags/list":
+			// Two tags per page, linked with a relative next URL
+			page := r.tags
+			last := req.URL.Query().Get("last")
+			for i, tag := range r.tags {
+				if tag == last {
+					page = r.tags[i+1:]
+				}
+			}
+			if len(page) > 2 {
+				w.Header().Set("Link", fmt.Sprintf(`</v2/team/app/tags/list?n=2&last=%s>; rel="next"`, page[1]))
+				page = page[:2]
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": "team/app", "tags": page})
+		default:
+			http.NotFound(w, req)
+		}
+	})
+
+	r.Server = httptest.NewServer(mux)
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *fakeRegistry) ref(t *testing.T) ImageRef {
+	ref, err := ParseImageRef(strings.TrimPrefix(r.URL, "http://") + "/team/app:1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func newTestRegistryClient() *RegistryClient {
+	c := NewRegistryClient(nil)
+	c.SetCredentials(func(host string) (string, string, bool) {
+		return "alice", "s3cret", true
+	})
+	return c
+}
+
+func TestManifestDigestTokenFlow(t *testing.T) {
+	registry := newFakeRegistry(t)
+	client := newTestRegistryClient()
+	ref := registry.ref(t)
+
+	digest, err := client.ManifestDigest(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != digestV1 {
+		t.Errorf("digest = %s, want %s", digest, digestV1)
+	}
+
+	// The token is cached for later requests
+	if _, err := client.ManifestDigest(ref); err != nil {
+		t.Fatal(err)
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.tokenRequests != 1 {
+		t.Errorf("token requested %d times, want 1", registry.tokenRequests)
+	}
+}
+
+func TestManifestDigestWithoutCredentials(t *testing.T) {
+	registry := newFakeRegistry(t)
+	client := NewRegistryClient(nil)
+
+	_, err := client.ManifestDigest(registry.ref(t))
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want a 401 from the token endpoint", err)
+	}
+}
+
+func TestManifestDigestChange(t *testing.T) {
+	registry := newFakeRegistry(t)
+	client := newTestRegistryClient()
+	ref := registry.ref(t)
+
+	local := repoDigestFor([]string{
+		"nginx@" + digestV2,
+		ref.Name + "@" + digestV1,
+	}, ref)
+	if local != digestV1 {
+		t.Fatalf("repoDigestFor = %q, want %s", local, digestV1)
+	}
+
+	remote, err := client.ManifestDigest(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote != local {
+		t.Errorf("unchanged tag reported digest %s, want %s", remote, local)
+	}
+
+	// A new image is pushed to the same tag
+	registry.mu.Lock()
+	registry.digest = digestV2
+	registry.mu.Unlock()
+
+	remote, err = client.ManifestDigest(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote != digestV2 || remote == local {
+		t.Errorf("digest after push = %s, want %s", remote, digestV2)
+	}
+}
+
+func TestListTagsFollowsPagination(t *testing.T) {
+	registry := newFakeRegistry(t)
+	registry.tags = []string{"1.0", "1.2", "1.10", "1.9", "latest"}
+	client := newTestRegistryClient()
+
+	tags, err := client.ListTags(registry.ref(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tags, " "); got != "1.0 1.2 1.10 1.9 latest" {
+		t.Errorf("tags = %s", got)
+	}
+
+	current, _ := parseVersionTag("1.2")
+	if newest := newestVersionTag(current, tags); newest != "1.10" {
+		t.Errorf("newest tag = %q, want 1.10", newest)
+	}
+}
+
+func TestNewestVersionTag(t *testing.T) {
+	tests := []struct {
+		current string
+		tags    []string
+		want    string
+	}{
+		{"1.2", []string{"1.1", "1.2"}, ""},
+		{"1.2", []string{"1.3", "1.10", "1.9"}, "1.10"},
+		{"1.2.3", []string{"1.2.4", "1.3", "2", "1.2.10"}, "1.2.10"},
+		{"v2.4.1", []string{"2.5.0", "v2.4.2", "v3.0.0"}, "v3.0.0"},
+		{"16.2-alpine", []string{"16.3", "17.0-alpine", "16.4-bookworm", "latest"}, "17.0-alpine"},
+		{"1.25", []string{"1.26-rc1", "1.26.0", "nightly"}, ""},
+		{"2", []string{"10", "9", "2.1"}, "10"},
+	}
+	for _, tt := range tests {
+		current, ok := parseVersionTag(tt.current)
+		if !ok {
+			t.Fatalf("parseVersionTag(%q) failed", tt.current)
+		}
+		if got := newestVersionTag(current, tt.tags); got != tt.want {
+			t.Errorf("newestVersionTag(%s, %v) = %q, want %q", tt.current, tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionTagRejectsNonVersions(t *testing.T) {
+	for _, tag := range []string{"latest", "stable", "alpine", "sha-abc123", "1.x"} {
+		if _, ok := parseVersionTag(tag); ok {
+			t.Errorf("parseVersionTag(%q) succeeded", tag)
+		}
+	}
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	if scheme != "Bearer" || params["realm"] != "https://auth.docker.io/token" ||
+		params["service"] != "registry.docker.io" || params["scope"] != "repository:library/nginx:pull" {
+		t.Errorf("parseAuthChallenge = %s %v", scheme, params)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const secretBoxPrefix = "v1:"
+
+// SecretBox encrypts secrets stored in the database with AES-256-GCM using a
+// key derived from the configured secret
+type SecretBox struct {
+	aead        cipher.AEAD
+	fingerprint string
+}
+
+func NewSecretBox(secret string) (*SecretBox, error) {
+	if secret == "" {
+		return nil, errors.New("encryption secret is empty")
+	}
+	key := sha256.Sum256([]byte("sunspear-secret-box:" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	check := sha256.Sum256(append([]byte("sunspear-secret-box-fingerprint:"), key[:]...))
+	return &SecretBox{aead: aead, fingerprint: fmt.Sprintf("sha256:%x", check[:8])}, nil
+}
+
+// Fingerprint identifies the key without revealing it, so archives can tell
+// whether the secrets they carry are readable with the current key
+func (b *SecretBox) Fingerprint() string {
+	return b.fingerprint
+}
+
+// Encrypt returns a versioned, base64-encoded ciphertext
+func (b *SecretBox) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretBoxPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *SecretBox) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, secretBoxPrefix)
+	if !ok {
+		return "", errors.New("unsupported secret format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < b.aead.NonceSize() {
+		return "", errors.New("secret is truncated")
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret (was the encryption key changed?): %w", err)
+	}
+	return string(plaintext), nil
+}
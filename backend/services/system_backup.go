@@ -0,0 +1,719 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sunspear/config"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mattn/go-sqlite3"
+)
+
+// systemBackupFormat is the archive layout version recorded in manifests
+const systemBackupFormat = 1
+
+// Archive entries besides the apps/ directory
+const (
+	systemBackupManifest = "manifest.json"
+	systemBackupDatabase = "sunspear.db"
+	systemBackupAppSpecs = "installed-apps.json"
+)
+
+// ErrSystemBackupInProgress is returned while another backup or restore runs
+var ErrSystemBackupInProgress = errors.New("a Sunspear backup or restore is already running")
+
+// ErrInvalidSystemBackup wraps every reason an archive is rejected
+var ErrInvalidSystemBackup = errors.New("invalid Sunspear backup")
+
+// ErrSecretsKeyMismatch is returned when an archive's credentials were
+// encrypted under a different SECRETS_KEY than the one configured
+var ErrSecretsKeyMismatch = errors.New("the backup was made with a different SECRETS_KEY; configure the key of the original host, or accept the mismatch and re-enter stored credentials")
+
+// SystemBackupManifest describes a Sunspear self-backup archive
+type SystemBackupManifest struct {
+	Format        int       `json:"format"`
+	Version       string    `json:"version"`
+	SchemaVersion int       `json:"schemaVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// SecretsKeyFingerprint identifies the SECRETS_KEY that encrypted the
+	// credentials in the database; restoring needs the same key
+	SecretsKeyFingerprint string             `json:"secretsKeyFingerprint,omitempty"`
+	Files                 []SystemBackupFile `json:"files"`
+}
+
+// SystemBackupFile is one archived file and its checksum
+type SystemBackupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// AppContainerSpec is the configuration of an installed app's container at
+// backup time, kept so the app can be recreated on another host
+type AppContainerSpec struct {
+	InstalledAppID int                   `json:"installedAppId"`
+	Name           string                `json:"name"`
+	Config         *container.Config     `json:"config"`
+	HostConfig     *container.HostConfig `json:"hostConfig"`
+	Networks       []string              `json:"networks"`
+}
+
+// SystemBackupArchive is a finished archive on disk; Close deletes it
+type SystemBackupArchive struct {
+	Name     string
+	Path     string
+	Manifest SystemBackupManifest
+	dir      string
+}
+
+func (a *SystemBackupArchive) Close() error {
+	return os.RemoveAll(a.dir)
+}
+
+// RestoreOptions controls what a restore does besides replacing state
+type RestoreOptions struct {
+	// Redeploy recreates every compose project and installed app
+	Redeploy bool
+	// AcceptKeyMismatch restores an archive made with another SECRETS_KEY.
+	// Stored credentials then fail to decrypt until they are entered again.
+	AcceptKeyMismatch bool
+}
+
+// RedeployResult reports the redeployment of one project or app
+type RedeployResult struct {
+	Kind   string `json:"kind"`
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RestoreResult describes a completed restore
+type RestoreResult struct {
+	Manifest         SystemBackupManifest `json:"manifest"`
+	SchemaVersion    int                  `json:"schemaVersion"`
+	PreRestoreBackup string               `json:"preRestoreBackup"`
+	Redeployed       []RedeployResult     `json:"redeployed"`
+	Warnings         []string             `json:"warnings,omitempty"`
+	// Restarting is set when the backend exits after an API restore so its
+	// schedulers and caches start over from the restored state
+	Restarting bool `json:"restarting"`
+}
+
+// SystemBackupService archives Sunspear's own state (database, app catalog
+// and compose templates) and restores it for disaster recovery
+type SystemBackupService struct {
+	db          *sql.DB
+	dbPath      string
+	appsDir     string
+	docker      *DockerService
+	compose     *ComposeService
+	marketplace *MarketplaceService
+	secrets     *SecretBox
+	mu          sync.Mutex
+	restart     func()
+}
+
+func NewSystemBackupService(db *sql.DB, dbPath, appsDir string, docker *DockerService, compose *ComposeService, marketplace *MarketplaceService, secrets *SecretBox) *SystemBackupService {
+	return &SystemBackupService{
+		db:          db,
+		dbPath:      dbPath,
+		appsDir:     appsDir,
+		docker:      docker,
+		compose:     compose,
+		marketplace: marketplace,
+		secrets:     secrets,
+	}
+}
+
+// SetRestartHook registers how the running backend restarts itself. Every
+// service has read the database by the time the API is up, so a restore
+// through the API is only complete once the process starts over.
+func (s *SystemBackupService) SetRestartHook(restart func()) {
+	s.restart = restart
+}
+
+// Restart calls the restart hook, reporting false if none is registered
+func (s *SystemBackupService) Restart() bool {
+	if s.restart == nil {
+		return false
+	}
+	s.restart()
+	return true
+}
+
+// archiveEntry is a file to archive, read from src or held in data
+type archiveEntry struct {
+	name string
+	src  string
+	data []byte
+}
+
+// Backup writes a gzipped tar archive holding a manifest, an online copy of
+// the database, the apps directory and the container specs of installed apps
+func (s *SystemBackupService) Backup(ctx context.Context) (*SystemBackupArchive, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrSystemBackupInProgress
+	}
+	defer s.mu.Unlock()
+
+	dir, err := os.MkdirTemp(filepath.Dir(s.dbPath), ".sunspear-backup-")
+	if err != nil {
+		return nil, err
+	}
+	archive := &SystemBackupArchive{dir: dir}
+	fail := func(err error) (*SystemBackupArchive, error) {
+		archive.Close()
+		return nil, err
+	}
+
+	schemaVersion, err := config.SchemaVersion(s.db)
+	if err != nil {
+		return fail(err)
+	}
+	snapshot := filepath.Join(dir, systemBackupDatabase)
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", snapshot); err != nil {
+		return fail(fmt.Errorf("failed to copy database: %w", err))
+	}
+	entries := []archiveEntry{{name: systemBackupDatabase, src: snapshot}}
+
+	err = filepath.WalkDir(s.appsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(s.appsDir, p)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, archiveEntry{name: path.Join("apps", filepath.ToSlash(rel)), src: p})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fail(fmt.Errorf("failed to read apps directory: %w", err))
+	}
+
+	specs, err := json.MarshalIndent(s.appSpecs(ctx), "", "  ")
+	if err != nil {
+		return fail(err)
+	}
+	entries = append(entries, archiveEntry{name: systemBackupAppSpecs, data: specs})
+
+	now := time.Now().UTC()
+	archive.Manifest = SystemBackupManifest{
+		Format:        systemBackupFormat,
+		Version:       config.Version,
+		SchemaVersion: schemaVersion,
+		CreatedAt:     now,
+
+		SecretsKeyFingerprint: s.secrets.Fingerprint(),
+	}
+	for _, e := range entries {
+		f, err := e.checksum()
+		if err != nil {
+			return fail(err)
+		}
+		archive.Manifest.Files = append(archive.Manifest.Files, f)
+	}
+
+	archive.Name = fmt.Sprintf("sunspear-backup-%s.tar.gz", now.Format("20060102-150405"))
+	archive.Path = filepath.Join(dir, archive.Name)
+	if err := writeSystemArchive(archive.Path, archive.Manifest, entries); err != nil {
+		return fail(err)
+	}
+	return archive, nil
+}
+
+// appSpecs inspects the containers of installed apps. Apps whose container
+// is gone are left out and cannot be redeployed from this backup.
+func (s *SystemBackupService) appSpecs(ctx context.Context) []AppContainerSpec {
+	specs := []AppContainerSpec{}
+	apps, err := s.marketplace.GetInstalledApps()
+	if err != nil {
+		log.Printf("Failed to list installed apps for backup: %v", err)
+		return specs
+	}
+	for _, app := range apps {
+		var ids []string
+		json.Unmarshal([]byte(app.ContainerIDs), &ids)
+		for _, id := range ids {
+			inspect, err := s.docker.GetContainer(ctx, id)
+			if err != nil || inspect.Config == nil || inspect.HostConfig == nil {
+				log.Printf("Backup skips container %s of app %s: not found", id, app.AppName)
+				continue
+			}
+			cfg := *inspect.Config
+			// Docker defaults the hostname to the short container ID
+			if len(inspect.ID) >= 12 && cfg.Hostname == inspect.ID[:12] {
+				cfg.Hostname = ""
+			}
+			cfg.MacAddress = ""
+			spec := AppContainerSpec{
+				InstalledAppID: app.ID,
+				Name:           strings.TrimPrefix(inspect.Name, "/"),
+				Config:         &cfg,
+				HostConfig:     inspect.HostConfig,
+				Networks:       []string{},
+			}
+			if inspect.NetworkSettings != nil {
+				for name := range inspect.NetworkSettings.Networks {
+					spec.Networks = append(spec.Networks, name)
+				}
+			}
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+func (e archiveEntry) open() (io.ReadCloser, error) {
+	if e.src == "" {
+		return io.NopCloser(bytes.NewReader(e.data)), nil
+	}
+	return os.Open(e.src)
+}
+
+func (e archiveEntry) checksum() (SystemBackupFile, error) {
+	r, err := e.open()
+	if err != nil {
+		return SystemBackupFile{}, err
+	}
+	defer r.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return SystemBackupFile{}, err
+	}
+	return SystemBackupFile{Path: e.name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// writeSystemArchive writes the manifest first so it can be read without
+// unpacking the database
+func writeSystemArchive(dest string, manifest SystemBackupManifest, entries []archiveEntry) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	entries = append([]archiveEntry{{name: systemBackupManifest, data: manifestJSON}}, entries...)
+
+	for _, e := range entries {
+		r, err := e.open()
+		if err != nil {
+			return err
+		}
+		size := int64(len(e.data))
+		if e.src != "" {
+			info, err := os.Stat(e.src)
+			if err != nil {
+				r.Close()
+				return err
+			}
+			size = info.Size()
+		}
+		err = tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: size, ModTime: manifest.CreatedAt, Typeflag: tar.TypeReg})
+		if err == nil {
+			_, err = io.Copy(tw, r)
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// Restore replaces the database and apps directory with the contents of an
+// archive written by Backup. The archive is unpacked and verified against
+// its manifest before anything is changed, the current database is saved
+// next to it, and the restored schema is migrated to this build's version.
+func (s *SystemBackupService) Restore(ctx context.Context, r io.Reader, opts RestoreOptions) (*RestoreResult, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrSystemBackupInProgress
+	}
+	defer s.mu.Unlock()
+
+	dir, err := os.MkdirTemp(filepath.Dir(s.dbPath), ".sunspear-restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := unpackSystemArchive(r, dir)
+	if err != nil {
+		return nil, err
+	}
+	staged := filepath.Join(dir, systemBackupDatabase)
+	if err := checkStagedDatabase(staged); err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{Manifest: *manifest, Redeployed: []RedeployResult{}}
+	switch manifest.SecretsKeyFingerprint {
+	case s.secrets.Fingerprint():
+	case "":
+		result.Warnings = append(result.Warnings, "the backup does not record its SECRETS_KEY; stored credentials are unreadable unless it matches the current key")
+	default:
+		if !opts.AcceptKeyMismatch {
+			return nil, ErrSecretsKeyMismatch
+		}
+		result.Warnings = append(result.Warnings, "the backup was made with a different SECRETS_KEY; re-enter registry credentials and backup target secrets")
+	}
+	for _, warning := range result.Warnings {
+		log.Printf("Restore: %s", warning)
+	}
+	result.PreRestoreBackup = fmt.Sprintf("%s.pre-restore-%s.bak", s.dbPath, time.Now().UTC().Format("20060102-150405"))
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", result.PreRestoreBackup); err != nil {
+		return nil, fmt.Errorf("failed to save current database: %w", err)
+	}
+
+	if err := copyDatabase(ctx, s.db, staged); err != nil {
+		return nil, fmt.Errorf("failed to restore database (previous copy kept at %s): %w", result.PreRestoreBackup, err)
+	}
+	if err := config.Migrate(s.db, s.dbPath); err != nil {
+		return nil, fmt.Errorf("failed to migrate restored database: %w", err)
+	}
+	if result.SchemaVersion, err = config.SchemaVersion(s.db); err != nil {
+		return nil, err
+	}
+
+	if err := s.restoreAppsDir(dir, manifest); err != nil {
+		return nil, err
+	}
+	if err := s.marketplace.LoadApps(); err != nil {
+		log.Printf("Warning: failed to reload app catalog after restore: %v", err)
+	}
+	log.Printf("Restored Sunspear backup from %s (version %s, schema %d)", manifest.CreatedAt.Format(time.RFC3339), manifest.Version, manifest.SchemaVersion)
+
+	if opts.Redeploy {
+		var specs []AppContainerSpec
+		data, err := os.ReadFile(filepath.Join(dir, systemBackupAppSpecs))
+		if err == nil {
+			err = json.Unmarshal(data, &specs)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSystemBackup, systemBackupAppSpecs, err)
+		}
+		result.Redeployed = s.redeploy(ctx, specs)
+	}
+	return result, nil
+}
+
+// restoreAppsDir replaces the apps directory with the archived one. The
+// archived files are copied into a staging directory next to it, which is
+// then swapped in, so files absent from the archive do not survive.
+func (s *SystemBackupService) restoreAppsDir(dir string, manifest *SystemBackupManifest) error {
+	parent := filepath.Dir(filepath.Clean(s.appsDir))
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(parent, ".sunspear-apps-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	for _, f := range manifest.Files {
+		rel, ok := strings.CutPrefix(f.Path, "apps/")
+		if !ok {
+			continue
+		}
+		dest := filepath.Join(staging, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if err == nil {
+			err = os.WriteFile(dest, data, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", f.Path, err)
+		}
+	}
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+
+	previous := staging + ".old"
+	if err := os.Rename(s.appsDir, previous); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to replace apps directory: %w", err)
+	}
+	if err := os.Rename(staging, s.appsDir); err != nil {
+		os.Rename(previous, s.appsDir)
+		return fmt.Errorf("failed to replace apps directory: %w", err)
+	}
+	return os.RemoveAll(previous)
+}
+
+// unpackSystemArchive extracts an archive into dir and verifies every file
+// against the manifest. Entries outside the known layout are rejected.
+func unpackSystemArchive(r io.Reader, dir string) (*SystemBackupManifest, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidSystemBackup, fmt.Sprintf(format, args...))
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, invalid("not a gzip archive")
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	extracted := make(map[string]bool)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalid("corrupt archive: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		known := name == systemBackupManifest || name == systemBackupDatabase || name == systemBackupAppSpecs ||
+			(strings.HasPrefix(name, "apps/") && !strings.Contains(name, ".."))
+		if hdr.Typeflag != tar.TypeReg || !known || extracted[name] {
+			return nil, invalid("unexpected entry %q", hdr.Name)
+		}
+
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return nil, invalid("corrupt archive: %v", err)
+		}
+		extracted[name] = true
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, systemBackupManifest))
+	if err != nil {
+		return nil, invalid("missing %s", systemBackupManifest)
+	}
+	var manifest SystemBackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, invalid("unreadable manifest: %v", err)
+	}
+	if manifest.Format != systemBackupFormat {
+		return nil, invalid("unsupported archive format %d", manifest.Format)
+	}
+	latest, err := config.LatestSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion > latest {
+		return nil, invalid("schema version %d is newer than this build supports (%d); upgrade Sunspear first", manifest.SchemaVersion, latest)
+	}
+
+	listed := map[string]bool{systemBackupManifest: true}
+	for _, f := range manifest.Files {
+		listed[f.Path] = true
+		sum, err := (archiveEntry{name: f.Path, src: filepath.Join(dir, filepath.FromSlash(f.Path))}).checksum()
+		if err != nil || !extracted[f.Path] {
+			return nil, invalid("missing %s", f.Path)
+		}
+		if sum.Size != f.Size || sum.SHA256 != f.SHA256 {
+			return nil, invalid("checksum mismatch for %s", f.Path)
+		}
+	}
+	for name := range extracted {
+		if !listed[name] {
+			return nil, invalid("%s is not listed in the manifest", name)
+		}
+	}
+	if !extracted[systemBackupDatabase] {
+		return nil, invalid("missing %s", systemBackupDatabase)
+	}
+	return &manifest, nil
+}
+
+// checkStagedDatabase makes sure the unpacked database is intact and no
+// newer than this build. It is opened read-write because checking an FTS5
+// index writes to it.
+func checkStagedDatabase(dbPath string) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil || result != "ok" {
+		return fmt.Errorf("%w: database failed integrity check", ErrInvalidSystemBackup)
+	}
+	version, err := config.SchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("%w: database has no schema version", ErrInvalidSystemBackup)
+	}
+	latest, err := config.LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > latest {
+		return fmt.Errorf("%w: database schema version %d is newer than this build supports (%d)", ErrInvalidSystemBackup, version, latest)
+	}
+	return nil
+}
+
+// copyDatabase overwrites the live database with the one at srcPath using
+// SQLite's online backup API, so open connections see the restored data
+func copyDatabase(ctx context.Context, dst *sql.DB, srcPath string) error {
+	src, err := sql.Open("sqlite3", "file:"+srcPath+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dstRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			dstSQLite, ok1 := dstRaw.(*sqlite3.SQLiteConn)
+			srcSQLite, ok2 := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return fmt.Errorf("database is not SQLite")
+			}
+			backup, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+// redeploy recreates every compose project from its stored YAML and every
+// installed app from its archived container spec. Projects or apps whose
+// containers already exist on this host are skipped.
+func (s *SystemBackupService) redeploy(ctx context.Context, specs []AppContainerSpec) []RedeployResult {
+	results := []RedeployResult{}
+
+	projects, err := s.compose.ListProjects()
+	if err != nil {
+		log.Printf("Failed to list compose projects for redeploy: %v", err)
+	}
+	for _, p := range projects {
+		res := RedeployResult{Kind: "project", ID: p.ID, Name: p.Name, Status: "deployed"}
+		if s.containersExist(ctx, p.ContainerIDs) {
+			res.Status = "skipped"
+		} else if _, err := s.compose.Redeploy(ctx, p.ID); err != nil {
+			res.Status, res.Error = "failed", err.Error()
+		}
+		results = append(results, res)
+	}
+
+	bySpec := make(map[int][]AppContainerSpec)
+	for _, spec := range specs {
+		bySpec[spec.InstalledAppID] = append(bySpec[spec.InstalledAppID], spec)
+	}
+	apps, err := s.marketplace.GetInstalledApps()
+	if err != nil {
+		log.Printf("Failed to list installed apps for redeploy: %v", err)
+	}
+	for _, app := range apps {
+		res := RedeployResult{Kind: "app", ID: app.ID, Name: app.AppName, Status: "deployed"}
+		switch {
+		case s.containersExist(ctx, app.ContainerIDs):
+			res.Status = "skipped"
+		case len(bySpec[app.ID]) == 0:
+			res.Status, res.Error = "failed", "no container configuration in backup"
+		default:
+			if err := s.redeployApp(ctx, app.ID, bySpec[app.ID]); err != nil {
+				res.Status, res.Error = "failed", err.Error()
+			}
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// containersExist reports whether any container in a JSON ID list exists
+func (s *SystemBackupService) containersExist(ctx context.Context, idsJSON string) bool {
+	var ids []string
+	json.Unmarshal([]byte(idsJSON), &ids)
+	for _, id := range ids {
+		if _, err := s.docker.GetContainer(ctx, id); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SystemBackupService) redeployApp(ctx context.Context, appID int, specs []AppContainerSpec) error {
+	var ids []string
+	for _, spec := range specs {
+		if err := s.docker.PullImageAndWait(ctx, spec.Config.Image); err != nil {
+			return fmt.Errorf("failed to pull image %s: %w", spec.Config.Image, err)
+		}
+		resp, err := s.docker.CreateContainer(ctx, spec.Config, spec.HostConfig, spec.Name)
+		if err != nil {
+			return fmt.Errorf("failed to create container %s: %w", spec.Name, err)
+		}
+		ids = append(ids, resp.ID)
+
+		// The network in NetworkMode is attached at creation; connect the rest
+		primary := string(spec.HostConfig.NetworkMode)
+		for _, name := range spec.Networks {
+			if name == primary || (primary == "default" && name == "bridge") || spec.HostConfig.NetworkMode.IsContainer() {
+				continue
+			}
+			if err := s.docker.ConnectNetwork(ctx, name, resp.ID); err != nil {
+				log.Printf("Redeploy: failed to connect %s to network %s: %v", spec.Name, name, err)
+			}
+		}
+		if err := s.docker.StartContainer(ctx, resp.ID); err != nil {
+			return fmt.Errorf("failed to start container %s: %w", spec.Name, err)
+		}
+	}
+	return s.marketplace.SetInstalledContainers(appID, ids)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sunspear/config"
+)
+
+func TestRestoreAppsDirRemovesStaleFiles(t *testing.T) {
+	root := t.TempDir()
+	appsDir := filepath.Join(root, "apps")
+	staged := filepath.Join(root, "staged")
+
+	writeTestFile(t, filepath.Join(appsDir, "nextcloud", "app.json"), "current")
+	writeTestFile(t, filepath.Join(appsDir, "stale", "app.json"), "stale")
+	writeTestFile(t, filepath.Join(staged, "apps", "nextcloud", "app.json"), "archived")
+	writeTestFile(t, filepath.Join(staged, "apps", "gitea", "app.json"), "archived")
+
+	s := &SystemBackupService{appsDir: appsDir}
+	manifest := &SystemBackupManifest{Files: []SystemBackupFile{
+		{Path: systemBackupDatabase},
+		{Path: "apps/nextcloud/app.json"},
+		{Path: "apps/gitea/app.json"},
+	}}
+	if err := s.restoreAppsDir(staged, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"nextcloud/app.json", "gitea/app.json"} {
+		data, err := os.ReadFile(filepath.Join(appsDir, name))
+		if err != nil || string(data) != "archived" {
+			t.Errorf("%s = %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(appsDir, "stale")); !os.IsNotExist(err) {
+		t.Errorf("stale app survived the restore: %v", err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 2 {
+		t.Errorf("staging directories left behind: %v", entries)
+	}
+}
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreChecksSecretsKey(t *testing.T) {
+	dir := t.TempDir()
+	source := newTestDB(t)
+	snapshot := filepath.Join(dir, "snapshot.db")
+	mustExec(t, source, "VACUUM INTO ?", snapshot)
+	schemaVersion, err := config.SchemaVersion(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An archive written on a host with another SECRETS_KEY
+	otherKey, _ := NewSecretBox("old-host-key")
+	entry := archiveEntry{name: systemBackupDatabase, src: snapshot}
+	file, err := entry.checksum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(dir, "backup.tar.gz")
+	manifest := SystemBackupManifest{
+		Format:                systemBackupFormat,
+		SchemaVersion:         schemaVersion,
+		SecretsKeyFingerprint: otherKey.Fingerprint(),
+		Files:                 []SystemBackupFile{file},
+	}
+	if err := writeSystemArchive(archivePath, manifest, []archiveEntry{entry}); err != nil {
+		t.Fatal(err)
+	}
+
+	box, _ := NewSecretBox("new-host-key")
+	db := newTestDB(t)
+	s := NewSystemBackupService(db, filepath.Join(dir, "current.db"), filepath.Join(dir, "apps"), nil, nil, nil, box)
+	f, err := os.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := s.Restore(context.Background(), f, RestoreOptions{}); !errors.Is(err, ErrSecretsKeyMismatch) {
+		t.Errorf("Restore with another key = %v, want ErrSecretsKeyMismatch", err)
+	}
+}
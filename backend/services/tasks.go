@@ -0,0 +1,673 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sunspear/config"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+)
+
+// ErrTaskRunning is returned when a task with the forbid concurrency policy
+// is triggered while a previous run is still in progress
+var ErrTaskRunning = errors.New("a run of this task is already in progress")
+
+const (
+	TaskActionContainerStart   = "container.start"
+	TaskActionContainerStop    = "container.stop"
+	TaskActionContainerRestart = "container.restart"
+	TaskActionProjectStart     = "project.start"
+	TaskActionProjectStop      = "project.stop"
+	TaskActionProjectRestart   = "project.restart"
+	TaskActionExec             = "exec"
+	TaskActionRun              = "run"
+	TaskActionPrune            = "prune"
+
+	// Concurrency policies for a task triggered while it is still running
+	TaskConcurrencyForbid  = "forbid"  // skip the new run
+	TaskConcurrencyAllow   = "allow"   // run both
+	TaskConcurrencyReplace = "replace" // cancel the running one
+
+	// Missed-run policies for schedules that fired while the backend was down
+	TaskMissedSkip = "skip" // record the missed run as skipped
+	TaskMissedRun  = "run"  // run once on startup
+
+	TaskRunRunning   = "running"
+	TaskRunSuccess   = "success"
+	TaskRunFailed    = "failed"
+	TaskRunSkipped   = "skipped"
+	TaskRunCancelled = "cancelled"
+
+	taskSchedulerInterval = 30 * time.Second
+	defaultTaskTimeout    = time.Hour
+	// maxTaskOutput bounds the stored output of a run; the tail is kept
+	maxTaskOutput = 64 << 10
+)
+
+var taskActions = map[string]bool{
+	TaskActionContainerStart: true, TaskActionContainerStop: true, TaskActionContainerRestart: true,
+	TaskActionProjectStart: true, TaskActionProjectStop: true, TaskActionProjectRestart: true,
+	TaskActionExec: true, TaskActionRun: true, TaskActionPrune: true,
+}
+
+var pruneResources = map[string]bool{"containers": true, "images": true, "volumes": true, "networks": true}
+
+// TaskConfig holds action-specific settings. Exec uses Command and Env; run
+// uses Image, Command, Env, Volumes (host or volume binds) and Network;
+// prune uses Prune (containers, images, volumes, networks).
+type TaskConfig struct {
+	Command        []string `json:"command,omitempty"`
+	Env            []string `json:"env,omitempty"`
+	Image          string   `json:"image,omitempty"`
+	Volumes        []string `json:"volumes,omitempty"`
+	Network        string   `json:"network,omitempty"`
+	Prune          []string `json:"prune,omitempty"`
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"`
+}
+
+// ScheduledTask runs an action on a cron schedule. Target is a container
+// name or ID for container actions and exec, or a compose project name.
+type ScheduledTask struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	Schedule        string     `json:"schedule"`
+	Action          string     `json:"action"`
+	Target          string     `json:"target"`
+	Config          TaskConfig `json:"config"`
+	Concurrency     string     `json:"concurrency"`
+	MissedRuns      string     `json:"missedRuns"`
+	Enabled         bool       `json:"enabled"`
+	LastScheduledAt string     `json:"lastScheduledAt,omitempty"`
+	NextRunAt       string     `json:"nextRunAt,omitempty"`
+	CreatedAt       string     `json:"createdAt"`
+	UpdatedAt       string     `json:"updatedAt"`
+}
+
+// TaskRun is one execution of a task
+type TaskRun struct {
+	ID         int    `json:"id"`
+	TaskID     int    `json:"taskId"`
+	Trigger    string `json:"trigger"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	Output     string `json:"output"`
+	ExitCode   *int   `json:"exitCode,omitempty"`
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt"`
+}
+
+// TaskService stores scheduled tasks and runs them on their cron schedules
+type TaskService struct {
+	db             *sql.DB
+	dockerService  *DockerService
+	composeService *ComposeService
+	notifications  *NotificationService
+	audit          *AuditService
+
+	mu      sync.Mutex
+	running map[int]map[int]context.CancelFunc // task ID -> run ID -> cancel
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewTaskService(db *sql.DB, dockerService *DockerService, composeService *ComposeService, notifications *NotificationService, audit *AuditService) *TaskService {
+	return &TaskService{
+		db:             db,
+		dockerService:  dockerService,
+		composeService: composeService,
+		notifications:  notifications,
+		audit:          audit,
+		running:        make(map[int]map[int]context.CancelFunc),
+		stopChan:       make(chan struct{}),
+	}
+}
+
+// Start marks runs interrupted by the last shutdown as failed, handles
+// schedules missed while the backend was down and launches the scheduler
+func (s *TaskService) Start() {
+	if _, err := s.db.Exec(`
+		UPDATE task_runs SET status = ?, message = 'interrupted by backend restart', finished_at = CURRENT_TIMESTAMP
+		WHERE status = ?
+	`, TaskRunFailed, TaskRunRunning); err != nil {
+		log.Printf("Failed to reset interrupted task runs: %v", err)
+	}
+	s.handleMissedRuns(time.Now())
+	go s.schedule()
+}
+
+// Stop halts the scheduler, cancels in-flight runs and waits for them
+func (s *TaskService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+	s.wg.Wait()
+}
+
+func (s *TaskService) schedule() {
+	ticker := time.NewTicker(taskSchedulerInterval)
+	defer ticker.Stop()
+
+	lastCheck := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.runDueTasks(lastCheck, now)
+			lastCheck = now
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// runDueTasks starts every enabled task whose schedule fired in (since, now]
+func (s *TaskService) runDueTasks(since, now time.Time) {
+	tasks, err := s.ListTasks()
+	if err != nil {
+		log.Printf("Task scheduler failed to load tasks: %v", err)
+		return
+	}
+
+	for _, task := range tasks {
+		if !task.Enabled {
+			continue
+		}
+		schedule, err := ParseCron(task.Schedule)
+		if err != nil {
+			continue
+		}
+		if next := schedule.Next(since); next.IsZero() || next.After(now) {
+			continue
+		}
+		s.markScheduled(task.ID, now)
+		if _, err := s.StartRun(task.ID, "schedule"); err != nil && !errors.Is(err, ErrTaskRunning) {
+			log.Printf("Failed to start task %s: %v", task.Name, err)
+		}
+	}
+}
+
+// handleMissedRuns applies each task's missed-run policy to schedules that
+// fired between its last scheduled run and now. Several missed firings
+// count as one.
+func (s *TaskService) handleMissedRuns(now time.Time) {
+	tasks, err := s.ListTasks()
+	if err != nil {
+		log.Printf("Task scheduler failed to load tasks: %v", err)
+		return
+	}
+
+	for _, task := range tasks {
+		if !task.Enabled {
+			continue
+		}
+		schedule, err := ParseCron(task.Schedule)
+		if err != nil {
+			continue
+		}
+		last, err := time.Parse(time.RFC3339, task.LastScheduledAt)
+		if err != nil {
+			continue
+		}
+		if next := schedule.Next(last.Local()); next.IsZero() || next.After(now) {
+			continue
+		}
+		s.markScheduled(task.ID, now)
+
+		if task.MissedRuns == TaskMissedRun {
+			if _, err := s.StartRun(task.ID, "missed"); err != nil && !errors.Is(err, ErrTaskRunning) {
+				log.Printf("Failed to start missed run of task %s: %v", task.Name, err)
+			}
+			continue
+		}
+		if _, err := s.db.Exec(`
+			INSERT INTO task_runs (task_id, trigger, status, message, finished_at) VALUES (?, 'missed', ?, ?, CURRENT_TIMESTAMP)
+		`, task.ID, TaskRunSkipped, "scheduled run missed while the backend was down"); err != nil {
+			log.Printf("Failed to record missed run of task %s: %v", task.Name, err)
+		}
+	}
+}
+
+func (s *TaskService) markScheduled(id int, at time.Time) {
+	if _, err := s.db.Exec("UPDATE scheduled_tasks SET last_scheduled_at = ? WHERE id = ?", at.Unix(), id); err != nil {
+		log.Printf("Failed to record schedule of task %d: %v", id, err)
+	}
+}
+
+// Tasks
+
+const scheduledTaskColumns = `id, name, schedule, action, target, config, concurrency, missed_runs, enabled, last_scheduled_at, created_at, updated_at`
+
+func (s *TaskService) ListTasks() ([]ScheduledTask, error) {
+	rows, err := s.db.Query("SELECT " + scheduledTaskColumns + " FROM scheduled_tasks ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []ScheduledTask{}
+	for rows.Next() {
+		t, err := scanScheduledTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *t)
+	}
+	return tasks, rows.Err()
+}
+
+func (s *TaskService) GetTask(id int) (*ScheduledTask, error) {
+	return scanScheduledTask(s.db.QueryRow("SELECT "+scheduledTaskColumns+" FROM scheduled_tasks WHERE id = ?", id))
+}
+
+func (s *TaskService) CreateTask(t ScheduledTask) (*ScheduledTask, error) {
+	if err := normalizeTask(&t); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+
+	configJSON, _ := json.Marshal(t.Config)
+	result, err := s.db.Exec(`
+		INSERT INTO scheduled_tasks (name, schedule, action, target, config, concurrency, missed_runs, enabled, last_scheduled_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.Name, t.Schedule, t.Action, t.Target, string(configJSON), t.Concurrency, t.MissedRuns, t.Enabled, time.Now().Unix())
+	if err != nil {
+		if config.IsUniqueViolation(err) {
+			return nil, errdefs.Conflict(fmt.Errorf("a task named %s already exists", t.Name))
+		}
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return s.GetTask(int(id))
+}
+
+// UpdateTask replaces a task's definition. Changing the schedule does not
+// count the time before the change as missed.
+func (s *TaskService) UpdateTask(id int, t ScheduledTask) (*ScheduledTask, error) {
+	existing, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeTask(&t); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+
+	query := `
+		UPDATE scheduled_tasks SET name = ?, schedule = ?, action = ?, target = ?, config = ?, concurrency = ?,
+		missed_runs = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP`
+	configJSON, _ := json.Marshal(t.Config)
+	args := []interface{}{t.Name, t.Schedule, t.Action, t.Target, string(configJSON), t.Concurrency, t.MissedRuns, t.Enabled}
+	if t.Schedule != existing.Schedule || (t.Enabled && !existing.Enabled) {
+		query += ", last_scheduled_at = ?"
+		args = append(args, time.Now().Unix())
+	}
+	query += " WHERE id = ?"
+	args = append(args, id)
+
+	if _, err := s.db.Exec(query, args...); err != nil {
+		if config.IsUniqueViolation(err) {
+			return nil, errdefs.Conflict(fmt.Errorf("a task named %s already exists", t.Name))
+		}
+		return nil, err
+	}
+	return s.GetTask(id)
+}
+
+// DeleteTask removes a task and its history, cancelling any running runs
+func (s *TaskService) DeleteTask(id int) error {
+	result, err := s.db.Exec("DELETE FROM scheduled_tasks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	s.cancelRuns(id)
+	_, err = s.db.Exec("DELETE FROM task_runs WHERE task_id = ?", id)
+	return err
+}
+
+// normalizeTask applies defaults and validates a task definition
+func normalizeTask(t *ScheduledTask) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Target = strings.TrimSpace(t.Target)
+	if !backupNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid task name (letters, digits, '.', '_' and '-' only)")
+	}
+	if _, err := ParseCron(t.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	if t.Concurrency == "" {
+		t.Concurrency = TaskConcurrencyForbid
+	}
+	if t.Concurrency != TaskConcurrencyForbid && t.Concurrency != TaskConcurrencyAllow && t.Concurrency != TaskConcurrencyReplace {
+		return fmt.Errorf("concurrency must be forbid, allow or replace")
+	}
+	if t.MissedRuns == "" {
+		t.MissedRuns = TaskMissedSkip
+	}
+	if t.MissedRuns != TaskMissedSkip && t.MissedRuns != TaskMissedRun {
+		return fmt.Errorf("missedRuns must be skip or run")
+	}
+	if t.Config.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeoutSeconds must not be negative")
+	}
+
+	if !taskActions[t.Action] {
+		return fmt.Errorf("unknown action %q", t.Action)
+	}
+	switch t.Action {
+	case TaskActionExec:
+		if t.Target == "" || len(t.Config.Command) == 0 {
+			return fmt.Errorf("exec requires a target container and a command")
+		}
+	case TaskActionRun:
+		if t.Config.Image == "" {
+			return fmt.Errorf("run requires an image")
+		}
+	case TaskActionPrune:
+		if len(t.Config.Prune) == 0 {
+			return fmt.Errorf("prune requires at least one of containers, images, volumes, networks")
+		}
+		for _, r := range t.Config.Prune {
+			if !pruneResources[r] {
+				return fmt.Errorf("cannot prune %q (containers, images, volumes or networks)", r)
+			}
+		}
+	default:
+		if t.Target == "" {
+			return fmt.Errorf("%s requires a target", t.Action)
+		}
+	}
+	return nil
+}
+
+// Runs
+
+// ListRuns returns the run history of a task, newest first
+func (s *TaskService) ListRuns(taskID int, limit int) ([]TaskRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.Query(`
+		SELECT id, task_id, trigger, status, message, output, exit_code, started_at, COALESCE(finished_at, '')
+		FROM task_runs WHERE task_id = ? ORDER BY id DESC LIMIT ?
+	`, taskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []TaskRun{}
+	for rows.Next() {
+		var run TaskRun
+		var exitCode sql.NullInt64
+		if err := rows.Scan(&run.ID, &run.TaskID, &run.Trigger, &run.Status, &run.Message, &run.Output, &exitCode, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			run.ExitCode = &code
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// StartRun begins a task run in the background and returns its run ID. The
+// task's concurrency policy decides what happens if it is already running.
+func (s *TaskService) StartRun(taskID int, trigger string) (int, error) {
+	task, err := s.GetTask(taskID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	if len(s.running[taskID]) > 0 {
+		switch task.Concurrency {
+		case TaskConcurrencyForbid:
+			s.mu.Unlock()
+			s.db.Exec(`
+				INSERT INTO task_runs (task_id, trigger, status, message, finished_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+			`, taskID, trigger, TaskRunSkipped, "previous run still in progress")
+			return 0, ErrTaskRunning
+		case TaskConcurrencyReplace:
+			for _, cancel := range s.running[taskID] {
+				cancel()
+			}
+		}
+	}
+
+	result, err := s.db.Exec("INSERT INTO task_runs (task_id, trigger, status) VALUES (?, ?, ?)", taskID, trigger, TaskRunRunning)
+	if err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	id, _ := result.LastInsertId()
+	runID := int(id)
+
+	timeout := defaultTaskTimeout
+	if task.Config.TimeoutSeconds > 0 {
+		timeout = time.Duration(task.Config.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if s.running[taskID] == nil {
+		s.running[taskID] = make(map[int]context.CancelFunc)
+	}
+	s.running[taskID][runID] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.running[taskID], runID)
+			s.mu.Unlock()
+			cancel()
+		}()
+		go func() {
+			select {
+			case <-s.stopChan:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		s.executeRun(ctx, runID, task, trigger)
+	}()
+
+	return runID, nil
+}
+
+func (s *TaskService) cancelRuns(taskID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.running[taskID] {
+		cancel()
+	}
+}
+
+func (s *TaskService) executeRun(ctx context.Context, runID int, task *ScheduledTask, trigger string) {
+	output := &tailBuffer{limit: maxTaskOutput}
+	exitCode, err := s.perform(ctx, task, output)
+
+	status, message := TaskRunSuccess, "completed"
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status, message = TaskRunFailed, "timed out"
+	case errors.Is(ctx.Err(), context.Canceled):
+		status, message = TaskRunCancelled, "cancelled"
+	case err != nil:
+		status, message = TaskRunFailed, err.Error()
+	case exitCode != nil && *exitCode != 0:
+		status, message = TaskRunFailed, fmt.Sprintf("exited with code %d", *exitCode)
+	}
+
+	var code interface{}
+	if exitCode != nil {
+		code = *exitCode
+	}
+	if _, dbErr := s.db.Exec(`
+		UPDATE task_runs SET status = ?, message = ?, output = ?, exit_code = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, status, message, output.String(), code, runID); dbErr != nil {
+		log.Printf("Failed to record task run %d: %v", runID, dbErr)
+	}
+
+	var runErr error
+	if status != TaskRunSuccess {
+		runErr = errors.New(message)
+	}
+	s.audit.RecordSystem("tasks.run", fmt.Sprintf("tasks/%d", task.ID), map[string]interface{}{
+		"run": runID, "trigger": trigger, "action": task.Action, "target": task.Target,
+	}, runErr)
+
+	if status == TaskRunFailed {
+		s.notifications.Notify("task.failed", NotificationError,
+			fmt.Sprintf("Task %s failed", task.Name), message)
+	}
+}
+
+// perform carries out a task's action, writing any output. Exec and run
+// return the command's exit code.
+func (s *TaskService) perform(ctx context.Context, task *ScheduledTask, output *tailBuffer) (*int, error) {
+	switch task.Action {
+	case TaskActionContainerStart:
+		return nil, s.dockerService.StartContainer(ctx, task.Target)
+	case TaskActionContainerStop:
+		return nil, s.dockerService.StopContainer(ctx, task.Target, 10)
+	case TaskActionContainerRestart:
+		return nil, s.dockerService.RestartContainer(ctx, task.Target, 10)
+	case TaskActionProjectStart, TaskActionProjectStop, TaskActionProjectRestart:
+		return nil, s.performProject(ctx, task)
+	case TaskActionExec:
+		code, err := s.dockerService.ExecCommand(ctx, task.Target, task.Config.Command, task.Config.Env, output, output)
+		if err != nil {
+			return nil, err
+		}
+		return &code, nil
+	case TaskActionRun:
+		return s.runContainer(ctx, task, output)
+	case TaskActionPrune:
+		return nil, s.prune(ctx, task.Config.Prune, output)
+	}
+	return nil, fmt.Errorf("unknown action %q", task.Action)
+}
+
+func (s *TaskService) performProject(ctx context.Context, task *ScheduledTask) error {
+	projects, err := s.composeService.ListProjects()
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if p.Name != task.Target {
+			continue
+		}
+		switch task.Action {
+		case TaskActionProjectStart:
+			return s.composeService.StartProject(ctx, p.ID)
+		case TaskActionProjectStop:
+			return s.composeService.StopProject(ctx, p.ID)
+		default:
+			return s.composeService.RestartProject(ctx, p.ID)
+		}
+	}
+	return fmt.Errorf("project %s not found", task.Target)
+}
+
+// runContainer runs a throwaway container to completion and removes it
+func (s *TaskService) runContainer(ctx context.Context, task *ScheduledTask, output *tailBuffer) (*int, error) {
+	if err := s.dockerService.EnsureImage(ctx, task.Config.Image); err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", task.Config.Image, err)
+	}
+
+	resp, err := s.dockerService.CreateContainer(ctx, &container.Config{
+		Image:  task.Config.Image,
+		Cmd:    task.Config.Command,
+		Env:    task.Config.Env,
+		Labels: map[string]string{"com.sunspear.helper": "task", "com.sunspear.task": strconv.Itoa(task.ID)},
+	}, &container.HostConfig{
+		Binds:       task.Config.Volumes,
+		NetworkMode: container.NetworkMode(task.Config.Network),
+		// Output is read back after exit, so use a driver that supports reading logs
+		LogConfig: container.LogConfig{Type: "json-file"},
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+	defer removeVolumeHelper(s.dockerService, resp.ID)
+
+	if err := s.dockerService.StartContainer(ctx, resp.ID); err != nil {
+		return nil, err
+	}
+	code, err := s.dockerService.WaitContainer(ctx, resp.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.dockerService.ReadContainerOutput(ctx, resp.ID, output, output); err != nil {
+		return nil, fmt.Errorf("failed to read output: %w", err)
+	}
+	exitCode := int(code)
+	return &exitCode, nil
+}
+
+func (s *TaskService) prune(ctx context.Context, resources []string, output *tailBuffer) error {
+	for _, resource := range resources {
+		var line string
+		switch resource {
+		case "containers":
+			report, err := s.dockerService.PruneContainers(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to prune containers: %w", err)
+			}
+			line = fmt.Sprintf("containers: removed %d, reclaimed %d bytes", len(report.ContainersDeleted), report.SpaceReclaimed)
+		case "images":
+			report, err := s.dockerService.PruneImages(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to prune images: %w", err)
+			}
+			line = fmt.Sprintf("images: removed %d, reclaimed %d bytes", len(report.ImagesDeleted), report.SpaceReclaimed)
+		case "volumes":
+			report, err := s.dockerService.PruneVolumes(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to prune volumes: %w", err)
+			}
+			line = fmt.Sprintf("volumes: removed %d, reclaimed %d bytes", len(report.VolumesDeleted), report.SpaceReclaimed)
+		case "networks":
+			report, err := s.dockerService.PruneNetworks(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to prune networks: %w", err)
+			}
+			line = fmt.Sprintf("networks: removed %d", len(report.NetworksDeleted))
+		}
+		output.WriteString(line + "\n")
+	}
+	return nil
+}
+
+func scanScheduledTask(row rowScanner) (*ScheduledTask, error) {
+	var t ScheduledTask
+	var configJSON string
+	var lastScheduled int64
+	if err := row.Scan(&t.ID, &t.Name, &t.Schedule, &t.Action, &t.Target, &configJSON, &t.Concurrency, &t.MissedRuns, &t.Enabled, &lastScheduled, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(configJSON), &t.Config); err != nil {
+		return nil, fmt.Errorf("corrupt task config: %w", err)
+	}
+	if lastScheduled > 0 {
+		last := time.Unix(lastScheduled, 0).UTC()
+		t.LastScheduledAt = last.Format(time.RFC3339)
+		if schedule, err := ParseCron(t.Schedule); err == nil && t.Enabled {
+			if next := schedule.Next(time.Now()); !next.IsZero() {
+				t.NextRunAt = next.UTC().Format(time.RFC3339)
+			}
+		}
+	}
+	return &t, nil
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// ErrChecksumMismatch is returned when an uploaded archive does not match its expected checksum
+var ErrChecksumMismatch = errors.New("archive checksum mismatch")
+
+// VolumeArchiveRecord describes a completed backup or restore
+type VolumeArchiveRecord struct {
+	ID         int    `json:"id"`
+	VolumeName string `json:"volumeName"`
+	Operation  string `json:"operation"`
+	Compressed bool   `json:"compressed"`
+	SizeBytes  int64  `json:"sizeBytes"`
+	SHA256     string `json:"sha256"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+// VolumeArchiveOptions controls backup and restore behavior
+type VolumeArchiveOptions struct {
+	// StopContainers stops running containers that use the volume for the
+	// duration of the operation and starts them again afterwards.
+	StopContainers bool
+	// Compress gzips backup archives.
+	Compress bool
+	// Replace deletes existing volume contents before a restore.
+	Replace bool
+	// ExpectedSHA256, when set, is verified against the uploaded archive
+	// before anything in the volume is touched.
+	ExpectedSHA256 string
+}
+
+// VolumeBackupService archives volume contents through helper containers
+type VolumeBackupService struct {
+	db            *sql.DB
+	dockerService *DockerService
+}
+
+func NewVolumeBackupService(db *sql.DB, dockerService *DockerService) *VolumeBackupService {
+	return &VolumeBackupService{
+		db:            db,
+		dockerService: dockerService,
+	}
+}
+
+// Backup writes a tar archive (gzipped when opts.Compress) of the volume
+// contents to w. The archive streams straight from the Docker daemon, so
+// large volumes are never held in memory.
+func (s *VolumeBackupService) Backup(ctx context.Context, volumeName string, w io.Writer, opts VolumeArchiveOptions) (*VolumeArchiveRecord, error) {
+	if _, err := s.dockerService.InspectVolume(ctx, volumeName); err != nil {
+		return nil, err
+	}
+
+	restart, err := s.stopVolumeUsers(ctx, volumeName, opts.StopContainers)
+	defer restart()
+	if err != nil {
+		return nil, err
+	}
+
+	helperID, err := createVolumeHelper(ctx, s.dockerService, volumeName, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer removeVolumeHelper(s.dockerService, helperID)
+
+	// The container never needs to run: the daemon can read a created
+	// container's mounts directly.
+	reader, err := s.dockerService.CopyFromContainer(ctx, helperID, volumeMountPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read volume %s: %w", volumeName, err)
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(w, hasher)}
+
+	var archiveWriter io.Writer = counter
+	var gz *gzip.Writer
+	if opts.Compress {
+		gz = gzip.NewWriter(counter)
+		archiveWriter = gz
+	}
+
+	if err := rewriteVolumeTar(reader, archiveWriter); err != nil {
+		return nil, fmt.Errorf("failed to archive volume %s: %w", volumeName, err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.recordArchive(volumeName, "backup", opts.Compress, counter.n, hex.EncodeToString(hasher.Sum(nil)))
+}
+
+// Restore extracts a tar or tar.gz archive into the volume. The upload is
+// received in full and checked before the volume is touched, so a
+// truncated or corrupt archive never clears it.
+func (s *VolumeBackupService) Restore(ctx context.Context, volumeName string, archive io.Reader, opts VolumeArchiveOptions) (*VolumeArchiveRecord, error) {
+	if _, err := s.dockerService.InspectVolume(ctx, volumeName); err != nil {
+		return nil, err
+	}
+
+	spooled, err := spoolArchive(archive, opts.ExpectedSHA256)
+	if err != nil {
+		return nil, err
+	}
+	defer spooled.remove()
+
+	tarStream, err := spooled.tarStream()
+	if err != nil {
+		return nil, err
+	}
+
+	restart, err := s.stopVolumeUsers(ctx, volumeName, opts.StopContainers)
+	defer restart()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Replace {
+		if _, err := runVolumeHelper(ctx, s.dockerService, volumeName, false, []string{"sh", "-c", "find " + volumeMountPath + " -mindepth 1 -delete"}); err != nil {
+			return nil, fmt.Errorf("failed to clear volume %s: %w", volumeName, err)
+		}
+	}
+
+	helperID, err := createVolumeHelper(ctx, s.dockerService, volumeName, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer removeVolumeHelper(s.dockerService, helperID)
+
+	if err := s.dockerService.CopyToContainer(ctx, helperID, volumeMountPath, tarStream); err != nil {
+		return nil, fmt.Errorf("failed to restore volume %s: %w", volumeName, err)
+	}
+
+	return s.recordArchive(volumeName, "restore", spooled.compressed, spooled.size, spooled.sha256)
+}
+
+// ListArchives returns backup and restore history for a volume, newest first
+func (s *VolumeBackupService) ListArchives(volumeName string) ([]VolumeArchiveRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT id, volume_name, operation, compressed, size_bytes, sha256, created_at
+		FROM volume_archives
+		WHERE volume_name = ?
+		ORDER BY id DESC
+	`, volumeName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []VolumeArchiveRecord{}
+	for rows.Next() {
+		var r VolumeArchiveRecord
+		if err := rows.Scan(&r.ID, &r.VolumeName, &r.Operation, &r.Compressed, &r.SizeBytes, &r.SHA256, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (s *VolumeBackupService) recordArchive(volumeName, operation string, compressed bool, size int64, checksum string) (*VolumeArchiveRecord, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO volume_archives (volume_name, operation, compressed, size_bytes, sha256)
+		VALUES (?, ?, ?, ?, ?)
+	`, volumeName, operation, compressed, size, checksum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %s: %w", operation, err)
+	}
+
+	id, _ := result.LastInsertId()
+	var r VolumeArchiveRecord
+	err = s.db.QueryRow(`
+		SELECT id, volume_name, operation, compressed, size_bytes, sha256, created_at
+		FROM volume_archives WHERE id = ?
+	`, id).Scan(&r.ID, &r.VolumeName, &r.Operation, &r.Compressed, &r.SizeBytes, &r.SHA256, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// stopVolumeUsers stops running containers that mount the volume when stop
+// is set. The returned function restarts them and is always safe to call.
+func (s *VolumeBackupService) stopVolumeUsers(ctx context.Context, volumeName string, stop bool) (func(), error) {
+	noop := func() {}
+	if !stop {
+		return noop, nil
+	}
+
+	containers, err := s.dockerService.ListContainersUsingVolume(ctx, volumeName)
+	if err != nil {
+		return noop, err
+	}
+
+	var stopped []string
+	restart := func() {
+		for _, id := range stopped {
+			if err := s.dockerService.StartContainer(context.Background(), id); err != nil {
+				log.Printf("Failed to restart container %s after volume operation: %v", id, err)
+			}
+		}
+	}
+
+	for _, c := range containers {
+		if c.State != "running" || c.Labels["com.sunspear.helper"] != "" {
+			continue
+		}
+		if err := s.dockerService.StopContainer(ctx, c.ID, 30); err != nil {
+			return restart, fmt.Errorf("failed to stop container %s: %w", c.ID[:12], err)
+		}
+		stopped = append(stopped, c.ID)
+	}
+
+	return restart, nil
+}
+
+// rewriteVolumeTar copies a tar stream from CopyFromContainer, stripping the
+// leading mount directory so archive entries are relative to the volume root.
+func rewriteVolumeTar(src io.Reader, dst io.Writer) error {
+	tr := tar.NewReader(src)
+	tw := tar.NewWriter(dst)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, ok := stripFirstComponent(hdr.Name)
+		if !ok {
+			// The mount directory itself
+			continue
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			name += "/"
+		}
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname, _ = stripFirstComponent(hdr.Linkname)
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// spooledArchive is an uploaded archive held in a temporary file
+type spooledArchive struct {
+	file       *os.File
+	size       int64
+	sha256     string
+	compressed bool
+}
+
+// spoolArchive copies an archive to a temporary file and checks it: its
+// SHA-256 when expected is set, and that its gzip and tar streams read
+// cleanly to the end.
+func spoolArchive(archive io.Reader, expected string) (*spooledArchive, error) {
+	tmp, err := os.CreateTemp("", "sunspear-restore-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &spooledArchive{file: tmp}
+
+	hasher := sha256.New()
+	spooled.size, err = io.Copy(io.MultiWriter(tmp, hasher), archive)
+	if err != nil {
+		spooled.remove()
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	spooled.sha256 = hex.EncodeToString(hasher.Sum(nil))
+	if expected != "" && !strings.EqualFold(spooled.sha256, expected) {
+		spooled.remove()
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expected, spooled.sha256)
+	}
+
+	if spooled.size == 0 {
+		spooled.remove()
+		return nil, errdefs.InvalidParameter(errors.New("archive is empty"))
+	}
+	if err := spooled.check(); err != nil {
+		spooled.remove()
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid archive: %w", err))
+	}
+	return spooled, nil
+}
+
+// check reads the whole archive, which also verifies the gzip checksum
+func (a *spooledArchive) check() error {
+	stream, err := a.tarStream()
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(stream)
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return err
+		}
+	}
+	_, err = io.Copy(io.Discard, stream)
+	return err
+}
+
+// tarStream rewinds the file and returns its tar stream, detecting gzip by
+// its magic bytes so both .tar and .tar.gz are accepted
+func (a *spooledArchive) tarStream() (io.Reader, error) {
+	if _, err := a.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(a.file)
+	magic, err := buffered.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return buffered, nil
+	}
+	a.compressed = true
+	gz, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid gzip archive: %w", err))
+	}
+	return gz, nil
+}
+
+func (a *spooledArchive) remove() {
+	a.file.Close()
+	os.Remove(a.file.Name())
+}
+
+func stripFirstComponent(name string) (string, bool) {
+	name = strings.TrimPrefix(path.Clean(name), "/")
+	_, rest, found := strings.Cut(name, "/")
+	return rest, found
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
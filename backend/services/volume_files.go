@@ -0,0 +1,455 @@
+package services
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+)
+
+var (
+	ErrVolumeNotFound       = errors.New("volume not found")
+	ErrVolumePathNotFound   = errors.New("path not found")
+	ErrVolumePathInvalid    = errors.New("invalid path")
+	ErrVolumePathIsDir      = errors.New("path is a directory")
+	ErrVolumePathNotDir     = errors.New("path is not a directory")
+	ErrVolumeDirNotEmpty    = errors.New("directory is not empty")
+	ErrVolumePathNotRegular = errors.New("path is not a regular file")
+)
+
+// VolumeFileEntry describes a file or directory inside a volume. Path is
+// relative to the volume root and always starts with "/".
+type VolumeFileEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Type    string    `json:"type"` // file, dir, symlink or other
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	UID     int       `json:"uid"`
+	GID     int       `json:"gid"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// VolumeFileService browses and edits files inside named volumes. All access
+// goes through helper containers that mount only the target volume, so paths
+// (including symlinks) can never resolve to the host filesystem.
+type VolumeFileService struct {
+	dockerService *DockerService
+}
+
+func NewVolumeFileService(dockerService *DockerService) *VolumeFileService {
+	return &VolumeFileService{dockerService: dockerService}
+}
+
+// CleanVolumePath normalizes a user-supplied path to a path relative to the
+// volume root ("" for the root itself). ".." can never climb above the root.
+func CleanVolumePath(p string) (string, error) {
+	if strings.ContainsRune(p, 0) {
+		return "", ErrVolumePathInvalid
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/"), nil
+}
+
+// listScript prints one record per directory entry:
+// type<TAB>size<TAB>mtime<TAB>mode<TAB>uid<TAB>gid<NL>name<NUL>. Names may
+// contain tabs and newlines, so only the NUL ends a record.
+const listScript = `[ -e "$1" ] || [ -L "$1" ] || exit 3
+[ -d "$1" ] && [ ! -L "$1" ] || exit 4
+cd "$1" && find . -mindepth 1 -maxdepth 1 -exec stat -c '%F	%s	%Y	%a	%u	%g' {} \; -print0`
+
+// List returns the entries of a directory, directories first
+func (s *VolumeFileService) List(ctx context.Context, volumeName, dir string) ([]VolumeFileEntry, error) {
+	rel, err := CleanVolumePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkVolume(ctx, volumeName); err != nil {
+		return nil, err
+	}
+
+	out, err := runVolumeHelper(ctx, s.dockerService, volumeName, true,
+		[]string{"sh", "-c", listScript, "sh", path.Join(volumeMountPath, rel)})
+	if err != nil {
+		return nil, helperPathError(err)
+	}
+
+	return parseFileList(out, rel), nil
+}
+
+// parseFileList parses listScript output for the directory rel
+func parseFileList(out []byte, rel string) []VolumeFileEntry {
+	entries := []VolumeFileEntry{}
+	for _, record := range strings.Split(string(out), "\x00") {
+		meta, name, found := strings.Cut(record, "\n")
+		fields := strings.Split(meta, "\t")
+		if !found || len(fields) != 6 {
+			continue
+		}
+		name = strings.TrimPrefix(name, "./")
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		mtime, _ := strconv.ParseInt(fields[2], 10, 64)
+		uid, _ := strconv.Atoi(fields[4])
+		gid, _ := strconv.Atoi(fields[5])
+		entries = append(entries, VolumeFileEntry{
+			Name:    name,
+			Path:    "/" + path.Join(rel, name),
+			Type:    statFileType(fields[0]),
+			Size:    size,
+			Mode:    fields[3],
+			UID:     uid,
+			GID:     gid,
+			ModTime: time.Unix(mtime, 0).UTC(),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if (entries[i].Type == "dir") != (entries[j].Type == "dir") {
+			return entries[i].Type == "dir"
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// Open returns the contents of a regular file. Closing the reader releases
+// the helper container.
+func (s *VolumeFileService) Open(ctx context.Context, volumeName, file string) (io.ReadCloser, *VolumeFileEntry, error) {
+	rel, err := CleanVolumePath(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rel == "" {
+		return nil, nil, ErrVolumePathIsDir
+	}
+	if err := s.checkVolume(ctx, volumeName); err != nil {
+		return nil, nil, err
+	}
+
+	helperID, err := createVolumeHelper(ctx, s.dockerService, volumeName, true, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := s.dockerService.CopyFromContainer(ctx, helperID, path.Join(volumeMountPath, rel))
+	if err != nil {
+		removeVolumeHelper(s.dockerService, helperID)
+		return nil, nil, copyPathError(err)
+	}
+
+	tr := tar.NewReader(reader)
+	hdr, err := tr.Next()
+	if err == nil && hdr.Typeflag != tar.TypeReg {
+		err = ErrVolumePathNotRegular
+		if hdr.Typeflag == tar.TypeDir {
+			err = ErrVolumePathIsDir
+		}
+	}
+	if err != nil {
+		reader.Close()
+		removeVolumeHelper(s.dockerService, helperID)
+		return nil, nil, err
+	}
+
+	entry := entryFromHeader(rel, hdr)
+	return &helperFileReader{Reader: tr, closer: reader, release: func() {
+		removeVolumeHelper(s.dockerService, helperID)
+	}}, &entry, nil
+}
+
+// WriteFile creates or replaces a file. A replaced file keeps its owner and
+// mode; a new file gets mode 0644 and the owner of its directory, which must
+// already exist. A negative size spools content to a temporary file first.
+func (s *VolumeFileService) WriteFile(ctx context.Context, volumeName, file string, content io.Reader, size int64) (*VolumeFileEntry, error) {
+	rel, err := CleanVolumePath(file)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "" {
+		return nil, ErrVolumePathIsDir
+	}
+	if err := s.checkVolume(ctx, volumeName); err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		tmp, err := os.CreateTemp("", "sunspear-upload-*")
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}()
+		if size, err = io.Copy(tmp, content); err != nil {
+			return nil, fmt.Errorf("failed to read upload: %w", err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		content = tmp
+	}
+
+	helperID, err := createVolumeHelper(ctx, s.dockerService, volumeName, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer removeVolumeHelper(s.dockerService, helperID)
+
+	hdr := &tar.Header{Name: rel, Typeflag: tar.TypeReg, Mode: 0644}
+	existing, err := s.stat(ctx, helperID, rel)
+	switch {
+	case err == nil && existing.Typeflag == tar.TypeDir:
+		return nil, ErrVolumePathIsDir
+	case err == nil:
+		hdr.Uid, hdr.Gid = existing.Uid, existing.Gid
+		if existing.Typeflag == tar.TypeReg {
+			hdr.Mode = existing.Mode
+		}
+	case errors.Is(err, ErrVolumePathNotFound):
+		parent, err := s.stat(ctx, helperID, path.Dir(rel))
+		if err != nil {
+			return nil, err
+		}
+		if parent.Typeflag != tar.TypeDir {
+			return nil, ErrVolumePathNotDir
+		}
+		hdr.Uid, hdr.Gid = parent.Uid, parent.Gid
+	default:
+		return nil, err
+	}
+	hdr.Size = size
+	hdr.ModTime = time.Now()
+
+	if err := s.copyIn(ctx, helperID, []*tar.Header{hdr}, content); err != nil {
+		return nil, err
+	}
+
+	entry := entryFromHeader(rel, hdr)
+	return &entry, nil
+}
+
+// Mkdir creates a directory and any missing parents, owned like the nearest
+// existing ancestor. Creating an existing directory is not an error.
+func (s *VolumeFileService) Mkdir(ctx context.Context, volumeName, dir string) (*VolumeFileEntry, error) {
+	rel, err := CleanVolumePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "" {
+		return nil, ErrVolumePathInvalid
+	}
+	if err := s.checkVolume(ctx, volumeName); err != nil {
+		return nil, err
+	}
+
+	helperID, err := createVolumeHelper(ctx, s.dockerService, volumeName, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer removeVolumeHelper(s.dockerService, helperID)
+
+	// Walk up to the nearest existing ancestor
+	var missing []string
+	current := rel
+	var ancestor *tar.Header
+	for {
+		hdr, err := s.stat(ctx, helperID, current)
+		if err == nil {
+			ancestor = hdr
+			break
+		}
+		if !errors.Is(err, ErrVolumePathNotFound) {
+			return nil, err
+		}
+		missing = append(missing, current)
+		current = path.Dir(current)
+	}
+	if ancestor.Typeflag != tar.TypeDir {
+		return nil, ErrVolumePathNotDir
+	}
+	if len(missing) == 0 {
+		entry := entryFromHeader(rel, ancestor)
+		return &entry, nil
+	}
+
+	now := time.Now()
+	headers := make([]*tar.Header, 0, len(missing))
+	for i := len(missing) - 1; i >= 0; i-- {
+		headers = append(headers, &tar.Header{
+			Name:     missing[i] + "/",
+			Typeflag: tar.TypeDir,
+			Mode:     0755,
+			Uid:      ancestor.Uid,
+			Gid:      ancestor.Gid,
+			ModTime:  now,
+		})
+	}
+	if err := s.copyIn(ctx, helperID, headers, nil); err != nil {
+		return nil, err
+	}
+
+	entry := entryFromHeader(rel, headers[len(headers)-1])
+	return &entry, nil
+}
+
+// deleteScript removes a file, symlink or directory. Non-empty directories
+// are only removed when the second argument is "1".
+const deleteScript = `[ -e "$1" ] || [ -L "$1" ] || exit 3
+if [ -d "$1" ] && [ ! -L "$1" ]; then
+	if [ "$2" = "1" ]; then rm -rf -- "$1"; else rmdir -- "$1" 2>/dev/null || exit 5; fi
+else
+	rm -f -- "$1"
+fi`
+
+// Delete removes a path. Directories must be empty unless recursive is set.
+// The volume root itself cannot be deleted.
+func (s *VolumeFileService) Delete(ctx context.Context, volumeName, p string, recursive bool) error {
+	rel, err := CleanVolumePath(p)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return ErrVolumePathInvalid
+	}
+	if err := s.checkVolume(ctx, volumeName); err != nil {
+		return err
+	}
+
+	flag := "0"
+	if recursive {
+		flag = "1"
+	}
+	_, err = runVolumeHelper(ctx, s.dockerService, volumeName, false,
+		[]string{"sh", "-c", deleteScript, "sh", path.Join(volumeMountPath, rel), flag})
+	return helperPathError(err)
+}
+
+func (s *VolumeFileService) checkVolume(ctx context.Context, volumeName string) error {
+	// Binding a missing volume would silently create it
+	if _, err := s.dockerService.InspectVolume(ctx, volumeName); err != nil {
+		if errdefs.IsNotFound(err) {
+			return ErrVolumeNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// stat returns the tar header of a path in the helper's volume without
+// reading its contents
+func (s *VolumeFileService) stat(ctx context.Context, helperID, rel string) (*tar.Header, error) {
+	reader, err := s.dockerService.CopyFromContainer(ctx, helperID, path.Join(volumeMountPath, rel))
+	if err != nil {
+		return nil, copyPathError(err)
+	}
+	defer reader.Close()
+
+	return tar.NewReader(reader).Next()
+}
+
+// copyIn extracts the given entries at the volume root. content supplies
+// the data of the regular-file entries, in order.
+func (s *VolumeFileService) copyIn(ctx context.Context, helperID string, headers []*tar.Header, content io.Reader) error {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		for _, hdr := range headers {
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				if _, err := io.CopyN(tw, content, hdr.Size); err != nil {
+					pw.CloseWithError(fmt.Errorf("failed to read upload: %w", err))
+					return
+				}
+			}
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+
+	err := s.dockerService.CopyToContainer(ctx, helperID, volumeMountPath, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// helperFileReader streams a file out of a helper container and removes the
+// helper when closed
+type helperFileReader struct {
+	io.Reader
+	closer  io.Closer
+	release func()
+}
+
+func (r *helperFileReader) Close() error {
+	err := r.closer.Close()
+	r.release()
+	return err
+}
+
+func entryFromHeader(rel string, hdr *tar.Header) VolumeFileEntry {
+	entryType := "other"
+	switch hdr.Typeflag {
+	case tar.TypeReg:
+		entryType = "file"
+	case tar.TypeDir:
+		entryType = "dir"
+	case tar.TypeSymlink:
+		entryType = "symlink"
+	}
+	return VolumeFileEntry{
+		Name:    path.Base("/" + rel),
+		Path:    "/" + rel,
+		Type:    entryType,
+		Size:    hdr.Size,
+		Mode:    strconv.FormatInt(hdr.Mode&0o7777, 8),
+		UID:     hdr.Uid,
+		GID:     hdr.Gid,
+		ModTime: hdr.ModTime.UTC(),
+	}
+}
+
+// statFileType maps busybox stat %F output to an entry type
+func statFileType(f string) string {
+	switch {
+	case strings.Contains(f, "directory"):
+		return "dir"
+	case strings.Contains(f, "symbolic link"):
+		return "symlink"
+	case strings.Contains(f, "regular"):
+		return "file"
+	}
+	return "other"
+}
+
+// helperPathError maps the exit codes used by the helper scripts
+func helperPathError(err error) error {
+	var exitErr *helperExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.Code {
+		case 3:
+			return ErrVolumePathNotFound
+		case 4:
+			return ErrVolumePathNotDir
+		case 5:
+			return ErrVolumeDirNotEmpty
+		}
+	}
+	return err
+}
+
+func copyPathError(err error) error {
+	if errdefs.IsNotFound(err) {
+		return ErrVolumePathNotFound
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package services
+
+import "testing"
+
+func TestParseFileList(t *testing.T) {
+	out := "directory\t4096\t1700000000\t755\t0\t0\n./sub dir\x00" +
+		"regular file\t1\t1700000000\t644\t1000\t1000\n./a\ttab\x00" +
+		"regular file\t2\t1700000000\t600\t0\t0\n./new\nline\x00"
+
+	entries := parseFileList([]byte(out), "data")
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(entries), entries)
+	}
+
+	want := []struct{ name, path, typ string }{
+		{"sub dir", "/data/sub dir", "dir"},
+		{"a\ttab", "/data/a\ttab", "file"},
+		{"new\nline", "/data/new\nline", "file"},
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Name != w.name || e.Path != w.path || e.Type != w.typ {
+			t.Errorf("entry %d = %q %q %q, want %q %q %q", i, e.Name, e.Path, e.Type, w.name, w.path, w.typ)
+		}
+	}
+	if entries[1].UID != 1000 || entries[1].Size != 1 || entries[1].Mode != "644" {
+		t.Errorf("entry 1 metadata = %+v", entries[1])
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// volumeHelperImage runs short-lived helper containers that mount volumes
+const volumeHelperImage = "alpine:3.20"
+
+// volumeMountPath is where helper containers mount the target volume
+const volumeMountPath = "/volume"
+
+// createVolumeHelper creates (but does not start) a helper container with the volume mounted
+func createVolumeHelper(ctx context.Context, dockerService *DockerService, volumeName string, readOnly bool, cmd []string) (string, error) {
+	bind := volumeName + ":" + volumeMountPath
+	if readOnly {
+		bind += ":ro"
+	}
+	return createHelperContainer(ctx, dockerService, []string{bind}, cmd)
+}
+
+func createHelperContainer(ctx context.Context, dockerService *DockerService, binds []string, cmd []string) (string, error) {
+	if err := dockerService.EnsureImage(ctx, volumeHelperImage); err != nil {
+		return "", fmt.Errorf("failed to pull helper image: %w", err)
+	}
+	if cmd == nil {
+		cmd = []string{"true"}
+	}
+
+	resp, err := dockerService.CreateContainer(ctx, &container.Config{
+		Image:  volumeHelperImage,
+		Cmd:    cmd,
+		Labels: map[string]string{"com.sunspear.helper": "volume"},
+	}, &container.HostConfig{
+		Binds:       binds,
+		NetworkMode: "none",
+		// Output is read back after exit, so use a driver that supports reading logs
+		LogConfig: container.LogConfig{Type: "json-file"},
+	}, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create helper container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// helperExitError reports a helper command that exited non-zero
+type helperExitError struct {
+	Code   int64
+	Stderr string
+}
+
+func (e *helperExitError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("helper exited with code %d: %s", e.Code, e.Stderr)
+	}
+	return fmt.Sprintf("helper exited with code %d", e.Code)
+}
+
+// runVolumeHelper runs a command in a helper container, waits for it to exit
+// and returns its stdout. A non-zero exit returns a *helperExitError.
+func runVolumeHelper(ctx context.Context, dockerService *DockerService, volumeName string, readOnly bool, cmd []string) ([]byte, error) {
+	helperID, err := createVolumeHelper(ctx, dockerService, volumeName, readOnly, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return runHelperContainer(ctx, dockerService, helperID)
+}
+
+// copyVolume copies the contents of one volume into another, preserving
+// ownership, permissions and timestamps
+func copyVolume(ctx context.Context, dockerService *DockerService, src, dst string) error {
+	helperID, err := createHelperContainer(ctx, dockerService,
+		[]string{src + ":/from:ro", dst + ":/to"},
+		[]string{"cp", "-a", "/from/.", "/to/"})
+	if err != nil {
+		return err
+	}
+	_, err = runHelperContainer(ctx, dockerService, helperID)
+	return err
+}
+
+// runHelperContainer starts a created helper, waits for it to exit and
+// removes it
+func runHelperContainer(ctx context.Context, dockerService *DockerService, helperID string) ([]byte, error) {
+	defer removeVolumeHelper(dockerService, helperID)
+
+	if err := dockerService.StartContainer(ctx, helperID); err != nil {
+		return nil, err
+	}
+	code, err := dockerService.WaitContainer(ctx, helperID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := dockerService.ReadContainerOutput(ctx, helperID, &stdout, &stderr); err != nil {
+		return nil, fmt.Errorf("failed to read helper output: %w", err)
+	}
+	if code != 0 {
+		return nil, &helperExitError{Code: code, Stderr: strings.TrimSpace(stderr.String())}
+	}
+	return stdout.Bytes(), nil
+}
+
+func removeVolumeHelper(dockerService *DockerService, id string) {
+	if err := dockerService.RemoveContainer(context.Background(), id, true); err != nil {
+		log.Printf("Failed to remove helper container %s: %v", id, err)
+	}
+}
@@ -5,19 +5,20 @@ import router from '@/router'
 const api = axios.create({
   baseURL: import.meta.env.VITE_API_URL || '/api',
   timeout: 600000,
+  withCredentials: true,
   headers: {
     'Content-Type': 'application/json'
   }
 })
 
-// Request interceptor - add auth token
+// Request interceptor - echo the CSRF token of the cookie session
 api.interceptors.request.use(
   (config) => {
     const authStore = useAuthStore()
-    const token = authStore.getToken()
+    const csrfToken = authStore.getCSRFToken()
 
-    if (token) {
-      config.headers.Authorization = `Bearer ${token}`
+    if (csrfToken) {
+      config.headers['X-CSRF-Token'] = csrfToken
     }
 
     return config
@@ -31,9 +32,14 @@ api.interceptors.request.use(
 api.interceptors.response.use(
   (response) => response,
   (error) => {
+    // Surface the API's error message instead of axios' generic one
+    if (error.response?.data?.message) {
+      error.message = error.response.data.message
+    }
+
     if (error.response?.status === 401) {
       const authStore = useAuthStore()
-      authStore.logout()
+      authStore.clearSession()
       router.push('/login')
     }
 
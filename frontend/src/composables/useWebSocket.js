@@ -13,11 +13,11 @@ export function useWebSocket(path) {
     let manualClose = false
 
     function connect() {
+        // The browser sends the session cookie with the upgrade request
         const authStore = useAuthStore()
-        const token = authStore.getToken()
 
-        if (!token) {
-            error.value = 'No authentication token available'
+        if (!authStore.isAuthenticated) {
+            error.value = 'Not logged in'
             return
         }
 
@@ -33,7 +33,7 @@ export function useWebSocket(path) {
             .replace(/\/$/, '')
 
         const normalizedPath = path.startsWith('/') ? path : `/${path}`
-        const fullUrl = `${wsBaseUrl}${normalizedPath}?token=${encodeURIComponent(token)}`
+        const fullUrl = `${wsBaseUrl}${normalizedPath}`
 
         try {
             manualClose = false
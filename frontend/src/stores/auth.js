@@ -2,15 +2,28 @@ import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
 import api from '@/composables/useDockerAPI'
 
+// The session JWT lives in an HttpOnly cookie the page cannot read. The CSRF
+// cookie beside it is readable and expires with the session, so its presence
+// tells whether we are logged in.
+const CSRF_COOKIE = 'sunspear_csrf'
+
+function readCSRFCookie() {
+  const prefix = `${CSRF_COOKIE}=`
+  const cookie = document.cookie.split('; ').find((c) => c.startsWith(prefix))
+  return cookie ? decodeURIComponent(cookie.slice(prefix.length)) : null
+}
+
 export const useAuthStore = defineStore('auth', () => {
-  const token = ref(localStorage.getItem('token') || null)
-  const isAuthenticated = computed(() => !!token.value)
+  // Older versions kept a bearer token in localStorage
+  localStorage.removeItem('token')
+
+  const csrfToken = ref(readCSRFCookie())
+  const isAuthenticated = computed(() => !!csrfToken.value)
 
   async function login(username, password) {
     try {
-      const response = await api.post('/auth/login', { username, password })
-      token.value = response.data.token
-      localStorage.setItem('token', token.value)
+      const response = await api.post('/auth/login', { username, password, mode: 'cookie' })
+      csrfToken.value = response.data.csrfToken
       return true
     } catch (error) {
       console.error('Login failed:', error)
@@ -38,22 +51,31 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
-  function logout() {
-    token.value = null
-    localStorage.removeItem('token')
+  async function logout() {
+    try {
+      await api.post('/auth/logout')
+    } catch (error) {
+      console.error('Logout failed:', error)
+    }
+    clearSession()
+  }
+
+  // clearSession forgets a session the server no longer accepts
+  function clearSession() {
+    csrfToken.value = null
   }
 
-  function getToken() {
-    return token.value
+  function getCSRFToken() {
+    return csrfToken.value
   }
 
   return {
-    token,
     isAuthenticated,
     login,
     setup,
     checkSetupRequired,
     logout,
-    getToken
+    clearSession,
+    getCSRFToken
   }
 })